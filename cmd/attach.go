@@ -0,0 +1,88 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/session"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func newAttachCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "attach [session]",
+		Short: "Attach to a running agent session",
+		Long: `Attach this terminal to the tmux session of a running agent container,
+chosen by session name, container name or container name prefix (see
+'exitbox ps'). Without an argument the only running container is used.
+
+Detach again with the tmux prefix followed by d (Ctrl-b d); the agent keeps
+running.`,
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			records, _ := session.ListRunning()
+			var out []string
+			for _, r := range records {
+				if r.Session != "" && strings.HasPrefix(r.Session, toComplete) {
+					out = append(out, r.Session)
+				}
+			}
+			return out, cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+				ui.Error("attach needs a terminal.")
+			}
+			rt := container.Detect()
+			if rt == nil {
+				ui.Error(container.NotFoundMessage())
+			}
+			selector := ""
+			if len(args) == 1 {
+				selector = args[0]
+			}
+			r, err := session.FindRunning(runningContainers(rt), selector)
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+			if r.Headless {
+				ui.Errorf("%s runs headless (exitbox exec); there is no session to attach to.", r.Container)
+			}
+
+			code, err := rt.ExecInteractive([]string{
+				"exec", "-it", "-e", "TERM=xterm-256color", r.Container,
+				"tmux", "attach-session", "-t", r.TmuxSession(),
+			})
+			if err != nil {
+				ui.Errorf("Failed to attach to %s: %v", r.Container, err)
+			}
+			os.Exit(code)
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newAttachCmd())
+}
@@ -0,0 +1,195 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newAuditCmd() *cobra.Command {
+	var since, workspace, reqType string
+	var verify bool
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show the IPC audit log",
+		Long: `Show the requests agents made over IPC, such as vault reads and domain
+requests, with how each was decided and by what. Each workspace has its
+own log of hash-chained entries; --verify checks that no entry has been
+edited or removed since it was written, including from the end of the
+log, which is checked against a record kept in the config directory.
+Requests refused for lacking the session token are logged as denied
+by "token".
+
+Examples:
+  exitbox audit                           All workspaces' requests
+  exitbox audit --since 24h               Requests of the last day
+  exitbox audit -w work --type vault_get  Vault reads in workspace "work"
+  exitbox audit --verify                  Check every log's chain`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var from time.Time
+			if since != "" {
+				var err error
+				if from, err = parseSince(since, time.Now()); err != nil {
+					ui.Errorf("Invalid --since: %v", err)
+				}
+			}
+
+			workspaces := []string{workspace}
+			if !cmd.Flags().Changed("workspace") {
+				var err error
+				if workspaces, err = audit.Workspaces(); err != nil {
+					ui.Errorf("Failed to read audit logs: %v", err)
+				}
+			}
+			if len(workspaces) == 0 {
+				fmt.Println("No audit log yet. IPC requests are logged once an agent runs with the firewall enabled.")
+				return
+			}
+
+			var entries []audit.Entry
+			broken := 0
+			for _, ws := range workspaces {
+				logEntries, err := audit.Read(ws)
+				if err != nil && !verify {
+					ui.Errorf("Failed to read audit log: %v", err)
+				}
+				if err != nil {
+					ui.Warnf("%s: %v", auditName(ws), err)
+					broken++
+					continue
+				}
+				if verify {
+					if i := audit.Verify(logEntries); i >= 0 {
+						ui.Warnf("%s: chain broken at entry %d (%s); entries from there on cannot be trusted.",
+							auditName(ws), i+1, logEntries[i].Time.Local().Format("2006-01-02 15:04:05"))
+						broken++
+					} else if err := audit.VerifyHead(ws, logEntries); err != nil {
+						ui.Warnf("%s: %v.", auditName(ws), err)
+						broken++
+					} else {
+						fmt.Printf("%s[OK]%s   %s: %d entries\n", ui.Green, ui.NC, auditName(ws), len(logEntries))
+					}
+					continue
+				}
+				for _, e := range logEntries {
+					if e.Time.Before(from) || reqType != "" && e.Type != reqType {
+						continue
+					}
+					entries = append(entries, e)
+				}
+			}
+			if verify {
+				if broken > 0 {
+					ui.Errorf("%d audit log(s) failed verification.", broken)
+				}
+				return
+			}
+
+			if len(entries) == 0 {
+				fmt.Println("No matching requests.")
+				return
+			}
+			sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+			for _, e := range entries {
+				fmt.Println(formatAuditEntry(e))
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only show requests after a time (2026-01-31, RFC 3339) or within a duration (90m, 24h, 7d)")
+	cmd.Flags().StringVarP(&workspace, "workspace", "w", "", "Only show this workspace's requests")
+	cmd.Flags().StringVar(&reqType, "type", "", "Only show requests of this type (allow_domain, vault_get, vault_list, git_push, open_pr)")
+	cmd.Flags().BoolVar(&verify, "verify", false, "Verify the hash chain of the audit logs")
+	return cmd
+}
+
+// parseSince parses --since as a duration before now, a date or an RFC
+// 3339 time.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a duration, date or RFC 3339 time", s)
+}
+
+// auditName names a workspace's audit log in messages.
+func auditName(workspace string) string {
+	if workspace == "" {
+		return "no workspace"
+	}
+	return "workspace " + workspace
+}
+
+// formatAuditEntry renders one audit log entry as a single line.
+func formatAuditEntry(e audit.Entry) string {
+	decision := fmt.Sprintf("%-8s", strings.ToUpper(e.Decision))
+	switch e.Decision {
+	case audit.Approved:
+		decision = ui.Green + decision + ui.NC
+	case audit.Denied:
+		decision = ui.Red + decision + ui.NC
+	default:
+		decision = ui.Yellow + decision + ui.NC
+	}
+	subject := e.Subject
+	if e.Remote != "" {
+		subject += " -> " + e.Remote
+	}
+	if e.Scope != "" {
+		subject += " (" + e.Scope + ")"
+	}
+	who := fmt.Sprintf("%s/%s (%s)", e.Agent, filepath.Base(e.Project), e.Container)
+	if e.Session != "" {
+		who += " session " + e.Session
+	}
+	source := e.Source
+	if e.Rule != "" {
+		source += " " + e.Rule
+	}
+	line := fmt.Sprintf("%s  %s  %-12s %-30s  %s  by %s",
+		e.Time.Local().Format("2006-01-02 15:04:05"), decision, e.Type, subject, who, source)
+	if e.Detail != "" {
+		line += ": " + e.Detail
+	}
+	return line
+}
+
+func init() {
+	rootCmd.AddCommand(newAuditCmd())
+}
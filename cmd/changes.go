@@ -0,0 +1,199 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/overlay"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newChangesCmd() *cobra.Command {
+	var diff, apply, discard bool
+
+	cmd := &cobra.Command{
+		Use:   "changes [session] [path...]",
+		Short: "Review and apply the changes of an --overlay session",
+		Long: `Review what an agent changed in a session run with --overlay, and apply
+it to the project. Until applied, the changes exist only in the overlay.
+
+Without a session, list this project's overlay sessions. With one, list
+its added, modified and deleted files. Paths narrow --diff and --apply to
+those files or directories.
+
+Examples:
+  exitbox changes                          List overlay sessions
+  exitbox changes task                     List the files session "task" changed
+  exitbox changes task --diff src          Show the changes under src/
+  exitbox changes task --apply             Apply every change
+  exitbox changes task --apply README.md   Apply one file
+  exitbox changes task --discard           Drop the overlay and its changes`,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveDefault
+			}
+			projectDir, _ := os.Getwd()
+			sessions, _ := overlay.List(projectDir)
+			var out []string
+			for _, s := range sessions {
+				if strings.HasPrefix(s.Name, toComplete) {
+					out = append(out, s.Name)
+				}
+			}
+			return out, cobra.ShellCompDirectiveNoFileComp
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			projectDir, _ := os.Getwd()
+			if len(args) == 0 {
+				listOverlays(projectDir)
+				return
+			}
+			if diff && (apply || discard) || apply && discard {
+				ui.Error("Use only one of --diff, --apply and --discard.")
+			}
+
+			s, err := overlay.Find(projectDir, args[0])
+			if err != nil {
+				ui.Errorf("%v", err)
+			}
+			if apply || discard {
+				ensureOverlayIdle(s)
+			}
+			if discard {
+				if err := s.Discard(); err != nil {
+					ui.Errorf("Failed to discard overlay: %v", err)
+				}
+				ui.Successf("Discarded overlay for session '%s'.", s.Name)
+				return
+			}
+
+			changes, err := s.Changes()
+			if err != nil {
+				ui.Errorf("Failed to read overlay: %v", err)
+			}
+			changes = overlay.Select(changes, args[1:])
+			if len(changes) == 0 {
+				fmt.Println("No changes.")
+				return
+			}
+
+			switch {
+			case diff:
+				for _, c := range changes {
+					if err := s.Diff(os.Stdout, c); err != nil {
+						ui.Warnf("%s: %v", c.Path, err)
+					}
+				}
+			case apply:
+				if err := s.Apply(changes); err != nil {
+					ui.Errorf("Failed to apply changes: %v", err)
+				}
+				ui.Successf("Applied %d change(s) to %s.", len(changes), projectDir)
+				if rest, _ := s.Changes(); len(rest) == 0 {
+					ui.Infof("Nothing left in the overlay; remove it with 'exitbox changes %q --discard'.", s.Name)
+				}
+			default:
+				for _, c := range changes {
+					fmt.Printf("  %-9s %s\n", c.Kind, c.Path)
+				}
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&diff, "diff", false, "Show the changes as a unified diff")
+	cmd.Flags().BoolVar(&apply, "apply", false, "Apply the changes to the project")
+	cmd.Flags().BoolVar(&discard, "discard", false, "Remove the overlay and everything in it")
+	return cmd
+}
+
+// listOverlays prints the project's overlay sessions.
+func listOverlays(projectDir string) {
+	sessions, err := overlay.List(projectDir)
+	if err != nil {
+		ui.Errorf("%v", err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No overlay sessions for this project. Start one with 'exitbox run <agent> --overlay'.")
+		return
+	}
+	fmt.Printf("%-30s %-8s %s\n", "SESSION", "CHANGES", "LAST USED")
+	for _, s := range sessions {
+		count := "?"
+		if changes, changesErr := s.Changes(); changesErr == nil {
+			count = fmt.Sprint(len(changes))
+		}
+		fmt.Printf("%-30s %-8s %s\n", s.Name, count, s.Updated.Format("2006-01-02 15:04"))
+	}
+}
+
+// openOverlay returns the session's --overlay, creating it on the first
+// run of the session.
+func openOverlay(projectDir string, flags parsedFlags) *overlay.Session {
+	switch {
+	case runtime.GOOS != "linux":
+		ui.Error("--overlay needs a Linux host; the runtime's VM cannot overlay host directories.")
+	case flags.ReadOnly:
+		ui.Error("--overlay cannot be combined with --read-only.")
+	case flags.Worktree:
+		ui.Error("Use either --overlay or --worktree, not both.")
+	case strings.TrimSpace(flags.SessionName) == "":
+		ui.Error("--overlay needs a session name; use --name or --resume SESSION.")
+	}
+	s, err := overlay.Open(projectDir, flags.SessionName)
+	if err != nil {
+		ui.Errorf("Failed to prepare overlay: %v", err)
+	}
+	return s
+}
+
+// overlaySummary tells the user what the session left in its overlay.
+func overlaySummary(s *overlay.Session) {
+	changes, err := s.Changes()
+	if err != nil {
+		ui.Warnf("Failed to read overlay: %v", err)
+		return
+	}
+	if len(changes) == 0 {
+		ui.Info("Overlay: no changes this session.")
+		return
+	}
+	ui.Infof("Overlay: %d file(s) changed. Review them with 'exitbox changes %q' and apply with --apply.", len(changes), s.Name)
+}
+
+// ensureOverlayIdle refuses to change an overlay a running container has
+// mounted.
+func ensureOverlayIdle(s *overlay.Session) {
+	rt := container.Detect()
+	if rt == nil {
+		return
+	}
+	for _, r := range runningContainers(rt) {
+		if r.Project == s.Project && r.Session == s.Name {
+			ui.Errorf("Session '%s' is still running (%s); stop the agent first.", s.Name, r.Container)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(newChangesCmd())
+}
@@ -0,0 +1,162 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/agent"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/image"
+	"github.com/cloud-exit/exitbox/internal/run"
+	"github.com/cloud-exit/exitbox/internal/session"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+// superviseEnv is set for the background exitbox process of a detached
+// run; its value is the log file the process writes to.
+const superviseEnv = "EXITBOX_SUPERVISE"
+
+// detachedStartTimeout bounds how long --detach waits for the container.
+const detachedStartTimeout = 2 * time.Minute
+
+// startDetached starts a background exitbox process that runs the agent
+// with its container detached, and returns once the container runs. The
+// background process keeps serving the firewall and IPC for the container
+// and cleans up after it exits. Anything that needs the terminal (image
+// builds, unlocking credentials) happens here first.
+func startDetached(ctx context.Context, rt container.Runtime, agentName, projectDir string, passthrough []string, flags parsedFlags) {
+	if flags.Learn {
+		ui.Warn("--learn is not available with --detach; review denials with 'exitbox network log --denied'.")
+	}
+	if err := image.BuildProject(ctx, rt, agentName, projectDir, flags.Workspace, false); err != nil {
+		ui.Errorf("Failed to build images: %v", err)
+	}
+	if !flags.NoFirewall {
+		if err := run.UnlockUpstreamProxy(); err != nil {
+			ui.Errorf("failed to unlock upstream proxy credentials: %v", err)
+		}
+	}
+
+	args := []string{"run", agentName}
+	if !flags.SessionNameSet && !flags.Resume {
+		// Pin the generated name so the session can be attached by it.
+		args = append(args, "--name", flags.SessionName, "--no-resume")
+	}
+	args = append(args, detachedArgs(passthrough)...)
+
+	logFile := filepath.Join(session.RunningDir(), fmt.Sprintf("%s-%s.log", agentName, time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(session.RunningDir(), 0755); err != nil {
+		ui.Errorf("Failed to create %s: %v", session.RunningDir(), err)
+	}
+	logOut, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		ui.Errorf("Failed to open log file: %v", err)
+	}
+	defer logOut.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		ui.Errorf("Failed to find the exitbox executable: %v", err)
+	}
+	c := exec.Command(exe, args...)
+	c.Dir = projectDir
+	c.Env = append(os.Environ(), superviseEnv+"="+logFile)
+	c.Stdout = logOut
+	c.Stderr = logOut
+	detachProcess(c)
+	if err := c.Start(); err != nil {
+		ui.Errorf("Failed to start background session: %v", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		_ = c.Wait()
+		close(exited)
+	}()
+
+	spin := ui.NewSpinner("Starting " + agent.DisplayName(agentName) + " in the background...")
+	spin.Start()
+	rec, ok := waitForRecord(c.Process.Pid, exited)
+	spin.Stop()
+	if !ok {
+		data, _ := os.ReadFile(logFile)
+		fmt.Fprintln(os.Stderr, lastLines(string(data), 20))
+		ui.Errorf("The background session did not start; see %s", logFile)
+	}
+
+	selector := rec.Session
+	if selector == "" {
+		selector = rec.Container
+	}
+	ui.Success(fmt.Sprintf("%s is running in the background (%s)", agent.DisplayName(agentName), rec.Container))
+	fmt.Printf("  Attach: exitbox attach %q\n", selector)
+	fmt.Printf("  Log:    %s\n", logFile)
+}
+
+// waitForRecord waits for the background process with pid to record its
+// running container. It gives up when the process exits or on timeout.
+func waitForRecord(pid int, exited <-chan struct{}) (session.Running, bool) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(detachedStartTimeout)
+	for {
+		select {
+		case <-exited:
+			return session.Running{}, false
+		case <-deadline:
+			return session.Running{}, false
+		case <-ticker.C:
+		}
+		running, _ := session.ListRunning()
+		for _, r := range running {
+			if r.PID == pid {
+				return r, true
+			}
+		}
+	}
+}
+
+// detachedArgs returns the run flags for the background process: the
+// user's own, without --detach and --learn, which need this terminal.
+func detachedArgs(passthrough []string) []string {
+	var out []string
+	for i, a := range passthrough {
+		if a == "--" {
+			return append(out, passthrough[i:]...)
+		}
+		if a != "--detach" && a != "--learn" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,284 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/agent"
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/image"
+	"github.com/cloud-exit/exitbox/internal/ipc"
+	"github.com/cloud-exit/exitbox/internal/profile"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"github.com/cloud-exit/exitbox/internal/run"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <agent> --prompt-file FILE [flags...] [-- agent args...]",
+	Short: "Run an agent headless on a single prompt",
+	Long: `Run an agent non-interactively on a single prompt, for CI pipelines and
+scripts. There is no terminal and no tmux: the agent's output goes to
+stdout, or to stderr with --output -, and exitbox exits with the agent's
+exit code.
+
+Requests the agent makes at runtime (allow_domain, vault_get, vault_list)
+cannot be approved in a popup. They are answered by the policy file given
+with --policy and denied without one. Approved domains last for the run.
+
+Flags (passed after the agent name):
+      --prompt-file FILE  Read the prompt from FILE ("-" for stdin)
+      --prompt TEXT       Use TEXT as the prompt
+      --output FILE       Write a JSON summary to FILE ("-" for stdout,
+                          moving all other output to stderr)
+      --policy FILE       Answer approval requests from a policy file
+
+All 'exitbox run' flags apply too, except --detach, --learn, --worktree and
+--overlay. Every exec starts a fresh agent session.
+Vaults are unlocked with the password in $EXITBOX_VAULT_PASSWORD.
+
+Policy file (YAML):
+  allow_domains:
+    - "*.npmjs.org"
+    - api.github.com
+  vault_keys:
+    - GITHUB_TOKEN
+  vault_list: false
+
+Summary (JSON): agent, exit_code, started, duration_seconds, changed_files
+(path and added/modified/deleted), denied_domains, approvals and, when
+the run failed, error.
+
+Examples:
+  exitbox exec claude --prompt-file task.md --output result.json
+  exitbox exec codex --prompt "fix the failing test" --policy ci-policy.yaml
+  cat task.md | exitbox exec claude --prompt-file - -w work`,
+}
+
+func newAgentExecCmd(agentName string) *cobra.Command {
+	display := agent.DisplayName(agentName)
+	return &cobra.Command{
+		Use:                agentName + " --prompt-file FILE [flags...]",
+		Short:              "Run " + display + " headless",
+		Long:               "Run " + display + " headless on a single prompt. See 'exitbox exec --help'.",
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, a := range args {
+				if a == "--" {
+					break
+				}
+				if a == "--help" || a == "-h" {
+					_ = execCmd.Help()
+					return
+				}
+			}
+			execAgent(agentName, args)
+		},
+	}
+}
+
+// execFlags are the flags exec adds to the run flags.
+type execFlags struct {
+	Prompt     string
+	PromptFile string
+	Output     string
+	Policy     string
+}
+
+// parseExecFlags takes exec's own flags out of args and returns the rest
+// for parseRunFlags.
+func parseExecFlags(args []string) (execFlags, []string) {
+	var f execFlags
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var target *string
+		switch arg {
+		case "--prompt":
+			target = &f.Prompt
+		case "--prompt-file":
+			target = &f.PromptFile
+		case "--output":
+			target = &f.Output
+		case "--policy":
+			target = &f.Policy
+		case "--":
+			return f, append(rest, args[i:]...)
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		if i+1 < len(args) {
+			i++
+			*target = args[i]
+		}
+	}
+	return f, rest
+}
+
+// readPrompt returns the prompt given by --prompt or --prompt-file.
+func readPrompt(f execFlags) (string, error) {
+	switch {
+	case f.Prompt != "" && f.PromptFile != "":
+		return "", fmt.Errorf("use either --prompt or --prompt-file, not both")
+	case f.Prompt != "":
+		return f.Prompt, nil
+	case f.PromptFile == "":
+		return "", fmt.Errorf("a prompt is required (--prompt-file FILE or --prompt TEXT)")
+	}
+	var data []byte
+	var err error
+	if f.PromptFile == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(f.PromptFile)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read prompt: %w", err)
+	}
+	prompt := strings.TrimSpace(string(data))
+	if prompt == "" {
+		return "", fmt.Errorf("prompt file %s is empty", f.PromptFile)
+	}
+	return prompt, nil
+}
+
+func execAgent(agentName string, args []string) {
+	cfg := config.LoadOrDefault()
+	if !cfg.IsAgentEnabled(agentName) {
+		ui.Errorf("Agent '%s' is not enabled. Run 'exitbox enable %s' first.", agentName, agentName)
+	}
+
+	ef, rest := parseExecFlags(args)
+	// With --output -, stdout carries only the summary: the agent's
+	// output and exitbox's messages go to stderr.
+	stdout := os.Stdout
+	if ef.Output == "-" {
+		os.Stdout = os.Stderr
+	}
+	prompt, err := readPrompt(ef)
+	if err != nil {
+		ui.Errorf("%v", err)
+	}
+	policy := &ipc.Policy{}
+	if ef.Policy != "" {
+		if policy, err = ipc.LoadPolicy(ef.Policy); err != nil {
+			ui.Errorf("Failed to load policy: %v", err)
+		}
+	}
+
+	flags := parseRunFlags(rest, cfg.Settings.DefaultFlags)
+	if flags.Detach || flags.Learn || flags.Worktree || flags.Overlay {
+		ui.Warn("--detach, --learn, --worktree and --overlay are ignored by exec.")
+	}
+	if flags.Verbose {
+		ui.Verbose = true
+	}
+	if flags.Workspace != "" && profile.FindWorkspace(cfg, flags.Workspace) == nil {
+		ui.Errorf("Unknown workspace '%s'. Available workspaces: %s", flags.Workspace, strings.Join(profile.WorkspaceNames(cfg), ", "))
+	}
+
+	rt := container.Detect()
+	if rt == nil {
+		ui.Error(container.NotFoundMessage())
+	}
+
+	projectDir, _ := os.Getwd()
+	if err := project.Init(projectDir); err != nil {
+		ui.Warnf("Failed to initialize project directory: %v", err)
+	}
+	applyProjectConfig(cfg, agentName, projectDir, &flags)
+
+	image.Version = Version
+	image.SessionTools = flags.Tools
+	image.ForceRebuild = flags.ForceUpdate
+	image.AutoUpdate = cfg.Settings.AutoUpdate || flags.ForceUpdate
+	if err := image.BuildProject(context.Background(), rt, agentName, projectDir, flags.Workspace, false); err != nil {
+		ui.Errorf("Failed to build images: %v", err)
+	}
+
+	summary, err := run.Headless(rt, run.Options{
+		Agent:             agentName,
+		ProjectDir:        projectDir,
+		WorkspaceHash:     image.WorkspaceHash(cfg, projectDir, flags.Workspace),
+		WorkspaceOverride: flags.Workspace,
+		NoFirewall:        flags.NoFirewall,
+		ReadOnly:          flags.ReadOnly,
+		NoEnv:             flags.NoEnv,
+		SessionName:       flags.SessionName,
+		EnvVars:           flags.EnvVars,
+		IncludeDirs:       flags.IncludeDirs,
+		AllowURLs:         flags.AllowURLs,
+		Passthrough:       append(agent.HeadlessArgs(agentName, prompt), flags.Remaining...),
+		Verbose:           flags.Verbose,
+		Version:           Version,
+		Ollama:            flags.Ollama,
+		Memory:            flags.Memory,
+		CPUs:              flags.CPUs,
+		Resources:         flags.Resources,
+		Hardened:          flags.Hardened,
+		Policy:            policy,
+	})
+
+	// The summary is written even when the run failed, so scripts can
+	// read why.
+	if ef.Output != "" {
+		if werr := writeSummary(ef.Output, stdout, summary); werr != nil {
+			ui.Warnf("Failed to write summary: %v", werr)
+			if err == nil {
+				os.Exit(1)
+			}
+		}
+	}
+	if err != nil {
+		ui.Errorf("%v", err)
+	}
+	if ef.Output == "" {
+		fmt.Fprintf(os.Stderr, "exit code %d, %d file(s) changed, %d domain(s) denied\n",
+			summary.ExitCode, len(summary.ChangedFiles), len(summary.DeniedDomains))
+	}
+	os.Exit(summary.ExitCode)
+}
+
+// writeSummary writes the run summary as JSON to file, or to stdout for "-".
+func writeSummary(file string, stdout io.Writer, summary run.Summary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if file == "-" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+func init() {
+	for _, name := range agent.AgentNames {
+		execCmd.AddCommand(newAgentExecCmd(name))
+	}
+	rootCmd.AddCommand(execCmd)
+}
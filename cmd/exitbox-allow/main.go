@@ -22,32 +22,23 @@
 package main
 
 import (
-	"bufio"
-	"crypto/rand"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net"
 	"os"
-)
+	"os/signal"
+	"syscall"
 
-type request struct {
-	Type    string      `json:"type"`
-	ID      string      `json:"id"`
-	Payload interface{} `json:"payload"`
-}
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
 
 type allowDomainPayload struct {
 	Domain string `json:"domain"`
 }
 
-type response struct {
-	Type    string          `json:"type"`
-	ID      string          `json:"id"`
-	Payload json.RawMessage `json:"payload"`
-}
-
 type allowDomainResponse struct {
 	Approved bool   `json:"approved"`
+	Scope    string `json:"scope,omitempty"`
 	Error    string `json:"error,omitempty"`
 }
 
@@ -57,21 +48,34 @@ func main() {
 		os.Exit(1)
 	}
 
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
+	// Interrupting cancels the pending request and closes its popup.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conn, err := client.Dial(client.SocketPath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: IPC socket not available. Domain allow requests require firewall mode")
+		os.Exit(1)
 	}
+	defer conn.Close()
 
 	hasFailure := false
 	for _, domain := range os.Args[1:] {
-		approved, err := requestAllow(socketPath, domain)
+		resp, err := requestAllow(ctx, conn, domain)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", domain, err)
 			hasFailure = true
+			if ctx.Err() != nil {
+				break
+			}
 			continue
 		}
-		if approved {
-			fmt.Printf("Approved: %s\n", domain)
+		if resp.Approved {
+			if resp.Scope != "" {
+				fmt.Printf("Approved (%s): %s\n", resp.Scope, domain)
+			} else {
+				fmt.Printf("Approved: %s\n", domain)
+			}
 		} else {
 			fmt.Printf("Denied: %s\n", domain)
 			hasFailure = true
@@ -83,59 +87,22 @@ func main() {
 	}
 }
 
-func requestAllow(socketPath, domain string) (bool, error) {
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
-		return false, fmt.Errorf("IPC socket not available. Domain allow requests require firewall mode")
-	}
-	defer conn.Close()
-
-	id := randomID()
-	req := request{
-		Type: "allow_domain",
-		ID:   id,
-		Payload: allowDomainPayload{
-			Domain: domain,
-		},
-	}
-
-	data, err := json.Marshal(req)
+func requestAllow(ctx context.Context, conn *client.Conn, domain string) (allowDomainResponse, error) {
+	raw, err := conn.Call(ctx, "allow_domain", allowDomainPayload{Domain: domain}, func(msg string) {
+		fmt.Fprintf(os.Stderr, "%s: %s...\n", domain, msg)
+	})
 	if err != nil {
-		return false, err
-	}
-	data = append(data, '\n')
-
-	if _, err := conn.Write(data); err != nil {
-		return false, err
-	}
-
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			return false, err
-		}
-		return false, fmt.Errorf("no response from host")
-	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
-		return false, err
+		return allowDomainResponse{}, err
 	}
 
 	var payload allowDomainResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
-		return false, err
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return allowDomainResponse{}, err
 	}
 
 	if payload.Error != "" {
-		return false, fmt.Errorf("%s", payload.Error)
+		return allowDomainResponse{}, fmt.Errorf("%s", payload.Error)
 	}
 
-	return payload.Approved, nil
-}
-
-func randomID() string {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return fmt.Sprintf("%x", b)
+	return payload, nil
 }
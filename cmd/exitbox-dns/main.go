@@ -0,0 +1,93 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-dns is the resolver that runs next to Squid in the ExitBox proxy
+// container. It serves DNS on 127.0.0.1:53 and reloads its configuration
+// when the host rewrites the file.
+//
+// Usage: exitbox-dns <config.json>
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/dns"
+)
+
+const listenAddr = "127.0.0.1:53"
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: exitbox-dns <config.json>")
+		os.Exit(1)
+	}
+	path := os.Args[1]
+
+	cfg, err := dns.LoadConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exitbox-dns: %v\n", err)
+		os.Exit(1)
+	}
+	r := dns.NewResolver(cfg)
+
+	udp, err := net.ListenPacket("udp", listenAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exitbox-dns: %v\n", err)
+		os.Exit(1)
+	}
+	tcp, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exitbox-dns: %v\n", err)
+		os.Exit(1)
+	}
+
+	go watchConfig(path, r)
+	go func() {
+		if err := r.ServeTCP(tcp); err != nil {
+			fmt.Fprintf(os.Stderr, "exitbox-dns: %v\n", err)
+			os.Exit(1)
+		}
+	}()
+	if err := r.ServeUDP(udp); err != nil {
+		fmt.Fprintf(os.Stderr, "exitbox-dns: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// watchConfig reloads the configuration when the file changes. An invalid
+// file keeps the previous configuration.
+func watchConfig(path string, r *dns.Resolver) {
+	var last time.Time
+	if fi, err := os.Stat(path); err == nil {
+		last = fi.ModTime()
+	}
+	for range time.Tick(2 * time.Second) {
+		fi, err := os.Stat(path)
+		if err != nil || fi.ModTime().Equal(last) {
+			continue
+		}
+		last = fi.ModTime()
+		cfg, err := dns.LoadConfig(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "exitbox-dns: keeping previous configuration: %v\n", err)
+			continue
+		}
+		r.SetConfig(cfg)
+	}
+}
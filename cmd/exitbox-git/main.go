@@ -0,0 +1,194 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// exitbox-git is a standalone binary for pushing branches and opening pull
+// requests from inside an ExitBox container. The host does the work with
+// the user's credentials, after they approve it; the credentials never
+// enter the container. It communicates with the host via a Unix domain
+// socket using JSON-lines protocol.
+//
+// Usage:
+//
+//	exitbox-git push [branch] [--remote NAME]
+//	exitbox-git pr [branch] --title TITLE [--body TEXT | --body-file FILE] [--base BRANCH] [--draft] [--remote NAME]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
+
+type gitPushPayload struct {
+	Branch string `json:"branch"`
+	Remote string `json:"remote,omitempty"`
+}
+
+type gitPushResponse struct {
+	Approved bool   `json:"approved"`
+	Range    string `json:"range,omitempty"`
+	Commits  int    `json:"commits,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type openPRPayload struct {
+	Branch string `json:"branch"`
+	Base   string `json:"base,omitempty"`
+	Title  string `json:"title"`
+	Body   string `json:"body,omitempty"`
+	Draft  bool   `json:"draft,omitempty"`
+	Remote string `json:"remote,omitempty"`
+}
+
+type openPRResponse struct {
+	Approved bool   `json:"approved"`
+	URL      string `json:"url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	// Interrupting cancels the pending request and closes its popup.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch os.Args[1] {
+	case "push":
+		cmdPush(ctx, os.Args[2:])
+	case "pr":
+		cmdPR(ctx, os.Args[2:])
+	default:
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  exitbox-git push [branch] [--remote NAME]")
+	fmt.Fprintln(os.Stderr, "  exitbox-git pr [branch] --title TITLE [--body TEXT | --body-file FILE] [--base BRANCH] [--draft] [--remote NAME]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "The branch defaults to the current one. Commit first; the host pushes")
+	fmt.Fprintln(os.Stderr, "the committed branch from /workspace after the user approves it.")
+}
+
+// parseArgs parses flags before and after an optional branch argument,
+// which defaults to the current branch.
+func parseArgs(fs *flag.FlagSet, args []string) string {
+	_ = fs.Parse(args)
+	branch := ""
+	if fs.NArg() > 0 {
+		branch = fs.Arg(0)
+		_ = fs.Parse(fs.Args()[1:])
+		if fs.NArg() > 0 {
+			printUsage()
+			os.Exit(1)
+		}
+	}
+	if branch == "" {
+		out, err := exec.Command("git", "-C", "/workspace", "symbolic-ref", "--short", "HEAD").Output()
+		if err != nil {
+			fail("no branch given and /workspace is not on a branch")
+		}
+		branch = strings.TrimSpace(string(out))
+	}
+	return branch
+}
+
+func cmdPush(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	remote := fs.String("remote", "", "Remote to push to (default origin)")
+	branch := parseArgs(fs, args)
+
+	var resp gitPushResponse
+	call(ctx, "git_push", gitPushPayload{Branch: branch, Remote: *remote}, &resp)
+	if resp.Error != "" {
+		fail("Error: " + resp.Error)
+	}
+	if !resp.Approved {
+		fail("Denied: host user rejected the push")
+	}
+	fmt.Printf("Pushed %s: %d commit(s), %s\n", branch, resp.Commits, resp.Range)
+}
+
+func cmdPR(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("pr", flag.ExitOnError)
+	title := fs.String("title", "", "Pull request title (required)")
+	body := fs.String("body", "", "Pull request description")
+	bodyFile := fs.String("body-file", "", "Read the description from a file")
+	base := fs.String("base", "", "Branch to merge into (default: the repository's default branch)")
+	draft := fs.Bool("draft", false, "Open the pull request as a draft")
+	remote := fs.String("remote", "", "Remote whose repository gets the pull request (default origin)")
+	branch := parseArgs(fs, args)
+
+	if *title == "" {
+		fail("Error: --title is required")
+	}
+	if *bodyFile != "" {
+		data, err := os.ReadFile(*bodyFile)
+		if err != nil {
+			fail("Error: " + err.Error())
+		}
+		*body = string(data)
+	}
+
+	var resp openPRResponse
+	call(ctx, "open_pr", openPRPayload{Branch: branch, Base: *base, Title: *title, Body: *body, Draft: *draft, Remote: *remote}, &resp)
+	if resp.Error != "" {
+		fail("Error: " + resp.Error)
+	}
+	if !resp.Approved {
+		fail("Denied: host user rejected the pull request")
+	}
+	fmt.Println(resp.URL)
+}
+
+// call sends a request to the host and decodes its response into out.
+// It exits on failure.
+func call(ctx context.Context, msgType string, payload, out interface{}) {
+	conn, err := client.Dial(client.SocketPath())
+	if err != nil {
+		fail("Error: IPC socket not available. exitbox-git requires firewall mode")
+	}
+	defer conn.Close()
+
+	raw, err := conn.Call(ctx, msgType, payload, func(msg string) {
+		fmt.Fprintf(os.Stderr, "exitbox-git: %s...\n", msg)
+	})
+	if err != nil {
+		fail("Error: " + err.Error())
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		fail("Error: " + err.Error())
+	}
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
@@ -26,25 +26,15 @@
 package main
 
 import (
-	"bufio"
-	"crypto/rand"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net"
 	"os"
-)
-
-type request struct {
-	Type    string      `json:"type"`
-	ID      string      `json:"id"`
-	Payload interface{} `json:"payload"`
-}
+	"os/signal"
+	"syscall"
 
-type response struct {
-	Type    string          `json:"type"`
-	ID      string          `json:"id"`
-	Payload json.RawMessage `json:"payload"`
-}
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
+)
 
 type vaultGetPayload struct {
 	Key string `json:"key"`
@@ -62,12 +52,28 @@ type vaultListResponse struct {
 	Error    string   `json:"error,omitempty"`
 }
 
+// conn is the connection to the host, shared by every request.
+var conn *client.Conn
+
+// ctx is cancelled on interrupt, which cancels the pending request.
+var ctx context.Context
+
 func main() {
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
 	}
 
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var err error
+	if conn, err = client.Dial(client.SocketPath()); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: IPC socket not available. Vault requires the IPC server to be running")
+		os.Exit(1)
+	}
+	defer conn.Close()
+
 	switch os.Args[1] {
 	case "get":
 		if len(os.Args) < 3 {
@@ -170,109 +176,28 @@ func cmdEnv() {
 }
 
 func sendVaultGet(key string) (*vaultGetResponse, error) {
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
-	}
-
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
-		return nil, fmt.Errorf("IPC socket not available. Vault requires the IPC server to be running")
-	}
-	defer conn.Close()
-
-	id := randomID()
-	req := request{
-		Type: "vault_get",
-		ID:   id,
-		Payload: vaultGetPayload{
-			Key: key,
-		},
-	}
-
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
-	data = append(data, '\n')
-
-	if _, err := conn.Write(data); err != nil {
-		return nil, err
-	}
-
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if scanErr := scanner.Err(); scanErr != nil {
-			return nil, scanErr
-		}
-		return nil, fmt.Errorf("no response from host")
-	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
-		return nil, err
-	}
-
 	var payload vaultGetResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := call("vault_get", vaultGetPayload{Key: key}, &payload); err != nil {
 		return nil, err
 	}
-
 	return &payload, nil
 }
 
 func sendVaultList() (*vaultListResponse, error) {
-	socketPath := os.Getenv("EXITBOX_IPC_SOCKET")
-	if socketPath == "" {
-		socketPath = "/run/exitbox/host.sock"
-	}
-
-	conn, err := net.Dial("unix", socketPath)
-	if err != nil {
-		return nil, fmt.Errorf("IPC socket not available. Vault requires the IPC server to be running")
-	}
-	defer conn.Close()
-
-	id := randomID()
-	req := request{
-		Type:    "vault_list",
-		ID:      id,
-		Payload: struct{}{},
-	}
-
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
-	data = append(data, '\n')
-
-	if _, err := conn.Write(data); err != nil {
-		return nil, err
-	}
-
-	scanner := bufio.NewScanner(conn)
-	if !scanner.Scan() {
-		if scanErr := scanner.Err(); scanErr != nil {
-			return nil, scanErr
-		}
-		return nil, fmt.Errorf("no response from host")
-	}
-
-	var resp response
-	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
-		return nil, err
-	}
-
 	var payload vaultListResponse
-	if err := json.Unmarshal(resp.Payload, &payload); err != nil {
+	if err := call("vault_list", struct{}{}, &payload); err != nil {
 		return nil, err
 	}
-
 	return &payload, nil
 }
 
-func randomID() string {
-	b := make([]byte, 8)
-	_, _ = rand.Read(b)
-	return fmt.Sprintf("%x", b)
+// call sends a request to the host and decodes its response into out.
+func call(msgType string, payload, out interface{}) error {
+	raw, err := conn.Call(ctx, msgType, payload, func(msg string) {
+		fmt.Fprintf(os.Stderr, "exitbox-vault: %s...\n", msg)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/platform"
+	"github.com/cloud-exit/exitbox/internal/run"
 	"github.com/cloud-exit/exitbox/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -49,14 +50,48 @@ var infoCmd = &cobra.Command{
 
 		if rt != nil {
 			fmt.Printf("  %-20s %s\n", "Runtime:", rt.Name())
+			if name, source := container.Preferred(); name != "" {
+				fmt.Printf("  %-20s %s\n", "Chosen by:", source)
+			}
+			if socket := container.APISocket(rt); socket != "" {
+				_, source := container.APIEnabled()
+				fmt.Printf("  %-20s API socket %s (%s)\n", "Driven through:", socket, source)
+			} else if on, _ := container.APIEnabled(); on {
+				fmt.Printf("  %-20s CLI (API socket not reachable)\n", "Driven through:")
+			} else {
+				fmt.Printf("  %-20s CLI\n", "Driven through:")
+			}
 			if container.IsAvailable(rt) {
 				fmt.Printf("  %-20s %srunning%s\n", "Status:", ui.Green, ui.NC)
+				caps := rt.Capabilities()
+				fmt.Printf("  %-20s %s\n", "Rootless:", yesNo(caps.Rootless))
+				fmt.Printf("  %-20s %s\n", "User namespaces:", yesNo(caps.UserNS))
+				fmt.Printf("  %-20s %s\n", "Internal networks:", yesNo(caps.InternalNetworks))
+				fmt.Printf("  %-20s %s\n", "BuildKit:", yesNo(caps.BuildKit))
 			} else {
 				fmt.Printf("  %-20s %snot running%s\n", "Status:", ui.Red, ui.NC)
 			}
 		} else {
 			fmt.Printf("  %-20s %snot found%s\n", "Runtime:", ui.Red, ui.NC)
-			fmt.Println("  Install Podman (recommended) or Docker to use exitbox.")
+			fmt.Println("  Install Podman (recommended), Docker or nerdctl to use exitbox.")
+		}
+
+		fmt.Println()
+		ui.Cecho("Container Protections", ui.Cyan)
+		fmt.Println()
+
+		hardening := config.LoadOrDefault().Settings.Hardening
+		if hardening.Enabled {
+			fmt.Printf("  %-20s %son%s (settings.hardening.enabled)\n", "Hardened mode:", ui.Green, ui.NC)
+		} else {
+			fmt.Printf("  %-20s off (enable with --hardened or settings.hardening.enabled)\n", "Hardened mode:")
+		}
+		if rt != nil && container.IsAvailable(rt) {
+			for _, p := range run.Protections(hardening, hardening.Enabled, rt.Capabilities()) {
+				fmt.Printf("  %-20s %s (%s)\n", p.Name+":", yesNo(p.Active), p.Detail)
+			}
+		} else {
+			fmt.Println("  Start the container runtime to see which protections it supports.")
 		}
 
 		fmt.Println()
@@ -80,3 +115,10 @@ var infoCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(infoCmd)
 }
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,203 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+func newNetworkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "Inspect the network firewall",
+		Long:  "Inspect the Squid firewall that mediates agent egress.",
+	}
+	cmd.AddCommand(newNetworkLogCmd())
+	cmd.AddCommand(newNetworkDoctorCmd())
+	return cmd
+}
+
+func newNetworkDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check that the firewall works",
+		Long: "Check the firewall networks and proxy, resolve a name from the proxy,\n" +
+			"and send an allowlisted and an unlisted domain through it as an agent\n" +
+			"would. Failed checks come with a suggested fix.",
+		Run: func(cmd *cobra.Command, args []string) {
+			rt := container.Detect()
+			if rt == nil {
+				ui.Error("No container runtime found.")
+			}
+
+			failed := 0
+			for _, c := range network.Doctor(rt) {
+				if c.OK {
+					fmt.Printf("%s[OK]%s   %s\n", ui.Green, ui.NC, c.Name)
+					continue
+				}
+				failed++
+				fmt.Printf("%s[FAIL]%s %s: %s\n", ui.Red, ui.NC, c.Name, c.Detail)
+				fmt.Printf("       Fix: %s\n", c.Fix)
+			}
+			if failed > 0 {
+				fmt.Println()
+				ui.Errorf("%d check(s) failed.", failed)
+			}
+			fmt.Println()
+			ui.Success("The firewall is working.")
+		},
+	}
+}
+
+func newNetworkLogCmd() *cobra.Command {
+	var containerFilter string
+	var deniedOnly bool
+	var follow bool
+	var usage bool
+
+	cmd := &cobra.Command{
+		Use:   "log",
+		Short: "Show requests seen by the firewall",
+		Long: "Show every request agents sent through the firewall, attributed to\n" +
+			"the container, agent and project that made it.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if usage {
+				printSessionUsage(containerFilter)
+				return
+			}
+
+			stop := make(chan struct{})
+			if follow {
+				sig := make(chan os.Signal, 1)
+				signal.Notify(sig, os.Interrupt)
+				go func() {
+					<-sig
+					close(stop)
+				}()
+			}
+
+			found := false
+			err := network.TailAccessLog(follow, stop, func(e network.AccessEntry) {
+				if deniedOnly && !e.Denied() {
+					return
+				}
+				if containerFilter != "" && !strings.Contains(e.Container, containerFilter) {
+					return
+				}
+				found = true
+				fmt.Println(formatAccessEntry(e))
+			})
+			if err != nil {
+				if os.IsNotExist(err) {
+					fmt.Println("No network log yet. Requests are logged once an agent runs with the firewall enabled.")
+					return
+				}
+				ui.Errorf("failed to read network log: %v", err)
+			}
+			if !found && !follow {
+				fmt.Println("No matching requests.")
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&containerFilter, "container", "", "Only show requests from containers whose name contains this value")
+	cmd.Flags().BoolVar(&deniedOnly, "denied", false, "Only show requests the firewall refused")
+	cmd.Flags().BoolVar(&follow, "follow", false, "Keep printing new requests as they arrive")
+	cmd.Flags().BoolVar(&usage, "usage", false, "Show running sessions' usage of their egress limits")
+	return cmd
+}
+
+// printSessionUsage prints the egress usage of running sessions with limits.
+func printSessionUsage(containerFilter string) {
+	found := false
+	for _, u := range network.SessionUsages() {
+		if containerFilter != "" && !strings.Contains(u.Container, containerFilter) {
+			continue
+		}
+		found = true
+		fmt.Println(u.Container)
+		fmt.Printf("  Transferred:  %s", formatBytes(u.Bytes))
+		if u.Limits.SessionBytes != "" {
+			fmt.Printf(" of %s", u.Limits.SessionBytes)
+		}
+		if u.OverQuota {
+			fmt.Print(ui.Red + "  (quota used up)" + ui.NC)
+		}
+		fmt.Println()
+		fmt.Printf("  Requests:     %d", u.Requests)
+		if u.Limits.RequestsPerMinute > 0 {
+			fmt.Printf(" (limit %d per minute per domain)", u.Limits.RequestsPerMinute)
+		}
+		fmt.Println()
+		if len(u.Throttled) > 0 {
+			fmt.Printf("  Rate limited: %s\n", strings.Join(u.Throttled, ", "))
+		}
+		if u.Limits.Bandwidth != "" {
+			fmt.Printf("  Bandwidth:    %s/s\n", strings.TrimSuffix(u.Limits.Bandwidth, "/s"))
+		}
+		if u.Limits.MaxConnections > 0 {
+			fmt.Printf("  Connections:  at most %d\n", u.Limits.MaxConnections)
+		}
+	}
+	if !found {
+		fmt.Println("No running sessions with egress limits.")
+	}
+}
+
+// formatBytes renders a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAccessEntry renders one access log entry as a single line.
+func formatAccessEntry(e network.AccessEntry) string {
+	status := fmt.Sprintf("%03d", e.HTTPStatus)
+	if e.Limited() {
+		status = ui.Yellow + "LIMIT " + ui.NC
+	} else if e.Denied() {
+		status = ui.Red + "DENIED" + ui.NC
+	}
+	who := "unattributed " + e.ClientIP
+	if e.Container != "" {
+		who = fmt.Sprintf("%s/%s (%s)", e.Agent, filepath.Base(e.Project), e.Container)
+	}
+	return fmt.Sprintf("%s  %-6s  %-7s %-40s  %s",
+		e.Time.Format("2006-01-02 15:04:05"), status, e.Method, e.Domain, who)
+}
+
+func init() {
+	rootCmd.AddCommand(newNetworkCmd())
+}
@@ -0,0 +1,77 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"slices"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/profile"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"github.com/cloud-exit/exitbox/internal/run"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+// projectReviewed is set once the project's config has been reviewed, so
+// a declined config is not asked about twice in one command.
+var projectReviewed bool
+
+// reviewProjectConfig asks the user to approve the project's config when
+// it is new or has changed. Nothing in it but its masks is used until
+// they do.
+func reviewProjectConfig(projectDir string) {
+	if projectReviewed {
+		return
+	}
+	projectReviewed = true
+	if err := run.ReviewProjectConfig(projectDir); err != nil {
+		ui.Errorf("%v", err)
+	}
+}
+
+// applyProjectConfig folds the project's .exitbox/config.yaml into the
+// parsed flags: its workspace unless -w was given, and its arguments for
+// the agent ahead of the command line's, so the command line's win. The
+// rest of the file is read where it applies: image packages in the
+// build, domains, mounts and resources in the container. Like those,
+// both are used only once the user has approved the file.
+func applyProjectConfig(cfg *config.Config, agentName, projectDir string, flags *parsedFlags) {
+	pc, _, err := project.LoadConfig(projectDir)
+	if err != nil {
+		ui.Errorf("%v", err)
+	}
+	if flags.Workspace == "" && pc.Workspace != "" {
+		if profile.FindWorkspace(cfg, pc.Workspace) == nil {
+			ui.Warnf("Workspace '%s' from %s is not configured; using the default.", pc.Workspace, config.ProjectConfigFile(projectDir))
+		} else {
+			flags.Workspace = pc.Workspace
+		}
+	}
+	if args := pc.AgentArgs[agentName]; len(args) > 0 {
+		flags.Remaining = append(slices.Clone(args), flags.Remaining...)
+	}
+}
+
+// projectAgent returns the agent the project's approved config names as
+// its default, or "" when there is none.
+func projectAgent(projectDir string) string {
+	pc, _, err := project.LoadConfig(projectDir)
+	if err != nil {
+		ui.Errorf("%v", err)
+	}
+	return pc.Agent
+}
@@ -0,0 +1,121 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/session"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// staleRecordAge is how long a record may exist without its container
+// running before it is considered left over from a crashed exitbox.
+const staleRecordAge = time.Minute
+
+func newPsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ps",
+		Short: "List running agent containers",
+		Long: `List the agent containers that are running, detached or in a terminal,
+with their project, workspace, session and uptime. Attach to one with
+'exitbox attach <session>'.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			rt := container.Detect()
+			if rt == nil {
+				ui.Error(container.NotFoundMessage())
+			}
+			running := runningContainers(rt)
+			if len(running) == 0 {
+				fmt.Println("No agent containers are running.")
+				return
+			}
+
+			now := time.Now()
+			fmt.Printf("%-22s %-9s %-20s %-12s %-8s %-9s %s\n", "SESSION", "AGENT", "PROJECT", "WORKSPACE", "UPTIME", "MODE", "CONTAINER")
+			for _, r := range running {
+				mode := "terminal"
+				if r.Headless {
+					mode = "headless"
+				} else if r.Detached {
+					mode = "detached"
+				}
+				fmt.Printf("%-22s %-9s %-20s %-12s %-8s %-9s %s\n",
+					orDash(r.Session), r.Agent, filepath.Base(r.Project), orDash(r.Workspace),
+					formatUptime(now.Sub(r.Started)), mode, r.Container)
+			}
+		},
+	}
+}
+
+// runningContainers returns the recorded agent containers the runtime
+// reports as running. Records left behind by an exitbox process that died
+// are removed.
+func runningContainers(rt container.Runtime) []session.Running {
+	records, err := session.ListRunning()
+	if err != nil {
+		ui.Errorf("%v", err)
+	}
+	names, err := rt.PS("name=exitbox-", "{{.Names}}")
+	if err != nil {
+		ui.Errorf("Failed to list containers: %v", err)
+	}
+	live := make(map[string]bool, len(names))
+	for _, n := range names {
+		live[n] = true
+	}
+
+	var out []session.Running
+	for _, r := range records {
+		if live[r.Container] {
+			out = append(out, r)
+		} else if time.Since(r.Started) > staleRecordAge {
+			session.RemoveRunning(r.Container)
+		}
+	}
+	return out
+}
+
+// formatUptime formats a duration in its two largest units ("3h 4m").
+func formatUptime(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(newPsCmd())
+}
@@ -39,7 +39,7 @@ var rebuildCmd = &cobra.Command{
 
 		rt := container.Detect()
 		if rt == nil {
-			ui.Error("No container runtime found. Install Podman or Docker.")
+			ui.Error(container.NotFoundMessage())
 		}
 
 		image.Version = Version
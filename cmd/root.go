@@ -54,6 +54,9 @@ var rootCmd = &cobra.Command{
 			}
 			fmt.Println()
 		}
+		if !skipWizardCommands[cmd.Name()] {
+			reviewSettings()
+		}
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -28,6 +28,7 @@ import (
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/image"
+	"github.com/cloud-exit/exitbox/internal/overlay"
 	"github.com/cloud-exit/exitbox/internal/profile"
 	"github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/run"
@@ -37,7 +38,7 @@ import (
 )
 
 var runCmd = &cobra.Command{
-	Use:   "run <agent> [args...]",
+	Use:   "run [agent] [args...]",
 	Short: "Run an agent in a container",
 	Long: `Run an AI coding assistant in an isolated container.
 
@@ -46,6 +47,9 @@ Available agents:
   codex       OpenAI Codex CLI
   opencode    OpenCode (open-source)
 
+  Without an agent, run the one named by 'agent' in the project's
+  .exitbox/config.yaml.
+
 Workspaces:
   Workspaces are named contexts (e.g. personal/work) with development stacks
   and separate agent config storage.
@@ -71,7 +75,13 @@ Flags (passed after the agent name):
   -t, --tools PKG         Add Alpine packages to the image
   -i, --include-dir DIR   Mount host dir inside /workspace
   -a, --allow-urls DOM    Allow extra domains for this session
+      --learn             Review denied domains at exit and add them to the allowlist
+      --detach            Run in the background; reattach with 'exitbox attach'
+      --worktree          Work in a private git checkout; merge or discard it at exit
+      --overlay           Keep the agent's writes in an overlay until 'exitbox changes' applies them
       --ollama            Use host Ollama for local models
+      --hardened          Read-only root filesystem and a stricter seccomp profile
+      --resources PRESET  Use a resource preset (small, large or one in default_flags.presets)
       --memory SIZE       Container memory limit (default: 8g)
       --cpus COUNT        Container CPU limit (default: 4)
 
@@ -83,7 +93,31 @@ Examples:
   exitbox run claude --resume "feature-x"   Resume session "feature-x" by name
   exitbox run claude -f -e GITHUB_TOKEN=$GITHUB_TOKEN
   exitbox run claude --workspace work
+  exitbox run claude --learn                Review blocked domains when the session ends
+  exitbox run claude --detach --name task   Run in the background as session "task"
+  exitbox run claude --worktree --name fix  Work on a separate checkout for session "fix"
+  exitbox run claude --overlay --name try   Review the changes with 'exitbox changes try'
+  exitbox run claude --hardened             Run with the hardened container profile
+  exitbox run claude --resources small      Tighter memory, CPU, process and file limits
   exitbox run opencode --ollama --memory 16g --cpus 8`,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		projectDir, _ := os.Getwd()
+		if wantsHelp(args) {
+			_ = cmd.Help()
+			return
+		}
+		reviewProjectConfig(projectDir)
+		name := projectAgent(projectDir)
+		if name == "" {
+			_ = cmd.Help()
+			return
+		}
+		if !agent.IsValidAgent(name) {
+			ui.Errorf("Unknown agent '%s' in %s. Available agents: %s", name, config.ProjectConfigFile(projectDir), strings.Join(agent.AgentNames, ", "))
+		}
+		runAgent(name, args)
+	},
 }
 
 func newAgentRunCmd(agentName string) *cobra.Command {
@@ -97,24 +131,32 @@ func newAgentRunCmd(agentName string) *cobra.Command {
 			return completeAgentRunArgs(agentName, args, toComplete)
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			// DisableFlagParsing swallows --help; handle it manually
-			for _, a := range args {
-				if a == "--" {
-					break
-				}
-				if a == "--help" || a == "-h" {
-					_ = cmd.Help()
-					return
-				}
-				if !strings.HasPrefix(a, "-") {
-					break
-				}
+			if wantsHelp(args) {
+				_ = cmd.Help()
+				return
 			}
 			runAgent(agentName, args)
 		},
 	}
 }
 
+// wantsHelp reports whether args ask for help. DisableFlagParsing
+// swallows --help, so it is handled manually.
+func wantsHelp(args []string) bool {
+	for _, a := range args {
+		if a == "--" {
+			break
+		}
+		if a == "--help" || a == "-h" {
+			return true
+		}
+		if !strings.HasPrefix(a, "-") {
+			break
+		}
+	}
+	return false
+}
+
 func runAgent(agentName string, passthrough []string) {
 	cfg := config.LoadOrDefault()
 	if !cfg.IsAgentEnabled(agentName) {
@@ -123,7 +165,7 @@ func runAgent(agentName string, passthrough []string) {
 
 	rt := container.Detect()
 	if rt == nil {
-		ui.Error("No container runtime found. Install Podman or Docker.")
+		ui.Error(container.NotFoundMessage())
 	}
 
 	projectDir, _ := os.Getwd()
@@ -138,10 +180,15 @@ func runAgent(agentName string, passthrough []string) {
 
 	flags := parseRunFlags(passthrough, cfg.Settings.DefaultFlags)
 	flags = applySessionResumeDefaults(flags)
+	reviewProjectConfig(projectDir)
+	applyProjectConfig(cfg, agentName, projectDir, &flags)
 
 	if flags.Verbose {
 		ui.Verbose = true
 	}
+	if flags.Learn && flags.NoFirewall {
+		ui.Warn("--learn has no effect with the firewall disabled.")
+	}
 
 	image.SessionTools = flags.Tools
 	image.ForceRebuild = flags.ForceUpdate
@@ -197,6 +244,25 @@ func runAgent(agentName string, passthrough []string) {
 		flags.SessionName = defaultSessionName()
 	}
 
+	// --worktree mounts a per-session clone instead of the project, and
+	// --overlay mounts the project copy-on-write. Both are opened before
+	// detaching so errors reach the terminal.
+	var worktree *project.Worktree
+	var ov *overlay.Session
+	if flags.Overlay {
+		ov = openOverlay(projectDir, flags)
+	} else if flags.Worktree {
+		worktree = openWorktree(projectDir, flags)
+	}
+
+	// A detached run hands the session to a background exitbox process,
+	// which runs the rest of this function with the container detached.
+	supervisorLog := os.Getenv(superviseEnv)
+	if flags.Detach && supervisorLog == "" {
+		startDetached(ctx, rt, agentName, projectDir, passthrough, flags)
+		return
+	}
+
 	switchFile := filepath.Join(projectDir, ".exitbox", "workspace-switch")
 	actionFile := filepath.Join(projectDir, ".exitbox", "session-action")
 
@@ -225,6 +291,7 @@ func runAgent(agentName string, passthrough []string) {
 			EnvVars:           flags.EnvVars,
 			IncludeDirs:       flags.IncludeDirs,
 			AllowURLs:         flags.AllowURLs,
+			Learn:             flags.Learn,
 			Passthrough:       flags.Remaining,
 			Verbose:           flags.Verbose,
 			StatusBar:         cfg.Settings.StatusBar,
@@ -232,8 +299,16 @@ func runAgent(agentName string, passthrough []string) {
 			Ollama:            flags.Ollama,
 			Memory:            flags.Memory,
 			CPUs:              flags.CPUs,
+			Resources:         flags.Resources,
+			Hardened:          flags.Hardened,
 			Keybindings:       cfg.Settings.Keybindings.EnvValue(),
+			Detach:            supervisorLog != "",
+			LogFile:           supervisorLog,
 		}
+		if worktree != nil {
+			opts.WorkspaceDir = worktree.MountDir()
+		}
+		opts.Overlay = ov
 
 		exitCode, err := run.AgentContainer(rt, opts)
 		if err != nil {
@@ -291,6 +366,12 @@ func runAgent(agentName string, passthrough []string) {
 			}
 		}
 
+		if worktree != nil {
+			finishWorktree(worktree, agentName)
+		}
+		if ov != nil {
+			overlaySummary(ov)
+		}
 		os.Exit(exitCode)
 	}
 }
@@ -310,9 +391,15 @@ type parsedFlags struct {
 	Ollama      bool
 	Memory      string
 	CPUs        string
+	Resources   string
+	Hardened    bool
 	EnvVars     []string
 	IncludeDirs []string
 	AllowURLs   []string
+	Learn       bool
+	Detach      bool
+	Worktree    bool
+	Overlay     bool
 	Tools       []string
 	Remaining   []string
 }
@@ -323,8 +410,6 @@ func parseRunFlags(passthrough []string, defaults config.DefaultFlags) parsedFla
 		ReadOnly:   defaults.ReadOnly,
 		NoEnv:      defaults.NoEnv,
 		Resume:     defaults.AutoResume,
-		Memory:     defaults.Memory,
-		CPUs:       defaults.CPUs,
 	}
 
 	for i := 0; i < len(passthrough); i++ {
@@ -381,6 +466,14 @@ func parseRunFlags(passthrough []string, defaults config.DefaultFlags) parsedFla
 				i++
 				f.AllowURLs = append(f.AllowURLs, passthrough[i])
 			}
+		case "--learn":
+			f.Learn = true
+		case "--detach":
+			f.Detach = true
+		case "--worktree":
+			f.Worktree = true
+		case "--overlay":
+			f.Overlay = true
 		case "--ollama":
 			f.Ollama = true
 		case "--memory":
@@ -393,6 +486,13 @@ func parseRunFlags(passthrough []string, defaults config.DefaultFlags) parsedFla
 				i++
 				f.CPUs = passthrough[i]
 			}
+		case "--hardened":
+			f.Hardened = true
+		case "--resources":
+			if i+1 < len(passthrough) {
+				i++
+				f.Resources = passthrough[i]
+			}
 		case "--":
 			f.Remaining = append(f.Remaining, passthrough[i+1:]...)
 			i = len(passthrough)
@@ -9,9 +9,17 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"github.com/cloud-exit/exitbox/internal/run"
 )
 
 func TestParseRunFlags_BooleanFlags(t *testing.T) {
@@ -30,6 +38,10 @@ func TestParseRunFlags_BooleanFlags(t *testing.T) {
 		{"long verbose", []string{"--verbose"}, func(f parsedFlags) bool { return f.Verbose }},
 		{"short update", []string{"-u"}, func(f parsedFlags) bool { return f.ForceUpdate }},
 		{"long update", []string{"--update"}, func(f parsedFlags) bool { return f.ForceUpdate }},
+		{"learn", []string{"--learn"}, func(f parsedFlags) bool { return f.Learn }},
+		{"detach", []string{"--detach"}, func(f parsedFlags) bool { return f.Detach }},
+		{"worktree", []string{"--worktree"}, func(f parsedFlags) bool { return f.Worktree }},
+		{"overlay", []string{"--overlay"}, func(f parsedFlags) bool { return f.Overlay }},
 	}
 
 	for _, tc := range tests {
@@ -201,3 +213,123 @@ func TestApplySessionResumeDefaults_NoResumeWins(t *testing.T) {
 		t.Fatal("--no-resume should override --name's implied resume")
 	}
 }
+
+func TestDetachedArgs(t *testing.T) {
+	got := detachedArgs([]string{"--detach", "-f", "--learn", "--", "--detach"})
+	want := []string{"-f", "--", "--detach"}
+	if len(got) != len(want) {
+		t.Fatalf("detachedArgs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("detachedArgs = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	tests := map[time.Duration]string{
+		42 * time.Second:               "42s",
+		5*time.Minute + 10*time.Second: "5m",
+		3*time.Hour + 4*time.Minute:    "3h 4m",
+		50*time.Hour + 30*time.Minute:  "2d 2h",
+	}
+	for d, want := range tests {
+		if got := formatUptime(d); got != want {
+			t.Errorf("formatUptime(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestParseExecFlags(t *testing.T) {
+	ef, rest := parseExecFlags([]string{"--prompt-file", "task.md", "-f", "--output", "out.json", "--policy", "p.yaml", "--", "--prompt", "x"})
+	if ef.PromptFile != "task.md" || ef.Output != "out.json" || ef.Policy != "p.yaml" || ef.Prompt != "" {
+		t.Errorf("execFlags = %+v", ef)
+	}
+	flags := parseRunFlags(rest, config.DefaultFlags{})
+	if !flags.NoFirewall || len(flags.Remaining) != 2 || flags.Remaining[0] != "--prompt" {
+		t.Errorf("run flags = %+v", flags)
+	}
+
+	if _, err := readPrompt(execFlags{}); err == nil {
+		t.Error("a missing prompt should be an error")
+	}
+	if _, err := readPrompt(execFlags{Prompt: "a", PromptFile: "b"}); err == nil {
+		t.Error("--prompt with --prompt-file should be an error")
+	}
+	if p, err := readPrompt(execFlags{Prompt: "fix it"}); err != nil || p != "fix it" {
+		t.Errorf("readPrompt = %q, %v", p, err)
+	}
+}
+
+func TestParseRunFlags_Resources(t *testing.T) {
+	f := parseRunFlags([]string{"--resources", "small", "--memory", "2g"}, config.DefaultFlags{Memory: "16g"})
+	if f.Resources != "small" || f.Memory != "2g" || f.CPUs != "" {
+		t.Errorf("flags = %+v", f)
+	}
+}
+
+func TestParseRunFlags_Hardened(t *testing.T) {
+	f := parseRunFlags([]string{"--hardened", "-r"}, config.DefaultFlags{})
+	if !f.Hardened || !f.ReadOnly || len(f.Remaining) != 0 {
+		t.Errorf("flags = %+v", f)
+	}
+}
+
+func TestApplyProjectConfig(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	projectDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectDir, ".exitbox"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := "workspace: work\nagent_args:\n  claude: [--model, opus]\n"
+	if err := os.WriteFile(config.ProjectConfigFile(projectDir), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Workspaces.Items = []config.Workspace{{Name: "personal"}, {Name: "work"}}
+
+	// Nothing is used before the user approves the file.
+	flags := parseRunFlags([]string{"--verbose", "fix the tests"}, config.DefaultFlags{})
+	applyProjectConfig(cfg, "claude", projectDir, &flags)
+	if flags.Workspace != "" || len(flags.Remaining) != 1 {
+		t.Errorf("unapproved config applied: %+v", flags)
+	}
+	pc, err := config.LoadProjectConfig(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Trust(projectDir, pc); err != nil {
+		t.Fatal(err)
+	}
+
+	flags = parseRunFlags([]string{"--verbose", "fix the tests"}, config.DefaultFlags{})
+	applyProjectConfig(cfg, "claude", projectDir, &flags)
+	if flags.Workspace != "work" {
+		t.Errorf("Workspace = %q, want work", flags.Workspace)
+	}
+	if want := []string{"--model", "opus", "fix the tests"}; !slices.Equal(flags.Remaining, want) {
+		t.Errorf("Remaining = %v, want %v", flags.Remaining, want)
+	}
+
+	// -w and other agents are left alone.
+	flags = parseRunFlags([]string{"-w", "personal"}, config.DefaultFlags{})
+	applyProjectConfig(cfg, "codex", projectDir, &flags)
+	if flags.Workspace != "personal" || len(flags.Remaining) != 0 {
+		t.Errorf("flags = %+v", flags)
+	}
+}
+
+func TestWriteSummaryToStdout(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := writeSummary("-", &stdout, run.Summary{Agent: "claude", ExitCode: 3}); err != nil {
+		t.Fatal(err)
+	}
+	var got run.Summary
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil || got.ExitCode != 3 {
+		t.Errorf("summary on stdout = %q, %v", stdout.String(), err)
+	}
+}
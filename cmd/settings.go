@@ -0,0 +1,84 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"golang.org/x/term"
+)
+
+// reviewSettings asks the user to approve the pinned settings that were
+// changed in config.yaml outside ExitBox. Containers can write the file,
+// so until the user does, the approved settings are used; declining
+// restores them in config.yaml. Without a terminal it only warns.
+func reviewSettings() {
+	cfg, changes, err := config.PendingSettings()
+	if err != nil {
+		ui.Warnf("Failed to check %s: %v", config.ConfigFile(), err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	keys := make([]string, len(changes))
+	for i, c := range changes {
+		keys[i] = c.Key
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		ui.Warnf("%s changes %s outside ExitBox; the approved settings are used until you approve the change by running exitbox in a terminal.",
+			config.ConfigFile(), strings.Join(keys, ", "))
+		return
+	}
+
+	fmt.Println()
+	ui.Cecho("These settings in config.yaml changed outside ExitBox:", ui.Cyan)
+	fmt.Println()
+	for _, c := range changes {
+		if c.Value == "" {
+			fmt.Printf("  %s: (removed)\n", c.Key)
+			continue
+		}
+		fmt.Printf("  %s:\n", c.Key)
+		for _, line := range strings.Split(c.Value, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	fmt.Println()
+	fmt.Println("Agent containers can write config.yaml, so only approve changes you made.")
+	fmt.Print("Use these settings? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a == "y" || a == "yes" {
+		if err := config.ApproveSettings(cfg); err != nil {
+			ui.Errorf("Failed to approve the settings: %v", err)
+		}
+		return
+	}
+	approved, err := config.LoadConfig()
+	if err == nil {
+		err = config.SaveConfig(approved)
+	}
+	if err != nil {
+		ui.Errorf("Failed to restore the approved settings: %v", err)
+	}
+	ui.Info("Restored the approved settings in config.yaml.")
+}
@@ -63,6 +63,15 @@ func (r *testRuntime) NetworkExists(_ string) bool            { return false }
 func (r *testRuntime) NetworkConnect(_, _ string) error       { return nil }
 func (r *testRuntime) NetworkInspect(_, _ string) (string, error) { return "", nil }
 func (r *testRuntime) IsRootless() bool                       { return false }
+func (r *testRuntime) BuildQuiet(_ []string) (string, error)   { return "", nil }
+func (r *testRuntime) BuildInteractive(_ []string) error       { return nil }
+func (r *testRuntime) PullQuiet(_ string) (string, error)      { return "", nil }
+func (r *testRuntime) PullInteractive(_ string) error          { return nil }
+func (r *testRuntime) TagImage(_, _ string) error              { return nil }
+func (r *testRuntime) ExecInteractive(_ []string) (int, error) { return 0, nil }
+func (r *testRuntime) Capabilities() container.Capabilities {
+	return container.Capabilities{}
+}
 
 // Verify testRuntime implements container.Runtime.
 var _ container.Runtime = (*testRuntime)(nil)
@@ -0,0 +1,117 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/project"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"golang.org/x/term"
+)
+
+// openWorktree returns the session's --worktree clone, creating it on the
+// first run of the session.
+func openWorktree(projectDir string, flags parsedFlags) *project.Worktree {
+	if flags.ReadOnly {
+		ui.Error("--worktree cannot be combined with --read-only.")
+	}
+	if strings.TrimSpace(flags.SessionName) == "" {
+		ui.Error("--worktree needs a session name; use --name or --resume SESSION.")
+	}
+	w, created, err := project.OpenWorktree(projectDir, flags.SessionName)
+	if err != nil {
+		ui.Errorf("Failed to prepare worktree: %v", err)
+	}
+	if created {
+		if project.Dirty(projectDir) {
+			ui.Warn("The project has uncommitted changes; the worktree starts from the last commit.")
+		}
+		ui.Infof("Worktree: %s (branch %s)", w.Dir, w.Branch)
+	} else {
+		ui.Infof("Reusing worktree: %s", w.Dir)
+	}
+	return w
+}
+
+// finishWorktree summarizes the session's changes and asks whether to
+// merge, cherry-pick or discard them. Without a terminal the worktree is
+// kept for a later run.
+func finishWorktree(w *project.Worktree, agentName string) {
+	if err := w.Collect(); err != nil {
+		ui.Warnf("Failed to collect worktree changes: %v", err)
+		return
+	}
+	commits, err := w.Commits()
+	if err != nil {
+		ui.Warnf("Failed to list worktree commits: %v", err)
+		return
+	}
+	if len(commits) == 0 {
+		ui.Info("Worktree: no changes this session.")
+		if err := w.Remove(); err != nil {
+			ui.Warnf("Failed to remove worktree: %v", err)
+		}
+		return
+	}
+
+	fmt.Println()
+	ui.Cecho("Worktree changes from session "+w.Session, ui.Cyan)
+	fmt.Println()
+	for _, c := range commits {
+		fmt.Printf("  %s\n", c)
+	}
+	if stat, statErr := w.DiffStat(); statErr == nil && stat != "" {
+		fmt.Println()
+		fmt.Println(stat)
+	}
+	fmt.Println()
+
+	keep := fmt.Sprintf("Kept %s; resume with 'exitbox run %s --worktree --name %q'.", w.Dir, agentName, w.Session)
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		ui.Info(keep)
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("[m]erge, [c]herry-pick, [d]iscard, or Enter to keep: ")
+	answer, _ := reader.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "m", "merge":
+		if err := w.Merge(); err != nil {
+			ui.Warnf("%v; the worktree is kept.", err)
+			return
+		}
+	case "c", "cherry-pick":
+		if err := w.CherryPick(); err != nil {
+			ui.Warnf("%v; resolve it with 'git cherry-pick --continue'. The worktree is kept.", err)
+			return
+		}
+	case "d", "discard":
+	default:
+		ui.Info(keep)
+		return
+	}
+	if err := w.Remove(); err != nil {
+		ui.Warnf("Failed to remove worktree: %v", err)
+		return
+	}
+	ui.Success("Worktree removed.")
+}
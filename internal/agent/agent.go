@@ -84,3 +84,17 @@ func init() {
 	Register(&Codex{})
 	Register(&OpenCode{})
 }
+
+// HeadlessArgs returns the agent arguments that run a single prompt
+// non-interactively and exit.
+func HeadlessArgs(name, prompt string) []string {
+	switch name {
+	case "claude":
+		return []string{"--print", prompt}
+	case "codex":
+		return []string{"exec", prompt}
+	case "opencode":
+		return []string{"run", prompt}
+	}
+	return nil
+}
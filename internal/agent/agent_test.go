@@ -399,3 +399,15 @@ func TestImportConfig_OpenCodeConfigDir(t *testing.T) {
 		t.Errorf("expected .config/opencode/settings.json to exist: %v", err)
 	}
 }
+
+func TestHeadlessArgs(t *testing.T) {
+	for _, name := range AgentNames {
+		args := HeadlessArgs(name, "do it")
+		if len(args) != 2 || args[1] != "do it" {
+			t.Errorf("HeadlessArgs(%q) = %v", name, args)
+		}
+	}
+	if HeadlessArgs("unknown", "x") != nil {
+		t.Error("unknown agents have no headless args")
+	}
+}
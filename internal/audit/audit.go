@@ -0,0 +1,323 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package audit keeps a tamper-evident record of IPC requests: one log of
+// hash-chained JSON lines per workspace. Each entry carries the hash of
+// the one before it, so editing or removing an entry breaks the chain.
+// The count and hash of the last entry are also kept in ExitBox's config
+// directory, apart from the log, so entries cut from the end show too.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+// Decisions.
+const (
+	Approved = "approved"
+	Denied   = "denied"
+	Failed   = "failed"
+)
+
+// noWorkspace names the log of sessions without a workspace.
+const noWorkspace = "_none"
+
+// Entry is one audited IPC request.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container,omitempty"`
+	Agent     string    `json:"agent,omitempty"`
+	Project   string    `json:"project,omitempty"`
+	Session   string    `json:"session,omitempty"`
+	Workspace string    `json:"workspace,omitempty"`
+	// Type is the request type, e.g. vault_get.
+	Type string `json:"type"`
+	// Subject is the vault key, domain or git branch asked for.
+	Subject string `json:"subject,omitempty"`
+	// Remote is the git remote of a git request, as host/path.
+	Remote string `json:"remote,omitempty"`
+	// Decision is approved, denied or failed.
+	Decision string `json:"decision"`
+	// Scope is how long an approved domain is allowed.
+	Scope string `json:"scope,omitempty"`
+	// Source is what decided: "rule", "prompt" or "policy", or "token"
+	// for a request refused for lacking the session token.
+	Source string `json:"source"`
+	// Rule is the approval rule that matched, if any.
+	Rule string `json:"rule,omitempty"`
+	// Detail explains a failed request.
+	Detail string `json:"detail,omitempty"`
+
+	// Prev is the hash of the previous entry, empty for the first.
+	Prev string `json:"prev"`
+	// Hash covers the entry, including Prev, with Hash left empty.
+	Hash string `json:"hash"`
+}
+
+// Dir returns the directory holding the audit logs.
+func Dir() string {
+	return filepath.Join(config.Data, "audit")
+}
+
+// File returns the audit log of a workspace.
+func File(workspace string) string {
+	if workspace == "" {
+		workspace = noWorkspace
+	}
+	return filepath.Join(Dir(), workspace+".jsonl")
+}
+
+// HeadFile returns where the last entry of a workspace's log is
+// recorded. It is under config.Home, which containers never see.
+func HeadFile(workspace string) string {
+	if workspace == "" {
+		workspace = noWorkspace
+	}
+	return filepath.Join(config.Home, "audit", workspace+".head")
+}
+
+// Head is the last entry Append wrote to a log.
+type Head struct {
+	Count int    `json:"count"`
+	Hash  string `json:"hash"`
+}
+
+// Workspaces returns the workspaces that have an audit log, or had one:
+// a log deleted whole is still listed by its head.
+func Workspaces() ([]string, error) {
+	seen := map[string]bool{}
+	for dir, suffix := range map[string]string{Dir(): ".jsonl", filepath.Dir(HeadFile("")): ".head"} {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			if name, ok := strings.CutSuffix(e.Name(), suffix); ok && !e.IsDir() {
+				if name == noWorkspace {
+					name = ""
+				}
+				seen[name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// hash returns the hash of an entry.
+func hash(e Entry) string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Append adds an entry to its workspace's log, chaining it to the last
+// entry. Sessions of one workspace may append at the same time; the log
+// is locked while the last hash is read and the entry written.
+func Append(e Entry) error {
+	file := File(e.Workspace)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	prev, err := lastHash(f)
+	if err != nil {
+		return err
+	}
+	head, err := readHead(e.Workspace, f, prev)
+	if err != nil {
+		return err
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.Prev = prev
+	e.Hash = hash(e)
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return writeHead(e.Workspace, Head{Count: head.Count + 1, Hash: e.Hash})
+}
+
+// readHead returns the recorded head of a log whose last entry hashes to
+// last, and refuses to extend a log that no longer ends there. A log
+// written before heads were kept is counted instead.
+func readHead(workspace string, f *os.File, last string) (Head, error) {
+	head, err := ReadHead(workspace)
+	if err == nil {
+		if head.Hash != last {
+			return head, fmt.Errorf("%s: log does not end where it was last written; run 'exitbox audit --verify'", f.Name())
+		}
+		return head, nil
+	}
+	if !os.IsNotExist(err) {
+		return head, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return head, err
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		head.Count++
+	}
+	head.Hash = last
+	return head, scanner.Err()
+}
+
+// ReadHead returns the recorded head of a workspace's log.
+func ReadHead(workspace string) (Head, error) {
+	var head Head
+	data, err := os.ReadFile(HeadFile(workspace))
+	if err != nil {
+		return head, err
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return head, fmt.Errorf("%s: %w", HeadFile(workspace), err)
+	}
+	return head, nil
+}
+
+// writeHead records the head of a workspace's log, replacing the file so
+// a crash never leaves half of it.
+func writeHead(workspace string, head Head) error {
+	file := HeadFile(workspace)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(head)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// lastHash returns the hash of the last entry in f.
+func lastHash(f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return "", err
+	}
+	// Entries are small; the last one is within the final few KiB.
+	size := min(info.Size(), 64<<10)
+	buf := make([]byte, size)
+	if _, err := f.ReadAt(buf, info.Size()-size); err != nil && err != io.EOF {
+		return "", err
+	}
+	lines := bytes.Split(bytes.TrimRight(buf, "\n"), []byte("\n"))
+	var last Entry
+	if err := json.Unmarshal(lines[len(lines)-1], &last); err != nil || last.Hash == "" {
+		return "", fmt.Errorf("%s: last entry is corrupt; run 'exitbox audit --verify'", f.Name())
+	}
+	return last.Hash, nil
+}
+
+// Read returns the entries of a workspace's log, oldest first. A missing
+// log has none.
+func Read(workspace string) ([]Entry, error) {
+	f, err := os.Open(File(workspace))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return entries, fmt.Errorf("%s:%d: %w", f.Name(), n, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Verify checks the chain of a log's entries. It returns the index of
+// the first entry that does not fit, or -1 when the chain is intact. See
+// VerifyHead for entries missing from the end.
+func Verify(entries []Entry) int {
+	prev := ""
+	for i, e := range entries {
+		if e.Prev != prev || e.Hash != hash(e) {
+			return i
+		}
+		prev = e.Hash
+	}
+	return -1
+}
+
+// VerifyHead checks that a workspace's log, with an intact chain, still
+// ends with the last entry Append wrote.
+func VerifyHead(workspace string, entries []Entry) error {
+	head, err := ReadHead(workspace)
+	if os.IsNotExist(err) {
+		if len(entries) == 0 {
+			return nil
+		}
+		return fmt.Errorf("no record of the log's last entry; it is written with the next entry")
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(entries) < head.Count:
+		return fmt.Errorf("%d entries missing from the end", head.Count-len(entries))
+	case len(entries) > head.Count || entries[len(entries)-1].Hash != head.Hash:
+		return fmt.Errorf("log does not end with the last entry written")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package audit
+
+import (
+	"bytes"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func useTempData(t *testing.T) {
+	t.Helper()
+	oldData, oldHome := config.Data, config.Home
+	config.Data, config.Home = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { config.Data, config.Home = oldData, oldHome })
+}
+
+func TestAppendChains(t *testing.T) {
+	useTempData(t)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Append(Entry{Workspace: "work", Type: "vault_get", Subject: "NPM_TOKEN", Decision: Approved, Source: "prompt"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := Append(Entry{Type: "allow_domain", Subject: "example.com", Decision: Denied, Source: "rule"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 20 {
+		t.Fatalf("read %d entries, want 20", len(entries))
+	}
+	if i := Verify(entries); i != -1 {
+		t.Errorf("chain broken at entry %d", i)
+	}
+	if entries[0].Prev != "" || entries[1].Prev != entries[0].Hash {
+		t.Error("entries are not chained")
+	}
+
+	names, err := Workspaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"", "work"}; !slices.Equal(names, want) {
+		t.Errorf("Workspaces = %q, want %q", names, want)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	useTempData(t)
+	for _, subject := range []string{"A", "B", "C"} {
+		if err := Append(Entry{Workspace: "work", Type: "vault_get", Subject: subject, Decision: Denied, Source: "prompt"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := Read("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := slices.Clone(entries)
+	edited[1].Decision = Approved
+	if i := Verify(edited); i != 1 {
+		t.Errorf("edited entry: Verify = %d, want 1", i)
+	}
+	removed := slices.Delete(slices.Clone(entries), 1, 2)
+	if i := Verify(removed); i != 1 {
+		t.Errorf("removed entry: Verify = %d, want 1", i)
+	}
+
+	// A corrupt last line stops further appends rather than starting a
+	// new chain.
+	data, _ := os.ReadFile(File("work"))
+	data = append(bytes.TrimRight(data, "\n"), []byte("garbage\n")...)
+	if err := os.WriteFile(File("work"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(Entry{Workspace: "work", Type: "vault_list", Decision: Denied}); err == nil {
+		t.Error("appended after a corrupt entry")
+	}
+}
+
+func TestVerifyHeadDetectsTruncation(t *testing.T) {
+	useTempData(t)
+	for _, subject := range []string{"A", "B", "C"} {
+		if err := Append(Entry{Workspace: "work", Type: "vault_get", Subject: subject, Decision: Denied, Source: "prompt"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := Read("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyHead("work", entries); err != nil {
+		t.Fatal(err)
+	}
+	if head, err := ReadHead("work"); err != nil || head.Count != 3 || head.Hash != entries[2].Hash {
+		t.Errorf("head = %+v, %v", head, err)
+	}
+
+	// Cutting entries from the end leaves an intact chain, but not the
+	// recorded head.
+	if i := Verify(entries[:2]); i != -1 {
+		t.Fatalf("Verify = %d", i)
+	}
+	if err := VerifyHead("work", entries[:2]); err == nil {
+		t.Error("truncated log verified")
+	}
+	if err := VerifyHead("work", nil); err == nil {
+		t.Error("deleted log verified")
+	}
+
+	// Nor does the log take new entries once cut.
+	var data []byte
+	for _, line := range bytes.SplitAfter(mustRead(t, File("work")), []byte("\n"))[:2] {
+		data = append(data, line...)
+	}
+	if err := os.WriteFile(File("work"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Append(Entry{Workspace: "work", Type: "vault_list", Decision: Denied}); err == nil {
+		t.Error("appended to a truncated log")
+	}
+	if names, _ := Workspaces(); !slices.Equal(names, []string{"work"}) {
+		t.Errorf("Workspaces = %q", names)
+	}
+	if err := os.Remove(File("work")); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := Workspaces(); !slices.Equal(names, []string{"work"}) {
+		t.Errorf("a deleted log should still be listed, got %q", names)
+	}
+}
+
+func mustRead(t *testing.T, file string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -92,6 +92,17 @@ func AllowlistFile() string {
 	return filepath.Join(Home, "allowlist.yaml")
 }
 
+// WorkspaceAllowlistsDir returns the host-only directory holding the
+// workspaces' allowlists.
+func WorkspaceAllowlistsDir() string {
+	return filepath.Join(Home, "allowlists")
+}
+
+// WorkspaceAllowlistFile returns the path of a workspace's allowlist.
+func WorkspaceAllowlistFile(workspace string) string {
+	return filepath.Join(WorkspaceAllowlistsDir(), workspace+".yaml")
+}
+
 // ProjectsDir returns the path to the projects directory.
 func ProjectsDir() string {
 	return filepath.Join(Home, "projects")
@@ -111,3 +122,21 @@ func VaultDir(workspace string) string {
 func VaultFile(workspace string) string {
 	return filepath.Join(VaultDir(workspace), "vault.enc")
 }
+
+// NetworkLogDir returns the directory holding the Squid access log and the
+// container address bindings used to attribute requests.
+func NetworkLogDir() string {
+	return filepath.Join(Data, "network")
+}
+
+// IPCRejectLog returns the log of IPC requests refused for lacking the
+// session's token.
+func IPCRejectLog() string {
+	return filepath.Join(Data, "ipc", "rejected.log")
+}
+
+// TLSDir returns the directory holding the per-install CA used for TLS
+// inspection by the proxy.
+func TLSDir() string {
+	return filepath.Join(Data, "tls")
+}
@@ -0,0 +1,230 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config.yaml is mounted read-write into agent containers so that they
+// can switch workspaces. The settings that decide what containers may
+// reach, or how they are confined, are therefore pinned: ExitBox uses the
+// copy the user approved, kept in a host-only file, and a change made to
+// config.yaml outside ExitBox applies only once the user approves it on
+// the host.
+
+// pinnedSettings are the settings of config.yaml that are pinned, under
+// the same keys as in config.yaml. Workspaces are keyed by name.
+type pinnedSettings struct {
+	Settings   pinnedGlobal               `yaml:"settings"`
+	Workspaces map[string]pinnedWorkspace `yaml:"workspaces,omitempty"`
+}
+
+type pinnedGlobal struct {
+	// DefaultFlags holds the default egress and resource limits, and
+	// no_firewall.
+	DefaultFlags  DefaultFlags  `yaml:"default_flags"`
+	DNS           DNSConfig     `yaml:"dns,omitempty"`
+	UpstreamProxy UpstreamProxy `yaml:"upstream_proxy,omitempty"`
+	Hardening     Hardening     `yaml:"hardening,omitempty"`
+}
+
+type pinnedWorkspace struct {
+	Egress    *EgressLimits `yaml:"egress,omitempty"`
+	Resources *Resources    `yaml:"resources,omitempty"`
+}
+
+// PinnedSettingsFile returns the path of the approved pinned settings.
+func PinnedSettingsFile() string {
+	return filepath.Join(Home, "pinned-settings.yaml")
+}
+
+// pinned returns the config's pinned settings.
+func (c *Config) pinned() pinnedSettings {
+	var p pinnedSettings
+	p.Settings.DefaultFlags = c.Settings.DefaultFlags
+	p.Settings.DNS = c.Settings.DNS
+	p.Settings.UpstreamProxy = c.Settings.UpstreamProxy
+	p.Settings.Hardening = c.Settings.Hardening
+	for _, w := range c.Workspaces.Items {
+		if w.Egress == nil && w.Resources == nil {
+			continue
+		}
+		if p.Workspaces == nil {
+			p.Workspaces = make(map[string]pinnedWorkspace)
+		}
+		p.Workspaces[w.Name] = pinnedWorkspace{Egress: w.Egress, Resources: w.Resources}
+	}
+	return p
+}
+
+// setPinned replaces the config's pinned settings.
+func (c *Config) setPinned(p pinnedSettings) {
+	c.Settings.DefaultFlags = p.Settings.DefaultFlags
+	c.Settings.DNS = p.Settings.DNS
+	c.Settings.UpstreamProxy = p.Settings.UpstreamProxy
+	c.Settings.Hardening = p.Settings.Hardening
+	for i := range c.Workspaces.Items {
+		w := p.Workspaces[c.Workspaces.Items[i].Name]
+		c.Workspaces.Items[i].Egress = w.Egress
+		c.Workspaces.Items[i].Resources = w.Resources
+	}
+}
+
+// loadPinnedSettings returns the approved pinned settings, or nil when
+// none were approved yet.
+func loadPinnedSettings() (*pinnedSettings, error) {
+	data, err := os.ReadFile(PinnedSettingsFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p pinnedSettings
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", PinnedSettingsFile(), err)
+	}
+	return &p, nil
+}
+
+// savePinnedSettings records p as the approved pinned settings.
+func savePinnedSettings(p pinnedSettings) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Home, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(PinnedSettingsFile(), data, 0644)
+}
+
+// applyPinnedSettings replaces the pinned settings read from config.yaml
+// with the approved ones.
+func applyPinnedSettings(cfg *Config) error {
+	p, err := loadPinnedSettings()
+	if err != nil || p == nil {
+		return err
+	}
+	cfg.setPinned(*p)
+	return nil
+}
+
+// SettingChange is a pinned setting whose value in config.yaml is not
+// the approved one.
+type SettingChange struct {
+	Key   string // e.g. "settings.dns"
+	Value string // its value in config.yaml as YAML; empty when removed
+}
+
+// PendingSettings reads config.yaml as it is on disk and returns it with
+// the pinned settings in it that differ from the approved ones. When
+// none were approved yet, config.yaml's are approved as they are. A
+// missing config.yaml has no changes.
+func PendingSettings() (*Config, []SettingChange, error) {
+	cfg, err := LoadConfigFrom(ConfigFile())
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	approved, err := loadPinnedSettings()
+	if err != nil {
+		return nil, nil, err
+	}
+	if approved == nil {
+		return cfg, nil, savePinnedSettings(cfg.pinned())
+	}
+	changes, err := diffPinned(*approved, cfg.pinned())
+	return cfg, changes, err
+}
+
+// ApproveSettings approves the pinned settings of a config returned by
+// PendingSettings.
+func ApproveSettings(cfg *Config) error {
+	return savePinnedSettings(cfg.pinned())
+}
+
+// diffPinned lists the settings of current that differ from approved, two
+// levels deep, e.g. "settings.dns".
+func diffPinned(approved, current pinnedSettings) ([]SettingChange, error) {
+	a, err := flattenPinned(approved)
+	if err != nil {
+		return nil, err
+	}
+	b, err := flattenPinned(current)
+	if err != nil {
+		return nil, err
+	}
+	var changes []SettingChange
+	for key := range union(a, b) {
+		if reflect.DeepEqual(a[key], b[key]) {
+			continue
+		}
+		change := SettingChange{Key: key}
+		if v, ok := b[key]; ok {
+			data, err := yaml.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			change.Value = strings.TrimSpace(string(data))
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// flattenPinned returns the pinned settings keyed by their path in
+// config.yaml, two levels deep.
+func flattenPinned(p pinnedSettings) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var top map[string]map[string]interface{}
+	if err := yaml.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{})
+	for section, values := range top {
+		for key, v := range values {
+			out[section+"."+key] = v
+		}
+	}
+	return out, nil
+}
+
+func union(a, b map[string]interface{}) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
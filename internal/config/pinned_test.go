@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPinnedSettings(t *testing.T) {
+	origHome := Home
+	Home = t.TempDir()
+	defer func() { Home = origHome }()
+
+	cfg := DefaultConfig()
+	cfg.Settings.DNS.Servers = []string{"10.0.0.53"}
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, changes, err := PendingSettings(); err != nil || len(changes) != 0 {
+		t.Fatalf("after saving: %v, %v", changes, err)
+	}
+
+	// A container rewrites config.yaml.
+	tampered := "version: 1\nsettings:\n  dns:\n    servers: [10.0.0.53]\n    hosts:\n      github.com: 203.0.113.9\n  default_workspace: work\n" +
+		"  upstream_proxy:\n    url: http://203.0.113.9:8080\n    credentials:\n      username_key: GITHUB_TOKEN\n      password_key: AWS_SECRET\n"
+	if err := os.WriteFile(ConfigFile(), []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Settings.DNS.Hosts) != 0 || loaded.Settings.UpstreamProxy.URL != "" || loaded.Settings.DefaultWorkspace != "work" {
+		t.Errorf("loaded settings = %+v", loaded.Settings)
+	}
+	if loaded := LoadOrDefault(); len(loaded.Settings.DNS.Hosts) != 0 {
+		t.Errorf("LoadOrDefault dns = %+v", loaded.Settings.DNS)
+	}
+
+	raw, changes, err := PendingSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Key != "settings.dns" || changes[1].Key != "settings.upstream_proxy" ||
+		!strings.Contains(changes[1].Value, "203.0.113.9:8080") || !strings.Contains(changes[1].Value, "AWS_SECRET") {
+		t.Fatalf("changes = %+v", changes)
+	}
+	if err := ApproveSettings(raw); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := LoadConfig(); loaded.Settings.DNS.Hosts["github.com"] != "203.0.113.9" {
+		t.Errorf("approved dns = %+v", loaded.Settings.DNS)
+	}
+
+	// A corrupted config.yaml falls back to the defaults, still pinned.
+	if err := os.WriteFile(ConfigFile(), []byte("settings: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := LoadOrDefault(); loaded.Settings.DNS.Hosts["github.com"] != "203.0.113.9" {
+		t.Errorf("fallback dns = %+v", loaded.Settings.DNS)
+	}
+}
+
+func TestPendingSettingsApprovesFirstUse(t *testing.T) {
+	origHome := Home
+	Home = t.TempDir()
+	defer func() { Home = origHome }()
+
+	if err := os.WriteFile(ConfigFile(), []byte("version: 1\nsettings:\n  dns:\n    servers: [10.0.0.53]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, changes, err := PendingSettings(); err != nil || len(changes) != 0 {
+		t.Fatalf("first use: %v, %v", changes, err)
+	}
+	if _, err := os.Stat(PinnedSettingsFile()); err != nil {
+		t.Errorf("settings not pinned: %v", err)
+	}
+}
+
+func TestPinnedLimits(t *testing.T) {
+	origHome := Home
+	Home = t.TempDir()
+	defer func() { Home = origHome }()
+
+	cfg := DefaultConfig()
+	cfg.Settings.DefaultFlags.Egress = EgressLimits{SessionBytes: "2GB"}
+	cfg.Settings.DefaultFlags.Resources = Resources{PidsLimit: 512}
+	cfg.Settings.Hardening = Hardening{Enabled: true, Seccomp: "exitbox"}
+	cfg.Workspaces.Items = []Workspace{{Name: "work", Egress: &EgressLimits{RequestsPerMinute: 60}, Resources: &Resources{Memory: "2g"}}}
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// A container lifts the limits and turns the firewall and hardening off.
+	tampered := "version: 1\nworkspaces:\n  items:\n    - name: work\n      resources:\n        memory: 64g\nsettings:\n  default_flags:\n    no_firewall: true\n  hardening:\n    enabled: false\n    seccomp: runtime\n"
+	if err := os.WriteFile(ConfigFile(), []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := loaded.Settings.DefaultFlags
+	if flags.NoFirewall || flags.Egress.SessionBytes != "2GB" || flags.Resources.PidsLimit != 512 {
+		t.Errorf("default flags = %+v", flags)
+	}
+	if e := loaded.Workspaces.Items[0].Egress; e == nil || e.RequestsPerMinute != 60 {
+		t.Errorf("workspace egress = %+v", e)
+	}
+	if r := loaded.Workspaces.Items[0].Resources; r == nil || r.Memory != "2g" {
+		t.Errorf("workspace resources = %+v", r)
+	}
+	if !loaded.Settings.Hardening.Enabled || loaded.Settings.Hardening.Seccomp != "exitbox" {
+		t.Errorf("hardening = %+v", loaded.Settings.Hardening)
+	}
+	_, changes, err := PendingSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 || changes[0].Key != "settings.default_flags" || changes[1].Key != "settings.hardening" || changes[2].Key != "workspaces.work" || !strings.Contains(changes[2].Value, "64g") {
+		t.Errorf("changes = %+v", changes)
+	}
+}
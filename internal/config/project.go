@@ -0,0 +1,105 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfig is a repository's .exitbox/config.yaml. It is meant to be
+// committed, so everyone working on the project gets the same sandbox.
+//
+// It sits between the command line and config.yaml: flags override it,
+// and it overrides the user's workspace and default_flags settings. Lists
+// (development, packages, allowlist) add to the workspace's.
+type ProjectConfig struct {
+	// Workspace is used unless -w names another.
+	Workspace string `yaml:"workspace,omitempty"`
+	// Development and Packages are added to the workspace's development
+	// profiles and Alpine packages in the project image.
+	Development []string `yaml:"development,omitempty"`
+	Packages    []string `yaml:"packages,omitempty"`
+	// Allowlist holds extra domains the project's sessions may reach.
+	Allowlist []string `yaml:"allowlist,omitempty"`
+	// Resources override the workspace's and default_flags' limits.
+	Resources *Resources `yaml:"resources,omitempty"`
+	// Agent is run by `exitbox run` without an agent name.
+	Agent string `yaml:"agent,omitempty"`
+	// AgentArgs are passed to each agent before the command line's.
+	AgentArgs map[string][]string `yaml:"agent_args,omitempty"`
+	// Mounts are host paths mounted into the container.
+	Mounts []Mount `yaml:"mounts,omitempty"`
+	// Masks are glob patterns, relative to the project, for files and
+	// directories hidden from the agent, e.g. "secrets/**" or "*.pem".
+	Masks []string `yaml:"masks,omitempty"`
+}
+
+// Mount is a host path mounted into agent containers.
+type Mount struct {
+	Source string `yaml:"source"`
+	// Target is the container path. It defaults to the source under
+	// /workspace, as with --include-dir; "~/" is /home/user.
+	Target string `yaml:"target,omitempty"`
+	// Mode is "ro" (the default) or "rw".
+	Mode string `yaml:"mode,omitempty"`
+	// Sensitive opts in to mounting a credentials directory such as
+	// ~/.ssh, which is then always read-only.
+	Sensitive bool `yaml:"sensitive,omitempty"`
+}
+
+// ProjectConfigFile returns the path of a project's config file.
+func ProjectConfigFile(projectDir string) string {
+	return filepath.Join(projectDir, ".exitbox", "config.yaml")
+}
+
+// LoadProjectConfig reads a project's config file. A project without one
+// gets an empty config. Unknown keys are errors, so typos do not silently
+// drop a mask.
+func LoadProjectConfig(projectDir string) (*ProjectConfig, error) {
+	pc := &ProjectConfig{}
+	data, err := os.ReadFile(ProjectConfigFile(projectDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pc, nil
+		}
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(pc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", ProjectConfigFile(projectDir), err)
+	}
+	for _, pkg := range pc.Packages {
+		// Package names end up in a Dockerfile RUN line.
+		if !packagePattern.MatchString(pkg) {
+			return nil, fmt.Errorf("%s: invalid package name '%s'", ProjectConfigFile(projectDir), pkg)
+		}
+	}
+	return pc, nil
+}
+
+// packagePattern matches Alpine package names, optionally with a version
+// constraint such as "nodejs>=20".
+var packagePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*([<>=~]{1,2}[A-Za-z0-9._+-]+)?$`)
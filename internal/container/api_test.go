@@ -0,0 +1,261 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package container
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeAPI serves handler on a Unix socket and returns a runtime using it.
+func fakeAPI(t *testing.T, cmd string, handler http.HandlerFunc) *apiRuntime {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return newAPIRuntime(cmd, socket)
+}
+
+func TestAPIRuntime_Images(t *testing.T) {
+	rt := fakeAPI(t, "docker", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_ping":
+			_, _ = io.WriteString(w, "OK")
+		case "/images/exitbox-claude-core/json":
+			_, _ = io.WriteString(w, `{"Id":"sha256:abc","Created":"2026-01-02T03:04:05Z","Config":{"Labels":{"exitbox.version":"1.2.3"}}}`)
+		case "/images/json":
+			if got := r.URL.Query().Get("filters"); got != `{"reference":["exitbox-*"]}` {
+				t.Errorf("filters = %s", got)
+			}
+			_, _ = io.WriteString(w, `[{"RepoTags":["exitbox-claude-core:latest"]},{"RepoTags":["<none>:<none>"]}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"message":"No such image"}`)
+		}
+	})
+
+	if err := rt.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.ImageExists("exitbox-claude-core") || rt.ImageExists("missing") {
+		t.Error("ImageExists answered wrong")
+	}
+	if v, err := rt.ImageInspect("exitbox-claude-core", `{{index .Config.Labels "exitbox.version"}}`); err != nil || v != "1.2.3" {
+		t.Errorf("label = %q, %v", v, err)
+	}
+	if v, err := rt.ImageInspect("exitbox-claude-core", `{{index .Config.Labels "exitbox.tools.hash"}}`); err != nil || v != "" {
+		t.Errorf("missing label = %q, %v", v, err)
+	}
+	if v, _ := rt.ImageInspect("exitbox-claude-core", "{{.Created}}"); v != "2026-01-02T03:04:05Z" {
+		t.Errorf("created = %q", v)
+	}
+	if images, err := rt.ImageList("exitbox-*"); err != nil || len(images) != 1 || images[0] != "exitbox-claude-core:latest" {
+		t.Errorf("ImageList = %v, %v", images, err)
+	}
+
+	_, err := rt.ImageInspect("missing", "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Message != "No such image" || !IsNotFound(err) {
+		t.Errorf("error = %#v", err)
+	}
+}
+
+func TestAPIRuntime_PSAndNetworks(t *testing.T) {
+	var created map[string]any
+	rt := fakeAPI(t, "docker", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			if got := r.URL.Query().Get("filters"); got != `{"name":["exitbox-"]}` {
+				t.Errorf("filters = %s", got)
+			}
+			_, _ = io.WriteString(w, `[{"Id":"0123456789abcdef","Names":["/exitbox-squid"],"Image":"exitbox-squid"}]`)
+		case "/networks/exitbox-int":
+			_, _ = io.WriteString(w, `{"Name":"exitbox-int","Internal":true,"IPAM":{"Config":[{"Subnet":"10.89.0.0/24"}]}}`)
+		case "/networks/create":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = io.WriteString(w, `{"Id":"n1"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	if rows, err := rt.PS("name=exitbox-", "{{.ID}} {{.Names}}"); err != nil || len(rows) != 1 || rows[0] != "0123456789ab exitbox-squid" {
+		t.Errorf("PS = %v, %v", rows, err)
+	}
+	if !rt.NetworkExists("exitbox-int") || rt.NetworkExists("exitbox-egress") {
+		t.Error("NetworkExists answered wrong")
+	}
+	if v, _ := rt.NetworkInspect("exitbox-int", "{{.Internal}}"); v != "true" {
+		t.Errorf("internal = %q", v)
+	}
+	out, _ := rt.NetworkInspect("exitbox-int", "")
+	var nets []struct{ Name string }
+	if err := json.Unmarshal([]byte(out), &nets); err != nil || len(nets) != 1 || nets[0].Name != "exitbox-int" {
+		t.Errorf("inspect without format = %s", out)
+	}
+	if err := rt.NetworkCreate("exitbox-egress", false); err != nil || created["Name"] != "exitbox-egress" || created["Internal"] != false {
+		t.Errorf("NetworkCreate sent %v, %v", created, err)
+	}
+}
+
+func TestAPIRuntime_BuildStreamsOutput(t *testing.T) {
+	ctxDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(ctxDir, "Dockerfile"), []byte("FROM alpine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var files []string
+	fail := false
+	rt := fakeAPI(t, "podman", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("t") != "exitbox-squid" || q.Get("dockerfile") != "Dockerfile" || q.Get("buildargs") != `{"EXITBOX_VERSION":"1.0"}` {
+			t.Errorf("query = %v", q)
+		}
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			files = append(files, hdr.Name)
+		}
+		_, _ = io.WriteString(w, `{"stream":"STEP 1/1: FROM alpine\n"}`+"\n")
+		if fail {
+			_, _ = io.WriteString(w, `{"error":"boom","errorDetail":{"message":"boom"}}`+"\n")
+		}
+	})
+
+	args := []string{"--layers", "--pull=newer", "--build-arg", "EXITBOX_VERSION=1.0", "-t", "exitbox-squid",
+		"-f", filepath.Join(ctxDir, "Dockerfile"), ctxDir}
+	out, err := rt.BuildQuiet(args)
+	if err != nil || out != "STEP 1/1: FROM alpine\n" {
+		t.Errorf("BuildQuiet = %q, %v", out, err)
+	}
+	if len(files) != 1 || files[0] != "Dockerfile" {
+		t.Errorf("context = %v", files)
+	}
+
+	fail = true
+	var apiErr *APIError
+	if _, err := rt.BuildQuiet(args); !errors.As(err, &apiErr) || apiErr.Message != "boom" {
+		t.Errorf("failed build error = %v", err)
+	}
+}
+
+func TestParseBuildArgs(t *testing.T) {
+	req, ok := parseBuildArgs([]string{"--no-cache", "-t", "img", "-f", "/elsewhere/Dockerfile", "/ctx"})
+	if !ok || !req.noCache || req.dockerfileName != ".exitbox.Dockerfile" || req.dockerfile != "/elsewhere/Dockerfile" {
+		t.Errorf("parseBuildArgs = %+v, %v", req, ok)
+	}
+	// BuildKit's cache export has no API equivalent.
+	if _, ok := parseBuildArgs([]string{"--cache-to", "type=local,dest=/tmp/c", "/ctx"}); ok {
+		t.Error("--cache-to should leave the build to the CLI")
+	}
+}
+
+func TestSplitImageRef(t *testing.T) {
+	tests := map[string][2]string{
+		"exitbox-squid":                    {"exitbox-squid", ""},
+		"ghcr.io/cloud-exit/squid:1.0":     {"ghcr.io/cloud-exit/squid", "1.0"},
+		"localhost:5000/exitbox-squid":     {"localhost:5000/exitbox-squid", ""},
+		"localhost:5000/exitbox-squid:dev": {"localhost:5000/exitbox-squid", "dev"},
+	}
+	for in, want := range tests {
+		if repo, tag := splitImageRef(in); repo != want[0] || tag != want[1] {
+			t.Errorf("splitImageRef(%q) = %q, %q", in, repo, tag)
+		}
+	}
+}
+
+func TestAPISocket(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix:///tmp/custom.sock")
+	if got := apiSocket("docker"); got != "/tmp/custom.sock" {
+		t.Errorf("apiSocket = %q", got)
+	}
+	t.Setenv("DOCKER_HOST", "tcp://10.0.0.1:2376")
+	if got := apiSocket("docker"); got != "" {
+		t.Errorf("remote DOCKER_HOST should use the CLI, got %q", got)
+	}
+	if Cmd(newAPIRuntime("podman", "/nonexistent")) != "podman" {
+		t.Error("Cmd should name the API runtime's CLI")
+	}
+}
+
+func TestAPIEnabled(t *testing.T) {
+	t.Setenv("EXITBOX_RUNTIME_API", "1")
+	if on, source := APIEnabled(); !on || source != "EXITBOX_RUNTIME_API" {
+		t.Errorf("APIEnabled() = %v, %q", on, source)
+	}
+	t.Setenv("EXITBOX_RUNTIME_API", "0")
+	if on, _ := APIEnabled(); on {
+		t.Error("EXITBOX_RUNTIME_API=0 should use the CLI")
+	}
+	if APISocket(&shellRuntime{cmd: "podman"}) != "" || APISocket(newAPIRuntime("podman", "/x.sock")) != "/x.sock" {
+		t.Error("APISocket answered wrong")
+	}
+}
+
+func TestTarContextHonoursDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		".dockerignore":           ".git\n*.md\n!README.md\nnode_modules/\n**/*.log\n",
+		"Dockerfile":              "FROM alpine\n",
+		"README.md":               "readme",
+		"NOTES.md":                "notes",
+		"app/main.go":             "package main",
+		"app/debug.log":           "log",
+		".git/config":             "[core]",
+		"node_modules/x/index.js": "x",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body, err := tarContext(dir, "", "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	tr := tar.NewReader(body)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		got[hdr.Name] = true
+	}
+	for _, name := range []string{".dockerignore", "Dockerfile", "README.md", "app", "app/main.go"} {
+		if !got[name] {
+			t.Errorf("%s missing from context", name)
+		}
+	}
+	for _, name := range []string{"NOTES.md", "app/debug.log", ".git", ".git/config", "node_modules", "node_modules/x/index.js"} {
+		if got[name] {
+			t.Errorf("%s should be ignored", name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package container
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Runtimes are the container CLIs ExitBox can drive, in the order Detect
+// tries them.
+var Runtimes = []string{"podman", "docker", "nerdctl"}
+
+// Capabilities are the runtime features that run and build flags depend on.
+type Capabilities struct {
+	// Rootless is set when containers run in the invoking user's namespace
+	// rather than as the host's root.
+	Rootless bool
+	// UserNS is set when --userns=keep-id can map the host user into the
+	// container, keeping bind-mounted files owned by the user.
+	UserNS bool
+	// InternalNetworks is set when networks can be created with
+	// --internal, which the firewall depends on.
+	InternalNetworks bool
+	// BuildKit is set when builds go through BuildKit, which the
+	// Dockerfiles need and which supports a local layer cache.
+	BuildKit bool
+	// Layers is set for Buildah-based builds (--layers, --pull=newer).
+	Layers bool
+	// Seccomp, AppArmor and SELinux are set when the runtime can confine
+	// containers with them.
+	Seccomp  bool
+	AppArmor bool
+	SELinux  bool
+	// Overlay is set when the workspace can be mounted through an
+	// overlay with an upper directory on the host: podman overlay
+	// volumes, or the local volume driver of a rootful docker.
+	Overlay bool
+}
+
+// probeFunc runs the runtime CLI with args and returns its output.
+type probeFunc func(args ...string) (string, error)
+
+// cliProbe returns a probeFunc running cmd.
+func cliProbe(cmd string) probeFunc {
+	return func(args ...string) (string, error) {
+		out, err := exec.Command(cmd, args...).CombinedOutput()
+		return strings.TrimSpace(string(out)), err
+	}
+}
+
+// detectCapabilities asks the runtime CLI what it supports.
+func detectCapabilities(cmd string, probe probeFunc) Capabilities {
+	switch cmd {
+	case "podman":
+		caps := Capabilities{Rootless: os.Getuid() != 0, UserNS: true, InternalNetworks: true, Layers: true, Seccomp: true, Overlay: true}
+		out, err := probe("info", "--format",
+			"{{.Host.Security.Rootless}} {{.Host.Security.SECCOMPEnabled}} {{.Host.Security.AppArmorEnabled}} {{.Host.Security.SELinuxEnabled}}")
+		if f := strings.Fields(out); err == nil && len(f) == 4 {
+			caps.Rootless = f[0] == "true"
+			caps.Seccomp = f[1] == "true"
+			caps.AppArmor = f[2] == "true"
+			caps.SELinux = f[3] == "true"
+		}
+		return caps
+	case "nerdctl":
+		out, _ := probe("info", "--format", "{{json .SecurityOptions}}")
+		help, _ := probe("network", "create", "--help")
+		_, buildctlErr := exec.LookPath("buildctl")
+		caps := securityOptions(out)
+		caps.InternalNetworks = strings.Contains(help, "--internal")
+		caps.BuildKit = buildctlErr == nil
+		return caps
+	default:
+		out, _ := probe("info", "--format", "{{json .SecurityOptions}}")
+		_, buildxErr := probe("buildx", "version")
+		caps := securityOptions(out)
+		caps.InternalNetworks = true
+		caps.BuildKit = buildxErr == nil
+		caps.Overlay = !caps.Rootless
+		return caps
+	}
+}
+
+// securityOptions reads the SecurityOptions that docker and nerdctl info
+// report, e.g. ["name=seccomp,profile=builtin","name=rootless"].
+func securityOptions(out string) Capabilities {
+	return Capabilities{
+		Rootless: strings.Contains(out, "name=rootless"),
+		Seccomp:  strings.Contains(out, "name=seccomp"),
+		AppArmor: strings.Contains(out, "name=apparmor"),
+		SELinux:  strings.Contains(out, "name=selinux"),
+	}
+}
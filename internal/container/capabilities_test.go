@@ -0,0 +1,74 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package container
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeProbe answers CLI probes from a table keyed by the joined args.
+func fakeProbe(answers map[string]string) probeFunc {
+	return func(args ...string) (string, error) {
+		out, ok := answers[strings.Join(args, " ")]
+		if !ok {
+			return "unknown command", errors.New("exit status 1")
+		}
+		return out, nil
+	}
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	podman := detectCapabilities("podman", fakeProbe(map[string]string{
+		"info --format {{.Host.Security.Rootless}} {{.Host.Security.SECCOMPEnabled}} {{.Host.Security.AppArmorEnabled}} {{.Host.Security.SELinuxEnabled}}": "true true false true",
+	}))
+	if podman != (Capabilities{Rootless: true, UserNS: true, InternalNetworks: true, Layers: true, Seccomp: true, SELinux: true, Overlay: true}) {
+		t.Errorf("podman = %+v", podman)
+	}
+
+	docker := detectCapabilities("docker", fakeProbe(map[string]string{
+		"info --format {{json .SecurityOptions}}": `["name=seccomp,profile=builtin","name=rootless","name=cgroupns"]`,
+		"buildx version": "github.com/docker/buildx v0.17.1",
+	}))
+	if docker != (Capabilities{Rootless: true, InternalNetworks: true, BuildKit: true, Seccomp: true}) {
+		t.Errorf("rootless docker = %+v", docker)
+	}
+
+	legacy := detectCapabilities("docker", fakeProbe(map[string]string{
+		"info --format {{json .SecurityOptions}}": `["name=apparmor","name=seccomp,profile=builtin"]`,
+	}))
+	if legacy.Rootless || legacy.BuildKit || legacy.UserNS || !legacy.AppArmor || !legacy.Overlay {
+		t.Errorf("docker without buildx = %+v", legacy)
+	}
+
+	nerdctl := detectCapabilities("nerdctl", fakeProbe(map[string]string{
+		"info --format {{json .SecurityOptions}}": `["name=rootless"]`,
+		"network create --help":                   "Flags:\n  --internal   Restrict external access to the network\n",
+	}))
+	if !nerdctl.Rootless || !nerdctl.InternalNetworks || nerdctl.UserNS || nerdctl.Layers || nerdctl.Overlay {
+		t.Errorf("nerdctl = %+v", nerdctl)
+	}
+	old := detectCapabilities("nerdctl", fakeProbe(map[string]string{
+		"network create --help": "Flags:\n  --subnet string\n",
+	}))
+	if old.InternalNetworks {
+		t.Error("nerdctl without --internal should not report internal networks")
+	}
+}
+
+func TestPreferred(t *testing.T) {
+	t.Setenv("CONTAINER_RUNTIME", "nerdctl")
+	if name, source := Preferred(); name != "nerdctl" || source != "CONTAINER_RUNTIME" {
+		t.Errorf("Preferred() = %q, %q", name, source)
+	}
+	if msg := NotFoundMessage(); !strings.Contains(msg, "nerdctl") {
+		t.Errorf("NotFoundMessage() = %q", msg)
+	}
+}
@@ -16,22 +16,91 @@
 
 package container
 
-import "os/exec"
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
 
-// Detect finds and returns a container runtime (podman preferred, docker fallback).
-// Returns nil if none found.
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+// Detect finds and returns a container runtime: the one chosen with
+// CONTAINER_RUNTIME or settings.runtime, else podman, docker or nerdctl,
+// whichever is installed first. Returns nil if none found.
 func Detect() Runtime {
-	// Prefer Podman
-	if _, err := exec.LookPath("podman"); err == nil {
-		return &shellRuntime{cmd: "podman"}
+	if name, _ := Preferred(); name != "" {
+		if _, err := exec.LookPath(name); err != nil {
+			return nil
+		}
+		return forCmd(name)
 	}
-	// Fall back to Docker
-	if _, err := exec.LookPath("docker"); err == nil {
-		return &shellRuntime{cmd: "docker"}
+	for _, name := range Runtimes {
+		if _, err := exec.LookPath(name); err == nil {
+			return forCmd(name)
+		}
 	}
 	return nil
 }
 
+// Preferred returns the runtime the user chose and where they chose it
+// (CONTAINER_RUNTIME or settings.runtime), or "" to detect one. Unknown
+// names are ignored.
+func Preferred() (name, source string) {
+	if name := os.Getenv("CONTAINER_RUNTIME"); slices.Contains(Runtimes, name) {
+		return name, "CONTAINER_RUNTIME"
+	}
+	if name := config.LoadOrDefault().Settings.Runtime; slices.Contains(Runtimes, name) {
+		return name, "settings.runtime"
+	}
+	return "", ""
+}
+
+// NotFoundMessage explains why Detect found no runtime.
+func NotFoundMessage() string {
+	if name, source := Preferred(); name != "" {
+		return fmt.Sprintf("Container runtime %s (set by %s) not found.", name, source)
+	}
+	return "No container runtime found. Install Podman, Docker or nerdctl."
+}
+
+// forCmd returns the runtime for a CLI: the API client when it is turned
+// on and the runtime's socket answers, the CLI itself otherwise.
+func forCmd(cmd string) Runtime {
+	if on, _ := APIEnabled(); on {
+		if socket := apiSocket(cmd); socket != "" {
+			if rt := newAPIRuntime(cmd, socket); rt.Ping() == nil {
+				return rt
+			}
+		}
+	}
+	return &shellRuntime{cmd: cmd}
+}
+
+// APIEnabled reports whether runtimes are driven through their API socket
+// rather than their CLI, and what turned it on or off
+// (EXITBOX_RUNTIME_API or settings.runtime_api). The CLI is the default.
+func APIEnabled() (bool, string) {
+	switch os.Getenv("EXITBOX_RUNTIME_API") {
+	case "1":
+		return true, "EXITBOX_RUNTIME_API"
+	case "0":
+		return false, "EXITBOX_RUNTIME_API"
+	}
+	if config.LoadOrDefault().Settings.RuntimeAPI {
+		return true, "settings.runtime_api"
+	}
+	return false, ""
+}
+
+// APISocket returns the socket rt talks to, or "" when it drives the CLI.
+func APISocket(rt Runtime) string {
+	if r, ok := rt.(*apiRuntime); ok {
+		return r.socket
+	}
+	return ""
+}
+
 // MustDetect returns a runtime or panics if none is found.
 func MustDetect() Runtime {
 	rt := Detect()
@@ -46,6 +115,9 @@ func IsAvailable(rt Runtime) bool {
 	if rt == nil {
 		return false
 	}
+	if api, ok := rt.(*apiRuntime); ok {
+		return api.Ping() == nil
+	}
 	cmd := Cmd(rt)
 	return exec.Command(cmd, "info").Run() == nil
 }
@@ -23,6 +23,7 @@ type MockRuntime struct {
 	Networks   map[string]bool
 	Removed    []string
 	Built      [][]string
+	Caps       Capabilities
 }
 
 func NewMockRuntime() *MockRuntime {
@@ -98,6 +99,27 @@ func (m *MockRuntime) NetworkConnect(_, _ string) error           { return nil }
 func (m *MockRuntime) NetworkInspect(_, _ string) (string, error) { return "", nil }
 func (m *MockRuntime) IsRootless() bool                           { return false }
 
+func (m *MockRuntime) BuildQuiet(args []string) (string, error) {
+	return "", m.Build(context.Background(), args)
+}
+
+func (m *MockRuntime) BuildInteractive(args []string) error {
+	return m.Build(context.Background(), args)
+}
+
+func (m *MockRuntime) PullQuiet(_ string) (string, error) { return "", nil }
+func (m *MockRuntime) PullInteractive(_ string) error     { return nil }
+
+func (m *MockRuntime) TagImage(src, dst string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Images[dst] = m.Images[src]
+	return nil
+}
+
+func (m *MockRuntime) ExecInteractive(_ []string) (int, error) { return 0, nil }
+func (m *MockRuntime) Capabilities() Capabilities              { return m.Caps }
+
 func TestMockRuntimeImplementsInterface(t *testing.T) {
 	var _ Runtime = NewMockRuntime()
 }
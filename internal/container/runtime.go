@@ -18,10 +18,10 @@ package container
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 )
 
 // Runtime is the container runtime interface.
@@ -42,23 +42,47 @@ type Runtime interface {
 	NetworkConnect(network, container string) error
 	NetworkInspect(name, format string) (string, error)
 	IsRootless() bool
+	// Capabilities reports what the runtime supports.
+	Capabilities() Capabilities
+	// BuildQuiet builds an image capturing all output, which it returns
+	// with any error. Use this for non-verbose builds with a spinner.
+	BuildQuiet(args []string) (string, error)
+	// BuildInteractive builds an image streaming its output to stdout.
+	BuildInteractive(args []string) error
+	// PullQuiet pulls an image capturing all output, which it returns with
+	// any error. Use this for non-verbose pulls with a spinner.
+	PullQuiet(image string) (string, error)
+	// PullInteractive pulls an image streaming its output to stdout.
+	PullInteractive(image string) error
+	// TagImage tags an image with a new name.
+	TagImage(src, dst string) error
+	// ExecInteractive runs a runtime command with inherited stdio (for
+	// interactive use) and returns its exit code.
+	ExecInteractive(args []string) (int, error)
 }
 
-// shellRuntime implements Runtime by shelling out to podman/docker.
+// shellRuntime implements Runtime by shelling out to podman, docker or
+// nerdctl.
 type shellRuntime struct {
 	cmd string
+
+	capsOnce sync.Once
+	caps     Capabilities
 }
 
 func (r *shellRuntime) Name() string { return r.cmd }
 
 func (r *shellRuntime) Build(ctx context.Context, args []string) error {
-	cmdArgs := append([]string{"build"}, args...)
-	c := exec.CommandContext(ctx, r.cmd, cmdArgs...)
+	c := r.buildCmd(ctx, args)
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
 	return c.Run()
 }
 
+func (r *shellRuntime) buildCmd(ctx context.Context, args []string) *exec.Cmd {
+	return exec.CommandContext(ctx, r.cmd, append([]string{"build"}, args...)...)
+}
+
 func (r *shellRuntime) Run(ctx context.Context, args []string) (int, error) {
 	cmdArgs := append([]string{"run"}, args...)
 	c := exec.CommandContext(ctx, r.cmd, cmdArgs...)
@@ -185,26 +209,22 @@ func (r *shellRuntime) NetworkInspect(name, format string) (string, error) {
 }
 
 func (r *shellRuntime) IsRootless() bool {
-	if r.cmd == "podman" {
-		out, err := exec.Command(r.cmd, "info", "--format", "{{.Host.Security.Rootless}}").Output()
-		if err == nil && strings.TrimSpace(string(out)) == "true" {
-			return true
-		}
-		return os.Getuid() != 0
-	}
-	return false
+	return r.Capabilities().Rootless
+}
+
+func (r *shellRuntime) Capabilities() Capabilities {
+	r.capsOnce.Do(func() {
+		r.caps = detectCapabilities(r.cmd, cliProbe(r.cmd))
+	})
+	return r.caps
 }
 
 // ExecInteractive runs a command with inherited stdio (for interactive use).
-func ExecInteractive(rt Runtime, args []string) (int, error) {
-	sr, ok := rt.(*shellRuntime)
-	if !ok {
-		return 1, fmt.Errorf("unsupported runtime type")
-	}
-	c := exec.Command(sr.cmd, args...)
-	c.Stdin = nil  // inherited from parent
-	c.Stdout = nil // inherited from parent
-	c.Stderr = nil // inherited from parent
+func (r *shellRuntime) ExecInteractive(args []string) (int, error) {
+	c := exec.Command(r.cmd, args...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
 	err := c.Run()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
@@ -215,69 +235,43 @@ func ExecInteractive(rt Runtime, args []string) (int, error) {
 	return 0, nil
 }
 
-// BuildQuiet runs a build command capturing all output. Returns combined
-// output and error. Use this for non-verbose builds with a spinner.
-func BuildQuiet(rt Runtime, args []string) (string, error) {
-	sr, ok := rt.(*shellRuntime)
-	if !ok {
-		return "", fmt.Errorf("unsupported runtime type")
-	}
-	cmdArgs := append([]string{"build"}, args...)
-	c := exec.Command(sr.cmd, cmdArgs...)
-	out, err := c.CombinedOutput()
+// BuildQuiet runs a build command capturing all output.
+func (r *shellRuntime) BuildQuiet(args []string) (string, error) {
+	out, err := r.buildCmd(context.Background(), args).CombinedOutput()
 	return string(out), err
 }
 
 // BuildInteractive runs a build command with inherited stdout/stderr.
-func BuildInteractive(rt Runtime, args []string) error {
-	sr, ok := rt.(*shellRuntime)
-	if !ok {
-		return fmt.Errorf("unsupported runtime type")
-	}
-	cmdArgs := append([]string{"build"}, args...)
-	c := exec.Command(sr.cmd, cmdArgs...)
+func (r *shellRuntime) BuildInteractive(args []string) error {
+	c := r.buildCmd(context.Background(), args)
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
 	return c.Run()
 }
 
-// PullQuiet runs a pull command capturing all output. Returns combined
-// output and error. Use this for non-verbose pulls with a spinner.
-func PullQuiet(rt Runtime, image string) (string, error) {
-	sr, ok := rt.(*shellRuntime)
-	if !ok {
-		return "", fmt.Errorf("unsupported runtime type")
-	}
-	c := exec.Command(sr.cmd, "pull", image)
-	out, err := c.CombinedOutput()
+// PullQuiet runs a pull command capturing all output.
+func (r *shellRuntime) PullQuiet(image string) (string, error) {
+	out, err := exec.Command(r.cmd, "pull", image).CombinedOutput()
 	return string(out), err
 }
 
 // PullInteractive runs a pull command with inherited stdout/stderr.
-func PullInteractive(rt Runtime, image string) error {
-	sr, ok := rt.(*shellRuntime)
-	if !ok {
-		return fmt.Errorf("unsupported runtime type")
-	}
-	c := exec.Command(sr.cmd, "pull", image)
+func (r *shellRuntime) PullInteractive(image string) error {
+	c := exec.Command(r.cmd, "pull", image)
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
 	return c.Run()
 }
 
-// TagImage tags an image with a new name.
-func TagImage(rt Runtime, src, dst string) error {
-	sr, ok := rt.(*shellRuntime)
-	if !ok {
-		return fmt.Errorf("unsupported runtime type")
-	}
-	return exec.Command(sr.cmd, "tag", src, dst).Run()
+func (r *shellRuntime) TagImage(src, dst string) error {
+	return exec.Command(r.cmd, "tag", src, dst).Run()
 }
 
-// Cmd returns the raw command name for the runtime.
+// Cmd returns the CLI command for the runtime, for the operations that
+// still shell out.
 func Cmd(rt Runtime) string {
-	if sr, ok := rt.(*shellRuntime); ok {
-		return sr.cmd
+	if rt == nil {
+		return "docker"
 	}
-	return "docker"
+	return rt.Name()
 }
@@ -0,0 +1,132 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package dns implements the resolver that runs next to Squid in the proxy
+// container. Squid sends every lookup to it; it answers host overrides
+// itself, forwards names in configured zones to their own upstreams and
+// everything else to DoH endpoints or plain DNS servers.
+package dns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Config is the resolver configuration, rendered by the host as JSON.
+type Config struct {
+	Servers []string          `json:"servers"`
+	DoH     []string          `json:"doh,omitempty"`
+	Zones   []Zone            `json:"zones,omitempty"`
+	Hosts   map[string]string `json:"hosts,omitempty"`
+}
+
+// Zone routes lookups for a domain and its subdomains to its own upstreams.
+type Zone struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+}
+
+// LoadConfig reads a resolver configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &cfg, cfg.Validate()
+}
+
+// Validate checks upstreams, zone names and host overrides.
+func (c *Config) Validate() error {
+	if len(c.Servers) == 0 && len(c.DoH) == 0 {
+		return fmt.Errorf("no upstream servers configured")
+	}
+	for _, s := range c.Servers {
+		if err := validateUpstream(s); err != nil {
+			return err
+		}
+	}
+	for _, u := range c.DoH {
+		if !strings.HasPrefix(u, "https://") {
+			return fmt.Errorf("DoH endpoint must be an https:// URL: %s", u)
+		}
+		if err := validateUpstream(u); err != nil {
+			return err
+		}
+	}
+	for _, z := range c.Zones {
+		if canonicalName(z.Name) == "" {
+			return fmt.Errorf("zone without a name")
+		}
+		if len(z.Servers) == 0 {
+			return fmt.Errorf("zone %s has no servers", z.Name)
+		}
+		for _, s := range z.Servers {
+			if err := validateUpstream(s); err != nil {
+				return err
+			}
+		}
+	}
+	for name, ip := range c.Hosts {
+		if canonicalName(name) == "" {
+			return fmt.Errorf("host override without a name")
+		}
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("host override %s: invalid address %q", name, ip)
+		}
+	}
+	return nil
+}
+
+// validateUpstream accepts "ip", "ip:port" or an http(s) DoH URL.
+func validateUpstream(s string) error {
+	if strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://") {
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid DoH endpoint: %s", s)
+		}
+		return nil
+	}
+	if _, err := serverAddr(s); err != nil {
+		return err
+	}
+	return nil
+}
+
+// serverAddr returns the host:port of a plain DNS server, defaulting the
+// port to 53.
+func serverAddr(s string) (string, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		return net.JoinHostPort(s, "53"), nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil || net.ParseIP(host) == nil || port == "" {
+		return "", fmt.Errorf("invalid DNS server %q (want an IP address, optionally with :port)", s)
+	}
+	return s, nil
+}
+
+// canonicalName lowercases a name and strips the trailing dot.
+func canonicalName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
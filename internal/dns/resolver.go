@@ -0,0 +1,428 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxMessageSize bounds DNS messages over TCP and DoH.
+const maxMessageSize = 65535
+
+// hostTTL is the TTL of answers for host overrides.
+const hostTTL = 60
+
+// Resolver answers DNS queries according to a Config.
+type Resolver struct {
+	mu      sync.RWMutex
+	cfg     *Config
+	client  *http.Client
+	timeout time.Duration
+}
+
+// NewResolver returns a resolver for cfg.
+func NewResolver(cfg *Config) *Resolver {
+	return &Resolver{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		timeout: 5 * time.Second,
+	}
+}
+
+// SetConfig replaces the configuration used for new queries.
+func (r *Resolver) SetConfig(cfg *Config) {
+	r.mu.Lock()
+	r.cfg = cfg
+	r.mu.Unlock()
+}
+
+func (r *Resolver) config() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg
+}
+
+// Resolve answers a wire-format query. Upstreams are tried in order; when
+// all of them fail the answer is SERVFAIL.
+func (r *Resolver) Resolve(ctx context.Context, query []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil {
+		return nil, fmt.Errorf("malformed query: %w", err)
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, fmt.Errorf("malformed query: %w", err)
+	}
+
+	cfg := r.config()
+	name := canonicalName(q.Name.String())
+	if ip, ok := lookupHost(cfg.Hosts, name); ok {
+		return hostAnswer(hdr, q, ip)
+	}
+
+	for _, upstream := range upstreamsFor(cfg, name) {
+		qctx, cancel := context.WithTimeout(ctx, r.timeout)
+		resp, err := r.exchange(qctx, upstream, query)
+		cancel()
+		if err == nil {
+			return resp, nil
+		}
+	}
+	return errorAnswer(hdr, q, dnsmessage.RCodeServerFailure)
+}
+
+// upstreamsFor returns the upstreams for a name: those of the longest
+// matching zone, else the DoH endpoints, else the plain servers.
+func upstreamsFor(cfg *Config, name string) []string {
+	best := -1
+	var servers []string
+	for _, z := range cfg.Zones {
+		zone := strings.TrimPrefix(strings.TrimPrefix(canonicalName(z.Name), "*."), ".")
+		if name != zone && !strings.HasSuffix(name, "."+zone) {
+			continue
+		}
+		if len(zone) > best {
+			best = len(zone)
+			servers = z.Servers
+		}
+	}
+	if best >= 0 {
+		return servers
+	}
+	if len(cfg.DoH) > 0 {
+		return cfg.DoH
+	}
+	return cfg.Servers
+}
+
+// lookupHost returns the override address for a name.
+func lookupHost(hosts map[string]string, name string) (net.IP, bool) {
+	for h, addr := range hosts {
+		if canonicalName(h) == name {
+			return net.ParseIP(addr), true
+		}
+	}
+	return nil, false
+}
+
+// exchange sends a query to one upstream.
+func (r *Resolver) exchange(ctx context.Context, upstream string, query []byte) ([]byte, error) {
+	if strings.HasPrefix(upstream, "https://") || strings.HasPrefix(upstream, "http://") {
+		return r.exchangeDoH(ctx, upstream, query)
+	}
+	addr, err := serverAddr(upstream)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := exchangeConn(ctx, "udp", addr, query)
+	if err != nil {
+		return nil, err
+	}
+	// Retry truncated answers over TCP.
+	var p dnsmessage.Parser
+	if hdr, err := p.Start(resp); err == nil && hdr.Truncated {
+		return exchangeConn(ctx, "tcp", addr, query)
+	}
+	return resp, nil
+}
+
+// exchangeConn sends a query over UDP or TCP and returns the matching reply.
+func exchangeConn(ctx context.Context, network, addr string, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		if err := writeTCPMessage(conn, query); err != nil {
+			return nil, err
+		}
+		resp, err := readTCPMessage(conn)
+		if err != nil {
+			return nil, err
+		}
+		return resp, checkReply(query, resp)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Ignore stray datagrams that do not answer this query.
+		if checkReply(query, buf[:n]) == nil {
+			return append([]byte(nil), buf[:n]...), nil
+		}
+	}
+}
+
+// exchangeDoH sends a query to a DoH endpoint (RFC 8484, POST).
+func (r *Resolver) exchangeDoH(ctx context.Context, endpoint string, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH %s: %s", endpoint, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	// DoH servers may answer with ID 0; restore the caller's ID.
+	if len(body) >= 2 && len(query) >= 2 {
+		copy(body[:2], query[:2])
+	}
+	return body, checkReply(query, body)
+}
+
+// checkReply verifies that resp is a response to query.
+func checkReply(query, resp []byte) error {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(resp)
+	if err != nil {
+		return fmt.Errorf("malformed reply: %w", err)
+	}
+	if !hdr.Response || len(query) < 2 || hdr.ID != binary.BigEndian.Uint16(query) {
+		return errors.New("reply does not match query")
+	}
+	return nil
+}
+
+// hostAnswer builds the answer for a host override. Queries for the other
+// address family or other types get an empty answer.
+func hostAnswer(hdr dnsmessage.Header, q dnsmessage.Question, ip net.IP) ([]byte, error) {
+	b := newReply(hdr, dnsmessage.RCodeSuccess)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: hostTTL}
+	if v4 := ip.To4(); v4 != nil && q.Type == dnsmessage.TypeA {
+		var a dnsmessage.AResource
+		copy(a.A[:], v4)
+		if err := b.AResource(rh, a); err != nil {
+			return nil, err
+		}
+	} else if ip.To4() == nil && q.Type == dnsmessage.TypeAAAA {
+		var aaaa dnsmessage.AAAAResource
+		copy(aaaa.AAAA[:], ip.To16())
+		if err := b.AAAAResource(rh, aaaa); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+// errorAnswer builds an answer with no records and the given code.
+func errorAnswer(hdr dnsmessage.Header, q dnsmessage.Question, rcode dnsmessage.RCode) ([]byte, error) {
+	b := newReply(hdr, rcode)
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+func newReply(hdr dnsmessage.Header, rcode dnsmessage.RCode) dnsmessage.Builder {
+	return dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 hdr.ID,
+		Response:           true,
+		OpCode:             hdr.OpCode,
+		RecursionDesired:   hdr.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+}
+
+// udpLimit returns the largest UDP reply the client accepts: 512 bytes,
+// or the size it advertises with EDNS.
+func udpLimit(query []byte) int {
+	var p dnsmessage.Parser
+	if _, err := p.Start(query); err != nil {
+		return 512
+	}
+	if p.SkipAllQuestions() != nil || p.SkipAllAnswers() != nil || p.SkipAllAuthorities() != nil {
+		return 512
+	}
+	for {
+		h, err := p.AdditionalHeader()
+		if err != nil {
+			return 512
+		}
+		if h.Type == dnsmessage.TypeOPT {
+			if size := int(h.Class); size > 512 {
+				return size
+			}
+			return 512
+		}
+		if err := p.SkipAdditional(); err != nil {
+			return 512
+		}
+	}
+}
+
+// truncate replaces a reply that is too large for UDP with an empty one
+// that has the TC bit set, so the client retries over TCP.
+func truncate(query []byte) ([]byte, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 hdr.ID,
+		Response:           true,
+		Truncated:          true,
+		RecursionDesired:   hdr.RecursionDesired,
+		RecursionAvailable: true,
+	})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(q); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// ServeUDP answers queries arriving on conn until it is closed.
+func (r *Resolver) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			resp, err := r.Resolve(context.Background(), query)
+			if err != nil {
+				return
+			}
+			if len(resp) > udpLimit(query) {
+				if resp, err = truncate(query); err != nil {
+					return
+				}
+			}
+			_, _ = conn.WriteTo(resp, addr)
+		}()
+	}
+}
+
+// ServeTCP answers queries on connections accepted from l until it is
+// closed.
+func (r *Resolver) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go r.serveTCPConn(conn)
+	}
+}
+
+func (r *Resolver) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+		resp, err := r.Resolve(context.Background(), query)
+		if err != nil {
+			return
+		}
+		if err := writeTCPMessage(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+// readTCPMessage reads one length-prefixed DNS message.
+func readTCPMessage(conn net.Conn) ([]byte, error) {
+	var size uint16
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeTCPMessage writes one length-prefixed DNS message.
+func writeTCPMessage(conn net.Conn, msg []byte) error {
+	if len(msg) > maxMessageSize {
+		return errors.New("message too large")
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := conn.Write(buf)
+	return err
+}
@@ -0,0 +1,254 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package dns
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubServer is a local DNS server answering every A query with ip.
+func stubServer(t *testing.T, ip string) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp, err := answerWith(buf[:n], ip)
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// answerWith answers query with a single A record.
+func answerWith(query []byte, ip string) ([]byte, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(query)
+	if err != nil {
+		return nil, err
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+	return hostAnswer(hdr, q, net.ParseIP(ip))
+}
+
+func buildQuery(t *testing.T, name string, typ dnsmessage.Type) []byte {
+	t.Helper()
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 4242, RecursionDesired: true})
+	_ = b.StartQuestions()
+	if err := b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(name),
+		Type:  typ,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// resolveA resolves name and returns the rcode and the first A record.
+func resolveA(t *testing.T, r *Resolver, name string) (dnsmessage.RCode, string) {
+	t.Helper()
+	resp, err := r.Resolve(context.Background(), buildQuery(t, name, dnsmessage.TypeA))
+	if err != nil {
+		t.Fatalf("Resolve(%s): %v", name, err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	if msg.Header.ID != 4242 {
+		t.Errorf("reply ID = %d, want 4242", msg.Header.ID)
+	}
+	for _, a := range msg.Answers {
+		if rec, ok := a.Body.(*dnsmessage.AResource); ok {
+			return msg.Header.RCode, net.IP(rec.A[:]).String()
+		}
+	}
+	return msg.Header.RCode, ""
+}
+
+func TestResolverRoutesZones(t *testing.T) {
+	public := stubServer(t, "192.0.2.1")
+	corp := stubServer(t, "10.0.0.1")
+	lab := stubServer(t, "10.9.0.1")
+
+	r := NewResolver(&Config{
+		Servers: []string{public},
+		Zones: []Zone{
+			{Name: "corp.example", Servers: []string{corp}},
+			{Name: "lab.corp.example.", Servers: []string{lab}},
+		},
+	})
+
+	tests := map[string]string{
+		"www.example.org.":     "192.0.2.1",
+		"corp.example.":        "10.0.0.1",
+		"git.corp.example.":    "10.0.0.1",
+		"ci.lab.corp.example.": "10.9.0.1",
+		"notcorp.example.":     "192.0.2.1",
+		"GIT.Corp.Example.":    "10.0.0.1",
+	}
+	for name, want := range tests {
+		if _, got := resolveA(t, r, name); got != want {
+			t.Errorf("%s resolved to %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestResolverHostOverrides(t *testing.T) {
+	r := NewResolver(&Config{
+		Servers: []string{stubServer(t, "192.0.2.1")},
+		Hosts:   map[string]string{"Git.Corp.Example": "10.1.2.3"},
+	})
+	if _, got := resolveA(t, r, "git.corp.example."); got != "10.1.2.3" {
+		t.Errorf("override resolved to %q", got)
+	}
+
+	// An IPv4 override answers AAAA queries with no records rather than
+	// falling through to the upstream.
+	resp, err := r.Resolve(context.Background(), buildQuery(t, "git.corp.example.", dnsmessage.TypeAAAA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(resp); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.RCode != dnsmessage.RCodeSuccess || len(msg.Answers) != 0 {
+		t.Errorf("AAAA for IPv4 override: rcode %v, %d answers", msg.Header.RCode, len(msg.Answers))
+	}
+}
+
+func TestResolverDoH(t *testing.T) {
+	var gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotType = req.Header.Get("Content-Type")
+		query, _ := io.ReadAll(req.Body)
+		// Answer with ID 0, as DoH servers may.
+		query[0], query[1] = 0, 0
+		resp, err := answerWith(query, "198.51.100.7")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(resp)
+	}))
+	defer srv.Close()
+
+	r := NewResolver(&Config{
+		Servers: []string{stubServer(t, "192.0.2.1")},
+		DoH:     []string{srv.URL},
+	})
+	if _, got := resolveA(t, r, "example.org."); got != "198.51.100.7" {
+		t.Errorf("DoH answer = %q", got)
+	}
+	if gotType != "application/dns-message" {
+		t.Errorf("DoH request content type = %q", gotType)
+	}
+}
+
+func TestResolverFallsBackAndFails(t *testing.T) {
+	// A closed port never answers; the next upstream does.
+	dead, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	r := NewResolver(&Config{Servers: []string{deadAddr, stubServer(t, "192.0.2.1")}})
+	if _, got := resolveA(t, r, "example.org."); got != "192.0.2.1" {
+		t.Errorf("fallback answer = %q", got)
+	}
+
+	r = NewResolver(&Config{Servers: []string{deadAddr}})
+	if rcode, _ := resolveA(t, r, "example.org."); rcode != dnsmessage.RCodeServerFailure {
+		t.Errorf("rcode = %v, want SERVFAIL", rcode)
+	}
+}
+
+func TestResolverServeUDP(t *testing.T) {
+	r := NewResolver(&Config{
+		Servers: []string{stubServer(t, "192.0.2.1")},
+		Hosts:   map[string]string{"pinned.example": "10.1.2.3"},
+	})
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() { _ = r.ServeUDP(conn) }()
+
+	// Use the Go resolver against the served address, as Squid would.
+	client := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+	addrs, err := client.LookupHost(context.Background(), "pinned.example")
+	if err != nil {
+		t.Fatalf("LookupHost: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "10.1.2.3" {
+		t.Errorf("addrs = %v", addrs)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := &Config{
+		Servers: []string{"10.0.0.53", "10.0.0.54:5353", "[fd00::53]:53"},
+		DoH:     []string{"https://dns.example/dns-query"},
+		Zones:   []Zone{{Name: "corp.example", Servers: []string{"10.0.0.53"}}},
+		Hosts:   map[string]string{"git.corp.example": "10.1.2.3"},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+
+	invalid := []*Config{
+		{},
+		{Servers: []string{"dns.example"}},
+		{Servers: []string{"10.0.0.53"}, DoH: []string{"http://dns.example/dns-query"}},
+		{Servers: []string{"10.0.0.53"}, Zones: []Zone{{Name: "corp.example"}}},
+		{Servers: []string{"10.0.0.53"}, Hosts: map[string]string{"git.corp.example": "nope"}},
+	}
+	for i, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("invalid config %d accepted", i)
+		}
+	}
+}
@@ -116,7 +116,14 @@ func buildLocalIntermediary(ctx context.Context, rt container.Runtime, cmd, base
 		}
 	}
 
-	args := buildArgs(cmd)
+	// Write pre-built exitbox-git binary for the container's architecture.
+	if extra, err := writeExitboxGit(buildCtx); err == nil && extra != "" {
+		if err := appendToFile(dockerfilePath, extra); err != nil {
+			ui.Warnf("Failed to append exitbox-git to Dockerfile: %v", err)
+		}
+	}
+
+	args := buildArgs(rt.Capabilities())
 	args = append(args,
 		"--build-arg", fmt.Sprintf("BASE_IMAGE=%s", baseRef),
 		"--build-arg", fmt.Sprintf("USER_ID=%d", os.Getuid()),
@@ -154,7 +161,7 @@ func buildBaseFull(ctx context.Context, rt container.Runtime, cmd, imageName str
 		return fmt.Errorf("failed to write .dockerignore: %w", err)
 	}
 
-	args := buildArgs(cmd)
+	args := buildArgs(rt.Capabilities())
 	args = append(args,
 		"--build-arg", fmt.Sprintf("EXITBOX_VERSION=%s", Version),
 		"-t", publishedName,
@@ -211,18 +218,36 @@ func writeExitboxVault(buildCtx string) (string, error) {
 	return "\n# Vault IPC client\nCOPY exitbox-vault /usr/local/bin/exitbox-vault\n", nil
 }
 
+// writeExitboxGit writes the exitbox-git binary into the build context
+// and returns the Dockerfile snippet to COPY it. Returns empty string if
+// the binary could not be written.
+func writeExitboxGit(buildCtx string) (string, error) {
+	var gitBin []byte
+	switch runtime.GOARCH {
+	case "arm64":
+		gitBin = static.ExitboxGitArm64
+	default:
+		gitBin = static.ExitboxGitAmd64
+	}
+	if err := os.WriteFile(filepath.Join(buildCtx, "exitbox-git"), gitBin, 0755); err != nil {
+		ui.Warnf("Failed to write exitbox-git: %v", err)
+		return "", err
+	}
+	return "\n# Git IPC client\nCOPY exitbox-git /usr/local/bin/exitbox-git\n", nil
+}
+
 // pullImage pulls a container image, using a spinner in quiet mode or
 // full output in verbose mode.
 func pullImage(rt container.Runtime, ref, label string) error {
 	if ui.Verbose {
 		start := time.Now()
-		err := container.PullInteractive(rt, ref)
+		err := rt.PullInteractive(ref)
 		ui.Infof("Pull took %s", formatDuration(time.Since(start)))
 		return err
 	}
 	spin := ui.NewSpinner(label)
 	spin.Start()
-	output, err := container.PullQuiet(rt, ref)
+	output, err := rt.PullQuiet(ref)
 	elapsed := spin.Stop()
 	if err != nil {
 		ui.Debugf("Pull output: %s", output)
@@ -238,13 +263,13 @@ func pullImage(rt container.Runtime, ref, label string) error {
 func buildImage(rt container.Runtime, args []string, label string) error {
 	if ui.Verbose {
 		start := time.Now()
-		err := container.BuildInteractive(rt, args)
+		err := rt.BuildInteractive(args)
 		ui.Infof("Build took %s", formatDuration(time.Since(start)))
 		return err
 	}
 	spin := ui.NewSpinner(label)
 	spin.Start()
-	output, err := container.BuildQuiet(rt, args)
+	output, err := rt.BuildQuiet(args)
 	elapsed := spin.Stop()
 	if err != nil {
 		fmt.Fprint(os.Stderr, output)
@@ -265,11 +290,13 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%ds", s)
 }
 
-func buildArgs(cmd string) []string {
+// buildArgs returns the flags every build gets on a runtime with caps.
+func buildArgs(caps container.Capabilities) []string {
 	var args []string
-	if cmd == "podman" {
+	switch {
+	case caps.Layers:
 		args = append(args, "--layers", "--pull=newer")
-	} else {
+	case caps.BuildKit:
 		os.Setenv("DOCKER_BUILDKIT", "1")
 		cacheDir := filepath.Join(config.Cache, "buildx")
 		if err := os.MkdirAll(cacheDir, 0755); err != nil {
@@ -280,6 +307,8 @@ func buildArgs(cmd string) []string {
 			"--cache-from", "type=local,src="+cacheDir,
 			"--cache-to", "type=local,dest="+cacheDir+",mode=max",
 		)
+	default:
+		ui.Warnf("BuildKit is not available; the image build may fail (install docker-buildx, or buildkitd for nerdctl)")
 	}
 	return args
 }
@@ -190,7 +190,7 @@ func BuildCore(ctx context.Context, rt container.Runtime, agentName string, forc
 		return fmt.Errorf("failed to append labels to Dockerfile: %w", err)
 	}
 
-	args := buildArgs(cmd)
+	args := buildArgs(rt.Capabilities())
 	args = append(args,
 		"-t", imageName,
 		"-f", dockerfilePath,
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	proj "github.com/cloud-exit/exitbox/internal/project"
 )
 
 func TestFormatDuration(t *testing.T) {
@@ -33,7 +35,7 @@ func TestFormatDuration(t *testing.T) {
 }
 
 func TestBuildArgs_Docker(t *testing.T) {
-	args := buildArgs("docker")
+	args := buildArgs(container.Capabilities{BuildKit: true})
 	if len(args) < 5 {
 		t.Fatalf("buildArgs(docker) = %v, want at least 5 args (progress + cache-from + src + cache-to + dest)", args)
 	}
@@ -55,12 +57,18 @@ func TestBuildArgs_Docker(t *testing.T) {
 }
 
 func TestBuildArgs_Podman(t *testing.T) {
-	args := buildArgs("podman")
+	args := buildArgs(container.Capabilities{Layers: true, BuildKit: true})
 	if len(args) != 2 || args[0] != "--layers" || args[1] != "--pull=newer" {
 		t.Errorf("buildArgs(podman) = %v, want [--layers --pull=newer]", args)
 	}
 }
 
+func TestBuildArgs_NoBuildKit(t *testing.T) {
+	if args := buildArgs(container.Capabilities{}); len(args) != 0 {
+		t.Errorf("buildArgs without BuildKit = %v, want none", args)
+	}
+}
+
 func TestAppendToFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "test.txt")
@@ -243,6 +251,39 @@ func TestWorkspaceHash_IncludesSessionTools(t *testing.T) {
 	}
 }
 
+func TestWorkspaceHash_IncludesProjectPackages(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+
+	h1 := WorkspaceHash(cfg, dir, "")
+
+	if err := os.MkdirAll(filepath.Join(dir, ".exitbox"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.ProjectConfigFile(dir), []byte("packages: [postgresql-client]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if WorkspaceHash(cfg, dir, "") != h1 {
+		t.Error("unapproved project packages should not change WorkspaceHash")
+	}
+	pc, err := config.LoadProjectConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proj.Trust(dir, pc); err != nil {
+		t.Fatal(err)
+	}
+	h2 := WorkspaceHash(cfg, dir, "")
+
+	if h1 == h2 {
+		t.Error("WorkspaceHash should differ when the project config adds packages")
+	}
+}
+
 func TestIsReleaseVersion(t *testing.T) {
 	tests := []struct {
 		version string
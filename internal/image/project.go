@@ -26,6 +26,7 @@ import (
 
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/network"
 	"github.com/cloud-exit/exitbox/internal/profile"
 	proj "github.com/cloud-exit/exitbox/internal/project"
 	"github.com/cloud-exit/exitbox/internal/ui"
@@ -44,7 +45,17 @@ func WorkspaceHash(cfg *config.Config, projectDir string, overrideName string) s
 		parts = append(parts, active.Workspace.Development...)
 		parts = append(parts, active.Workspace.Packages...)
 	}
+	if pc, _, err := proj.LoadConfig(projectDir); err == nil && len(pc.Development)+len(pc.Packages) > 0 {
+		parts = append(parts, "project")
+		parts = append(parts, pc.Development...)
+		parts = append(parts, pc.Packages...)
+	}
 	parts = append(parts, SessionTools...)
+	// Images trust the TLS inspection CA only while inspection is enabled.
+	if ca, err := network.InspectionCA(); err == nil && ca != nil {
+		caHash := sha256.Sum256(ca)
+		parts = append(parts, fmt.Sprintf("tls-ca:%x", caHash[:8]))
+	}
 	h := sha256.Sum256([]byte(strings.Join(parts, ",")))
 	return fmt.Sprintf("%x", h[:8])
 }
@@ -80,10 +91,17 @@ func BuildProject(ctx context.Context, rt container.Runtime, agentName, projectD
 	if err != nil {
 		ui.Warnf("Failed to resolve active workspace: %v", err)
 	}
+	// The project's .exitbox/config.yaml adds its own profiles and
+	// packages, once approved.
+	projectCfg, _, err := proj.LoadConfig(projectDir)
+	if err != nil {
+		return err
+	}
 	var developmentProfiles []string
 	if active != nil {
 		developmentProfiles = append(developmentProfiles, active.Workspace.Development...)
 	}
+	developmentProfiles = dedup(append(developmentProfiles, projectCfg.Development...))
 
 	ui.Infof("Building %s project image with %s...", agentName, cmd)
 
@@ -110,11 +128,12 @@ func BuildProject(ctx context.Context, rt container.Runtime, agentName, projectD
 	}
 
 	// Collect ALL Alpine packages into a single apk add call:
-	// workspace packages + profile packages + session tools.
+	// workspace and project packages + profile packages + session tools.
 	var allPkgs []string
 	if active != nil {
 		allPkgs = append(allPkgs, active.Workspace.Packages...)
 	}
+	allPkgs = append(allPkgs, projectCfg.Packages...)
 	allPkgs = append(allPkgs, profile.CollectPackages(developmentProfiles)...)
 	allPkgs = append(allPkgs, SessionTools...)
 	allPkgs = dedup(allPkgs)
@@ -132,6 +151,27 @@ func BuildProject(ctx context.Context, rt container.Runtime, agentName, projectD
 		}
 	}
 
+	// Trust the TLS inspection CA so the proxy can enforce HTTP rules on
+	// HTTPS. Runtimes with their own CA bundle are pointed at the system one.
+	caPEM, err := network.InspectionCA()
+	if err != nil {
+		return fmt.Errorf("failed to prepare TLS inspection CA: %w", err)
+	}
+	caFile := filepath.Join(buildCtx, "exitbox-ca.crt")
+	if caPEM != nil {
+		if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+			return fmt.Errorf("failed to write CA certificate: %w", err)
+		}
+		df.WriteString("COPY exitbox-ca.crt /usr/local/share/ca-certificates/exitbox-ca.crt\n")
+		df.WriteString("RUN update-ca-certificates\n")
+		df.WriteString("ENV NODE_EXTRA_CA_CERTS=/usr/local/share/ca-certificates/exitbox-ca.crt \\\n" +
+			"    SSL_CERT_FILE=/etc/ssl/certs/ca-certificates.crt \\\n" +
+			"    REQUESTS_CA_BUNDLE=/etc/ssl/certs/ca-certificates.crt \\\n" +
+			"    PIP_CERT=/etc/ssl/certs/ca-certificates.crt\n\n")
+	} else {
+		_ = os.Remove(caFile)
+	}
+
 	// Fix home dir ownership after root package installs
 	df.WriteString("RUN chown -R user:user /home/user\n\n")
 
@@ -142,7 +182,7 @@ func BuildProject(ctx context.Context, rt container.Runtime, agentName, projectD
 		return fmt.Errorf("failed to write Dockerfile: %w", err)
 	}
 
-	args := buildArgs(cmd)
+	args := buildArgs(rt.Capabilities())
 	if force {
 		args = append(args, "--no-cache")
 	}
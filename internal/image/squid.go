@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
@@ -45,7 +46,7 @@ func BuildSquid(ctx context.Context, rt container.Runtime, force bool) error {
 	if isReleaseVersion(Version) {
 		remoteRef := SquidImageRegistry + ":" + Version
 		if err := pullImage(rt, remoteRef, "Pulling Squid image..."); err == nil {
-			if err := container.TagImage(rt, remoteRef, imageName); err == nil {
+			if err := rt.TagImage(remoteRef, imageName); err == nil {
 				ui.Success("Squid image ready (from registry)")
 				return nil
 			}
@@ -72,9 +73,22 @@ func buildSquidFull(rt container.Runtime, cmd, imageName string) error {
 		return fmt.Errorf("failed to write Dockerfile: %w", err)
 	}
 
-	args := buildArgs(cmd)
+	// Pre-built resolver for the container's architecture. TARGETARCH is
+	// passed explicitly for builders that do not set it.
+	arch := "amd64"
+	dnsBin := static.ExitboxDNSAmd64
+	if runtime.GOARCH == "arm64" {
+		arch = "arm64"
+		dnsBin = static.ExitboxDNSArm64
+	}
+	if err := os.WriteFile(filepath.Join(buildCtx, "exitbox-dns-"+arch), dnsBin, 0755); err != nil {
+		return fmt.Errorf("failed to write exitbox-dns: %w", err)
+	}
+
+	args := buildArgs(rt.Capabilities())
 	args = append(args,
 		"--build-arg", fmt.Sprintf("EXITBOX_VERSION=%s", Version),
+		"--build-arg", "TARGETARCH="+arch,
 		"-t", imageName,
 		buildCtx,
 	)
@@ -110,7 +110,7 @@ func BuildTools(ctx context.Context, rt container.Runtime, agentName string, for
 		return fmt.Errorf("failed to write Dockerfile: %w", err)
 	}
 
-	args := buildArgs(cmd)
+	args := buildArgs(rt.Capabilities())
 	args = append(args,
 		"-t", imageName,
 		"-f", dockerfilePath,
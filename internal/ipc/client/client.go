@@ -0,0 +1,279 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package client talks to the host's IPC server from inside a container.
+// It is used by the static exitbox-allow, exitbox-vault and exitbox-git
+// binaries, so it depends on the standard library only.
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the newest protocol version the client speaks.
+const Version = 2
+
+// DefaultSocket is where the host's socket is mounted in the container.
+const DefaultSocket = "/run/exitbox/host.sock"
+
+// maxResponseSize matches the server's maximum message size.
+const maxResponseSize = 64 * 1024
+
+type request struct {
+	Type      string      `json:"type"`
+	ID        string      `json:"id"`
+	Payload   interface{} `json:"payload"`
+	Token     string      `json:"token,omitempty"`
+	TimeoutMS int64       `json:"timeout_ms,omitempty"`
+}
+
+type response struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type hello struct {
+	Version int    `json:"version"`
+	Error   string `json:"error,omitempty"`
+}
+
+type progress struct {
+	Message string `json:"message"`
+}
+
+// Conn is a connection to the host. On a version 2 host, calls share one
+// connection and may run concurrently; on an older host, each call dials
+// its own connection.
+type Conn struct {
+	socketPath string
+	token      string
+	version    int
+	conn       net.Conn
+
+	mu      sync.Mutex // guards writes, pending and err
+	pending map[string]chan response
+	err     error
+}
+
+// SocketPath returns the socket named by EXITBOX_IPC_SOCKET, or the
+// default one.
+func SocketPath() string {
+	if p := os.Getenv("EXITBOX_IPC_SOCKET"); p != "" {
+		return p
+	}
+	return DefaultSocket
+}
+
+// Dial connects to the host and agrees on a protocol version. Requests
+// carry the session token from the "token" file next to the socket.
+func Dial(socketPath string) (*Conn, error) {
+	token, err := os.ReadFile(filepath.Join(filepath.Dir(socketPath), "token"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot read the session token: %w", err)
+	}
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{socketPath: socketPath, token: strings.TrimSpace(string(token)), version: 1}
+	data, err := json.Marshal(request{Type: "hello", ID: randomID(), Payload: hello{Version: Version}, Token: c.token})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, maxResponseSize), maxResponseSize)
+	var resp response
+	var h hello
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &resp) != nil || json.Unmarshal(resp.Payload, &h) != nil ||
+		h.Error != "" || h.Version < 2 {
+		// A version 1 host answers "hello" as an unknown type and
+		// hangs up.
+		conn.Close()
+		return c, nil
+	}
+	c.version = h.Version
+	c.conn = conn
+	c.pending = make(map[string]chan response)
+	go c.read(scanner)
+	return c, nil
+}
+
+// Version returns the protocol version agreed with the host.
+func (c *Conn) Version() int {
+	return c.version
+}
+
+// Close closes the connection. Calls still waiting fail, and the host
+// cancels them.
+func (c *Conn) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// read delivers the host's messages to the calls waiting for them.
+func (c *Conn) read(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		var resp response
+		if json.Unmarshal(scanner.Bytes(), &resp) != nil {
+			continue
+		}
+		c.mu.Lock()
+		ch := c.pending[resp.ID]
+		c.mu.Unlock()
+		switch {
+		case ch == nil:
+		case resp.Type == "progress":
+			// Progress may be dropped, but never the slot the
+			// response needs.
+			if len(ch) < cap(ch)-1 {
+				ch <- resp
+			}
+		default:
+			ch <- resp
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = fmt.Errorf("connection to host closed")
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// Call sends a request and waits for its response payload. Progress
+// messages are passed to onProgress, which may be nil. If ctx ends first,
+// the host is told to cancel the request; a ctx deadline is passed on as
+// the request's timeout.
+func (c *Conn) Call(ctx context.Context, msgType string, payload interface{}, onProgress func(string)) (json.RawMessage, error) {
+	req := request{Type: msgType, ID: randomID(), Payload: payload, Token: c.token}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.TimeoutMS = max(time.Until(deadline).Milliseconds(), 1)
+	}
+	if c.version < 2 {
+		return c.callOnce(ctx, req)
+	}
+
+	ch := make(chan response, 4)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.pending[req.ID] = ch
+	err := c.write(req)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		c.mu.Unlock()
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case resp, ok := <-ch:
+			if !ok {
+				return nil, fmt.Errorf("connection to host closed")
+			}
+			if resp.Type == "progress" {
+				var p progress
+				if onProgress != nil && json.Unmarshal(resp.Payload, &p) == nil {
+					onProgress(p.Message)
+				}
+				continue
+			}
+			return resp.Payload, nil
+		case <-ctx.Done():
+			c.mu.Lock()
+			_ = c.write(request{Type: "cancel", ID: req.ID, Token: c.token})
+			c.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// write sends a message; c.mu must be held.
+func (c *Conn) write(req request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(append(data, '\n'))
+	return err
+}
+
+// callOnce makes a version 1 call on a connection of its own. Closing the
+// connection is the only way to cancel it.
+func (c *Conn) callOnce(ctx context.Context, req request) (json.RawMessage, error) {
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, maxResponseSize), maxResponseSize)
+	if !scanner.Scan() {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no response from host")
+	}
+	var resp response
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Payload, nil
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDialVersion1 checks the fallback for hosts that answer one request
+// per connection and do not know "hello".
+func TestDialVersion1(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "host.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			scanner := bufio.NewScanner(conn)
+			if scanner.Scan() {
+				if strings.Contains(scanner.Text(), `"type":"hello"`) {
+					_, _ = conn.Write([]byte(`{"type":"hello","id":"","payload":{"error":"unknown message type: hello"}}` + "\n"))
+				} else {
+					_, _ = conn.Write([]byte(`{"type":"echo","id":"","payload":{"ok":true}}` + "\n"))
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	c, err := Dial(socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+	if c.Version() != 1 {
+		t.Fatalf("version = %d, want 1", c.Version())
+	}
+	for i := 0; i < 2; i++ {
+		raw, err := c.Call(context.Background(), "echo", nil, nil)
+		if err != nil || string(raw) != `{"ok":true}` {
+			t.Errorf("call %d = %s, %v", i, raw, err)
+		}
+	}
+}
@@ -18,44 +18,76 @@ package ipc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/cloud-exit/exitbox/internal/audit"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/network"
 )
 
+// AllowScope is how long an approved domain stays allowed.
+type AllowScope string
+
+const (
+	// AllowDenied means the request was refused.
+	AllowDenied AllowScope = ""
+	// AllowOnce allows the domain for OnceWindow, long enough for a
+	// single command to connect.
+	AllowOnce AllowScope = "once"
+	// AllowSession allows the domain until the container exits.
+	AllowSession AllowScope = "session"
+	// AllowProject persists the domain in the project's allowlist.
+	AllowProject AllowScope = "project"
+	// AllowWorkspace persists the domain in the workspace's allowlist.
+	AllowWorkspace AllowScope = "workspace"
+)
+
+// OnceWindow is how long an AllowOnce approval lasts.
+const OnceWindow = time.Minute
+
 // AllowDomainHandlerConfig holds dependencies for the allow_domain handler.
 type AllowDomainHandlerConfig struct {
 	Runtime       container.Runtime
 	ContainerName string
+	// ProjectDir and WorkspaceName select the allowlists durable approvals
+	// are written to. Without a workspace the workspace choice is not offered.
+	ProjectDir    string
+	WorkspaceName string
+	// Rules decide requests before PromptFunc is asked.
+	Rules *Rules
 	// PromptFunc overrides the tmux popup prompt for testing.
-	PromptFunc func(domain string) (bool, error)
+	PromptFunc func(domain string) (AllowScope, error)
 	// ReloadFunc overrides domain reload for testing.
-	ReloadFunc func(domain string) error
+	ReloadFunc func(domain string, scope AllowScope) error
 }
 
 // NewAllowDomainHandler returns a HandlerFunc that validates a domain,
-// prompts the user via a tmux popup in the container, and hot-reloads
-// Squid on approval.
+// prompts the user via a tmux popup in the container for how long to allow
+// it, and hot-reloads Squid on approval.
 func NewAllowDomainHandler(cfg AllowDomainHandlerConfig) HandlerFunc {
-	promptFn := cfg.PromptFunc
-	if promptFn == nil {
-		promptFn = func(domain string) (bool, error) {
-			return promptViaTmuxPopup(cfg.Runtime, cfg.ContainerName, domain)
-		}
-	}
-
 	reloadFn := cfg.ReloadFunc
 	if reloadFn == nil {
-		reloadFn = func(domain string) error {
-			return network.AddSessionURLAndReload(cfg.Runtime, cfg.ContainerName, domain)
+		reloadFn = func(domain string, scope AllowScope) error {
+			return applyAllowScope(cfg, domain, scope)
 		}
 	}
+	// Requests are handled concurrently; firewall updates are not.
+	var reloadMu sync.Mutex
 
 	return func(req *Request) (interface{}, error) {
+		promptFn := cfg.PromptFunc
+		if promptFn == nil {
+			promptFn = func(domain string) (AllowScope, error) {
+				return promptViaTmuxPopup(req.Context(), cfg.Runtime, cfg.ContainerName, domain, cfg.WorkspaceName)
+			}
+		}
+
 		var payload AllowDomainRequest
 		if err := json.Unmarshal(req.Payload, &payload); err != nil {
 			return AllowDomainResponse{Error: "invalid payload"}, nil
@@ -72,64 +104,161 @@ func NewAllowDomainHandler(cfg AllowDomainHandlerConfig) HandlerFunc {
 			return AllowDomainResponse{Error: fmt.Sprintf("invalid domain: %v", err)}, nil
 		}
 
-		approved, err := promptFn(domain)
-		if err != nil {
-			return AllowDomainResponse{Error: fmt.Sprintf("prompt failed: %v", err)}, nil
+		subject := strings.ToLower(strings.TrimSuffix(domain, "."))
+		verdict := cfg.Rules.Decide("allow_domain", subject)
+		var scope AllowScope
+		switch verdict.Action {
+		case ActionApprove:
+			scope = verdict.Scope
+		case ActionDeny:
+			scope = AllowDenied
+		default:
+			scope, err = prompt(req, func() (AllowScope, error) { return promptFn(domain) })
+			if err != nil {
+				cfg.Rules.Record(audit.Entry{Type: "allow_domain", Subject: subject, Decision: audit.Failed, Detail: err.Error()}, verdict)
+				return AllowDomainResponse{Error: fmt.Sprintf("prompt failed: %v", err)}, nil
+			}
 		}
-
-		if !approved {
+		entry := audit.Entry{Type: "allow_domain", Subject: subject, Decision: audit.Denied}
+		if scope == AllowDenied {
+			cfg.Rules.Record(entry, verdict)
 			return AllowDomainResponse{Approved: false}, nil
 		}
 
 		// Use the normalized form for Squid (may have leading dot for hostnames).
-		if err := reloadFn(normalized); err != nil {
+		req.Progress("updating the firewall")
+		reloadMu.Lock()
+		err = reloadFn(normalized, scope)
+		reloadMu.Unlock()
+		entry.Decision, entry.Scope = audit.Approved, string(scope)
+		if err != nil {
+			entry.Decision, entry.Detail = audit.Failed, "updating the firewall: "+err.Error()
+		}
+		cfg.Rules.Record(entry, verdict)
+		if err != nil {
 			return AllowDomainResponse{Error: fmt.Sprintf("failed to update firewall: %v", err)}, nil
 		}
 
-		return AllowDomainResponse{Approved: true}, nil
+		return AllowDomainResponse{Approved: true, Scope: string(scope)}, nil
+	}
+}
+
+// applyAllowScope records an approved domain at the chosen scope and
+// hot-reloads Squid.
+func applyAllowScope(cfg AllowDomainHandlerConfig, domain string, scope AllowScope) error {
+	switch scope {
+	case AllowOnce:
+		return network.AddTemporaryURLAndReload(cfg.Runtime, cfg.ContainerName, domain, OnceWindow)
+	case AllowSession:
+		return network.AddSessionURLAndReload(cfg.Runtime, cfg.ContainerName, domain)
+	}
+	if err := saveAllowScope(cfg, domain, scope); err != nil {
+		return err
+	}
+	return network.ReloadSquid(cfg.Runtime)
+}
+
+// saveAllowScope persists a durable approval in the project's or the
+// workspace's allowlist. Both are host-only files, so agents cannot fake
+// approvals that later sessions would honour.
+func saveAllowScope(cfg AllowDomainHandlerConfig, domain string, scope AllowScope) error {
+	switch scope {
+	case AllowProject:
+		return network.AddProjectDomain(cfg.ProjectDir, domain)
+	case AllowWorkspace:
+		return network.AddWorkspaceDomain(cfg.WorkspaceName, domain)
 	}
+	return fmt.Errorf("unknown scope %q", scope)
 }
 
+// Exit codes of the allow_domain popup script, one per scope.
+const (
+	popupExitOnce      = 10
+	popupExitSession   = 11
+	popupExitProject   = 12
+	popupExitWorkspace = 13
+)
+
 // promptViaTmuxPopup shows a tmux display-popup inside the agent container.
 // The host execs into the container's tmux to present an interactive popup
 // overlaying the agent session. This avoids competing with tmux for /dev/tty.
 //
-// The popup script reads a line and exits 0 on "y"/"yes", 1 otherwise.
-// tmux display-popup -E returns the script's exit code.
-func promptViaTmuxPopup(rt container.Runtime, containerName, domain string) (bool, error) {
-	cmd := container.Cmd(rt)
-
+// The popup script reads a choice and exits with one of the popupExit codes,
+// or 1 when the request is denied. tmux display-popup -E returns the
+// script's exit code. "y"/"yes" is accepted as "this session".
+func promptViaTmuxPopup(ctx context.Context, rt container.Runtime, containerName, domain, workspace string) (AllowScope, error) {
 	// Sanitize domain for shell embedding — only keep safe chars.
 	safeDomain := sanitizeForShell(domain)
 
+	options := `  1) Once (next minute)\n  2) This session\n  3) Always for this project\n`
+	cases := fmt.Sprintf(`1) exit %d;; 2|y|yes) exit %d;; 3) exit %d;; `,
+		popupExitOnce, popupExitSession, popupExitProject)
+	height := "14"
+	if workspace != "" {
+		options += `  4) Always for workspace ` + sanitizeForShell(workspace) + `\n`
+		cases += fmt.Sprintf(`4) exit %d;; `, popupExitWorkspace)
+		height = "15"
+	}
+
 	// Shell script runs inside the popup. Uses `read` (not `read -n1`)
-	// so the user types y + Enter, which works in all terminals.
+	// so the user types a choice + Enter, which works in all terminals.
 	script := `printf '\n  \033[1;33m[ExitBox]\033[0m Allow domain access?\n\n  Domain: \033[1m` +
 		safeDomain +
-		`\033[0m\n\n  [y/N]: '; read ans; [ "$ans" = "y" ] || [ "$ans" = "yes" ]`
-
-	c := exec.Command(cmd, "exec", containerName,
-		"tmux", "display-popup", "-E", "-w", "50", "-h", "8",
-		"sh", "-c", script,
-	)
+		`\033[0m\n\n` + options + `  n) Deny\n\n  Choice [n]: '; read ans; case "$ans" in ` +
+		cases + `esac; exit 1`
 
 	var stderr bytes.Buffer
-	c.Stderr = &stderr
-	err := c.Run()
+	err := runPopup(ctx, rt, containerName, &stderr, "56", height, script)
 
 	if err == nil {
-		return true, nil // exit 0 = approved
+		return AllowDenied, nil
+	}
+	if ctx.Err() != nil {
+		return AllowDenied, requestError(ctx)
+	}
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return AllowDenied, fmt.Errorf("popup exec failed: %w", err)
+	}
+	switch exitErr.ExitCode() {
+	case popupExitOnce:
+		return AllowOnce, nil
+	case popupExitSession:
+		return AllowSession, nil
+	case popupExitProject:
+		return AllowProject, nil
+	case popupExitWorkspace:
+		return AllowWorkspace, nil
 	}
 
 	// If stderr is empty, the popup ran and the user denied or dismissed it.
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		if stderr.Len() == 0 {
-			return false, nil
-		}
-		return false, fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
+	if stderr.Len() == 0 {
+		return AllowDenied, nil
 	}
+	return AllowDenied, fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
+}
 
-	return false, fmt.Errorf("popup exec failed: %w", err)
+// runPopup runs script in a tmux popup in the container. args are the
+// script's positional parameters, for text that should not be embedded in
+// it. If ctx ends first, the popup is closed so it does not wait for an
+// answer no one will read.
+func runPopup(ctx context.Context, rt container.Runtime, containerName string, stderr *bytes.Buffer, width, height, script string, args ...string) error {
+	cmd := container.Cmd(rt)
+	argv := []string{"exec", containerName,
+		"tmux", "display-popup", "-E", "-w", width, "-h", height,
+		"sh", "-c", script,
+	}
+	if len(args) > 0 {
+		argv = append(append(argv, "sh"), args...)
+	}
+	c := exec.CommandContext(ctx, cmd, argv...)
+	c.Stderr = stderr
+	err := c.Run()
+	if ctx.Err() != nil {
+		_ = exec.Command(cmd, "exec", containerName, "tmux", "display-popup", "-C").Run()
+	}
+	return err
 }
 
 // sanitizeForShell strips any characters that aren't safe for embedding
@@ -4,7 +4,11 @@ import (
 	"bufio"
 	"encoding/json"
 	"net"
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
 )
 
 func TestAllowDomainHandlerApproved(t *testing.T) {
@@ -15,10 +19,10 @@ func TestAllowDomainHandlerApproved(t *testing.T) {
 	defer srv.Stop()
 
 	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
-		PromptFunc: func(domain string) (bool, error) {
-			return true, nil
+		PromptFunc: func(domain string) (AllowScope, error) {
+			return AllowSession, nil
 		},
-		ReloadFunc: func(domain string) error {
+		ReloadFunc: func(domain string, scope AllowScope) error {
 			return nil
 		},
 	}))
@@ -28,11 +32,90 @@ func TestAllowDomainHandlerApproved(t *testing.T) {
 	if !resp.Approved {
 		t.Error("expected approved=true")
 	}
+	if resp.Scope != "session" {
+		t.Errorf("scope = %q, want session", resp.Scope)
+	}
 	if resp.Error != "" {
 		t.Errorf("unexpected error: %s", resp.Error)
 	}
 }
 
+func TestAllowDomainHandlerScopePassedToReload(t *testing.T) {
+	for _, scope := range []AllowScope{AllowOnce, AllowSession, AllowProject, AllowWorkspace} {
+		srv, err := NewServer()
+		if err != nil {
+			t.Fatalf("NewServer: %v", err)
+		}
+
+		var got AllowScope
+		srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
+			PromptFunc: func(domain string) (AllowScope, error) {
+				return scope, nil
+			},
+			ReloadFunc: func(domain string, s AllowScope) error {
+				got = s
+				return nil
+			},
+		}))
+		srv.Start()
+
+		resp := sendAllowDomain(t, srv, "example.com")
+		srv.Stop()
+		if !resp.Approved || got != scope || resp.Scope != string(scope) {
+			t.Errorf("scope %q: approved=%v reload scope=%q response scope=%q", scope, resp.Approved, got, resp.Scope)
+		}
+	}
+}
+
+func TestAllowDomainHandlerWorkspaceApprovalIsHostOnly(t *testing.T) {
+	origHome, origCache := config.Home, config.Cache
+	config.Home, config.Cache = t.TempDir(), t.TempDir()
+	defer func() { config.Home, config.Cache = origHome, origCache }()
+	cfg := config.DefaultConfig()
+	cfg.Workspaces.Items = []config.Workspace{{Name: "work"}}
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	handlerCfg := AllowDomainHandlerConfig{
+		WorkspaceName: "work",
+		PromptFunc: func(domain string) (AllowScope, error) {
+			return AllowWorkspace, nil
+		},
+	}
+	handlerCfg.ReloadFunc = func(domain string, scope AllowScope) error {
+		return saveAllowScope(handlerCfg, domain, scope)
+	}
+	srv.Handle("allow_domain", NewAllowDomainHandler(handlerCfg))
+	srv.Start()
+
+	if resp := sendAllowDomain(t, srv, "api.example.com"); !resp.Approved || resp.Error != "" {
+		t.Fatalf("response: %+v", resp)
+	}
+	if data, _ := os.ReadFile(config.ConfigFile()); strings.Contains(string(data), "api.example.com") {
+		t.Errorf("approval saved to config.yaml, which containers can write:\n%s", data)
+	}
+
+	// An approval an agent writes into config.yaml is not honoured.
+	tampered := "version: 1\nworkspaces:\n  items:\n    - name: work\n      allowlist:\n        custom: [evil.example]\n"
+	if err := os.WriteFile(config.ConfigFile(), []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := loaded.Workspaces.Items[0].Allowlist; w == nil || strings.Join(w.AllDomains(), ",") != "api.example.com" {
+		t.Errorf("workspace allowlist = %+v", w)
+	}
+}
+
 func TestAllowDomainHandlerDenied(t *testing.T) {
 	srv, err := NewServer()
 	if err != nil {
@@ -41,10 +124,10 @@ func TestAllowDomainHandlerDenied(t *testing.T) {
 	defer srv.Stop()
 
 	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
-		PromptFunc: func(domain string) (bool, error) {
-			return false, nil
+		PromptFunc: func(domain string) (AllowScope, error) {
+			return AllowDenied, nil
 		},
-		ReloadFunc: func(domain string) error {
+		ReloadFunc: func(domain string, scope AllowScope) error {
 			t.Error("reload should not be called when denied")
 			return nil
 		},
@@ -65,11 +148,11 @@ func TestAllowDomainHandlerInvalidDomain(t *testing.T) {
 	defer srv.Stop()
 
 	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
-		PromptFunc: func(domain string) (bool, error) {
+		PromptFunc: func(domain string) (AllowScope, error) {
 			t.Error("prompt should not be called for invalid domain")
-			return false, nil
+			return AllowDenied, nil
 		},
-		ReloadFunc: func(domain string) error {
+		ReloadFunc: func(domain string, scope AllowScope) error {
 			t.Error("reload should not be called for invalid domain")
 			return nil
 		},
@@ -93,11 +176,11 @@ func TestAllowDomainHandlerEmptyDomain(t *testing.T) {
 	defer srv.Stop()
 
 	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
-		PromptFunc: func(domain string) (bool, error) {
+		PromptFunc: func(domain string) (AllowScope, error) {
 			t.Error("prompt should not be called for empty domain")
-			return false, nil
+			return AllowDenied, nil
 		},
-		ReloadFunc: func(domain string) error {
+		ReloadFunc: func(domain string, scope AllowScope) error {
 			t.Error("reload should not be called for empty domain")
 			return nil
 		},
@@ -127,6 +210,7 @@ func sendAllowDomain(t *testing.T, srv *Server, domain string) AllowDomainRespon
 		Type:    "allow_domain",
 		ID:      "test",
 		Payload: payload,
+		Token:   srv.token,
 	}
 	data, err := json.Marshal(req)
 	if err != nil {
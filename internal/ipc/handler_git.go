@@ -0,0 +1,645 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// Git requests let an agent push a branch and open a pull request with
+// the host user's credentials, which never enter the container.
+//
+// The repository is agent-writable, so the host never runs git in it:
+// its config and hooks could run commands on the host or redirect the
+// push. Instead the branch is fetched into a temporary repository the
+// host owns, and pushed from there to the remote URL the user approved.
+// That URL must be listed in git_remotes in a host-only approvals.yaml,
+// and nothing contacts it before the request is approved.
+
+// GitHandlerConfig holds dependencies for the git_push and open_pr
+// handlers.
+type GitHandlerConfig struct {
+	Runtime       container.Runtime
+	ContainerName string
+	// RepoDir is the host directory mounted at /workspace.
+	RepoDir string
+	// Disabled, when set, is why git requests are refused.
+	Disabled string
+	// Rules decide requests before PromptFunc is asked.
+	Rules *Rules
+	// PromptFunc overrides the tmux popup approval prompt for testing.
+	PromptFunc func(title string, lines []string) (bool, error)
+	// CreatePRFunc overrides opening the pull request with gh for testing.
+	CreatePRFunc func(ctx context.Context, repo string, pr OpenPRRequest) (string, error)
+
+	// allowLocalRemotes lets tests push to a repository on disk.
+	allowLocalRemotes bool
+}
+
+// maxPopupCommits is how many commits the approval popup lists.
+const maxPopupCommits = 10
+
+var remoteNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// NewGitPushHandler returns a HandlerFunc for "git_push" requests. The
+// popup shows the commits as /workspace last saw the remote; the host
+// contacts the remote only once the push is approved, and refuses it if
+// the remote turns out to need commits the popup did not show.
+func NewGitPushHandler(cfg GitHandlerConfig) HandlerFunc {
+	return func(req *Request) (interface{}, error) {
+		if cfg.Disabled != "" {
+			return GitPushResponse{Error: cfg.Disabled}, nil
+		}
+		var payload GitPushRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return GitPushResponse{Error: "invalid payload"}, nil
+		}
+		if err := checkBranch(req.Context(), payload.Branch); err != nil {
+			return GitPushResponse{Error: err.Error()}, nil
+		}
+		remote, err := resolveRemote(req.Context(), cfg, payload.Remote)
+		if err != nil {
+			return GitPushResponse{Error: err.Error()}, nil
+		}
+
+		req.Progress("reading the branch")
+		push, err := prepareGitPush(req.Context(), cfg.RepoDir, remote, payload.Branch)
+		if err != nil {
+			return GitPushResponse{Error: err.Error()}, nil
+		}
+		defer push.cleanup()
+
+		title := "Push a branch with your git credentials?"
+		approved, err := cfg.approve(req, "git_push", push.branch, remote, title, push.summary())
+		if err != nil {
+			return GitPushResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
+		}
+		if !approved {
+			return GitPushResponse{Approved: false}, nil
+		}
+
+		req.Progress("reading " + remote.url)
+		if err := push.checkRemote(req.Context()); err != nil {
+			return GitPushResponse{Approved: true, Error: err.Error()}, nil
+		}
+		req.Progress("pushing " + push.branch)
+		if err := push.push(req.Context()); err != nil {
+			return GitPushResponse{Approved: true, Error: err.Error()}, nil
+		}
+		return GitPushResponse{Approved: true, Range: push.rangeLabel(), Commits: push.count}, nil
+	}
+}
+
+// NewOpenPRHandler returns a HandlerFunc for "open_pr" requests. The
+// branch must already be pushed; pull requests are opened with the GitHub
+// CLI (gh) on the host.
+func NewOpenPRHandler(cfg GitHandlerConfig) HandlerFunc {
+	createPR := cfg.CreatePRFunc
+	if createPR == nil {
+		createPR = createPRWithGH
+	}
+
+	return func(req *Request) (interface{}, error) {
+		if cfg.Disabled != "" {
+			return OpenPRResponse{Error: cfg.Disabled}, nil
+		}
+		var payload OpenPRRequest
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return OpenPRResponse{Error: "invalid payload"}, nil
+		}
+		payload.Title = strings.TrimSpace(payload.Title)
+		if payload.Title == "" {
+			return OpenPRResponse{Error: "empty title"}, nil
+		}
+		if err := checkBranch(req.Context(), payload.Branch); err != nil {
+			return OpenPRResponse{Error: err.Error()}, nil
+		}
+		if payload.Base != "" {
+			if err := checkBranch(req.Context(), payload.Base); err != nil {
+				return OpenPRResponse{Error: err.Error()}, nil
+			}
+		}
+		remote, err := resolveRemote(req.Context(), cfg, payload.Remote)
+		if err != nil {
+			return OpenPRResponse{Error: err.Error()}, nil
+		}
+		repo, ok := githubRepo(remote.url)
+		if !ok {
+			return OpenPRResponse{Error: fmt.Sprintf("cannot open a pull request for %s: only GitHub remotes are supported", remote.url)}, nil
+		}
+
+		base := payload.Base
+		if base == "" {
+			base = "(default branch)"
+		}
+		lines := []string{
+			"Remote:     " + remote.name + " " + remote.url,
+			"Host:       " + remote.host,
+			"Repository: " + repo,
+			"Branch:     " + payload.Branch + " -> " + base,
+			"Title:      " + payload.Title,
+		}
+		if payload.Draft {
+			lines = append(lines, "Draft:      yes")
+		}
+		if body := strings.TrimSpace(payload.Body); body != "" {
+			lines = append(lines, "", "Description:")
+			bodyLines := strings.Split(body, "\n")
+			for i, l := range bodyLines {
+				if i == maxPopupCommits {
+					lines = append(lines, fmt.Sprintf("  ... %d more line(s)", len(bodyLines)-i))
+					break
+				}
+				lines = append(lines, "  "+l)
+			}
+		}
+
+		approved, err := cfg.approve(req, "open_pr", payload.Branch, remote, "Open a pull request with your GitHub credentials?", lines)
+		if err != nil {
+			return OpenPRResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
+		}
+		if !approved {
+			return OpenPRResponse{Approved: false}, nil
+		}
+
+		req.Progress("opening the pull request")
+		prURL, err := createPR(req.Context(), repo, payload)
+		if err != nil {
+			return OpenPRResponse{Approved: true, Error: err.Error()}, nil
+		}
+		return OpenPRResponse{Approved: true, URL: prURL}, nil
+	}
+}
+
+// approve decides a git request by the rules, falling back to the popup
+// when none decides it, and records the decision.
+func (cfg GitHandlerConfig) approve(req *Request, kind, subject string, remote gitRemote, title string, lines []string) (bool, error) {
+	promptFn := cfg.PromptFunc
+	if promptFn == nil {
+		promptFn = func(title string, lines []string) (bool, error) {
+			return promptGitApproval(req.Context(), cfg.Runtime, cfg.ContainerName, title, lines)
+		}
+	}
+
+	verdict := cfg.Rules.DecideRemote(kind, subject, remote.id)
+	entry := audit.Entry{Type: kind, Subject: subject, Remote: remote.id}
+	approved := verdict.Action == ActionApprove
+	if verdict.Action == ActionPrompt {
+		var err error
+		approved, err = prompt(req, func() (bool, error) { return promptFn(title, lines) })
+		if err != nil {
+			entry.Decision, entry.Detail = audit.Failed, err.Error()
+			cfg.Rules.Record(entry, verdict)
+			return false, err
+		}
+	}
+	entry.Decision = audit.Denied
+	if approved {
+		entry.Decision = audit.Approved
+	}
+	cfg.Rules.Record(entry, verdict)
+	return approved, nil
+}
+
+// gitRemote is a remote of the workspace's repository that git requests
+// may use.
+type gitRemote struct {
+	name string
+	url  string
+	host string
+	// id is the remote as host/path, which git_remotes and rules match.
+	id string
+}
+
+// resolveRemote looks up a remote of the workspace's repository. Reading
+// config runs nothing from it, but the repository is agent-writable, so
+// the URL it names must be a network URL listed in git_remotes, which
+// only the host can change.
+func resolveRemote(ctx context.Context, cfg GitHandlerConfig, name string) (gitRemote, error) {
+	r := gitRemote{name: remoteOrDefault(name)}
+	url, err := remoteURL(ctx, cfg, name)
+	if err != nil {
+		return r, err
+	}
+	r.url = url
+	var ok bool
+	if cfg.allowLocalRemotes && filepath.IsAbs(url) {
+		r.host, r.id, ok = "localhost", "localhost"+strings.ToLower(strings.TrimSuffix(url, ".git")), true
+	} else {
+		r.host, r.id, ok = remoteID(url)
+	}
+	if !ok {
+		return r, fmt.Errorf("remote %s has unsupported URL %q", r.name, url)
+	}
+	if !cfg.Rules.RemoteAllowed(r.id) {
+		return r, fmt.Errorf("remote %s (%s) is not an allowed git remote; add it to git_remotes in the project's or workspace's approvals.yaml on the host", r.name, r.id)
+	}
+	return r, nil
+}
+
+// remoteID returns the host of a network remote URL and the remote as
+// host/path, lower-cased and without ".git".
+func remoteID(url string) (host, id string, ok bool) {
+	if !networkRemote(url) {
+		return "", "", false
+	}
+	rest, found := strings.CutPrefix(url, "https://")
+	if !found {
+		rest, found = strings.CutPrefix(url, "ssh://")
+	}
+	if !found {
+		// scp-like user@host:path
+		rest = strings.Replace(url, ":", "/", 1)
+	}
+	host, p, found := strings.Cut(rest, "/")
+	if !found {
+		return "", "", false
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	host, _, _ = strings.Cut(strings.ToLower(host), ":")
+	p = strings.TrimSuffix(strings.TrimSuffix(p, "/"), ".git")
+	if host == "" || p == "" {
+		return "", "", false
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", "", false
+		}
+	}
+	return host, host + "/" + strings.ToLower(p), true
+}
+
+// gitPush is a branch ready to be pushed from a temporary repository.
+type gitPush struct {
+	dir    string // the temporary repository
+	remote gitRemote
+	branch string
+	head   string
+	// base is the commit the remote branch is at, as /workspace last saw
+	// it until checkRemote reads the remote itself. It is empty for a
+	// new branch until then.
+	base    string
+	newRef  bool
+	count   int
+	commits []string
+}
+
+// prepareGitPush fetches the branch from the workspace into a temporary
+// repository, along with the workspace's record of the remote, to list
+// the commits the push would add. It does not contact the remote.
+func prepareGitPush(ctx context.Context, repoDir string, remote gitRemote, branch string) (*gitPush, error) {
+	dir, err := os.MkdirTemp("", "exitbox-git-")
+	if err != nil {
+		return nil, err
+	}
+	p := &gitPush{dir: dir, remote: remote, branch: branch}
+	if _, err := runGit(ctx, "", "init", "-q", "--bare", dir); err != nil {
+		p.cleanup()
+		return nil, err
+	}
+	if err := p.load(ctx, repoDir); err != nil {
+		p.cleanup()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *gitPush) load(ctx context.Context, repoDir string) error {
+	ref := "refs/heads/" + p.branch
+	if _, err := runGit(ctx, p.dir, "fetch", "-q", "--no-tags", repoDir, "+"+ref+":"+ref); err != nil {
+		return fmt.Errorf("branch %s not found in /workspace", p.branch)
+	}
+	head, err := runGit(ctx, p.dir, "rev-parse", ref)
+	if err != nil {
+		return err
+	}
+	p.head = head
+
+	// The workspace's remote-tracking branches, under refs/remotes/seen.
+	// They come from the agent, so they only shape the popup.
+	src := "refs/remotes/" + p.remote.name + "/*"
+	if _, err := runGit(ctx, p.dir, "fetch", "-q", "--no-tags", repoDir, "+"+src+":refs/remotes/seen/*"); err != nil {
+		return err
+	}
+	if base, err := runGit(ctx, p.dir, "rev-parse", "--verify", "-q", "refs/remotes/seen/"+p.branch); err == nil && base != "" {
+		if _, err := runGit(ctx, p.dir, "merge-base", "--is-ancestor", base, ref); err != nil {
+			return fmt.Errorf("%s on %s has commits the branch in /workspace lacks; merge or rebase first", p.branch, p.remote.name)
+		}
+		p.base = base
+	} else {
+		p.newRef = true
+	}
+
+	count, err := runGit(ctx, p.dir, "rev-list", "--count", ref, "--not", "--remotes=seen")
+	if err != nil {
+		return err
+	}
+	if p.count, err = strconv.Atoi(count); err != nil {
+		return err
+	}
+	if p.count == 0 && !p.newRef {
+		return fmt.Errorf("%s on %s is up to date", p.branch, p.remote.name)
+	}
+	log, err := runGit(ctx, p.dir, "log", "--format=%h %s", "-n", fmt.Sprint(maxPopupCommits), ref, "--not", "--remotes=seen")
+	if err != nil {
+		return err
+	}
+	if log != "" {
+		p.commits = strings.Split(log, "\n")
+	}
+	return nil
+}
+
+// checkRemote reads what the remote has, now that the push is approved,
+// and refuses a push that would add commits the popup did not list:
+// those not on any of the workspace's tracking branches.
+func (p *gitPush) checkRemote(ctx context.Context) error {
+	ref := "refs/heads/" + p.branch
+	url := p.remote.url
+	remoteHead, err := runGit(ctx, p.dir, "ls-remote", url, ref)
+	if err != nil {
+		return fmt.Errorf("cannot reach %s: %v", url, err)
+	}
+	base := ""
+	if remoteHead != "" {
+		if _, err := runGit(ctx, p.dir, "fetch", "-q", "--no-tags", url, "+"+ref+":refs/remotes/target/branch"); err != nil {
+			return fmt.Errorf("cannot fetch %s from %s: %v", p.branch, url, err)
+		}
+		if _, err := runGit(ctx, p.dir, "merge-base", "--is-ancestor", "refs/remotes/target/branch", ref); err != nil {
+			return fmt.Errorf("%s on %s has commits the branch in /workspace lacks; merge or rebase first", p.branch, p.remote.name)
+		}
+		base, _ = runGit(ctx, p.dir, "rev-parse", "refs/remotes/target/branch")
+	} else if _, err := runGit(ctx, p.dir, "fetch", "-q", "--no-tags", url, "+HEAD:refs/remotes/target/HEAD"); err == nil {
+		base, _ = runGit(ctx, p.dir, "merge-base", "refs/remotes/target/HEAD", ref)
+	}
+
+	revs := []string{ref}
+	if base != "" {
+		revs = append(revs, "^"+base)
+	}
+	pushed, err := runGit(ctx, p.dir, append([]string{"rev-list", "--count"}, revs...)...)
+	if err != nil {
+		return err
+	}
+	shown, err := runGit(ctx, p.dir, append(append([]string{"rev-list", "--count"}, revs...), "--not", "--remotes=seen")...)
+	if err != nil {
+		return err
+	}
+	if pushed != shown {
+		return fmt.Errorf("%s is not where /workspace last saw it; the push would add commits the approval did not show. Fetch and try again", p.remote.name)
+	}
+	p.base = base
+	if p.count, err = strconv.Atoi(pushed); err != nil {
+		return err
+	}
+	if p.count == 0 && remoteHead != "" {
+		return fmt.Errorf("%s on %s is up to date", p.branch, p.remote.name)
+	}
+	return nil
+}
+
+// rangeLabel describes the pushed commits as a short range.
+func (p *gitPush) rangeLabel() string {
+	if p.base == "" {
+		return short(p.head)
+	}
+	return short(p.base) + ".." + short(p.head)
+}
+
+// summary is the text of the approval popup.
+func (p *gitPush) summary() []string {
+	target := p.branch
+	if p.newRef {
+		target += " (new branch)"
+	}
+	lines := []string{
+		"Remote:  " + p.remote.name + " " + p.remote.url,
+		"Host:    " + p.remote.host,
+		"Branch:  " + target,
+		fmt.Sprintf("Commits: %d (%s)", p.count, p.rangeLabel()),
+		"         not on " + p.remote.name + " as /workspace last fetched it;",
+		"         a push needing more is refused",
+		"",
+	}
+	for _, c := range p.commits {
+		lines = append(lines, "  "+c)
+	}
+	if p.count > len(p.commits) {
+		lines = append(lines, fmt.Sprintf("  ... %d more", p.count-len(p.commits)))
+	}
+	return lines
+}
+
+// push pushes the branch to the remote. It is never forced, so a remote
+// that moved on since checkRemote rejects it.
+func (p *gitPush) push(ctx context.Context) error {
+	ref := "refs/heads/" + p.branch
+	if _, err := runGit(ctx, p.dir, "push", "-q", p.remote.url, ref+":"+ref); err != nil {
+		return fmt.Errorf("push failed: %v", err)
+	}
+	return nil
+}
+
+func (p *gitPush) cleanup() {
+	_ = os.RemoveAll(p.dir)
+}
+
+func short(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func remoteOrDefault(remote string) string {
+	if remote == "" {
+		return "origin"
+	}
+	return remote
+}
+
+// checkBranch refuses branch names git would not accept, and ones that
+// could be taken for options.
+func checkBranch(ctx context.Context, branch string) error {
+	if branch == "" {
+		return fmt.Errorf("empty branch")
+	}
+	if strings.HasPrefix(branch, "-") || strings.HasPrefix(branch, "refs/") {
+		return fmt.Errorf("invalid branch name %q", branch)
+	}
+	if _, err := runGit(ctx, "", "check-ref-format", "refs/heads/"+branch); err != nil {
+		return fmt.Errorf("invalid branch name %q", branch)
+	}
+	return nil
+}
+
+// remoteURL returns the URL of a remote in the workspace's repository,
+// which must be a network URL.
+func remoteURL(ctx context.Context, cfg GitHandlerConfig, remote string) (string, error) {
+	remote = remoteOrDefault(remote)
+	if !remoteNamePattern.MatchString(remote) {
+		return "", fmt.Errorf("invalid remote name %q", remote)
+	}
+	url, err := runGit(ctx, cfg.RepoDir, "config", "--get", "remote."+remote+".url")
+	if err != nil || url == "" {
+		return "", fmt.Errorf("remote %s is not configured in /workspace", remote)
+	}
+	if !cfg.allowLocalRemotes && !networkRemote(url) {
+		return "", fmt.Errorf("remote %s has unsupported URL %q; use an https or ssh URL", remote, url)
+	}
+	return url, nil
+}
+
+var scpRemotePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*@[A-Za-z0-9][A-Za-z0-9.-]*:[A-Za-z0-9._~][A-Za-z0-9._~/-]*$`)
+
+// networkRemote reports whether url is an https or ssh remote. Local
+// paths, file:// and transports that run commands (ext::) are refused.
+func networkRemote(url string) bool {
+	if strings.ContainsAny(url, " \t\n") {
+		return false
+	}
+	for _, prefix := range []string{"https://", "ssh://"} {
+		if rest, ok := strings.CutPrefix(url, prefix); ok {
+			return rest != "" && !strings.HasPrefix(rest, "-")
+		}
+	}
+	return scpRemotePattern.MatchString(url)
+}
+
+var githubPattern = regexp.MustCompile(`^(?:https://(?:[^@/]+@)?github\.com/|ssh://git@github\.com/|git@github\.com:)([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+?)(?:\.git)?/?$`)
+
+// githubRepo returns the owner/name of a GitHub remote URL.
+func githubRepo(url string) (string, bool) {
+	m := githubPattern.FindStringSubmatch(url)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// runGit runs git on the host, in dir when set, and returns its trimmed
+// output. It never prompts on the host's terminal.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	c := exec.CommandContext(ctx, "git", args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", requestError(ctx)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// createPRWithGH opens a pull request with the host's GitHub CLI. It runs
+// outside the workspace so gh reads nothing from it.
+func createPRWithGH(ctx context.Context, repo string, pr OpenPRRequest) (string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return "", fmt.Errorf("opening pull requests needs the GitHub CLI (gh) on the host")
+	}
+	args := []string{"pr", "create", "--repo", repo, "--head", pr.Branch, "--title", pr.Title, "--body", pr.Body}
+	if pr.Base != "" {
+		args = append(args, "--base", pr.Base)
+	}
+	if pr.Draft {
+		args = append(args, "--draft")
+	}
+	c := exec.CommandContext(ctx, "gh", args...)
+	c.Dir = os.TempDir()
+	c.Env = append(os.Environ(), "GH_PROMPT_DISABLED=1")
+	var stdout, stderr bytes.Buffer
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", requestError(ctx)
+		}
+		return "", fmt.Errorf("gh pr create failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	out := strings.Fields(stdout.String())
+	if len(out) == 0 {
+		return "", nil
+	}
+	return out[len(out)-1], nil
+}
+
+// promptGitApproval shows a tmux popup asking to approve a git request.
+// The text is passed as arguments rather than embedded in the script, and
+// stripped of control characters, since it comes from the agent.
+func promptGitApproval(ctx context.Context, rt container.Runtime, containerName, title string, lines []string) (bool, error) {
+	args := []string{printable(title)}
+	for _, l := range lines {
+		args = append(args, printable(l))
+	}
+	script := `printf '\n  \033[1;33m[ExitBox]\033[0m %s\n\n' "$1"; shift; ` +
+		`for l in "$@"; do printf '  %s\n' "$l"; done; ` +
+		`printf '\n  [y/N]: '; read ans; [ "$ans" = "y" ] || [ "$ans" = "yes" ]`
+
+	var stderr bytes.Buffer
+	err := runPopup(ctx, rt, containerName, &stderr, "84", fmt.Sprint(len(lines)+8), script, args...)
+	if err == nil {
+		return true, nil
+	}
+	if ctx.Err() != nil {
+		return false, requestError(ctx)
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if stderr.Len() == 0 {
+			return false, nil
+		}
+		return false, fmt.Errorf("popup failed (exit %d): %s", exitErr.ExitCode(), stderr.String())
+	}
+	return false, fmt.Errorf("popup exec failed: %w", err)
+}
+
+// printable drops control characters, such as terminal escapes, and
+// shortens s to fit the popup.
+func printable(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if !unicode.IsPrint(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if r := []rune(s); len(r) > 76 {
+		s = string(r[:75]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,379 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitRepos creates a bare remote and a workspace clone of it with one
+// commit on main.
+func gitRepos(t *testing.T) (remote, workspace string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	remote = filepath.Join(t.TempDir(), "remote.git")
+	workspace = t.TempDir()
+	git(t, "", "init", "-q", "--bare", "-b", "main", remote)
+	git(t, workspace, "init", "-q", "-b", "main")
+	git(t, workspace, "remote", "add", "origin", remote)
+	commit(t, workspace, "initial")
+	git(t, workspace, "push", "-q", "origin", "main")
+	return remote, workspace
+}
+
+// allowRemotes returns rules that let git requests use remotes, given
+// as host/path or as a local path.
+func allowRemotes(remotes ...string) *Rules {
+	r := &Rules{now: time.Now}
+	for _, remote := range remotes {
+		if filepath.IsAbs(remote) {
+			remote = "localhost" + strings.TrimSuffix(remote, ".git")
+		}
+		r.remotes = append(r.remotes, strings.ToLower(remote))
+	}
+	return r
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runGit(context.Background(), dir, args...)
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return out
+}
+
+func commit(t *testing.T, dir, msg string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, msg+".txt"), []byte(msg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", msg)
+}
+
+func sendGitPush(t *testing.T, srv *Server, branch string) GitPushResponse {
+	t.Helper()
+	conn, err := net.Dial("unix", srv.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	payload, _ := json.Marshal(GitPushRequest{Branch: branch})
+	req, _ := json.Marshal(Request{Type: "git_push", ID: "1", Payload: payload, Token: srv.token})
+	if _, err := conn.Write(append(req, '\n')); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		t.Fatal("no response")
+	}
+	var resp struct {
+		Payload GitPushResponse `json:"payload"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Payload
+}
+
+func TestGitPushHandler(t *testing.T) {
+	remote, workspace := gitRepos(t)
+	git(t, workspace, "checkout", "-q", "-b", "feature")
+	commit(t, workspace, "first")
+	commit(t, workspace, "second")
+
+	// A hook in the agent-writable repository must never run on the host.
+	marker := filepath.Join(t.TempDir(), "hook-ran")
+	hook := filepath.Join(workspace, ".git", "hooks", "pre-push")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	approve := true
+	var shown []string
+	srv.Handle("git_push", NewGitPushHandler(GitHandlerConfig{
+		RepoDir: workspace,
+		Rules:   allowRemotes(remote),
+		PromptFunc: func(title string, lines []string) (bool, error) {
+			shown = lines
+			return approve, nil
+		},
+		allowLocalRemotes: true,
+	}))
+	srv.Start()
+
+	resp := sendGitPush(t, srv, "feature")
+	if !resp.Approved || resp.Error != "" || resp.Commits != 2 {
+		t.Fatalf("push: %+v", resp)
+	}
+	summary := strings.Join(shown, "\n")
+	for _, want := range []string{"feature (new branch)", "Commits: 2", "first", "second", remote, "Host:    localhost"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("popup lacks %q:\n%s", want, summary)
+		}
+	}
+	if got, want := git(t, remote, "rev-parse", "feature"), git(t, workspace, "rev-parse", "feature"); got != want {
+		t.Errorf("remote feature = %s, want %s", got, want)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the workspace's pre-push hook ran on the host")
+	}
+
+	if resp := sendGitPush(t, srv, "feature"); !strings.Contains(resp.Error, "up to date") {
+		t.Errorf("pushing an unchanged branch: %+v", resp)
+	}
+
+	commit(t, workspace, "third")
+	approve = false
+	before := git(t, remote, "rev-parse", "feature")
+	if resp := sendGitPush(t, srv, "feature"); resp.Approved || resp.Error != "" {
+		t.Errorf("denied push: %+v", resp)
+	}
+	if git(t, remote, "rev-parse", "feature") != before {
+		t.Error("denied push reached the remote")
+	}
+	approve = true
+	resp = sendGitPush(t, srv, "feature")
+	if !resp.Approved || resp.Commits != 1 || resp.Range != short(before)+".."+short(git(t, workspace, "rev-parse", "feature")) {
+		t.Errorf("second push: %+v", resp)
+	}
+
+	for _, branch := range []string{"", "-f", "a..b", "missing"} {
+		if resp := sendGitPush(t, srv, branch); resp.Error == "" {
+			t.Errorf("branch %q: expected an error, got %+v", branch, resp)
+		}
+	}
+}
+
+func TestGitPushRemoteChecks(t *testing.T) {
+	_, workspace := gitRepos(t)
+	git(t, workspace, "checkout", "-q", "-b", "feature")
+	commit(t, workspace, "change")
+	git(t, workspace, "remote", "set-url", "origin", "https://unreachable.invalid/acme/app.git")
+
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	rules := allowRemotes()
+	approve, prompted := false, false
+	var shown []string
+	srv.Handle("git_push", NewGitPushHandler(GitHandlerConfig{
+		RepoDir: workspace,
+		Rules:   rules,
+		PromptFunc: func(title string, lines []string) (bool, error) {
+			prompted, shown = true, lines
+			return approve, nil
+		},
+	}))
+	srv.Start()
+
+	// A remote missing from git_remotes is refused before any prompt.
+	if resp := sendGitPush(t, srv, "feature"); !strings.Contains(resp.Error, "not an allowed git remote") || prompted {
+		t.Errorf("unlisted remote: %+v (prompted %v)", resp, prompted)
+	}
+
+	// A listed remote is prompted for without being contacted: an
+	// unreachable one is only noticed once the push is approved.
+	rules.remotes = []string{"unreachable.invalid/acme/*"}
+	if resp := sendGitPush(t, srv, "feature"); resp.Approved || resp.Error != "" || !prompted {
+		t.Errorf("denied push: %+v (prompted %v)", resp, prompted)
+	}
+	summary := strings.Join(shown, "\n")
+	for _, want := range []string{"https://unreachable.invalid/acme/app.git", "Host:    unreachable.invalid", "Commits: 1"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("popup lacks %q:\n%s", want, summary)
+		}
+	}
+	approve = true
+	if resp := sendGitPush(t, srv, "feature"); !resp.Approved || !strings.Contains(resp.Error, "cannot reach") {
+		t.Errorf("approved push to an unreachable remote: %+v", resp)
+	}
+}
+
+func TestGitPushRefusesUnshownCommits(t *testing.T) {
+	remote, workspace := gitRepos(t)
+	git(t, workspace, "checkout", "-q", "-b", "feature")
+	commit(t, workspace, "hidden")
+	// Moving the tracking branch hides the commit from the popup.
+	git(t, workspace, "update-ref", "refs/remotes/origin/main", "HEAD")
+	commit(t, workspace, "shown")
+
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	var shown []string
+	srv.Handle("git_push", NewGitPushHandler(GitHandlerConfig{
+		RepoDir: workspace,
+		Rules:   allowRemotes(remote),
+		PromptFunc: func(title string, lines []string) (bool, error) {
+			shown = lines
+			return true, nil
+		},
+		allowLocalRemotes: true,
+	}))
+	srv.Start()
+
+	resp := sendGitPush(t, srv, "feature")
+	if !resp.Approved || !strings.Contains(resp.Error, "did not show") {
+		t.Errorf("push: %+v", resp)
+	}
+	if summary := strings.Join(shown, "\n"); !strings.Contains(summary, "Commits: 1") || strings.Contains(summary, "hidden") {
+		t.Errorf("popup:\n%s", summary)
+	}
+	if _, err := runGit(context.Background(), remote, "rev-parse", "--verify", "-q", "refs/heads/feature"); err == nil {
+		t.Error("the push reached the remote")
+	}
+}
+
+func TestRemoteID(t *testing.T) {
+	tests := []struct {
+		url, host, id string
+	}{
+		{"https://github.com/Cloud-Exit/ExitBox.git", "github.com", "github.com/cloud-exit/exitbox"},
+		{"https://token@GitHub.com:443/cloud-exit/exitbox/", "github.com", "github.com/cloud-exit/exitbox"},
+		{"ssh://git@example.com:2222/team/repo.git", "example.com", "example.com/team/repo"},
+		{"git@github.com:cloud-exit/exitbox.git", "github.com", "github.com/cloud-exit/exitbox"},
+		{"https://github.com/", "", ""},
+		{"https://github.com/a/../b", "", ""},
+		{"https://github.com/a//b", "", ""},
+		{"/srv/git/repo.git", "", ""},
+	}
+	for _, tc := range tests {
+		host, id, ok := remoteID(tc.url)
+		if host != tc.host || id != tc.id || ok != (tc.id != "") {
+			t.Errorf("remoteID(%q) = %q, %q, %v; want %q, %q", tc.url, host, id, ok, tc.host, tc.id)
+		}
+	}
+}
+
+func TestGitPushRefusesLocalRemote(t *testing.T) {
+	_, workspace := gitRepos(t)
+	if _, err := remoteURL(context.Background(), GitHandlerConfig{RepoDir: workspace}, ""); err == nil {
+		t.Error("expected an error for a local path remote")
+	}
+}
+
+func TestNetworkRemote(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://github.com/cloud-exit/exitbox.git", true},
+		{"ssh://git@example.com:2222/repo.git", true},
+		{"git@github.com:cloud-exit/exitbox.git", true},
+		{"/srv/git/repo.git", false},
+		{"file:///srv/git/repo.git", false},
+		{"ext::sh -c touch% /tmp/pwned", false},
+		{"-oProxyCommand=sh@host:repo", false},
+		{"ssh://-oProxyCommand=sh/repo", false},
+	}
+	for _, tc := range tests {
+		if got := networkRemote(tc.url); got != tc.want {
+			t.Errorf("networkRemote(%q) = %v, want %v", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestGithubRepo(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://github.com/cloud-exit/exitbox.git", "cloud-exit/exitbox"},
+		{"https://token@github.com/cloud-exit/exitbox", "cloud-exit/exitbox"},
+		{"git@github.com:cloud-exit/exitbox.git", "cloud-exit/exitbox"},
+		{"ssh://git@github.com/cloud-exit/exitbox.git", "cloud-exit/exitbox"},
+		{"https://gitlab.com/cloud-exit/exitbox.git", ""},
+		{"https://github.com.evil.example/a/b", ""},
+	}
+	for _, tc := range tests {
+		if got, _ := githubRepo(tc.url); got != tc.want {
+			t.Errorf("githubRepo(%q) = %q, want %q", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestOpenPRHandler(t *testing.T) {
+	_, workspace := gitRepos(t)
+	git(t, workspace, "remote", "set-url", "origin", "git@github.com:cloud-exit/exitbox.git")
+
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	var gotRepo string
+	var gotPR OpenPRRequest
+	srv.Handle("open_pr", NewOpenPRHandler(GitHandlerConfig{
+		RepoDir:    workspace,
+		Rules:      allowRemotes("github.com/cloud-exit/exitbox"),
+		PromptFunc: func(string, []string) (bool, error) { return true, nil },
+		CreatePRFunc: func(_ context.Context, repo string, pr OpenPRRequest) (string, error) {
+			gotRepo, gotPR = repo, pr
+			return "https://github.com/cloud-exit/exitbox/pull/1", nil
+		},
+	}))
+	srv.Start()
+
+	conn, err := net.Dial("unix", srv.socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	payload, _ := json.Marshal(OpenPRRequest{Branch: "feature", Title: "Add a feature", Base: "main"})
+	req, _ := json.Marshal(Request{Type: "open_pr", ID: "1", Payload: payload, Token: srv.token})
+	if _, err := conn.Write(append(req, '\n')); err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(conn)
+	if !scanner.Scan() {
+		t.Fatal("no response")
+	}
+	var resp struct {
+		Payload OpenPRResponse `json:"payload"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Payload.Approved || resp.Payload.URL != "https://github.com/cloud-exit/exitbox/pull/1" {
+		t.Errorf("response: %+v", resp.Payload)
+	}
+	if gotRepo != "cloud-exit/exitbox" || gotPR.Branch != "feature" || gotPR.Base != "main" {
+		t.Errorf("created %s %+v", gotRepo, gotPR)
+	}
+}
+
+func TestPrintable(t *testing.T) {
+	if got := printable("fix\x1b[2J\tbug\r"); got != "fix[2J bug" {
+		t.Errorf("printable = %q", got)
+	}
+}
@@ -18,6 +18,7 @@ package ipc
 
 import (
 	"bytes"
+	"context"
 	cryptoRand "crypto/rand"
 	"encoding/json"
 	"fmt"
@@ -25,6 +26,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/cloud-exit/exitbox/internal/audit"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/vault"
 )
@@ -34,6 +36,8 @@ type VaultHandlerConfig struct {
 	Runtime       container.Runtime
 	ContainerName string
 	WorkspaceName string
+	// Rules decide requests before PromptApproveFunc is asked.
+	Rules *Rules
 	// PromptPasswordFunc overrides the tmux popup password prompt for testing.
 	PromptPasswordFunc func() (string, error)
 	// PromptApproveFunc overrides the tmux popup approval prompt for testing.
@@ -57,28 +61,62 @@ func (vs *VaultState) Cleanup() {
 	vs.store = nil
 }
 
-// NewVaultGetHandler returns a HandlerFunc for "vault_get" requests.
-func NewVaultGetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
+// prompts returns the approval and password prompts for a request. Both
+// run in the request's prompt lane.
+func (cfg VaultHandlerConfig) prompts(req *Request) (func(string) (bool, error), func() (string, error)) {
 	promptApprove := cfg.PromptApproveFunc
 	if promptApprove == nil {
 		promptApprove = func(key string) (bool, error) {
-			return promptVaultApproval(cfg.Runtime, cfg.ContainerName, key)
+			return promptVaultApproval(req.Context(), cfg.Runtime, cfg.ContainerName, key)
 		}
 	}
-
 	promptPassword := cfg.PromptPasswordFunc
 	if promptPassword == nil {
 		promptPassword = func() (string, error) {
-			return promptVaultPassword(cfg.Runtime, cfg.ContainerName)
+			return promptVaultPassword(req.Context(), cfg.Runtime, cfg.ContainerName)
+		}
+	}
+	approve := func(key string) (bool, error) {
+		return prompt(req, func() (bool, error) { return promptApprove(key) })
+	}
+	password := func() (string, error) {
+		return prompt(req, promptPassword)
+	}
+	return approve, password
+}
+
+// approve decides a request by the rules, falling back to promptFn when
+// none decides it, and records the decision.
+func (cfg VaultHandlerConfig) approve(kind, subject string, promptFn func() (bool, error)) (bool, error) {
+	verdict := cfg.Rules.Decide(kind, subject)
+	entry := audit.Entry{Type: kind, Subject: subject}
+	approved := verdict.Action == ActionApprove
+	if verdict.Action == ActionPrompt {
+		var err error
+		if approved, err = promptFn(); err != nil {
+			entry.Decision, entry.Detail = audit.Failed, err.Error()
+			cfg.Rules.Record(entry, verdict)
+			return false, err
 		}
 	}
+	entry.Decision = audit.Denied
+	if approved {
+		entry.Decision = audit.Approved
+	}
+	cfg.Rules.Record(entry, verdict)
+	return approved, nil
+}
 
+// NewVaultGetHandler returns a HandlerFunc for "vault_get" requests.
+func NewVaultGetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 	openFn := cfg.OpenFunc
 	if openFn == nil {
 		openFn = openVaultAsMap
 	}
 
 	return func(req *Request) (interface{}, error) {
+		promptApprove, promptPassword := cfg.prompts(req)
+
 		var payload VaultGetRequest
 		if err := json.Unmarshal(req.Payload, &payload); err != nil {
 			return VaultGetResponse{Error: "invalid payload"}, nil
@@ -89,8 +127,8 @@ func NewVaultGetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 			return VaultGetResponse{Error: "empty key"}, nil
 		}
 
-		// Prompt user for approval.
-		approved, err := promptApprove(key)
+		// Ask the rules, then the user, for approval.
+		approved, err := cfg.approve("vault_get", key, func() (bool, error) { return promptApprove(key) })
 		if err != nil {
 			return VaultGetResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
 		}
@@ -115,28 +153,16 @@ func NewVaultGetHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
 
 // NewVaultListHandler returns a HandlerFunc for "vault_list" requests.
 func NewVaultListHandler(cfg VaultHandlerConfig, state *VaultState) HandlerFunc {
-	promptApprove := cfg.PromptApproveFunc
-	if promptApprove == nil {
-		promptApprove = func(_ string) (bool, error) {
-			return promptVaultApproval(cfg.Runtime, cfg.ContainerName, "list keys")
-		}
-	}
-
-	promptPassword := cfg.PromptPasswordFunc
-	if promptPassword == nil {
-		promptPassword = func() (string, error) {
-			return promptVaultPassword(cfg.Runtime, cfg.ContainerName)
-		}
-	}
-
 	openFn := cfg.OpenFunc
 	if openFn == nil {
 		openFn = openVaultAsMap
 	}
 
 	return func(req *Request) (interface{}, error) {
-		// Prompt user for approval.
-		approved, err := promptApprove("list keys")
+		promptApprove, promptPassword := cfg.prompts(req)
+
+		// Ask the rules, then the user, for approval.
+		approved, err := cfg.approve("vault_list", "", func() (bool, error) { return promptApprove("list keys") })
 		if err != nil {
 			return VaultListResponse{Error: fmt.Sprintf("approval prompt failed: %v", err)}, nil
 		}
@@ -201,7 +227,7 @@ func openVaultAsMap(workspace, password string) (map[string]string, error) {
 // Since tmux display-popup runs the script inside the popup's terminal
 // (stdout goes to the popup, not back through docker exec), we use a
 // temp file inside the container to pass the password back to the host.
-func promptVaultPassword(rt container.Runtime, containerName string) (string, error) {
+func promptVaultPassword(ctx context.Context, rt container.Runtime, containerName string) (string, error) {
 	cmd := container.Cmd(rt)
 
 	// Generate a random temp file path inside the container.
@@ -218,13 +244,8 @@ func promptVaultPassword(rt container.Runtime, containerName string) (string, er
 		`echo "$pw" > ` + tmpFile + `; [ -n "$pw" ]`
 
 	// Run the popup (blocks until user submits or dismisses).
-	c := exec.Command(cmd, "exec", containerName,
-		"tmux", "display-popup", "-E", "-w", "55", "-h", "7",
-		"sh", "-c", script,
-	)
 	var stderr bytes.Buffer
-	c.Stderr = &stderr
-	popupErr := c.Run()
+	popupErr := runPopup(ctx, rt, containerName, &stderr, "55", "7", script)
 
 	// Read the password from the temp file.
 	readCmd := exec.Command(cmd, "exec", containerName, "cat", tmpFile)
@@ -236,6 +257,9 @@ func promptVaultPassword(rt container.Runtime, containerName string) (string, er
 	cleanupCmd := exec.Command(cmd, "exec", containerName, "rm", "-f", tmpFile)
 	_ = cleanupCmd.Run()
 
+	if ctx.Err() != nil {
+		return "", requestError(ctx)
+	}
 	if popupErr != nil {
 		if exitErr, ok := popupErr.(*exec.ExitError); ok {
 			if stderr.Len() == 0 {
@@ -258,27 +282,22 @@ func promptVaultPassword(rt container.Runtime, containerName string) (string, er
 }
 
 // promptVaultApproval shows a tmux popup for approving secret access.
-func promptVaultApproval(rt container.Runtime, containerName, key string) (bool, error) {
-	cmd := container.Cmd(rt)
-
+func promptVaultApproval(ctx context.Context, rt container.Runtime, containerName, key string) (bool, error) {
 	safeKey := sanitizeForShell(key)
 
 	script := `printf '\n  \033[1;33m[ExitBox Vault]\033[0m Allow secret read?\n\n  Key: \033[1m` +
 		safeKey +
 		`\033[0m\n\n  [y/N]: '; read ans; [ "$ans" = "y" ] || [ "$ans" = "yes" ]`
 
-	c := exec.Command(cmd, "exec", containerName,
-		"tmux", "display-popup", "-E", "-w", "55", "-h", "9",
-		"sh", "-c", script,
-	)
-
 	var stderr bytes.Buffer
-	c.Stderr = &stderr
-	err := c.Run()
+	err := runPopup(ctx, rt, containerName, &stderr, "55", "9", script)
 
 	if err == nil {
 		return true, nil
 	}
+	if ctx.Err() != nil {
+		return false, requestError(ctx)
+	}
 
 	if exitErr, ok := err.(*exec.ExitError); ok {
 		if stderr.Len() == 0 {
@@ -283,6 +283,7 @@ func sendVaultGet(t *testing.T, srv *Server, key string) VaultGetResponse {
 		Type:    "vault_get",
 		ID:      "test",
 		Payload: payload,
+		Token:   srv.token,
 	}
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -330,6 +331,7 @@ func sendVaultList(t *testing.T, srv *Server) VaultListResponse {
 		Type:    "vault_list",
 		ID:      "test",
 		Payload: payload,
+		Token:   srv.token,
 	}
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -0,0 +1,134 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy decides approval requests without prompting, for headless runs
+// where no one can answer a tmux popup. Anything it does not allow is
+// denied. Approved domains last for the session and are never persisted.
+type Policy struct {
+	// AllowDomains are domains the agent may ask for. A pattern may use
+	// "*" wildcards; "*.example.com" also matches example.com.
+	AllowDomains []string `yaml:"allow_domains"`
+	// VaultKeys are the vault keys the agent may read, as glob patterns.
+	VaultKeys []string `yaml:"vault_keys"`
+	// VaultList allows the agent to list the vault's key names.
+	VaultList bool `yaml:"vault_list"`
+
+	mu        sync.Mutex
+	decisions []Decision
+}
+
+// Decision is a request a Policy answered.
+type Decision struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Subject  string    `json:"subject"`
+	Approved bool      `json:"approved"`
+}
+
+// LoadPolicy reads a policy file.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	for _, pattern := range append(append([]string{}, p.AllowDomains...), p.VaultKeys...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern %q", file, pattern)
+		}
+	}
+	return &p, nil
+}
+
+// AllowDomain answers an allow_domain request. It matches the signature
+// of AllowDomainHandlerConfig.PromptFunc.
+func (p *Policy) AllowDomain(domain string) (AllowScope, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	allowed := false
+	for _, pattern := range p.AllowDomains {
+		if matchDomain(pattern, domain) {
+			allowed = true
+			break
+		}
+	}
+	p.record("allow_domain", domain, allowed)
+	if !allowed {
+		return AllowDenied, nil
+	}
+	return AllowSession, nil
+}
+
+// ApproveVaultKey answers a vault_get request. It matches the signature
+// of VaultHandlerConfig.PromptApproveFunc.
+func (p *Policy) ApproveVaultKey(key string) (bool, error) {
+	allowed := false
+	for _, pattern := range p.VaultKeys {
+		if matchPattern(pattern, key) {
+			allowed = true
+			break
+		}
+	}
+	p.record("vault_get", key, allowed)
+	return allowed, nil
+}
+
+// ApproveVaultList answers a vault_list request.
+func (p *Policy) ApproveVaultList(string) (bool, error) {
+	p.record("vault_list", "", p.VaultList)
+	return p.VaultList, nil
+}
+
+// Decisions returns the requests answered so far, oldest first.
+func (p *Policy) Decisions() []Decision {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Decision(nil), p.decisions...)
+}
+
+func (p *Policy) record(kind, subject string, approved bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decisions = append(p.decisions, Decision{Time: time.Now(), Type: kind, Subject: subject, Approved: approved})
+}
+
+// matchDomain reports whether a lower-case domain matches the glob
+// pattern. "*.example.com" also matches example.com.
+func matchDomain(pattern, domain string) bool {
+	pattern = strings.ToLower(pattern)
+	return matchPattern(pattern, domain) || (strings.HasPrefix(pattern, "*.") && domain == pattern[2:])
+}
+
+// matchPattern reports whether s matches the glob pattern.
+func matchPattern(pattern, s string) bool {
+	ok, err := path.Match(pattern, s)
+	return err == nil && ok
+}
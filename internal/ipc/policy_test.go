@@ -0,0 +1,86 @@
+package ipc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyDecisions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	data := "allow_domains:\n  - \"*.npmjs.org\"\n  - api.github.com\nvault_keys:\n  - GITHUB_*\n"
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(file)
+	if err != nil {
+		t.Fatalf("LoadPolicy: %v", err)
+	}
+
+	domains := map[string]AllowScope{
+		"registry.npmjs.org": AllowSession,
+		"npmjs.org":          AllowSession,
+		"API.github.com":     AllowSession,
+		"github.com":         AllowDenied,
+		"evil-npmjs.org":     AllowDenied,
+	}
+	for domain, want := range domains {
+		if got, err := p.AllowDomain(domain); err != nil || got != want {
+			t.Errorf("AllowDomain(%q) = %q, %v; want %q", domain, got, err, want)
+		}
+	}
+	if ok, _ := p.ApproveVaultKey("GITHUB_TOKEN"); !ok {
+		t.Error("GITHUB_TOKEN should be approved")
+	}
+	if ok, _ := p.ApproveVaultKey("AWS_SECRET"); ok {
+		t.Error("AWS_SECRET should be denied")
+	}
+	if ok, _ := p.ApproveVaultList("list keys"); ok {
+		t.Error("listing should be denied unless vault_list is set")
+	}
+
+	decisions := p.Decisions()
+	if len(decisions) != len(domains)+3 {
+		t.Fatalf("recorded %d decisions", len(decisions))
+	}
+	last := decisions[len(decisions)-1]
+	if last.Type != "vault_list" || last.Approved {
+		t.Errorf("last decision = %+v", last)
+	}
+}
+
+func TestPolicyHandler(t *testing.T) {
+	p := &Policy{AllowDomains: []string{"example.com"}}
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	var reloaded AllowScope
+	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
+		PromptFunc: p.AllowDomain,
+		ReloadFunc: func(domain string, scope AllowScope) error {
+			reloaded = scope
+			return nil
+		},
+	}))
+	srv.Start()
+
+	if resp := sendAllowDomain(t, srv, "example.com"); !resp.Approved || reloaded != AllowSession {
+		t.Errorf("response = %+v, reloaded at %q", resp, reloaded)
+	}
+	if resp := sendAllowDomain(t, srv, "other.com"); resp.Approved {
+		t.Error("other.com should be denied")
+	}
+}
+
+func TestLoadPolicyRejectsBadPattern(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(file, []byte("vault_keys: [\"[\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicy(file); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
@@ -16,13 +16,49 @@
 
 package ipc
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
+
+// ProtocolVersion is the newest protocol version the server speaks.
+//
+// Version 1 is one request and one response per connection. A client
+// that opens with a "hello" message agreeing on version 2 may instead
+// send any number of requests over the connection, each with its own ID.
+// Responses arrive in any order, may be preceded by "progress" messages
+// with the same ID, and a "cancel" message with a request's ID abandons
+// it. Prompts that need the container's terminal are still answered one
+// at a time.
+const ProtocolVersion = 2
+
+// TokenFile is the name of the file next to the socket holding the
+// session's token. Only the container's user can read it, and it is not
+// passed in the environment, where every child process would see it.
+const TokenFile = "token"
+
+// Message types of the protocol itself, as opposed to handlers.
+const (
+	TypeHello    = "hello"
+	TypeProgress = "progress"
+	TypeCancel   = "cancel"
+)
 
 // Request is a message sent from the container to the host.
 type Request struct {
 	Type    string          `json:"type"`
 	ID      string          `json:"id"`
 	Payload json.RawMessage `json:"payload"`
+	// Token is the session's token, read from TokenFile. Requests
+	// without it are refused.
+	Token string `json:"token,omitempty"`
+	// TimeoutMS shortens how long the host works on the request before
+	// giving up (version 2). The host's own limit applies either way.
+	TimeoutMS int64 `json:"timeout_ms,omitempty"`
+
+	ctx  context.Context
+	send func(Response) error
+	lane chan struct{}
 }
 
 // Response is a message sent from the host back to the container.
@@ -32,14 +68,27 @@ type Response struct {
 	Payload interface{} `json:"payload"`
 }
 
+// Hello is the payload of "hello" messages: the newest version the
+// client speaks, and in the reply the version both will use.
+type Hello struct {
+	Version int `json:"version"`
+}
+
+// Progress is the payload of "progress" messages.
+type Progress struct {
+	Message string `json:"message"`
+}
+
 // AllowDomainRequest is the payload for "allow_domain" requests.
 type AllowDomainRequest struct {
 	Domain string `json:"domain"`
 }
 
 // AllowDomainResponse is the payload for "allow_domain" responses.
+// Scope tells how long the approval lasts (see AllowScope).
 type AllowDomainResponse struct {
 	Approved bool   `json:"approved"`
+	Scope    string `json:"scope,omitempty"`
 	Error    string `json:"error,omitempty"`
 }
 
@@ -64,3 +113,38 @@ type VaultListResponse struct {
 	Approved bool     `json:"approved"`
 	Error    string   `json:"error,omitempty"`
 }
+
+// GitPushRequest is the payload for "git_push" requests.
+type GitPushRequest struct {
+	Branch string `json:"branch"`
+	// Remote defaults to "origin".
+	Remote string `json:"remote,omitempty"`
+}
+
+// GitPushResponse is the payload for "git_push" responses. Range is the
+// pushed commit range, e.g. "1a2b3c4..5d6e7f8".
+type GitPushResponse struct {
+	Approved bool   `json:"approved"`
+	Range    string `json:"range,omitempty"`
+	Commits  int    `json:"commits,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// OpenPRRequest is the payload for "open_pr" requests.
+type OpenPRRequest struct {
+	Branch string `json:"branch"`
+	// Base defaults to the repository's default branch.
+	Base  string `json:"base,omitempty"`
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	Draft bool   `json:"draft,omitempty"`
+	// Remote defaults to "origin".
+	Remote string `json:"remote,omitempty"`
+}
+
+// OpenPRResponse is the payload for "open_pr" responses.
+type OpenPRResponse struct {
+	Approved bool   `json:"approved"`
+	URL      string `json:"url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
@@ -0,0 +1,440 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package ipc
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"gopkg.in/yaml.v3"
+)
+
+// Approval rules answer requests before anyone is prompted. They live in
+// host-only files that are never mounted into containers, one per project
+// and one per workspace; the project's are checked first. The first rule
+// that matches decides; without one the user is prompted as before.
+
+// Rule actions.
+const (
+	ActionApprove = "approve"
+	ActionDeny    = "deny"
+	ActionPrompt  = "prompt"
+)
+
+// ProjectRulesFile returns the path of a project's approval rules.
+func ProjectRulesFile(projectDir string) string {
+	return filepath.Join(project.ParentDir(projectDir), "approvals.yaml")
+}
+
+// WorkspaceRulesFile returns the path of a workspace's approval rules.
+func WorkspaceRulesFile(workspace string) string {
+	return filepath.Join(config.Home, "approvals", workspace+".yaml")
+}
+
+// Rule matches requests and decides them.
+type Rule struct {
+	// Name labels the rule in the audit log.
+	Name string `yaml:"name,omitempty"`
+	// Type is the request type (allow_domain, vault_get, vault_list,
+	// git_push or open_pr); empty matches any.
+	Type string `yaml:"type,omitempty"`
+	// Match is a glob for the domain, vault key or git branch; empty
+	// matches any. "*.example.com" also matches example.com.
+	Match string `yaml:"match,omitempty"`
+	// Remote is a glob for the remote of a git_push or open_pr request,
+	// as host/path without ".git", such as "github.com/acme/*". Host is
+	// a glob for its host alone.
+	Remote string `yaml:"remote,omitempty"`
+	Host   string `yaml:"host,omitempty"`
+	// Agent limits the rule to one agent.
+	Agent string `yaml:"agent,omitempty"`
+	// Action is approve, deny or prompt.
+	Action string `yaml:"action"`
+	// Scope is how long an approved domain lasts: once or session (the
+	// default).
+	Scope string `yaml:"scope,omitempty"`
+	// Hours limits the rule to a daily window in local time, such as
+	// "09:00-18:00". A window may wrap past midnight.
+	Hours string `yaml:"hours,omitempty"`
+	// Days limits the rule to some days of the week (mon, tue, ...).
+	Days []string `yaml:"days,omitempty"`
+	// Limit caps how often the rule applies, as "N/session", "N/hour",
+	// "N/minute" or "N/day". Past it the rule is skipped.
+	Limit string `yaml:"limit,omitempty"`
+
+	source string
+	start  int // minutes after midnight, -1 when there is no window
+	end    int
+	max    int
+	per    time.Duration // 0 means per session
+}
+
+// RuleFile is the format of an approval rules file.
+type RuleFile struct {
+	// GitRemotes are the remotes git requests may use, as globs like
+	// Rule.Remote. The workspace's repository only names the remote;
+	// one not listed here is refused.
+	GitRemotes []string `yaml:"git_remotes,omitempty"`
+	Rules      []Rule   `yaml:"rules"`
+}
+
+// Verdict is what the rules decided for a request.
+type Verdict struct {
+	Action string
+	// Scope is set for approved allow_domain requests.
+	Scope AllowScope
+	// Rule describes the rule that matched, empty when none did.
+	Rule string
+
+	rule *Rule
+	use  *ruleUse // held against the rule's limit until Record
+}
+
+// ruleUse is one use of a rule with a limit. A use is held while its
+// request is being decided and counts once the rule's decision applied.
+type ruleUse struct {
+	at   time.Time
+	held bool
+}
+
+// Rules decides requests for one session.
+type Rules struct {
+	// Agent, Container, Project, Session and Workspace are recorded with
+	// each decision.
+	Agent     string
+	Container string
+	Project   string
+	Session   string
+	Workspace string
+	// Audit records decisions in the workspace's audit log.
+	Audit bool
+	// Prompter names what answers requests no rule decides, "prompt" by
+	// default.
+	Prompter string
+
+	rules   []*Rule
+	remotes []string
+	now     func() time.Time
+
+	mu       sync.Mutex
+	uses     map[*Rule][]*ruleUse
+	auditErr error
+}
+
+// LoadRules reads rule files in order of precedence. Missing files are
+// skipped.
+func LoadRules(files ...string) (*Rules, error) {
+	r := &Rules{now: time.Now, uses: make(map[*Rule][]*ruleUse)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rf RuleFile
+		if err := yaml.Unmarshal(data, &rf); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", file, err)
+		}
+		for _, remote := range rf.GitRemotes {
+			if _, err := path.Match(remote, ""); err != nil || remote == "" {
+				return nil, fmt.Errorf("%s: invalid git remote %q", file, remote)
+			}
+			r.remotes = append(r.remotes, strings.ToLower(remote))
+		}
+		for i := range rf.Rules {
+			rule := &rf.Rules[i]
+			rule.source = fmt.Sprintf("%s#%d", file, i+1)
+			if rule.Name != "" {
+				rule.source += " (" + rule.Name + ")"
+			}
+			if err := rule.compile(); err != nil {
+				return nil, fmt.Errorf("%s: rule %d: %w", file, i+1, err)
+			}
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return r, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// compile validates a rule and parses its window and limit.
+func (rule *Rule) compile() error {
+	switch rule.Type {
+	case "", "allow_domain", "vault_get", "vault_list", "git_push", "open_pr":
+	default:
+		return fmt.Errorf("unknown type %q", rule.Type)
+	}
+	switch rule.Action {
+	case ActionApprove, ActionDeny, ActionPrompt:
+	default:
+		return fmt.Errorf("action must be approve, deny or prompt")
+	}
+	switch AllowScope(rule.Scope) {
+	case "", AllowOnce, AllowSession:
+	default:
+		return fmt.Errorf("scope must be once or session")
+	}
+	for _, pattern := range []string{rule.Match, rule.Remote, rule.Host} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	if (rule.Remote != "" || rule.Host != "") && rule.Type != "" && rule.Type != "git_push" && rule.Type != "open_pr" {
+		return fmt.Errorf("remote and host apply only to git_push and open_pr")
+	}
+	for _, d := range rule.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("unknown day %q", d)
+		}
+	}
+
+	rule.start = -1
+	if rule.Hours != "" {
+		from, to, ok := strings.Cut(rule.Hours, "-")
+		start, err1 := parseClock(from)
+		end, err2 := parseClock(to)
+		if !ok || err1 != nil || err2 != nil {
+			return fmt.Errorf("hours must look like 09:00-18:00")
+		}
+		rule.start, rule.end = start, end
+	}
+
+	if rule.Limit != "" {
+		count, per, ok := strings.Cut(rule.Limit, "/")
+		n, err := strconv.Atoi(count)
+		if !ok || err != nil || n < 1 {
+			return fmt.Errorf("limit must look like 5/session")
+		}
+		rule.max = n
+		switch per {
+		case "session":
+		case "minute":
+			rule.per = time.Minute
+		case "hour":
+			rule.per = time.Hour
+		case "day":
+			rule.per = 24 * time.Hour
+		default:
+			return fmt.Errorf("limit must be per session, minute, hour or day")
+		}
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matches reports whether the rule applies to a request at now, not
+// counting its limit. remote is set for git requests.
+func (rule *Rule) matches(kind, subject, remote, agentName string, now time.Time) bool {
+	if rule.Type != "" && rule.Type != kind {
+		return false
+	}
+	if rule.Agent != "" && rule.Agent != agentName {
+		return false
+	}
+	if rule.Match != "" {
+		if kind == "allow_domain" {
+			if !matchDomain(rule.Match, subject) {
+				return false
+			}
+		} else if !matchPattern(rule.Match, subject) {
+			return false
+		}
+	}
+	if rule.Remote != "" || rule.Host != "" {
+		host, _, _ := strings.Cut(remote, "/")
+		if remote == "" ||
+			rule.Remote != "" && !matchPattern(strings.ToLower(rule.Remote), remote) ||
+			rule.Host != "" && !matchDomain(rule.Host, host) {
+			return false
+		}
+	}
+	if len(rule.Days) > 0 {
+		found := false
+		for _, d := range rule.Days {
+			if weekdays[strings.ToLower(d)] == now.Weekday() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.start >= 0 {
+		minute := now.Hour()*60 + now.Minute()
+		if rule.start <= rule.end {
+			return minute >= rule.start && minute < rule.end
+		}
+		return minute >= rule.start || minute < rule.end
+	}
+	return true
+}
+
+// Decide returns the verdict of the first rule that matches a request and
+// is within its limit. Without one the verdict is ActionPrompt. A nil
+// Rules always prompts. A rule with a limit holds a use for the request,
+// which Record counts or gives back.
+func (r *Rules) Decide(kind, subject string) Verdict {
+	return r.decide(kind, subject, "")
+}
+
+// DecideRemote is Decide for a git request to remote, given as host/path.
+func (r *Rules) DecideRemote(kind, subject, remote string) Verdict {
+	return r.decide(kind, subject, remote)
+}
+
+func (r *Rules) decide(kind, subject, remote string) Verdict {
+	if r == nil {
+		return Verdict{Action: ActionPrompt}
+	}
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rule := range r.rules {
+		if !rule.matches(kind, subject, remote, r.Agent, now) {
+			continue
+		}
+		var use *ruleUse
+		if rule.max > 0 {
+			uses := r.uses[rule]
+			if rule.per > 0 {
+				recent := uses[:0]
+				for _, u := range uses {
+					if u.held || now.Sub(u.at) < rule.per {
+						recent = append(recent, u)
+					}
+				}
+				uses = recent
+			}
+			if len(uses) >= rule.max {
+				r.uses[rule] = uses
+				continue
+			}
+			use = &ruleUse{at: now, held: true}
+			r.uses[rule] = append(uses, use)
+		}
+		v := Verdict{Action: rule.Action, Rule: rule.source, rule: rule, use: use}
+		if kind == "allow_domain" && rule.Action == ActionApprove {
+			v.Scope = AllowSession
+			if rule.Scope != "" {
+				v.Scope = AllowScope(rule.Scope)
+			}
+		}
+		return v
+	}
+	return Verdict{Action: ActionPrompt}
+}
+
+// RemoteAllowed reports whether git requests may use remote, given as
+// host/path. A nil Rules allows none.
+func (r *Rules) RemoteAllowed(remote string) bool {
+	if r == nil {
+		return false
+	}
+	for _, pattern := range r.remotes {
+		if matchPattern(pattern, remote) {
+			return true
+		}
+	}
+	return false
+}
+
+// Record writes how a request was decided to the workspace's audit log,
+// when Audit is set. v is the verdict Decide gave for the request; e
+// holds its type, subject and decision, and its source when something
+// other than the rules or the prompt decided it. A use held by v counts against
+// the rule's limit only if the request was approved, or denied by a deny
+// rule; a failed or cancelled prompt gives it back.
+func (r *Rules) Record(e audit.Entry, v Verdict) {
+	if r == nil {
+		return
+	}
+	if v.use != nil {
+		r.settle(v, e.Decision == audit.Approved || (v.Action == ActionDeny && e.Decision == audit.Denied))
+	}
+	if !r.Audit {
+		return
+	}
+	e.Time = r.now()
+	e.Container, e.Agent, e.Project, e.Session, e.Workspace = r.Container, r.Agent, r.Project, r.Session, r.Workspace
+	e.Rule = v.Rule
+	switch {
+	case e.Source != "":
+	case v.Action == ActionPrompt:
+		e.Source = r.Prompter
+		if e.Source == "" {
+			e.Source = ActionPrompt
+		}
+	default:
+		e.Source = "rule"
+	}
+	if err := audit.Append(e); err != nil {
+		r.mu.Lock()
+		if r.auditErr == nil {
+			r.auditErr = err
+		}
+		r.mu.Unlock()
+	}
+}
+
+// settle counts a held use from r.now(), or gives it back.
+func (r *Rules) settle(v Verdict, applied bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if applied {
+		v.use.at, v.use.held = r.now(), false
+		return
+	}
+	uses := r.uses[v.rule]
+	for i, u := range uses {
+		if u == v.use {
+			r.uses[v.rule] = append(uses[:i], uses[i+1:]...)
+			break
+		}
+	}
+}
+
+// AuditError returns the first error writing the audit log, if any.
+func (r *Rules) AuditError() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.auditErr
+}
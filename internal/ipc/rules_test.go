@@ -0,0 +1,302 @@
+package ipc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func writeRules(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "approvals.yaml")
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestRulesDecide(t *testing.T) {
+	project := writeRules(t, `rules:
+  - name: npm
+    type: vault_get
+    match: NPM_*
+    agent: claude
+    action: approve
+    limit: 2/session
+  - type: allow_domain
+    match: "*.evil.example"
+    action: deny
+`)
+	workspace := writeRules(t, `rules:
+  - type: allow_domain
+    match: "*.example"
+    action: approve
+    scope: once
+  - type: vault_get
+    action: deny
+`)
+	r, err := LoadRules(project, filepath.Join(t.TempDir(), "missing.yaml"), workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Agent = "claude"
+
+	tests := []struct {
+		kind, subject string
+		action        string
+		scope         AllowScope
+		rule          string
+	}{
+		{"vault_get", "NPM_TOKEN", ActionApprove, "", project + "#1 (npm)"},
+		{"vault_get", "NPM_TOKEN", ActionApprove, "", project + "#1 (npm)"},
+		// Past its limit the npm rule is skipped and the next one denies.
+		{"vault_get", "NPM_TOKEN", ActionDeny, "", workspace + "#2"},
+		{"allow_domain", "evil.example", ActionDeny, "", project + "#2"},
+		{"allow_domain", "api.example", ActionApprove, AllowOnce, workspace + "#1"},
+		{"allow_domain", "example.com", ActionPrompt, "", ""},
+		{"vault_list", "", ActionPrompt, "", ""},
+	}
+	for _, tc := range tests {
+		v := r.Decide(tc.kind, tc.subject)
+		if v.Action != tc.action || v.Scope != tc.scope || v.Rule != tc.rule {
+			t.Errorf("Decide(%s, %s) = %+v, want %s %q %s", tc.kind, tc.subject, v, tc.action, tc.scope, tc.rule)
+		}
+	}
+
+	r.Agent = "codex"
+	if v := r.Decide("vault_get", "NPM_TOKEN"); v.Action != ActionDeny {
+		t.Errorf("rule for another agent applied: %+v", v)
+	}
+
+	var none *Rules
+	if v := none.Decide("vault_get", "NPM_TOKEN"); v.Action != ActionPrompt {
+		t.Errorf("nil rules decided %+v", v)
+	}
+}
+
+func TestRulesWindowAndRate(t *testing.T) {
+	file := writeRules(t, `rules:
+  - action: approve
+    hours: "22:00-06:00"
+    days: [mon, tue]
+    limit: 2/hour
+`)
+	r, err := LoadRules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-10-12 is a Monday.
+	now := time.Date(2026, 10, 12, 23, 0, 0, 0, time.Local)
+	r.now = func() time.Time { return now }
+
+	check := func(want string) {
+		t.Helper()
+		v := r.Decide("vault_list", "")
+		if v.Action != want {
+			t.Errorf("at %s: %s, want %s", now.Format("Mon 15:04"), v.Action, want)
+		}
+		r.Record(audit.Entry{Type: "vault_list", Decision: audit.Approved}, v)
+	}
+	check(ActionApprove)
+	check(ActionApprove)
+	check(ActionPrompt) // over the limit
+	now = now.Add(time.Hour)
+	check(ActionApprove) // Tuesday 00:00, inside the wrapped window
+	now = time.Date(2026, 10, 13, 12, 0, 0, 0, time.Local)
+	check(ActionPrompt) // outside the hours
+	now = time.Date(2026, 10, 14, 23, 0, 0, 0, time.Local)
+	check(ActionPrompt) // Wednesday
+}
+
+func TestRulesLimitCountsAppliedApprovals(t *testing.T) {
+	r, err := LoadRules(writeRules(t, `rules:
+  - type: vault_get
+    action: prompt
+    limit: 1/session
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed or denied prompt gives the use back.
+	v := r.Decide("vault_get", "KEY")
+	if v.Action != ActionPrompt || v.Rule == "" {
+		t.Fatalf("verdict = %+v", v)
+	}
+	r.Record(audit.Entry{Type: "vault_get", Subject: "KEY", Decision: audit.Failed}, v)
+	v = r.Decide("vault_get", "KEY")
+	if v.Rule == "" {
+		t.Fatal("a failed prompt used up the limit")
+	}
+	r.Record(audit.Entry{Type: "vault_get", Subject: "KEY", Decision: audit.Denied}, v)
+
+	// A use is held while a request is decided, so concurrent requests
+	// cannot overshoot the limit.
+	held := r.Decide("vault_get", "KEY")
+	if v := r.Decide("vault_get", "KEY"); v.Rule != "" {
+		t.Error("a held use was not counted")
+	}
+	r.Record(audit.Entry{Type: "vault_get", Subject: "KEY", Decision: audit.Approved}, held)
+	if v := r.Decide("vault_get", "KEY"); v.Rule != "" {
+		t.Error("an approval did not use up the limit")
+	}
+}
+
+func TestLoadRulesRejectsInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"action: allow",
+		"action: approve\n    type: exec",
+		"action: approve\n    scope: forever",
+		"action: approve\n    match: '[bad'",
+		"action: approve\n    hours: 9-5",
+		"action: approve\n    days: [someday]",
+		"action: approve\n    limit: 5",
+		"action: approve\n    limit: 0/session",
+		"action: approve\n    limit: 5/week",
+		"action: approve\n    type: vault_get\n    remote: github.com/*",
+		"action: approve\n    host: '[bad'",
+	} {
+		if _, err := LoadRules(writeRules(t, "rules:\n  - "+rule+"\n")); err == nil {
+			t.Errorf("rule %q: expected an error", rule)
+		}
+	}
+}
+
+func TestRulesRemotes(t *testing.T) {
+	r, err := LoadRules(writeRules(t, `git_remotes:
+  - GitHub.com/acme/*
+  - git.example.com/*/*
+rules:
+  - type: git_push
+    remote: github.com/acme/*
+    match: feature/*
+    action: approve
+  - host: "*.example.com"
+    action: deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for remote, want := range map[string]bool{
+		"github.com/acme/app":      true,
+		"git.example.com/team/app": true,
+		"github.com/other/app":     false,
+		"github.com/acme/app/x":    false,
+		"":                         false,
+	} {
+		if got := r.RemoteAllowed(remote); got != want {
+			t.Errorf("RemoteAllowed(%q) = %v, want %v", remote, got, want)
+		}
+	}
+	if (*Rules)(nil).RemoteAllowed("github.com/acme/app") {
+		t.Error("a nil Rules allowed a remote")
+	}
+
+	tests := []struct {
+		kind, branch, remote, action string
+	}{
+		{"git_push", "feature/x", "github.com/acme/app", ActionApprove},
+		{"git_push", "main", "github.com/acme/app", ActionPrompt},
+		{"open_pr", "feature/x", "github.com/acme/app", ActionPrompt},
+		{"git_push", "feature/x", "github.com/other/app", ActionPrompt},
+		{"open_pr", "main", "git.example.com/team/app", ActionDeny},
+		// A rule naming a remote or host never matches a request without one.
+		{"git_push", "feature/x", "", ActionPrompt},
+	}
+	for _, tc := range tests {
+		if got := r.DecideRemote(tc.kind, tc.branch, tc.remote); got.Action != tc.action {
+			t.Errorf("DecideRemote(%s, %s, %s) = %s, want %s", tc.kind, tc.branch, tc.remote, got.Action, tc.action)
+		}
+	}
+}
+
+func TestRulesHandlers(t *testing.T) {
+	file := writeRules(t, `rules:
+  - type: allow_domain
+    match: "*.example.com"
+    action: approve
+  - type: vault_get
+    match: SECRET
+    action: deny
+`)
+	r, err := LoadRules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldData, oldHome := config.Data, config.Home
+	config.Data, config.Home = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { config.Data, config.Home = oldData, oldHome })
+	r.Agent = "claude"
+	r.Container = "exitbox-claude-test"
+	r.Workspace = "work"
+	r.Audit = true
+
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	defer srv.Stop()
+
+	prompted := 0
+	srv.Handle("allow_domain", NewAllowDomainHandler(AllowDomainHandlerConfig{
+		Rules: r,
+		PromptFunc: func(string) (AllowScope, error) {
+			prompted++
+			return AllowDenied, nil
+		},
+		ReloadFunc: func(string, AllowScope) error { return nil },
+	}))
+	srv.Handle("vault_get", NewVaultGetHandler(VaultHandlerConfig{
+		Rules: r,
+		PromptApproveFunc: func(string) (bool, error) {
+			prompted++
+			return true, nil
+		},
+		OpenFunc: func(string, string) (map[string]string, error) {
+			return map[string]string{"SECRET": "s"}, nil
+		},
+	}, &VaultState{}))
+	srv.Start()
+
+	if resp := sendAllowDomain(t, srv, "api.example.com"); !resp.Approved || resp.Scope != "session" {
+		t.Errorf("rule approval: %+v", resp)
+	}
+	if resp := sendVaultGet(t, srv, "SECRET"); resp.Approved {
+		t.Errorf("rule denial: %+v", resp)
+	}
+	if resp := sendAllowDomain(t, srv, "other.org"); resp.Approved {
+		t.Errorf("prompt denial: %+v", resp)
+	}
+	if prompted != 1 {
+		t.Errorf("prompted %d times, want 1", prompted)
+	}
+
+	entries, err := audit.Read("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		if e.Agent != "claude" || e.Container != "exitbox-claude-test" || e.Workspace != "work" {
+			t.Errorf("entry without session details: %+v", e)
+		}
+		got = append(got, strings.TrimSpace(strings.Join([]string{e.Type, e.Subject, e.Decision, e.Scope, e.Source, filepath.Base(e.Rule)}, " ")))
+	}
+	want := []string{
+		"allow_domain api.example.com approved session rule approvals.yaml#1",
+		"vault_get SECRET denied  rule approvals.yaml#2",
+		"allow_domain other.org denied  prompt .",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("audit log:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if i := audit.Verify(entries); i != -1 {
+		t.Errorf("chain broken at entry %d", i)
+	}
+}
@@ -18,18 +18,36 @@ package ipc
 
 import (
 	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
 )
 
 // maxRequestSize is the maximum allowed size of a single IPC request line.
 const maxRequestSize = 64 * 1024 // 64 KB
 
+// maxInFlight caps the concurrent requests on one version 2 connection.
+const maxInFlight = 16
+
+// RequestTimeout is the longest the server works on a request, including
+// the time a prompt waits for an answer.
+const RequestTimeout = 10 * time.Minute
+
 // HandlerFunc processes an IPC request and returns a response payload.
+// Handlers run concurrently; ones that prompt in the container's terminal
+// do so through Request.Prompt.
 type HandlerFunc func(req *Request) (interface{}, error)
 
 // Server listens on a Unix domain socket and dispatches JSON-lines messages.
@@ -38,8 +56,15 @@ type Server struct {
 	socketPath string
 	listener   net.Listener
 	handlers   map[string]HandlerFunc
-	mu         sync.Mutex // serializes handler calls (one prompt at a time)
-	done       chan struct{}
+	mu         sync.RWMutex  // guards handlers
+	lane       chan struct{} // held while a prompt owns the container's TTY
+	token      string
+	rejectLog  string
+	label      string
+	rejected   atomic.Int64
+	audited    atomic.Pointer[Rules]
+	ctx        context.Context
+	cancel     context.CancelFunc
 	wg         sync.WaitGroup
 }
 
@@ -57,19 +82,35 @@ func NewServer() (*Server, error) {
 		return nil, err
 	}
 
-	// Allow non-root container user to connect.
+	// Allow non-root container user to connect. Requests must still
+	// carry the token, which only the container's user can read.
 	if err := os.Chmod(socketPath, 0666); err != nil {
 		listener.Close()
 		os.RemoveAll(dir)
 		return nil, err
 	}
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		listener.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, TokenFile), []byte(hex.EncodeToString(token)), 0400); err != nil {
+		listener.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Server{
 		socketDir:  dir,
 		socketPath: socketPath,
 		listener:   listener,
 		handlers:   make(map[string]HandlerFunc),
-		done:       make(chan struct{}),
+		lane:       make(chan struct{}, 1),
+		token:      hex.EncodeToString(token),
+		ctx:        ctx,
+		cancel:     cancel,
 	}, nil
 }
 
@@ -90,7 +131,7 @@ func (s *Server) Start() {
 			conn, err := s.listener.Accept()
 			if err != nil {
 				select {
-				case <-s.done:
+				case <-s.ctx.Done():
 					return
 				default:
 					continue
@@ -105,9 +146,10 @@ func (s *Server) Start() {
 	}()
 }
 
-// Stop closes the listener, waits for goroutines, and removes the socket dir.
+// Stop closes the listener, cancels pending requests, waits for
+// goroutines, and removes the socket dir.
 func (s *Server) Stop() {
-	close(s.done)
+	s.cancel()
 	_ = s.listener.Close()
 	s.wg.Wait()
 	_ = os.RemoveAll(s.socketDir)
@@ -118,13 +160,153 @@ func (s *Server) SocketDir() string {
 	return s.socketDir
 }
 
+// LogRejections appends the requests refused for a missing or wrong
+// token to file, labelled with the container they came from. Must be
+// called before Start.
+func (s *Server) LogRejections(file, label string) {
+	s.rejectLog = file
+	s.label = label
+}
+
+// AuditRejections also records refused requests in the audit log of the
+// session r decides for. It may be called after Start.
+func (s *Server) AuditRejections(r *Rules) {
+	s.audited.Store(r)
+}
+
+// Rejected returns how many requests were refused for their token.
+func (s *Server) Rejected() int64 {
+	return s.rejected.Load()
+}
+
+// Rejection is a refused request, as logged by LogRejections.
+type Rejection struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container,omitempty"`
+	Type      string    `json:"type"`
+	ID        string    `json:"id,omitempty"`
+	Reason    string    `json:"reason"`
+}
+
+// authenticate checks a request's token. A refused request is answered
+// with an error and logged as suspicious: only a process that cannot read
+// the token file would send one.
+func (s *Server) authenticate(w *connWriter, req *Request) bool {
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.token)) == 1 {
+		return true
+	}
+	reason := "wrong token"
+	if req.Token == "" {
+		reason = "missing token"
+	}
+	_ = w.write(Response{Type: req.Type, ID: req.ID, Payload: ErrorResponse{Error: "unauthenticated request"}})
+	s.rejected.Add(1)
+	s.audited.Load().Record(audit.Entry{Type: printable(req.Type), Decision: audit.Denied, Source: "token", Detail: reason}, Verdict{})
+	if s.rejectLog == "" {
+		return false
+	}
+	data, err := json.Marshal(Rejection{Time: time.Now().UTC(), Container: s.label, Type: req.Type, ID: req.ID, Reason: reason})
+	if err != nil {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(s.rejectLog), 0700); err != nil {
+		return false
+	}
+	f, err := os.OpenFile(s.rejectLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, _ = f.Write(append(data, '\n'))
+	return false
+}
+
 // ErrorResponse is a generic error payload for IPC responses.
 type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+// Context returns the request's context, which is done when the client
+// cancels the request, disconnects or runs out of time.
+func (r *Request) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Progress tells the client what the request is waiting on. It is
+// dropped for version 1 clients, which expect a single response.
+func (r *Request) Progress(message string) {
+	if r.send != nil {
+		_ = r.send(Response{Type: TypeProgress, ID: r.ID, Payload: Progress{Message: message}})
+	}
+}
+
+// Prompt runs fn once no other request is prompting, so two popups never
+// compete for the container's terminal. It gives up if the request ends
+// while waiting.
+func (r *Request) Prompt(fn func() error) error {
+	if r.lane == nil {
+		return fn()
+	}
+	select {
+	case r.lane <- struct{}{}:
+	default:
+		r.Progress("waiting for another prompt to be answered")
+		select {
+		case r.lane <- struct{}{}:
+		case <-r.Context().Done():
+			return requestError(r.Context())
+		}
+	}
+	defer func() { <-r.lane }()
+	if r.Context().Err() != nil {
+		return requestError(r.Context())
+	}
+	return fn()
+}
+
+// prompt runs fn in the request's prompt lane and returns its result.
+func prompt[T any](req *Request, fn func() (T, error)) (T, error) {
+	var out T
+	err := req.Prompt(func() error {
+		var err error
+		out, err = fn()
+		return err
+	})
+	return out, err
+}
+
+// requestError describes why a request's context ended.
+func requestError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.New("request timed out")
+	}
+	return errors.New("request cancelled")
+}
+
+// connWriter serializes the messages written to a connection.
+type connWriter struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *connWriter) write(resp Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("ipc: failed to marshal response: %v", err)
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.conn.Write(append(data, '\n'))
+	return err
+}
+
 func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
+	w := &connWriter{conn: conn}
 
 	scanner := bufio.NewScanner(conn)
 	scanner.Buffer(make([]byte, 0, maxRequestSize), maxRequestSize)
@@ -134,40 +316,123 @@ func (s *Server) handleConnection(conn net.Conn) {
 
 	var req Request
 	if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
-		resp := Response{Type: "error", Payload: ErrorResponse{Error: "invalid request"}}
-		data, merr := json.Marshal(resp)
-		if merr != nil {
-			log.Printf("ipc: failed to marshal error response: %v", merr)
-			return
+		_ = w.write(Response{Type: "error", Payload: ErrorResponse{Error: "invalid request"}})
+		return
+	}
+	if !s.authenticate(w, &req) {
+		return
+	}
+	if req.Type != TypeHello {
+		// Version 1: this is the connection's only request.
+		s.serve(s.ctx, w, &req, false)
+		return
+	}
+
+	var hello Hello
+	_ = json.Unmarshal(req.Payload, &hello)
+	version := min(max(hello.Version, 1), ProtocolVersion)
+	if err := w.write(Response{Type: TypeHello, ID: req.ID, Payload: Hello{Version: version}}); err != nil {
+		return
+	}
+	if version < 2 {
+		if scanner.Scan() && json.Unmarshal(scanner.Bytes(), &req) == nil && s.authenticate(w, &req) {
+			s.serve(s.ctx, w, &req, false)
 		}
-		_, _ = conn.Write(append(data, '\n'))
 		return
 	}
+	s.serveConnection(scanner, w)
+}
 
-	s.mu.Lock()
+// serveConnection runs a version 2 connection: every request is handled
+// in its own goroutine until the client disconnects, which cancels the
+// requests still pending.
+func (s *Server) serveConnection(scanner *bufio.Scanner, w *connWriter) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	var pending sync.WaitGroup
+	defer pending.Wait()
+	defer cancel()
+
+	var mu sync.Mutex
+	cancels := make(map[string]context.CancelFunc)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			_ = w.write(Response{Type: "error", Payload: ErrorResponse{Error: "invalid request"}})
+			continue
+		}
+		if !s.authenticate(w, &req) {
+			continue
+		}
+		if req.Type == TypeCancel {
+			mu.Lock()
+			if c := cancels[req.ID]; c != nil {
+				c()
+			}
+			mu.Unlock()
+			continue
+		}
+
+		mu.Lock()
+		var refusal string
+		switch {
+		case req.ID == "":
+			refusal = "request id required"
+		case cancels[req.ID] != nil:
+			refusal = "request id already in use: " + req.ID
+		case len(cancels) >= maxInFlight:
+			refusal = "too many requests in flight"
+		}
+		if refusal != "" {
+			mu.Unlock()
+			_ = w.write(Response{Type: req.Type, ID: req.ID, Payload: ErrorResponse{Error: refusal}})
+			continue
+		}
+		reqCtx, reqCancel := context.WithCancel(ctx)
+		cancels[req.ID] = reqCancel
+		mu.Unlock()
+
+		pending.Add(1)
+		go func() {
+			defer pending.Done()
+			s.serve(reqCtx, w, &req, true)
+			mu.Lock()
+			delete(cancels, req.ID)
+			mu.Unlock()
+			reqCancel()
+		}()
+	}
+}
+
+// serve runs a request's handler and writes its response. Progress
+// messages are only sent on version 2 connections.
+func (s *Server) serve(ctx context.Context, w *connWriter, req *Request, progress bool) {
+	s.mu.RLock()
 	handler, ok := s.handlers[req.Type]
+	s.mu.RUnlock()
 	if !ok {
-		s.mu.Unlock()
-		resp := Response{
+		_ = w.write(Response{
 			Type: req.Type,
 			ID:   req.ID,
 			Payload: ErrorResponse{
 				Error: "unknown message type: " + req.Type,
 			},
-		}
-		data, merr := json.Marshal(resp)
-		if merr != nil {
-			log.Printf("ipc: failed to marshal error response: %v", merr)
-			return
-		}
-		_, _ = conn.Write(append(data, '\n'))
+		})
 		return
 	}
 
-	// Serialize handler calls so only one TTY prompt runs at a time.
-	payload, err := handler(&req)
-	s.mu.Unlock()
+	timeout := RequestTimeout
+	if t := time.Duration(req.TimeoutMS) * time.Millisecond; t > 0 && t < timeout {
+		timeout = t
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req.ctx = ctx
+	req.lane = s.lane
+	if progress {
+		req.send = w.write
+	}
 
+	payload, err := handler(req)
 	resp := Response{
 		Type: req.Type,
 		ID:   req.ID,
@@ -177,11 +442,5 @@ func (s *Server) handleConnection(conn net.Conn) {
 	} else {
 		resp.Payload = payload
 	}
-
-	data, merr := json.Marshal(resp)
-	if merr != nil {
-		log.Printf("ipc: failed to marshal response: %v", merr)
-		return
-	}
-	_, _ = conn.Write(append(data, '\n'))
+	_ = w.write(resp)
 }
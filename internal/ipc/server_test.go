@@ -2,9 +2,20 @@ package ipc
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/audit"
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/ipc/client"
 )
 
 func TestServerRoundTrip(t *testing.T) {
@@ -31,8 +42,9 @@ func TestServerRoundTrip(t *testing.T) {
 	defer conn.Close()
 
 	req := Request{
-		Type: "echo",
-		ID:   "test-1",
+		Type:  "echo",
+		ID:    "test-1",
+		Token: srv.token,
 	}
 	payloadBytes, err := json.Marshal(map[string]string{"msg": "hello"})
 	if err != nil {
@@ -92,7 +104,7 @@ func TestServerUnknownType(t *testing.T) {
 	}
 	defer conn.Close()
 
-	req := Request{Type: "nonexistent", ID: "test-2"}
+	req := Request{Type: "nonexistent", ID: "test-2", Token: srv.token}
 	data, err := json.Marshal(req)
 	if err != nil {
 		t.Fatalf("marshal request: %v", err)
@@ -124,3 +136,226 @@ func TestServerUnknownType(t *testing.T) {
 		t.Error("expected error for unknown type")
 	}
 }
+
+// startServer starts a server with a "prompt" handler that holds the
+// prompt lane until release is closed, and an "echo" handler that does
+// not prompt.
+func startServer(t *testing.T, release chan struct{}) *Server {
+	t.Helper()
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(srv.Stop)
+	srv.Handle("prompt", func(req *Request) (interface{}, error) {
+		err := req.Prompt(func() error {
+			select {
+			case <-release:
+				return nil
+			case <-req.Context().Done():
+				return requestError(req.Context())
+			}
+		})
+		return map[string]bool{"done": err == nil}, err
+	})
+	srv.Handle("echo", func(req *Request) (interface{}, error) {
+		return map[string]string{"id": req.ID}, nil
+	})
+	srv.Start()
+	return srv
+}
+
+func decodeError(t *testing.T, raw json.RawMessage) string {
+	t.Helper()
+	var payload ErrorResponse
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	return payload.Error
+}
+
+func TestServerV2Concurrent(t *testing.T) {
+	release := make(chan struct{})
+	srv := startServer(t, release)
+
+	conn, err := client.Dial(srv.socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if conn.Version() != 2 {
+		t.Fatalf("version = %d, want 2", conn.Version())
+	}
+
+	// Two prompts queue in the lane; the one that waits says so.
+	ctx := context.Background()
+	results := make(chan json.RawMessage, 2)
+	waiting := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			raw, _ := conn.Call(ctx, "prompt", nil, func(msg string) { waiting <- msg })
+			results <- raw
+		}()
+	}
+
+	// A request that does not prompt is answered meanwhile on the same
+	// connection.
+	echoCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := conn.Call(echoCtx, "echo", nil, nil); err != nil {
+		t.Fatalf("echo while a prompt is pending: %v", err)
+	}
+	select {
+	case msg := <-waiting:
+		if !strings.Contains(msg, "waiting") {
+			t.Errorf("progress = %q", msg)
+		}
+	case <-echoCtx.Done():
+		t.Fatal("no progress message for the queued prompt")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if raw := <-results; !strings.Contains(string(raw), `"done":true`) {
+			t.Errorf("prompt result = %s", raw)
+		}
+	}
+}
+
+func TestServerV2CancelAndTimeout(t *testing.T) {
+	srv := startServer(t, make(chan struct{}))
+	conn, err := client.Dial(srv.socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := conn.Call(ctx, "prompt", nil, nil); err != context.Canceled {
+		t.Fatalf("cancelled call = %v", err)
+	}
+
+	// The cancel freed the lane for the next prompt.
+	release := make(chan struct{})
+	close(release)
+	if _, err := conn.Call(context.Background(), "echo", nil, nil); err != nil {
+		t.Fatalf("echo after cancel: %v", err)
+	}
+}
+
+func TestServerV2Messages(t *testing.T) {
+	srv := startServer(t, make(chan struct{}))
+	conn, err := net.Dial("unix", srv.socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	// Another prompt holds the lane.
+	srv.lane <- struct{}{}
+
+	for _, line := range []string{
+		`{"type":"hello","id":"h","payload":{"version":3}}`,
+		`{"type":"prompt","id":"a"}`,
+		`{"type":"prompt","id":"a"}`,
+		`{"type":"prompt","id":"b","timeout_ms":50}`,
+	} {
+		line = strings.Replace(line, "{", `{"token":"`+srv.token+`",`, 1)
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	scanner := bufio.NewScanner(conn)
+	var got []string
+	for len(got) < 5 && scanner.Scan() {
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%s %s %v", resp.Type, resp.ID, resp.Payload))
+	}
+	// Messages for different requests arrive in any order.
+	slices.Sort(got)
+	want := []string{
+		"hello h map[version:2]",
+		"progress a map[message:waiting for another prompt to be answered]",
+		"progress b map[message:waiting for another prompt to be answered]",
+		"prompt a map[error:request id already in use: a]",
+		"prompt b map[error:request timed out]",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("messages =\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestServerRejectsUnauthenticated(t *testing.T) {
+	oldData, oldHome := config.Data, config.Home
+	config.Data, config.Home = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { config.Data, config.Home = oldData, oldHome })
+
+	srv := startServer(t, make(chan struct{}))
+	logFile := filepath.Join(t.TempDir(), "rejected.log")
+	srv.LogRejections(logFile, "exitbox-test")
+	srv.AuditRejections(&Rules{Container: "exitbox-test", Workspace: "work", Audit: true, now: time.Now})
+
+	// The token is in a file only the owner can read.
+	info, err := os.Stat(filepath.Join(srv.SocketDir(), TokenFile))
+	if err != nil || info.Mode().Perm() != 0400 {
+		t.Fatalf("token file = %v, %v", info, err)
+	}
+
+	for _, line := range []string{
+		`{"type":"echo","id":"1"}`,
+		`{"type":"hello","id":"2","token":"guess","payload":{"version":2}}`,
+	} {
+		conn, err := net.Dial("unix", srv.socketPath)
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		if _, err := conn.Write([]byte(line + "\n")); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		scanner := bufio.NewScanner(conn)
+		if !scanner.Scan() || !strings.Contains(scanner.Text(), "unauthenticated request") {
+			t.Errorf("%s: response = %s", line, scanner.Text())
+		}
+		if scanner.Scan() {
+			t.Errorf("%s: connection still open", line)
+		}
+		conn.Close()
+	}
+
+	if srv.Rejected() != 2 {
+		t.Errorf("Rejected() = %d, want 2", srv.Rejected())
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var first Rejection
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first) != nil ||
+		first.Container != "exitbox-test" || first.Type != "echo" || first.Reason != "missing token" {
+		t.Errorf("log = %s", data)
+	}
+	entries, err := audit.Read("work")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("audit log = %+v, %v", entries, err)
+	}
+	if e := entries[1]; e.Type != "hello" || e.Decision != audit.Denied || e.Source != "token" || e.Detail != "wrong token" || e.Container != "exitbox-test" {
+		t.Errorf("audit entry = %+v", e)
+	}
+
+	// The client reads the token next to the socket.
+	conn, err := client.Dial(srv.socketPath)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if raw, err := conn.Call(context.Background(), "echo", nil, nil); err != nil || strings.Contains(string(raw), "error") {
+		t.Errorf("authenticated call = %s, %v", raw, err)
+	}
+}
@@ -0,0 +1,306 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+// squidLogDir is where the host's network log directory is mounted inside
+// the Squid container.
+const squidLogDir = "/var/log/squid"
+
+// accessLogName is the file Squid writes the exitbox logformat to.
+const accessLogName = "exitbox-access.log"
+
+// accessLogFormat is the Squid logformat for the access log. Fields are
+// space separated: timestamp, client address, Squid result code, HTTP
+// status, method, URL (URL-quoted) and reply size.
+const accessLogFormat = "%ts.%03tu %>a %Ss %03>Hs %rm %#ru %<st"
+
+// AccessEntry is a single request seen by Squid, attributed to the agent
+// container that made it.
+type AccessEntry struct {
+	Time       time.Time
+	ClientIP   string
+	Result     string // Squid result code, e.g. TCP_TUNNEL or TCP_DENIED
+	HTTPStatus int
+	Method     string
+	URL        string
+	Domain     string
+	Bytes      int64
+	Container  string // empty when the address could not be attributed
+	Agent      string
+	Project    string
+}
+
+// Denied reports whether Squid refused the request.
+func (e AccessEntry) Denied() bool {
+	return strings.Contains(e.Result, "DENIED") || e.HTTPStatus == 403
+}
+
+// Limited reports whether Squid refused the request for exceeding an
+// egress limit.
+func (e AccessEntry) Limited() bool {
+	return e.Denied() && e.HTTPStatus == 429
+}
+
+// AccessLogFile returns the host path of the Squid access log.
+func AccessLogFile() string {
+	return filepath.Join(config.NetworkLogDir(), accessLogName)
+}
+
+// bindingsFile returns the host path of the address bindings log.
+func bindingsFile() string {
+	return filepath.Join(config.NetworkLogDir(), "bindings.log")
+}
+
+// ensureNetworkLogDir creates the host log directory. Squid writes to it as
+// its unprivileged cache_effective_user, which has a different UID than the
+// host user, so the directory must be world-writable.
+func ensureNetworkLogDir() error {
+	dir := config.NetworkLogDir()
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return os.Chmod(dir, 0777)
+}
+
+// recordBinding appends an address binding so requests from ip after now
+// are attributed to containerName, even after the container has exited.
+func recordBinding(containerName, ip string) error {
+	if err := ensureNetworkLogDir(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(bindingsFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%d %s %s\n", time.Now().Unix(), ip, containerName)
+	return err
+}
+
+// binding is one line of the bindings log.
+type binding struct {
+	since     time.Time
+	container string
+}
+
+// bindings maps a client address to its bindings, oldest first.
+type bindings map[string][]binding
+
+// loadBindings reads the bindings log.
+func loadBindings() bindings {
+	b := make(bindings)
+	for _, line := range readLines(bindingsFile()) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		b[fields[1]] = append(b[fields[1]], binding{since: time.Unix(sec, 0), container: fields[2]})
+	}
+	for ip := range b {
+		list := b[ip]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].since.Before(list[j].since) })
+	}
+	return b
+}
+
+// containerAt returns the container bound to ip at time t.
+func (b bindings) containerAt(ip string, t time.Time) string {
+	name := ""
+	for _, bd := range b[ip] {
+		if bd.since.After(t) {
+			break
+		}
+		name = bd.container
+	}
+	return name
+}
+
+// ParseAccessLine parses one line written with accessLogFormat.
+func ParseAccessLine(line string) (AccessEntry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 7 {
+		return AccessEntry{}, false
+	}
+	ts, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return AccessEntry{}, false
+	}
+	status, _ := strconv.Atoi(fields[3])
+	size, _ := strconv.ParseInt(fields[6], 10, 64)
+	rawURL := fields[5]
+	if u, err := url.PathUnescape(rawURL); err == nil {
+		rawURL = u
+	}
+
+	sec := int64(ts)
+	return AccessEntry{
+		Time:       time.Unix(sec, int64((ts-float64(sec))*1e9)).Round(time.Millisecond),
+		ClientIP:   fields[1],
+		Result:     fields[2],
+		HTTPStatus: status,
+		Method:     fields[4],
+		URL:        rawURL,
+		Domain:     urlDomain(rawURL),
+		Bytes:      size,
+	}, true
+}
+
+// urlDomain extracts the host from a request URL or CONNECT authority.
+func urlDomain(raw string) string {
+	if strings.Contains(raw, "://") {
+		if u, err := url.Parse(raw); err == nil {
+			return u.Hostname()
+		}
+		return raw
+	}
+	if host, _, err := net.SplitHostPort(raw); err == nil {
+		return host
+	}
+	return raw
+}
+
+// attribute fills in the container, agent and project of an entry.
+func (b bindings) attribute(e *AccessEntry) {
+	e.Container = b.containerAt(e.ClientIP, e.Time)
+	if e.Container != "" {
+		e.Agent, e.Project = describeContainer(e.Container)
+	}
+}
+
+// describeContainer derives the agent and project path from an agent
+// container name (exitbox-<agent>-<project folder>-<suffix>).
+func describeContainer(name string) (agentName, projectPath string) {
+	parts := strings.Split(name, "-")
+	if len(parts) != 4 || parts[0] != "exitbox" {
+		return "", ""
+	}
+	agentName = parts[1]
+	projectPath = parts[2]
+	if data, err := os.ReadFile(filepath.Join(config.ProjectsDir(), parts[2], ".project_path")); err == nil {
+		projectPath = strings.TrimSpace(string(data))
+	}
+	return agentName, projectPath
+}
+
+// TailAccessLog reads the access log and calls fn for every attributed
+// entry. Without follow it returns at end of file; with follow it keeps
+// polling for appended lines until stop is closed.
+func TailAccessLog(follow bool, stop <-chan struct{}, fn func(AccessEntry)) error {
+	f, err := os.Open(AccessLogFile())
+	if err != nil {
+		if os.IsNotExist(err) && follow {
+			// Squid has not logged anything yet; wait for the file.
+			for {
+				select {
+				case <-stop:
+					return nil
+				case <-time.After(500 * time.Millisecond):
+				}
+				if f, err = os.Open(AccessLogFile()); err == nil {
+					break
+				}
+			}
+		} else {
+			return err
+		}
+	}
+	defer f.Close()
+
+	b := loadBindings()
+	r := bufio.NewReader(f)
+	var partial string
+	for {
+		line, err := r.ReadString('\n')
+		if err == nil {
+			line = partial + line
+			partial = ""
+			if e, ok := ParseAccessLine(line); ok {
+				b.attribute(&e)
+				fn(e)
+			}
+			continue
+		}
+		if err != io.EOF {
+			return err
+		}
+		partial += line
+		if !follow {
+			return nil
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(500 * time.Millisecond):
+		}
+		// New containers may have been bound since the last read.
+		b = loadBindings()
+	}
+}
+
+// DeniedDomain is a destination Squid refused, with the number of refused
+// requests.
+type DeniedDomain struct {
+	Domain string
+	Count  int
+}
+
+// DeniedDomains returns the destinations Squid refused for containerName
+// since the given time, most requested first.
+func DeniedDomains(containerName string, since time.Time) ([]DeniedDomain, error) {
+	counts := make(map[string]int)
+	err := TailAccessLog(false, nil, func(e AccessEntry) {
+		if e.Container != containerName || !e.Denied() || e.Time.Before(since) || e.Domain == "" {
+			return
+		}
+		counts[strings.ToLower(e.Domain)]++
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	out := make([]DeniedDomain, 0, len(counts))
+	for d, n := range counts {
+		out = append(out, DeniedDomain{Domain: d, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Domain < out[j].Domain
+	})
+	return out, nil
+}
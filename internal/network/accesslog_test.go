@@ -0,0 +1,120 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestParseAccessLine(t *testing.T) {
+	e, ok := ParseAccessLine("1760000000.250 10.89.0.5 TCP_DENIED 403 CONNECT evil.example:443 3850\n")
+	if !ok {
+		t.Fatal("expected line to parse")
+	}
+	if e.ClientIP != "10.89.0.5" || e.Method != "CONNECT" || e.Domain != "evil.example" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if !e.Denied() {
+		t.Error("TCP_DENIED entry should be denied")
+	}
+	if e.Time.Unix() != 1760000000 || e.Time.Nanosecond() != 250*int(time.Millisecond) {
+		t.Errorf("time = %v", e.Time)
+	}
+
+	e, ok = ParseAccessLine("1760000001.000 10.89.0.5 TCP_MISS 200 GET http://example.com/a%20b 512")
+	if !ok {
+		t.Fatal("expected line to parse")
+	}
+	if e.Domain != "example.com" || e.URL != "http://example.com/a b" || e.Bytes != 512 {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+	if e.Denied() {
+		t.Error("TCP_MISS/200 should not be denied")
+	}
+
+	if _, ok := ParseAccessLine("garbage"); ok {
+		t.Error("garbage line should not parse")
+	}
+}
+
+func TestTailAccessLogAttribution(t *testing.T) {
+	origData, origHome := config.Data, config.Home
+	config.Data, config.Home = t.TempDir(), t.TempDir()
+	defer func() { config.Data, config.Home = origData, origHome }()
+
+	folder := "home_user_proj_0000abcd"
+	_ = os.MkdirAll(filepath.Join(config.ProjectsDir(), folder), 0755)
+	_ = os.WriteFile(filepath.Join(config.ProjectsDir(), folder, ".project_path"), []byte("/home/user/proj\n"), 0644)
+
+	dir := config.NetworkLogDir()
+	_ = os.MkdirAll(dir, 0755)
+	// The address is reused: first by a claude container, later by codex.
+	_ = os.WriteFile(bindingsFile(), []byte(
+		"1760000000 10.89.0.5 exitbox-claude-"+folder+"-11111111\n"+
+			"1760000100 10.89.0.5 exitbox-codex-"+folder+"-22222222\n"), 0644)
+	_ = os.WriteFile(AccessLogFile(), []byte(
+		"1759999999.000 10.89.0.5 TCP_DENIED 403 CONNECT early.example:443 0\n"+
+			"1760000050.000 10.89.0.5 TCP_TUNNEL 200 CONNECT a.example:443 100\n"+
+			"1760000150.000 10.89.0.5 TCP_DENIED 403 CONNECT b.example:443 0\n"), 0644)
+
+	var got []AccessEntry
+	if err := TailAccessLog(false, nil, func(e AccessEntry) { got = append(got, e) }); err != nil {
+		t.Fatalf("TailAccessLog: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(got))
+	}
+	if got[0].Container != "" {
+		t.Errorf("request before any binding should be unattributed, got %q", got[0].Container)
+	}
+	if got[1].Agent != "claude" || got[1].Project != "/home/user/proj" {
+		t.Errorf("entry 1 attributed to %s/%s", got[1].Agent, got[1].Project)
+	}
+	if got[2].Agent != "codex" {
+		t.Errorf("entry 2 attributed to %s, want codex", got[2].Agent)
+	}
+}
+
+func TestDeniedDomains(t *testing.T) {
+	origData := config.Data
+	config.Data = t.TempDir()
+	defer func() { config.Data = origData }()
+
+	name := "exitbox-claude-proj-11111111"
+	_ = os.MkdirAll(config.NetworkLogDir(), 0755)
+	_ = os.WriteFile(bindingsFile(), []byte(
+		"1760000000 10.89.0.5 "+name+"\n"+
+			"1760000000 10.89.0.6 exitbox-codex-proj-22222222\n"), 0644)
+	_ = os.WriteFile(AccessLogFile(), []byte(
+		"1760000010.000 10.89.0.5 TCP_DENIED 403 CONNECT old.example:443 0\n"+
+			"1760000100.000 10.89.0.5 TCP_DENIED 403 CONNECT B.example:443 0\n"+
+			"1760000101.000 10.89.0.5 TCP_DENIED 403 GET http://b.example/x 0\n"+
+			"1760000102.000 10.89.0.5 TCP_DENIED 403 CONNECT a.example:443 0\n"+
+			"1760000103.000 10.89.0.5 TCP_TUNNEL 200 CONNECT ok.example:443 10\n"+
+			"1760000104.000 10.89.0.6 TCP_DENIED 403 CONNECT other.example:443 0\n"), 0644)
+
+	got, err := DeniedDomains(name, time.Unix(1760000050, 0))
+	if err != nil {
+		t.Fatalf("DeniedDomains: %v", err)
+	}
+	want := []DeniedDomain{{"b.example", 2}, {"a.example", 1}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("DeniedDomains = %v, want %v", got, want)
+	}
+
+	config.Data = t.TempDir()
+	if got, err := DeniedDomains(name, time.Time{}); err != nil || len(got) != 0 {
+		t.Errorf("missing log should give no domains, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,102 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/dns"
+)
+
+// Agent containers have no DNS of their own: Squid resolves every name.
+// With DNS settings in config.yaml, Squid sends its lookups to exitbox-dns,
+// a resolver running in the proxy container that implements split DNS, DoH
+// and host overrides (see internal/dns). The host renders the settings to
+// a file the resolver reloads when it changes.
+
+// squidDNSConfig is where the resolver configuration is mounted inside the
+// Squid container.
+const squidDNSConfig = "/etc/exitbox/dns.json"
+
+// dnsLabel marks a Squid container that runs the resolver.
+const dnsLabel = "exitbox.dns-resolver"
+
+// dnsConfigFile returns the path of the rendered resolver configuration.
+func dnsConfigFile() string {
+	return filepath.Join(config.Cache, "squid-dns.json")
+}
+
+// ResolverConfig converts DNS settings to the resolver configuration. Without
+// plain servers the resolver uses the proxy container's default servers.
+func ResolverConfig(s config.DNSConfig) *dns.Config {
+	cfg := &dns.Config{
+		Servers: s.Servers,
+		DoH:     s.DoH,
+		Hosts:   s.Hosts,
+	}
+	if len(cfg.Servers) == 0 {
+		cfg.Servers = getSquidDNSServers()
+	}
+	for _, z := range s.Zones {
+		cfg.Zones = append(cfg.Zones, dns.Zone{Name: z.Zone, Servers: z.Servers})
+	}
+	return cfg
+}
+
+// writeDNSConfig renders the DNS settings for the resolver and reports
+// whether any are configured. Invalid settings are an error rather than a
+// silent fallback, so internal names never leak to public resolvers.
+func writeDNSConfig() (bool, error) {
+	settings := config.LoadOrDefault().Settings.DNS
+	cfg := ResolverConfig(settings)
+	if err := cfg.Validate(); err != nil {
+		return false, fmt.Errorf("invalid dns settings: %w", err)
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dnsConfigFile()), 0755); err != nil {
+		return false, err
+	}
+	// Written in place: the file is bind-mounted, so it must keep its inode.
+	if err := os.WriteFile(dnsConfigFile(), data, 0644); err != nil {
+		return false, err
+	}
+	return !settings.IsEmpty(), nil
+}
+
+// squidContainerDNS returns the servers passed to the Squid container with
+// --dns. The resolver looks up DoH hosts through them, so configured plain
+// servers take precedence over the defaults.
+func squidContainerDNS(s config.DNSConfig) []string {
+	var servers []string
+	for _, srv := range s.Servers {
+		if net.ParseIP(srv) != nil {
+			servers = append(servers, srv)
+		}
+	}
+	if len(servers) == 0 {
+		return getSquidDNSServers()
+	}
+	return servers
+}
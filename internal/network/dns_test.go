@@ -0,0 +1,88 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/dns"
+)
+
+func TestResolverConfig(t *testing.T) {
+	os.Unsetenv("EXITBOX_SQUID_DNS")
+
+	cfg := ResolverConfig(config.DNSConfig{})
+	if !reflect.DeepEqual(cfg.Servers, []string{"1.1.1.1", "8.8.8.8"}) {
+		t.Errorf("default servers = %v", cfg.Servers)
+	}
+
+	cfg = ResolverConfig(config.DNSConfig{
+		Servers: []string{"10.0.0.53"},
+		DoH:     []string{"https://dns.example/dns-query"},
+		Zones:   []config.DNSZone{{Zone: "corp.example", Servers: []string{"10.0.0.54:53"}}},
+		Hosts:   map[string]string{"git.corp.example": "10.1.2.3"},
+	})
+	want := &dns.Config{
+		Servers: []string{"10.0.0.53"},
+		DoH:     []string{"https://dns.example/dns-query"},
+		Zones:   []dns.Zone{{Name: "corp.example", Servers: []string{"10.0.0.54:53"}}},
+		Hosts:   map[string]string{"git.corp.example": "10.1.2.3"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ResolverConfig = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestWriteDNSConfig(t *testing.T) {
+	useTempConfig(t)
+	os.Unsetenv("EXITBOX_SQUID_DNS")
+
+	enabled, err := writeDNSConfig()
+	if err != nil || enabled {
+		t.Fatalf("without settings: enabled=%v err=%v", enabled, err)
+	}
+	if _, err := dns.LoadConfig(dnsConfigFile()); err != nil {
+		t.Errorf("rendered default config does not load: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Settings.DNS.Zones = []config.DNSZone{{Zone: "corp.example", Servers: []string{"10.0.0.53"}}}
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, err := writeDNSConfig(); err != nil || !enabled {
+		t.Fatalf("with settings: enabled=%v err=%v", enabled, err)
+	}
+	loaded, err := dns.LoadConfig(dnsConfigFile())
+	if err != nil || len(loaded.Zones) != 1 {
+		t.Errorf("rendered config = %+v, err %v", loaded, err)
+	}
+
+	cfg.Settings.DNS.Zones[0].Servers = []string{"corp-dns.example"}
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeDNSConfig(); err == nil {
+		t.Error("invalid settings should be an error")
+	}
+}
+
+func TestSquidContainerDNS(t *testing.T) {
+	os.Unsetenv("EXITBOX_SQUID_DNS")
+	got := squidContainerDNS(config.DNSConfig{Servers: []string{"10.0.0.53", "10.0.0.54:5353"}})
+	if !reflect.DeepEqual(got, []string{"10.0.0.53"}) {
+		t.Errorf("squidContainerDNS = %v", got)
+	}
+	if got := squidContainerDNS(config.DNSConfig{}); !reflect.DeepEqual(got, []string{"1.1.1.1", "8.8.8.8"}) {
+		t.Errorf("default squidContainerDNS = %v", got)
+	}
+}
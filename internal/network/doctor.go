@@ -0,0 +1,265 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// deniedProbeDomain is a destination no allowlist should contain.
+const deniedProbeDomain = "exitbox-doctor.invalid"
+
+// probeScript sends a CONNECT for each domain through the proxy and prints
+// the status Squid answered with, or "error" when it could not ask.
+const probeScript = `import socket, sys
+proxy = sys.argv[1]
+for d in sys.argv[2:]:
+    try:
+        s = socket.create_connection((proxy, 3128), timeout=20)
+        s.sendall(("CONNECT %s:443 HTTP/1.1\r\nHost: %s:443\r\n\r\n" % (d, d)).encode())
+        f = s.makefile("rb")
+        parts = f.readline().decode("latin-1").split()
+        print(d, parts[1] if len(parts) > 1 else "error")
+        s.close()
+    except Exception:
+        print(d, "error")
+`
+
+// Check is the outcome of one firewall health check. Fix says what to do
+// about a failed check.
+type Check struct {
+	Name   string
+	OK     bool
+	Detail string
+	Fix    string
+}
+
+// Doctor checks that the firewall can do its job: the networks exist and
+// the internal one has no route out, Squid is running on both of them
+// with an address agents can use, it can resolve names, and it lets an
+// allowlisted domain through while refusing anything else. Checks that
+// depend on a failed one are skipped.
+func Doctor(rt container.Runtime) []Check {
+	cmd := container.Cmd(rt)
+	var checks []Check
+	add := func(c Check) bool {
+		checks = append(checks, c)
+		return c.OK
+	}
+
+	networksOK := true
+	for _, n := range []struct {
+		name     string
+		internal bool
+	}{{InternalNetwork, true}, {EgressNetwork, false}} {
+		flag := ""
+		if n.internal {
+			flag = "--internal "
+		}
+		networksOK = add(Check{
+			Name:   fmt.Sprintf("Network %s exists", n.name),
+			OK:     rt.NetworkExists(n.name),
+			Detail: "not found",
+			Fix:    fmt.Sprintf("Start an agent to create it, or run: %s network create %s%s", cmd, flag, n.name),
+		}) && networksOK
+	}
+	if rt.NetworkExists(InternalNetwork) {
+		internal, err := rt.NetworkInspect(InternalNetwork, "{{.Internal}}")
+		detail := "agents on it can reach the internet without the proxy"
+		if err != nil {
+			detail = fmt.Sprintf("could not inspect: %v", err)
+		}
+		add(Check{
+			Name:   fmt.Sprintf("Network %s is internal", InternalNetwork),
+			OK:     err == nil && internal == "true",
+			Detail: detail,
+			Fix: fmt.Sprintf("Stop all agents, run '%s network rm %s', then start an agent to recreate it with --internal",
+				cmd, InternalNetwork),
+		})
+	}
+
+	if !add(Check{
+		Name:   "Proxy is running",
+		OK:     squidRunning(rt),
+		Detail: SquidContainer + " is not running",
+		Fix:    fmt.Sprintf("Start an agent, or see '%s logs %s' for why the proxy exited", cmd, SquidContainer),
+	}) || !networksOK {
+		return checks
+	}
+
+	attached := squidNetworks(rt)
+	for _, n := range []string{InternalNetwork, EgressNetwork} {
+		fix := fmt.Sprintf("Run: %s network connect %s %s", cmd, n, SquidContainer)
+		if n == EgressNetwork {
+			fix = fmt.Sprintf("Stop all agents and run '%s rm -f %s'; the proxy is recreated on the next run", cmd, SquidContainer)
+		}
+		add(Check{
+			Name:   fmt.Sprintf("Proxy is attached to %s", n),
+			OK:     attached[n],
+			Detail: "not attached",
+			Fix:    fix,
+		})
+	}
+
+	// The address GetProxyEnvVars hands to agents.
+	proxyHost := containerIP(rt, SquidContainer)
+	detail := proxyHost
+	if proxyHost == "" {
+		detail = fmt.Sprintf("no address on %s; agents fall back to the name %s", InternalNetwork, SquidContainer)
+		proxyHost = SquidContainer
+	}
+	add(Check{
+		Name:   "Proxy address for agents",
+		OK:     proxyHost != SquidContainer,
+		Detail: detail,
+		Fix: fmt.Sprintf("Run: %s network disconnect %s %s && %s network connect %s %s",
+			cmd, InternalNetwork, SquidContainer, cmd, InternalNetwork, SquidContainer),
+	})
+
+	allowed := probeDomain()
+	if allowed == "" {
+		add(Check{
+			Name:   "Allowlisted domain is reachable",
+			OK:     false,
+			Detail: "the allowlist has no domain to test with",
+			Fix:    "Add a domain to the allowlist with 'exitbox setup' or in allowlist.yaml",
+		})
+		return checks
+	}
+
+	dnsArgs := []string{"exec", SquidContainer, "nslookup", allowed}
+	if squidHasLabel(rt, dnsLabel) && strings.Contains(readSquidConf(), "dns_nameservers 127.0.0.1") {
+		dnsArgs = append(dnsArgs, "127.0.0.1")
+	}
+	out, err := exec.Command(cmd, dnsArgs...).CombinedOutput()
+	add(Check{
+		Name:   "Proxy can resolve names",
+		OK:     err == nil,
+		Detail: fmt.Sprintf("looking up %s failed: %s", allowed, lastLine(string(out))),
+		Fix:    "Check that the DNS servers (settings.dns or EXITBOX_SQUID_DNS) are reachable from the egress network",
+	})
+
+	statuses, err := probeProxy(cmd, proxyHost, allowed, deniedProbeDomain)
+	if !add(Check{
+		Name:   "Agents can reach the proxy",
+		OK:     err == nil && (statuses[allowed] != "error" || statuses[deniedProbeDomain] != "error"),
+		Detail: fmt.Sprintf("no answer from %s:3128%s", proxyHost, errSuffix(err)),
+		Fix:    fmt.Sprintf("Check '%s logs %s'; restarting the proxy with '%s rm -f %s' and starting an agent usually helps", cmd, SquidContainer, cmd, SquidContainer),
+	}) {
+		return checks
+	}
+
+	status := statuses[allowed]
+	fix := "Check 'exitbox network log --denied' and the generated " + filepath.Join(config.Cache, "squid.conf")
+	if status == "502" || status == "503" || status == "504" {
+		fix = "The proxy could not connect: check DNS, the upstream proxy settings and the host's internet access"
+	}
+	add(Check{
+		Name:   fmt.Sprintf("Allowlisted domain %s is allowed", allowed),
+		OK:     status == "200",
+		Detail: "proxy answered " + status,
+		Fix:    fix,
+	})
+	add(Check{
+		Name:   "Other domains are refused",
+		OK:     statuses[deniedProbeDomain] == "403",
+		Detail: fmt.Sprintf("proxy answered %s for %s", statuses[deniedProbeDomain], deniedProbeDomain),
+		Fix:    fmt.Sprintf("Look for overly broad allowlist entries, then restart the proxy with '%s rm -f %s'", cmd, SquidContainer),
+	})
+	return checks
+}
+
+// squidNetworks returns the networks the Squid container is attached to.
+func squidNetworks(rt container.Runtime) map[string]bool {
+	out, err := exec.Command(container.Cmd(rt), "inspect", SquidContainer,
+		"--format", `{{range $name, $n := .NetworkSettings.Networks}}{{$name}} {{end}}`).Output()
+	nets := make(map[string]bool)
+	if err != nil {
+		return nets
+	}
+	for _, n := range strings.Fields(string(out)) {
+		nets[n] = true
+	}
+	return nets
+}
+
+// probeProxy asks the proxy for each domain from a throwaway container on
+// the internal network, as an agent would, and returns the answers.
+func probeProxy(cmd, proxyHost string, domains ...string) (map[string]string, error) {
+	args := []string{"run", "--rm", "--network", InternalNetwork, "exitbox-squid", "python3", "-c", probeScript, proxyHost}
+	out, err := exec.Command(cmd, append(args, domains...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseProbeOutput(string(out)), nil
+}
+
+// parseProbeOutput reads the "<domain> <status>" lines of the probe.
+func parseProbeOutput(out string) map[string]string {
+	statuses := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			statuses[fields[0]] = fields[1]
+		}
+	}
+	return statuses
+}
+
+// probeDomain returns an allowlisted hostname to test with. Domains with
+// HTTP rules are skipped, since CONNECT to them may be refused by design.
+func probeDomain() string {
+	al := config.LoadAllowlistOrDefault()
+	restricted := make(map[string]bool)
+	for _, d := range buildHTTPRules(al.HTTPRules).domains {
+		restricted[d] = true
+	}
+	for _, d := range al.AllDomains() {
+		normalized, err := NormalizeAllowlistEntry(d)
+		if err != nil || restricted[normalized] || normalized == "localhost" || net.ParseIP(normalized) != nil {
+			continue
+		}
+		return strings.TrimPrefix(normalized, ".")
+	}
+	return ""
+}
+
+// readSquidConf returns the generated Squid config.
+func readSquidConf() string {
+	data, _ := os.ReadFile(filepath.Join(config.Cache, "squid.conf"))
+	return string(data)
+}
+
+// lastLine returns the last non-empty line of command output.
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func errSuffix(err error) string {
+	if err == nil {
+		return ""
+	}
+	return fmt.Sprintf(" (%v)", err)
+}
@@ -0,0 +1,46 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestParseProbeOutput(t *testing.T) {
+	got := parseProbeOutput("github.com 200\nexitbox-doctor.invalid 403\n\ngarbage\n")
+	if len(got) != 2 || got["github.com"] != "200" || got["exitbox-doctor.invalid"] != "403" {
+		t.Errorf("parseProbeOutput = %v", got)
+	}
+}
+
+func TestProbeDomain(t *testing.T) {
+	useTempConfig(t)
+	save := func(al *config.Allowlist) {
+		t.Helper()
+		if err := config.SaveAllowlistTo(al, config.AllowlistFile()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	save(&config.Allowlist{
+		Version:     1,
+		Development: []string{"10.0.0.1", "localhost", "api.github.com", "*.npmjs.org"},
+		HTTPRules:   []config.HTTPRule{{Domain: "api.github.com", Methods: []string{"GET"}}},
+	})
+	if got := probeDomain(); got != "npmjs.org" {
+		t.Errorf("probeDomain() = %q, want npmjs.org", got)
+	}
+
+	save(&config.Allowlist{Version: 1, Custom: []string{"192.0.2.1"}})
+	if got := probeDomain(); got != "" {
+		t.Errorf("probeDomain() = %q, want none", got)
+	}
+}
@@ -0,0 +1,383 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/profile"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// Egress limits come from default_flags and the workspace and are
+// snapshotted per session, since config.yaml is writable from inside the
+// container. Squid enforces concurrent connections (maxconn) and bandwidth
+// (delay pools) itself. The session transfer quota and the per-domain
+// request rate are counted on the host from the access log: once one is
+// exceeded the session is reloaded with a deny rule that answers 429.
+
+// limitsLabel marks a Squid container whose image has the limit error pages.
+const limitsLabel = "exitbox.egress-limits"
+
+// Error pages for requests refused by a limit, installed by the image.
+const (
+	errPageRate        = "ERR_EXITBOX_RATE"
+	errPageQuota       = "ERR_EXITBOX_QUOTA"
+	errPageConnections = "ERR_EXITBOX_CONNECTIONS"
+)
+
+// SessionUsage is what a session has used of its egress limits.
+type SessionUsage struct {
+	Container string
+	Limits    config.EgressLimits
+	Bytes     int64
+	Requests  int
+	OverQuota bool
+	Throttled []string // domains over the request rate
+}
+
+var byteSizeRE = regexp.MustCompile(`(?i)^\s*(\d+)\s*([kmgt]?)(i?b)?\s*(/s)?\s*$`)
+
+// parseByteSize parses sizes such as "512K", "10MB" or "1GiB" (binary
+// units). An empty string is zero.
+func parseByteSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m := byteSizeRE.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	shift := strings.Index("kmgt", strings.ToLower(m[2])) + 1
+	if m[2] == "" {
+		shift = 0
+	}
+	return n << (10 * shift), nil
+}
+
+// validateLimits checks that the limits can be enforced.
+func validateLimits(l config.EgressLimits) error {
+	if _, err := parseByteSize(l.Bandwidth); err != nil {
+		return fmt.Errorf("bandwidth: %w", err)
+	}
+	if _, err := parseByteSize(l.SessionBytes); err != nil {
+		return fmt.Errorf("session_bytes: %w", err)
+	}
+	if l.RequestsPerMinute < 0 || l.MaxConnections < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// RegisterSessionLimits snapshots the egress limits for a container: the
+// defaults from default_flags, overridden by the workspace's own limits.
+func RegisterSessionLimits(containerName, workspace string) error {
+	cfg := config.LoadOrDefault()
+	limits := cfg.Settings.DefaultFlags.Egress
+	if workspace != "" {
+		if w := profile.FindWorkspace(cfg, workspace); w != nil && w.Egress != nil {
+			limits = limits.Merge(*w.Egress)
+		}
+	}
+	if err := validateLimits(limits); err != nil {
+		return err
+	}
+	if limits.IsEmpty() {
+		return nil
+	}
+	data, err := yaml.Marshal(limits)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sessionDir(), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(sessionDir(), containerName+".limits"), data, 0644)
+}
+
+// readSessionLimits returns a session's egress limits.
+func readSessionLimits(containerName string) config.EgressLimits {
+	var l config.EgressLimits
+	data, err := os.ReadFile(filepath.Join(sessionDir(), containerName+".limits"))
+	if err != nil {
+		return l
+	}
+	if err := yaml.Unmarshal(data, &l); err != nil || validateLimits(l) != nil {
+		return config.EgressLimits{}
+	}
+	return l
+}
+
+// readSessionUsage returns a session's recorded usage.
+func readSessionUsage(containerName string) SessionUsage {
+	u := SessionUsage{Container: containerName, Limits: readSessionLimits(containerName)}
+	for _, line := range readLines(filepath.Join(sessionDir(), containerName+".usage")) {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "bytes":
+			u.Bytes, _ = strconv.ParseInt(value, 10, 64)
+		case "requests":
+			u.Requests, _ = strconv.Atoi(value)
+		case "over_quota":
+			u.OverQuota = value == "true"
+		}
+	}
+	u.Throttled = throttledDomains(containerName, time.Now())
+	return u
+}
+
+// writeSessionUsage records a session's usage.
+func writeSessionUsage(u SessionUsage) error {
+	content := fmt.Sprintf("bytes=%d\nrequests=%d\nover_quota=%t\n", u.Bytes, u.Requests, u.OverQuota)
+	return os.WriteFile(filepath.Join(sessionDir(), u.Container+".usage"), []byte(content), 0644)
+}
+
+// throttledDomains returns the domains a session is currently refused for
+// exceeding the request rate.
+func throttledDomains(containerName string, now time.Time) []string {
+	var domains []string
+	for _, line := range readLines(filepath.Join(sessionDir(), containerName+".throttle")) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		until, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || now.Unix() >= until {
+			continue
+		}
+		domains = append(domains, fields[1])
+	}
+	return domains
+}
+
+// SessionUsages returns the usage of every session with egress limits,
+// sorted by container name.
+func SessionUsages() []SessionUsage {
+	entries, err := os.ReadDir(sessionDir())
+	if err != nil {
+		return nil
+	}
+	var out []SessionUsage
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".limits"); ok {
+			out = append(out, readSessionUsage(name))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Container < out[j].Container })
+	return out
+}
+
+// applyLimits adds a container's limits and the limits it has exceeded to
+// its policy.
+func applyLimits(p *ContainerPolicy) {
+	u := readSessionUsage(p.Container)
+	p.Limits = u.Limits
+	p.OverQuota = u.OverQuota && u.Limits.SessionBytes != ""
+	p.Throttled = u.Throttled
+}
+
+// quotaWatcher counts a session's requests in the access log.
+type quotaWatcher struct {
+	reload  func() error
+	usage   SessionUsage
+	quota   int64
+	started time.Time
+	recent  map[string][]time.Time // per domain, within the last minute
+	written time.Time
+}
+
+// WatchSessionQuota enforces a session's transfer quota and per-domain
+// request rate and records its usage, until done is closed. Requests are
+// counted when Squid logs them, which for HTTPS is when the tunnel closes.
+func WatchSessionQuota(rt container.Runtime, containerName string, done <-chan struct{}) {
+	limits := readSessionLimits(containerName)
+	if limits.IsEmpty() {
+		return
+	}
+	quota, _ := parseByteSize(limits.SessionBytes)
+	w := &quotaWatcher{
+		reload:  func() error { return ReloadSquid(rt) },
+		usage:   SessionUsage{Container: containerName, Limits: limits},
+		quota:   quota,
+		started: time.Now(),
+		recent:  make(map[string][]time.Time),
+	}
+	err := TailAccessLog(true, done, func(e AccessEntry) {
+		if e.Container == containerName && !e.Time.Before(w.started) {
+			w.record(e, time.Now())
+		}
+	})
+	if err != nil {
+		ui.Warnf("Failed to watch egress limits: %v", err)
+	}
+}
+
+// record counts one request and applies any limit it exceeds.
+func (w *quotaWatcher) record(e AccessEntry, now time.Time) {
+	if e.Denied() {
+		return
+	}
+	w.usage.Requests++
+	w.usage.Bytes += e.Bytes
+	changed := false
+
+	if w.quota > 0 && !w.usage.OverQuota && w.usage.Bytes >= w.quota {
+		w.usage.OverQuota = true
+		ui.Warnf("%s used its egress quota of %s; further requests are refused", w.usage.Container, w.usage.Limits.SessionBytes)
+		changed = true
+	}
+
+	if rpm := w.usage.Limits.RequestsPerMinute; rpm > 0 && e.Domain != "" {
+		domain := strings.ToLower(e.Domain)
+		window := append(w.recent[domain], e.Time)
+		for len(window) > 0 && !window[0].After(e.Time.Add(-time.Minute)) {
+			window = window[1:]
+		}
+		if len(window) > rpm {
+			window = window[len(window)-rpm:]
+		}
+		w.recent[domain] = window
+		if len(window) == rpm {
+			if until := window[0].Add(time.Minute); until.After(now) {
+				if err := w.throttle(domain, until); err != nil {
+					ui.Warnf("Failed to apply request rate limit: %v", err)
+				} else {
+					changed = true
+				}
+			}
+		}
+	}
+
+	if changed || now.Sub(w.written) >= time.Second {
+		w.written = now
+		if err := writeSessionUsage(w.usage); err != nil {
+			ui.Warnf("Failed to record egress usage: %v", err)
+		}
+	}
+	if changed {
+		if err := w.reload(); err != nil {
+			ui.Warnf("Failed to apply egress limits: %v", err)
+		}
+	}
+}
+
+// throttle refuses a domain to the session until the given time and
+// reloads Squid again once it has passed.
+func (w *quotaWatcher) throttle(domain string, until time.Time) error {
+	for _, d := range throttledDomains(w.usage.Container, time.Now()) {
+		if d == domain {
+			return nil
+		}
+	}
+	path := filepath.Join(sessionDir(), w.usage.Container+".throttle")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%d %s\n", until.Unix()+1, domain)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	time.AfterFunc(time.Until(until)+2*time.Second, func() {
+		_ = w.reload()
+	})
+	return nil
+}
+
+// writeLimitACLs renders the ACLs for containers with limits and returns
+// the http_access rules that refuse requests over them. They must come
+// before any rule that allows a request.
+func writeLimitACLs(b *strings.Builder, policies []ContainerPolicy, customPages bool) []string {
+	page := func(name string) string {
+		if customPages {
+			return "429:" + name
+		}
+		return "429:ERR_ACCESS_DENIED"
+	}
+	var rules []string
+	for _, p := range policies {
+		if p.IP == "" || p.Limits.IsEmpty() {
+			continue
+		}
+		name := containerACLName(p.Container)
+		fmt.Fprintf(b, "\n# Limits: %s\n", p.Container)
+		fmt.Fprintf(b, "acl %s_limited src %s\n", name, hostCIDR(p.IP))
+		if p.OverQuota {
+			fmt.Fprintf(b, "acl %s_over_quota src %s\n", name, hostCIDR(p.IP))
+			fmt.Fprintf(b, "deny_info %s %s_over_quota\n", page(errPageQuota), name)
+			rules = append(rules, fmt.Sprintf("http_access deny %s_over_quota\n", name))
+		}
+		if len(p.Throttled) > 0 {
+			for _, d := range p.Throttled {
+				fmt.Fprintf(b, "acl %s_throttled dstdomain %s\n", name, d)
+			}
+			fmt.Fprintf(b, "deny_info %s %s_throttled\n", page(errPageRate), name)
+			rules = append(rules, fmt.Sprintf("http_access deny %s_limited %s_throttled\n", name, name))
+		}
+		if p.Limits.MaxConnections > 0 {
+			fmt.Fprintf(b, "acl %s_maxconn maxconn %d\n", name, p.Limits.MaxConnections)
+			fmt.Fprintf(b, "deny_info %s %s_maxconn\n", page(errPageConnections), name)
+			rules = append(rules, fmt.Sprintf("http_access deny %s_limited %s_maxconn\n", name, name))
+		}
+	}
+	return rules
+}
+
+// writeDelayPools renders one delay pool per container with a bandwidth
+// limit.
+func writeDelayPools(b *strings.Builder, policies []ContainerPolicy) {
+	type pool struct {
+		name string
+		rate int64
+	}
+	var pools []pool
+	for _, p := range policies {
+		if p.IP == "" {
+			continue
+		}
+		if rate, err := parseByteSize(p.Limits.Bandwidth); err == nil && rate > 0 {
+			pools = append(pools, pool{containerACLName(p.Container), rate})
+		}
+	}
+	if len(pools) == 0 {
+		return
+	}
+	b.WriteString("\n# Bandwidth limits\n")
+	fmt.Fprintf(b, "delay_pools %d\n", len(pools))
+	for i, p := range pools {
+		n := i + 1
+		fmt.Fprintf(b, "delay_class %d 1\n", n)
+		fmt.Fprintf(b, "delay_parameters %d %d/%d\n", n, p.rate, p.rate)
+		fmt.Fprintf(b, "delay_access %d allow %s_limited\n", n, p.name)
+		fmt.Fprintf(b, "delay_access %d deny all\n", n)
+	}
+}
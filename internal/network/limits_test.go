@@ -0,0 +1,160 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"":        0,
+		"100":     100,
+		"512K":    512 << 10,
+		"10MB":    10 << 20,
+		"1GiB":    1 << 30,
+		"2 gb":    2 << 30,
+		"256KB/s": 256 << 10,
+	}
+	for in, want := range tests {
+		got, err := parseByteSize(in)
+		if err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"fast", "1.5G", "-1M", "10X"} {
+		if _, err := parseByteSize(bad); err == nil {
+			t.Errorf("parseByteSize(%q) accepted", bad)
+		}
+	}
+}
+
+func TestRegisterSessionLimits(t *testing.T) {
+	useTempConfig(t)
+	cfg := config.DefaultConfig()
+	cfg.Settings.DefaultFlags.Egress = config.EgressLimits{RequestsPerMinute: 60, Bandwidth: "1MB"}
+	cfg.Workspaces.Items = []config.Workspace{
+		{Name: "ci", Egress: &config.EgressLimits{RequestsPerMinute: 10}},
+		{Name: "bad", Egress: &config.EgressLimits{SessionBytes: "lots"}},
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RegisterSessionLimits("exitbox-claude-ci-1", "ci"); err != nil {
+		t.Fatal(err)
+	}
+	got := readSessionLimits("exitbox-claude-ci-1")
+	want := config.EgressLimits{RequestsPerMinute: 10, Bandwidth: "1MB"}
+	if got != want {
+		t.Errorf("limits = %+v, want %+v", got, want)
+	}
+	if err := RegisterSessionLimits("exitbox-claude-bad-1", "bad"); err == nil {
+		t.Error("invalid workspace limits accepted")
+	}
+}
+
+func TestQuotaWatcherThrottlesDomain(t *testing.T) {
+	useTempConfig(t)
+	name := "exitbox-claude-loop-1"
+	if err := os.MkdirAll(sessionDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	reloads := 0
+	w := &quotaWatcher{
+		reload: func() error { reloads++; return nil },
+		usage:  SessionUsage{Container: name, Limits: config.EgressLimits{RequestsPerMinute: 3, SessionBytes: "1K"}},
+		quota:  1024,
+		recent: make(map[string][]time.Time),
+	}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		w.record(AccessEntry{Time: now.Add(time.Duration(i) * time.Second), Domain: "registry.example", Bytes: 10, HTTPStatus: 200}, now.Add(3*time.Second))
+	}
+	throttled := throttledDomains(name, now.Add(3*time.Second))
+	if len(throttled) != 1 || throttled[0] != "registry.example" {
+		t.Fatalf("throttled = %v", throttled)
+	}
+	if got := throttledDomains(name, now.Add(62*time.Second)); len(got) != 0 {
+		t.Errorf("throttle should expire a minute after the first request, got %v", got)
+	}
+
+	if reloads != 1 {
+		t.Errorf("reloaded %d times, want 1", reloads)
+	}
+
+	// Crossing the transfer quota refuses everything else.
+	w.record(AccessEntry{Time: now.Add(4 * time.Second), Domain: "cdn.example", Bytes: 1000, HTTPStatus: 200}, now.Add(4*time.Second))
+	u := readSessionUsage(name)
+	if u.Requests != 4 || u.Bytes != 1030 || !u.OverQuota {
+		t.Errorf("usage = %d requests, %d bytes, over quota %v", u.Requests, u.Bytes, u.OverQuota)
+	}
+	if reloads != 2 {
+		t.Errorf("reloaded %d times, want 2", reloads)
+	}
+}
+
+func TestGenerateSquidConfig_Limits(t *testing.T) {
+	app1 := containerACLName("exitbox-claude-app-1")
+	app2 := containerACLName("exitbox-codex-app-2")
+	policies := []ContainerPolicy{
+		{
+			Container: "exitbox-claude-app-1",
+			IP:        "10.89.0.5",
+			Limits:    config.EgressLimits{Bandwidth: "1MB", MaxConnections: 8, RequestsPerMinute: 30},
+			Throttled: []string{"registry.example"},
+		},
+		{
+			Container: "exitbox-codex-app-2",
+			IP:        "10.89.0.6",
+			Limits:    config.EgressLimits{SessionBytes: "1G"},
+			OverQuota: true,
+		},
+		{Container: "exitbox-codex-free-3", IP: "10.89.0.7"},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{LimitPages: true})
+
+	for _, want := range []string{
+		"acl " + app1 + "_limited src 10.89.0.5/32\n",
+		"acl " + app1 + "_throttled dstdomain registry.example\n",
+		"deny_info 429:ERR_EXITBOX_RATE " + app1 + "_throttled\n",
+		"http_access deny " + app1 + "_limited " + app1 + "_throttled\n",
+		"acl " + app1 + "_maxconn maxconn 8\n",
+		"deny_info 429:ERR_EXITBOX_CONNECTIONS " + app1 + "_maxconn\n",
+		"http_access deny " + app2 + "_over_quota\n",
+		"deny_info 429:ERR_EXITBOX_QUOTA " + app2 + "_over_quota\n",
+		"delay_pools 1\n",
+		"delay_parameters 1 1048576/1048576\n",
+		"delay_access 1 allow " + app1 + "_limited\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q", want)
+		}
+	}
+	if strings.Contains(conf, containerACLName("exitbox-codex-free-3")+"_limited") {
+		t.Error("container without limits should get no limit rules")
+	}
+
+	// Limit denials come before any allow rule.
+	deny := strings.Index(conf, "http_access deny "+app2+"_over_quota")
+	allow := strings.Index(conf, "http_access allow agent_sources allowed_domains")
+	if deny < 0 || allow < 0 || deny > allow {
+		t.Error("limit denials must precede the allowlist")
+	}
+
+	conf = GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
+	if strings.Contains(conf, "ERR_EXITBOX_") || !strings.Contains(conf, "deny_info 429:ERR_ACCESS_DENIED") {
+		t.Error("without the image's pages limits should use the stock error page")
+	}
+}
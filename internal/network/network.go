@@ -88,78 +88,6 @@ func GetNetworkSubnet(rt container.Runtime, networkName string) (string, error)
 	return "", fmt.Errorf("could not detect subnet for %s", networkName)
 }
 
-// sessionDir returns the directory for per-container session URL files.
-func sessionDir() string {
-	return filepath.Join(config.Cache, "squid-sessions")
-}
-
-// RegisterSessionURLs writes a session file for a container's extra URLs.
-func RegisterSessionURLs(containerName string, urls []string) error {
-	dir := sessionDir()
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-	content := strings.Join(urls, "\n") + "\n"
-	return os.WriteFile(filepath.Join(dir, containerName+".urls"), []byte(content), 0644)
-}
-
-// RemoveSessionURLs removes a container's session file and regenerates squid config.
-func RemoveSessionURLs(rt container.Runtime, containerName string) {
-	dir := sessionDir()
-	_ = os.Remove(filepath.Join(dir, containerName+".urls"))
-
-	// Collect remaining URLs from all sessions and regenerate config
-	remaining := collectAllSessionURLs()
-	if err := writeSquidConfig(rt, remaining); err != nil {
-		ui.Warnf("Failed to regenerate squid config: %v", err)
-		return
-	}
-
-	// Reconfigure squid if running
-	cmd := container.Cmd(rt)
-	names, err := rt.PS("", "{{.Names}}")
-	if err != nil {
-		ui.Warnf("Failed to list containers: %v", err)
-		return
-	}
-	for _, n := range names {
-		if n == SquidContainer {
-			if recErr := exec.Command(cmd, "exec", SquidContainer, "squid", "-k", "reconfigure").Run(); recErr != nil {
-				ui.Warnf("Failed to reconfigure squid: %v", recErr)
-			}
-			break
-		}
-	}
-}
-
-// collectAllSessionURLs reads all session files and returns deduplicated URLs.
-func collectAllSessionURLs() []string {
-	dir := sessionDir()
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil
-	}
-	seen := make(map[string]bool)
-	var urls []string
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".urls") {
-			continue
-		}
-		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
-		if err != nil {
-			continue
-		}
-		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
-			line = strings.TrimSpace(line)
-			if line != "" && !seen[line] {
-				seen[line] = true
-				urls = append(urls, line)
-			}
-		}
-	}
-	return urls
-}
-
 // StartSquidProxy starts the Squid proxy container.
 func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []string) error {
 	cmd := container.Cmd(rt)
@@ -171,25 +99,27 @@ func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []str
 		}
 	}
 
-	// Collect all session URLs for config generation
-	allExtraURLs := collectAllSessionURLs()
+	tlsInspection := TLSInspectionEnabled()
+	settings := config.LoadOrDefault().Settings
+	dnsSettings := settings.DNS
 
 	// Check if already running
-	names, err := rt.PS("", "{{.Names}}")
-	if err != nil {
-		ui.Warnf("Failed to list containers: %v", err)
-	}
-	for _, n := range names {
-		if n == SquidContainer {
-			// Regenerate config with all session URLs and reload
-			if err := writeSquidConfig(rt, allExtraURLs); err != nil {
-				return err
-			}
-			if recErr := exec.Command(cmd, "exec", SquidContainer, "squid", "-k", "reconfigure").Run(); recErr != nil {
-				ui.Warnf("Failed to reconfigure squid: %v", recErr)
-			}
-			return nil
+	if squidRunning(rt) {
+		if tlsInspection && !squidHasTLS(rt) {
+			ui.Warn("HTTP rules need TLS inspection, which starts with the next proxy restart. Until then HTTPS to restricted domains is blocked.")
+		}
+		if !dnsSettings.IsEmpty() && !squidHasLabel(rt, dnsLabel) {
+			ui.Warn("DNS settings apply from the next proxy restart.")
 		}
+		if settings.UpstreamProxy.URL != "" && !squidHasLabel(rt, upstreamLabel) {
+			ui.Warn("The upstream proxy applies from the next proxy restart.")
+		}
+		// Regenerate config with all session policies and reload
+		if err := writeSquidConfig(rt); err != nil {
+			return err
+		}
+		reconfigureSquid(rt)
+		return nil
 	}
 
 	// Remove if stopped
@@ -199,23 +129,45 @@ func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []str
 	EnsureNetworks(rt)
 
 	// Generate config
-	if err := writeSquidConfig(rt, allExtraURLs); err != nil {
+	if err := writeSquidConfig(rt); err != nil {
 		return err
 	}
 
 	configFile := filepath.Join(config.Cache, "squid.conf")
 
+	// Access log directory, kept on the host so the log survives the proxy.
+	if err := ensureNetworkLogDir(); err != nil {
+		return fmt.Errorf("failed to create network log directory: %w", err)
+	}
+
 	runArgs := []string{
 		"run", "-d",
 		"--name", SquidContainer,
 		"--network", EgressNetwork,
 		"-v", configFile + ":/etc/squid/squid.conf",
+		"-v", config.NetworkLogDir() + ":" + squidLogDir,
+		"-v", dnsConfigFile() + ":" + squidDNSConfig + ":ro",
+		"--label", dnsLabel + "=true",
+		"-v", upstreamConfigFile() + ":" + squidUpstreamMount + ":ro",
+		"--label", upstreamLabel + "=true",
+		"--label", limitsLabel + "=true",
 		"--restart=unless-stopped",
 		"--add-host=host.docker.internal:host-gateway",
 	}
 
+	// TLS inspection CA, copied into place by the image at start.
+	if tlsInspection {
+		if err := ensureCA(); err != nil {
+			return err
+		}
+		runArgs = append(runArgs,
+			"-v", config.TLSDir()+":"+squidCADir+":ro",
+			"--label", tlsLabel+"=true",
+		)
+	}
+
 	// DNS flags
-	dnsServers := getSquidDNSServers()
+	dnsServers := squidContainerDNS(dnsSettings)
 	for _, dns := range dnsServers {
 		runArgs = append(runArgs, "--dns", dns)
 	}
@@ -239,14 +191,10 @@ func StartSquidProxy(rt container.Runtime, containerName string, extraURLs []str
 
 // GetProxyEnvVars returns proxy environment variable flags for container run.
 func GetProxyEnvVars(rt container.Runtime) []string {
-	cmd := container.Cmd(rt)
-
 	proxyHost := SquidContainer
 	// Try to get IP
-	out, err := exec.Command(cmd, "inspect", SquidContainer,
-		"--format", fmt.Sprintf(`{{with index .NetworkSettings.Networks "%s"}}{{.IPAddress}}{{end}}`, InternalNetwork)).Output()
-	if err == nil && strings.TrimSpace(string(out)) != "" {
-		proxyHost = strings.TrimSpace(string(out))
+	if ip := containerIP(rt, SquidContainer); ip != "" {
+		proxyHost = ip
 	}
 
 	proxyURL := fmt.Sprintf("http://%s:3128", proxyHost)
@@ -290,67 +238,68 @@ func CleanupSquidIfUnused(rt container.Runtime) {
 	if running == 0 {
 		// Clean stale session files
 		_ = os.RemoveAll(sessionDir())
+		clearUpstreamLogin()
 	}
 }
 
-// AddSessionURLAndReload adds a domain to a container's session URLs and
-// hot-reloads Squid so the change takes effect immediately.
-func AddSessionURLAndReload(rt container.Runtime, containerName string, domain string) error {
+// containerIP returns a container's address on the internal network, or ""
+// if the container is not (yet) attached to it.
+func containerIP(rt container.Runtime, name string) string {
 	cmd := container.Cmd(rt)
-	dir := sessionDir()
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	// Read existing session URLs for this container.
-	urlFile := filepath.Join(dir, containerName+".urls")
-	var urls []string
-	if data, err := os.ReadFile(urlFile); err == nil {
-		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
-			line = strings.TrimSpace(line)
-			if line != "" {
-				urls = append(urls, line)
-			}
-		}
-	}
-
-	// Deduplicate: skip if already present.
-	for _, u := range urls {
-		if u == domain {
-			return nil // already allowed
-		}
-	}
-	urls = append(urls, domain)
-
-	// Write back and regenerate config.
-	if err := RegisterSessionURLs(containerName, urls); err != nil {
-		return err
+	out, err := exec.Command(cmd, "inspect", name,
+		"--format", fmt.Sprintf(`{{with index .NetworkSettings.Networks "%s"}}{{.IPAddress}}{{end}}`, InternalNetwork)).Output()
+	if err != nil {
+		return ""
 	}
+	return strings.TrimSpace(string(out))
+}
 
-	allURLs := collectAllSessionURLs()
-	if err := writeSquidConfig(rt, allURLs); err != nil {
-		return err
+// squidHasLabel reports whether the running Squid container carries a
+// feature label with the value "true".
+func squidHasLabel(rt container.Runtime, label string) bool {
+	cmd := container.Cmd(rt)
+	out, err := exec.Command(cmd, "inspect", SquidContainer,
+		"--format", fmt.Sprintf(`{{index .Config.Labels "%s"}}`, label)).Output()
+	if err != nil {
+		return false
 	}
+	return strings.TrimSpace(string(out)) == "true"
+}
 
-	// Hot-reload Squid.
-	names, psErr := rt.PS("", "{{.Names}}")
-	if psErr != nil {
-		ui.Warnf("Failed to list containers for squid reload: %v", psErr)
-		return nil
+// squidRunning reports whether the Squid container is running.
+func squidRunning(rt container.Runtime) bool {
+	names, err := rt.PS("", "{{.Names}}")
+	if err != nil {
+		ui.Warnf("Failed to list containers: %v", err)
+		return false
 	}
 	for _, n := range names {
 		if n == SquidContainer {
-			if recErr := exec.Command(cmd, "exec", SquidContainer, "squid", "-k", "reconfigure").Run(); recErr != nil {
-				ui.Warnf("Failed to reconfigure squid: %v", recErr)
-			}
-			break
+			return true
 		}
 	}
+	return false
+}
 
-	return nil
+// reconfigureSquid hot-reloads Squid if it is running.
+func reconfigureSquid(rt container.Runtime) {
+	if !squidRunning(rt) {
+		return
+	}
+	cmd := container.Cmd(rt)
+	// Refresh the squid user's copy of the cache_peer file first.
+	if squidHasLabel(rt, upstreamLabel) {
+		if err := exec.Command(cmd, "exec", SquidContainer, "install", "-o", "squid", "-g", "squid", "-m", "0600",
+			squidUpstreamMount, squidUpstreamConf).Run(); err != nil {
+			ui.Warnf("Failed to update upstream proxy settings: %v", err)
+		}
+	}
+	if err := exec.Command(cmd, "exec", SquidContainer, "squid", "-k", "reconfigure").Run(); err != nil {
+		ui.Warnf("Failed to reconfigure squid: %v", err)
+	}
 }
 
-func writeSquidConfig(rt container.Runtime, extraURLs []string) error {
+func writeSquidConfig(rt container.Runtime) error {
 	subnet, err := GetNetworkSubnet(rt, InternalNetwork)
 	if err != nil {
 		return fmt.Errorf("could not detect internal network subnet: %w", err)
@@ -359,7 +308,33 @@ func writeSquidConfig(rt container.Runtime, extraURLs []string) error {
 	al := config.LoadAllowlistOrDefault()
 	domains := al.AllDomains()
 
-	content := GenerateSquidConfig(subnet, domains, extraURLs)
+	// A Squid that is not running yet is about to be started with the CA
+	// mounted when there are rules; a running one keeps what it started with.
+	opts := SquidOptions{HTTPRules: al.HTTPRules}
+	running := squidRunning(rt)
+	opts.TLSInspection = len(al.HTTPRules) > 0 && (!running || squidHasTLS(rt))
+
+	// Likewise only a Squid started with the resolver can use it.
+	dnsEnabled, err := writeDNSConfig()
+	if err != nil {
+		return err
+	}
+	opts.DNSResolver = dnsEnabled && (!running || squidHasLabel(rt, dnsLabel))
+
+	// And only one started with the cache_peer file can include it.
+	peer, err := upstreamPeer()
+	if err != nil {
+		return err
+	}
+	if err := writeUpstreamConfig(peer); err != nil {
+		return fmt.Errorf("failed to write upstream proxy settings: %w", err)
+	}
+	if peer != nil && (!running || squidHasLabel(rt, upstreamLabel)) {
+		opts.Upstream = peer
+	}
+	opts.LimitPages = !running || squidHasLabel(rt, limitsLabel)
+
+	content := GenerateSquidConfig(subnet, domains, collectSessions(), opts)
 	configFile := filepath.Join(config.Cache, "squid.conf")
 	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
@@ -0,0 +1,289 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/profile"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"gopkg.in/yaml.v3"
+)
+
+// Besides the global allowlist.yaml, domains can be allowed for a single
+// project or a single workspace. Both live in host-only files that are
+// never mounted into agent containers: project allowlists use the
+// allowlist.yaml format, workspace allowlists that of config.yaml's
+// workspaces (see config.WorkspaceAllowlistFile). Each session works from
+// a snapshot of its workspace's allowlist taken at start, which only
+// host-side approvals update.
+
+// ProjectAllowlistFile returns the path of a project's allowlist.
+func ProjectAllowlistFile(projectDir string) string {
+	return filepath.Join(project.ParentDir(projectDir), "allowlist.yaml")
+}
+
+// AddProjectDomain persists a domain in a project's allowlist.
+func AddProjectDomain(projectDir, domain string) error {
+	return addScopedDomain(ProjectAllowlistFile(projectDir), domain)
+}
+
+// AddWorkspaceDomain persists a domain in a workspace's allowlist and in
+// the snapshots of that workspace's running sessions.
+func AddWorkspaceDomain(workspace, domain string) error {
+	if workspace == "" {
+		return fmt.Errorf("no active workspace")
+	}
+	normalized, err := NormalizeAllowlistEntry(domain)
+	if err != nil {
+		return err
+	}
+	entry := strings.TrimPrefix(normalized, ".")
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	w := profile.FindWorkspace(cfg, workspace)
+	if w == nil {
+		return fmt.Errorf("unknown workspace '%s'", workspace)
+	}
+	al := w.Allowlist
+	if al == nil {
+		al = &config.WorkspaceAllowlist{}
+	}
+	if addDomainTo(&al.Custom, al.AllDomains(), entry) {
+		if err := config.SaveWorkspaceAllowlist(w.Name, al); err != nil {
+			return err
+		}
+	}
+
+	for name, ws := range readSessionWorkspaces() {
+		if ws != workspace {
+			continue
+		}
+		snap := readWorkspaceSnapshot(name)
+		if snap == nil {
+			snap = &config.WorkspaceAllowlist{}
+		}
+		if addDomainTo(&snap.Custom, snap.AllDomains(), entry) {
+			if err := writeWorkspaceSnapshot(name, snap); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addDomainTo appends entry to list unless existing already covers it.
+func addDomainTo(list *[]string, existing []string, entry string) bool {
+	want, _ := NormalizeAllowlistEntry(entry)
+	for _, d := range existing {
+		if n, err := NormalizeAllowlistEntry(d); err == nil && n == want {
+			return false
+		}
+	}
+	*list = append(*list, entry)
+	return true
+}
+
+// addScopedDomain appends domain to the custom list of the allowlist at
+// path, creating the file if needed.
+func addScopedDomain(path, domain string) error {
+	normalized, err := NormalizeAllowlistEntry(domain)
+	if err != nil {
+		return err
+	}
+	al, err := config.LoadAllowlistFrom(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		al = &config.Allowlist{Version: 1}
+	}
+	if !addDomainTo(&al.Custom, al.AllDomains(), strings.TrimPrefix(normalized, ".")) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return config.SaveAllowlistTo(al, path)
+}
+
+// scopedDomains returns the domains of the allowlist at path, or nil if it
+// does not exist.
+func scopedDomains(path string) []string {
+	al, err := config.LoadAllowlistFrom(path)
+	if err != nil {
+		return nil
+	}
+	return al.AllDomains()
+}
+
+// RegisterSessionScope records the project and workspace a container runs
+// in, and snapshots the workspace allowlist, so both apply to it.
+func RegisterSessionScope(containerName, projectDir, workspace string) error {
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	content := "project=" + projectDir + "\nworkspace=" + workspace + "\n"
+	if err := os.WriteFile(filepath.Join(dir, containerName+".scope"), []byte(content), 0644); err != nil {
+		return err
+	}
+	if workspace == "" {
+		return nil
+	}
+	if w := profile.FindWorkspace(config.LoadOrDefault(), workspace); w != nil && !w.Allowlist.IsEmpty() {
+		return writeWorkspaceSnapshot(containerName, w.Allowlist)
+	}
+	return nil
+}
+
+// readSessionScope returns the project directory and workspace recorded
+// for a container.
+func readSessionScope(containerName string) (projectDir, workspace string) {
+	for _, line := range readLines(filepath.Join(sessionDir(), containerName+".scope")) {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "project":
+			projectDir = value
+		case "workspace":
+			workspace = value
+		}
+	}
+	return projectDir, workspace
+}
+
+// readSessionWorkspaces returns the workspace of every session, keyed by
+// container name.
+func readSessionWorkspaces() map[string]string {
+	out := make(map[string]string)
+	entries, err := os.ReadDir(sessionDir())
+	if err != nil {
+		return out
+	}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".scope"); ok {
+			_, out[name] = readSessionScope(name)
+		}
+	}
+	return out
+}
+
+// workspaceSnapshotFile returns the path of a session's workspace
+// allowlist snapshot.
+func workspaceSnapshotFile(containerName string) string {
+	return filepath.Join(sessionDir(), containerName+".workspace")
+}
+
+// writeWorkspaceSnapshot saves a session's workspace allowlist.
+func writeWorkspaceSnapshot(containerName string, w *config.WorkspaceAllowlist) error {
+	data, err := yaml.Marshal(w)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(workspaceSnapshotFile(containerName), data, 0644)
+}
+
+// readWorkspaceSnapshot returns a session's workspace allowlist, or nil.
+func readWorkspaceSnapshot(containerName string) *config.WorkspaceAllowlist {
+	data, err := os.ReadFile(workspaceSnapshotFile(containerName))
+	if err != nil {
+		return nil
+	}
+	var w config.WorkspaceAllowlist
+	if err := yaml.Unmarshal(data, &w); err != nil {
+		return nil
+	}
+	return &w
+}
+
+// applyScope adds a container's project and workspace domains to its
+// policy. A workspace that replaces the global allowlist excludes the
+// container from it.
+func applyScope(p *ContainerPolicy) {
+	projectDir, _ := readSessionScope(p.Container)
+	if projectDir != "" {
+		p.URLs = append(p.URLs, scopedDomains(ProjectAllowlistFile(projectDir))...)
+	}
+	if w := readWorkspaceSnapshot(p.Container); w != nil {
+		p.URLs = append(p.URLs, w.AllDomains()...)
+		p.ExcludeGlobal = w.Replace
+	}
+}
+
+// AddTemporaryURLAndReload allows a domain for a container until ttl has
+// passed, then reloads Squid again to drop it. Connections opened in the
+// meantime are not interrupted.
+func AddTemporaryURLAndReload(rt container.Runtime, containerName, domain string, ttl time.Duration) error {
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, containerName+".once")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%d %s\n", time.Now().Add(ttl).Unix(), domain)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := ReloadSquid(rt); err != nil {
+		return err
+	}
+	time.AfterFunc(ttl+time.Second, func() {
+		_ = ReloadSquid(rt)
+	})
+	return nil
+}
+
+// temporaryURLs returns the unexpired temporary domains of a container.
+func temporaryURLs(containerName string, now time.Time) []string {
+	var urls []string
+	for _, line := range readLines(filepath.Join(sessionDir(), containerName+".once")) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		expiry, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || now.Unix() >= expiry {
+			continue
+		}
+		urls = append(urls, fields[1])
+	}
+	return urls
+}
+
+// ReloadSquid regenerates the Squid config and hot-reloads the proxy.
+func ReloadSquid(rt container.Runtime) error {
+	if err := writeSquidConfig(rt); err != nil {
+		return err
+	}
+	reconfigureSquid(rt)
+	return nil
+}
@@ -0,0 +1,167 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func useTempConfig(t *testing.T) {
+	t.Helper()
+	origHome, origCache := config.Home, config.Cache
+	config.Home, config.Cache = t.TempDir(), t.TempDir()
+	t.Cleanup(func() { config.Home, config.Cache = origHome, origCache })
+}
+
+func saveWorkspaces(t *testing.T, workspaces ...config.Workspace) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Workspaces.Items = workspaces
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddProjectDomain(t *testing.T) {
+	useTempConfig(t)
+
+	if err := AddProjectDomain("/home/user/proj", "registry.npmjs.org"); err != nil {
+		t.Fatalf("AddProjectDomain: %v", err)
+	}
+	// Adding again, in any spelling, is a no-op.
+	if err := AddProjectDomain("/home/user/proj", ".Registry.npmjs.org"); err != nil {
+		t.Fatalf("AddProjectDomain: %v", err)
+	}
+	if err := AddProjectDomain("/home/user/proj", "not a domain"); err == nil {
+		t.Error("invalid domain should be rejected")
+	}
+
+	al, err := config.LoadAllowlistFrom(ProjectAllowlistFile("/home/user/proj"))
+	if err != nil {
+		t.Fatalf("LoadAllowlistFrom: %v", err)
+	}
+	if !reflect.DeepEqual(al.Custom, []string{"registry.npmjs.org"}) {
+		t.Errorf("Custom = %v", al.Custom)
+	}
+
+	if err := AddWorkspaceDomain("", "example.com"); err == nil {
+		t.Error("AddWorkspaceDomain without a workspace should fail")
+	}
+}
+
+func TestAddWorkspaceDomain(t *testing.T) {
+	useTempConfig(t)
+	saveWorkspaces(t,
+		config.Workspace{Name: "work"},
+		config.Workspace{Name: "personal"},
+	)
+	_ = RegisterSessionScope("ctr-work", "/home/user/proj", "work")
+	_ = RegisterSessionScope("ctr-personal", "/home/user/proj", "personal")
+
+	if err := AddWorkspaceDomain("work", "internal.example"); err != nil {
+		t.Fatalf("AddWorkspaceDomain: %v", err)
+	}
+	if err := AddWorkspaceDomain("work", ".Internal.example"); err != nil {
+		t.Fatalf("AddWorkspaceDomain: %v", err)
+	}
+	if err := AddWorkspaceDomain("missing", "internal.example"); err == nil {
+		t.Error("unknown workspace should be rejected")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := cfg.Workspaces.Items[0]
+	if w.Allowlist == nil || !reflect.DeepEqual(w.Allowlist.Custom, []string{"internal.example"}) {
+		t.Errorf("workspace allowlist = %+v", w.Allowlist)
+	}
+	if cfg.Workspaces.Items[1].Allowlist != nil {
+		t.Errorf("other workspace changed: %+v", cfg.Workspaces.Items[1].Allowlist)
+	}
+	// Containers can write config.yaml, so the domain must not be there.
+	if data, _ := os.ReadFile(config.ConfigFile()); strings.Contains(string(data), "internal.example") {
+		t.Errorf("workspace domain saved to config.yaml:\n%s", data)
+	}
+
+	// Running sessions of the workspace pick the domain up; others do not.
+	if snap := readWorkspaceSnapshot("ctr-work"); snap == nil || !reflect.DeepEqual(snap.Custom, []string{"internal.example"}) {
+		t.Errorf("ctr-work snapshot = %+v", snap)
+	}
+	if snap := readWorkspaceSnapshot("ctr-personal"); snap != nil {
+		t.Errorf("ctr-personal snapshot = %+v", snap)
+	}
+}
+
+func TestRegisterSessionScopeSnapshotsWorkspace(t *testing.T) {
+	useTempConfig(t)
+	saveWorkspaces(t, config.Workspace{
+		Name:      "client-a",
+		Allowlist: &config.WorkspaceAllowlist{Replace: true, Custom: []string{"client-a.example"}},
+	})
+	_ = RegisterSessionScope("ctr-a", "/home/user/proj", "client-a")
+
+	// Edits to config.yaml after the session started do not apply to it.
+	saveWorkspaces(t, config.Workspace{
+		Name:      "client-a",
+		Allowlist: &config.WorkspaceAllowlist{Custom: []string{"evil.example"}},
+	})
+
+	all := collectSessions()
+	if len(all) != 1 {
+		t.Fatalf("expected 1 policy, got %d", len(all))
+	}
+	if !reflect.DeepEqual(all[0].URLs, []string{"client-a.example"}) {
+		t.Errorf("URLs = %v", all[0].URLs)
+	}
+	if !all[0].ExcludeGlobal {
+		t.Error("replacing workspace should exclude the global allowlist")
+	}
+}
+
+func TestCollectSessionsMergesScopes(t *testing.T) {
+	useTempConfig(t)
+	saveWorkspaces(t, config.Workspace{
+		Name:      "work",
+		Allowlist: &config.WorkspaceAllowlist{Custom: []string{"internal.example"}},
+	})
+
+	if err := AddProjectDomain("/home/user/proj", "pypi.org"); err != nil {
+		t.Fatal(err)
+	}
+	_ = RegisterSessionURLs("ctr-a", []string{"session.example"})
+	_ = RegisterSessionScope("ctr-a", "/home/user/proj", "work")
+	_ = RegisterSessionScope("ctr-b", "/home/user/other", "personal")
+
+	now := time.Now()
+	once := []byte(
+		"1 expired.example\n" +
+			strconv.FormatInt(now.Add(time.Minute).Unix(), 10) + " once.example\n")
+	_ = os.WriteFile(filepath.Join(sessionDir(), "ctr-a.once"), once, 0644)
+
+	all := collectSessions()
+	if len(all) != 2 {
+		t.Fatalf("expected 2 policies, got %d", len(all))
+	}
+	want := []string{"session.example", "once.example", "pypi.org", "internal.example"}
+	if !reflect.DeepEqual(all[0].URLs, want) {
+		t.Errorf("ctr-a URLs = %v, want %v", all[0].URLs, want)
+	}
+	if len(all[1].URLs) != 0 {
+		t.Errorf("ctr-b should not get other scopes' domains, got %v", all[1].URLs)
+	}
+}
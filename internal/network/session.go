@@ -0,0 +1,259 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+// ContainerPolicy is the per-container part of the Squid config: the
+// container's source address on the internal network and the extra domains
+// only that container may reach (--allow-urls, runtime approvals and the
+// allowlists of its project and workspace).
+type ContainerPolicy struct {
+	Container string
+	IP        string
+	URLs      []string
+	// ExcludeGlobal keeps the shared allowlist and HTTP rules from applying
+	// to the container, whose workspace replaces them with its own list.
+	ExcludeGlobal bool
+	// Limits caps the container's egress. OverQuota and Throttled are the
+	// transfer quota and per-domain request rates it has exceeded.
+	Limits    config.EgressLimits
+	OverQuota bool
+	Throttled []string
+}
+
+// sessionDir returns the directory for per-container session files.
+// Each container has a <name>.urls file (extra domains), a <name>.scope
+// file (its project and workspace), optionally a <name>.workspace file
+// (snapshot of the workspace allowlist), a <name>.once file (temporary
+// domains with their expiry), <name>.limits, .usage and .throttle files
+// (egress limits, usage and domains over the request rate) and, once it has
+// joined the internal network, a <name>.ip file (its source address).
+func sessionDir() string {
+	return filepath.Join(config.Cache, "squid-sessions")
+}
+
+// RegisterSessionURLs writes a session file for a container's extra URLs.
+func RegisterSessionURLs(containerName string, urls []string) error {
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	content := strings.Join(urls, "\n") + "\n"
+	return os.WriteFile(filepath.Join(dir, containerName+".urls"), []byte(content), 0644)
+}
+
+// RegisterSessionIP records the source address of a container on the
+// internal network. Any other session still claiming the same address
+// (left over from a container that exited uncleanly) is unbound so its
+// domains cannot leak to the new owner of the address.
+func RegisterSessionIP(containerName, ip string) error {
+	dir := sessionDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, other := range readSessionIPs() {
+		if name != containerName && other == ip {
+			_ = os.Remove(filepath.Join(dir, name+".ip"))
+		}
+	}
+	return os.WriteFile(filepath.Join(dir, containerName+".ip"), []byte(ip+"\n"), 0644)
+}
+
+// RemoveSession removes a container's session files and regenerates squid config.
+func RemoveSession(rt container.Runtime, containerName string) {
+	dir := sessionDir()
+	for _, ext := range []string{".urls", ".ip", ".scope", ".workspace", ".once", ".limits", ".usage", ".throttle"} {
+		_ = os.Remove(filepath.Join(dir, containerName+ext))
+	}
+
+	if err := writeSquidConfig(rt); err != nil {
+		ui.Warnf("Failed to regenerate squid config: %v", err)
+		return
+	}
+	reconfigureSquid(rt)
+}
+
+// AddSessionURLAndReload adds a domain to a container's session URLs and
+// hot-reloads Squid so the change takes effect immediately.
+func AddSessionURLAndReload(rt container.Runtime, containerName string, domain string) error {
+	urls := readLines(filepath.Join(sessionDir(), containerName+".urls"))
+
+	// Deduplicate: skip if already present.
+	for _, u := range urls {
+		if u == domain {
+			return nil // already allowed
+		}
+	}
+	urls = append(urls, domain)
+
+	// Write back and regenerate config.
+	if err := RegisterSessionURLs(containerName, urls); err != nil {
+		return err
+	}
+	if err := writeSquidConfig(rt); err != nil {
+		return err
+	}
+	reconfigureSquid(rt)
+	return nil
+}
+
+// WatchSessionIP waits for a freshly started container to join the internal
+// network, records its address and hot-reloads Squid so the container's own
+// domains apply to it. It gives up when done is closed or after a minute.
+// Until the address is known the container only gets the shared allowlist.
+func WatchSessionIP(rt container.Runtime, containerName string, done <-chan struct{}) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(time.Minute)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-deadline:
+			ui.Warnf("Could not detect %s address on %s; session domains are not applied", containerName, InternalNetwork)
+			return
+		case <-ticker.C:
+		}
+
+		ip := containerIP(rt, containerName)
+		if ip == "" {
+			continue
+		}
+		if err := RegisterSessionIP(containerName, ip); err != nil {
+			ui.Warnf("Failed to register session address: %v", err)
+			return
+		}
+		if err := recordBinding(containerName, ip); err != nil {
+			ui.Warnf("Failed to record address for network log: %v", err)
+		}
+		if err := writeSquidConfig(rt); err != nil {
+			ui.Warnf("Failed to regenerate squid config: %v", err)
+			return
+		}
+		reconfigureSquid(rt)
+		return
+	}
+}
+
+// collectSessions reads all session files and returns one policy per
+// container, sorted by container name. Containers whose address is not
+// yet known are returned with an empty IP.
+func collectSessions() []ContainerPolicy {
+	dir := sessionDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	ips := readSessionIPs()
+
+	byName := make(map[string]*ContainerPolicy)
+	policy := func(name string) *ContainerPolicy {
+		if p, ok := byName[name]; ok {
+			return p
+		}
+		p := &ContainerPolicy{Container: name, IP: ips[name]}
+		byName[name] = p
+		return p
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(e.Name(), ".urls"):
+			p := policy(strings.TrimSuffix(e.Name(), ".urls"))
+			seen := make(map[string]bool)
+			for _, line := range readLines(filepath.Join(dir, e.Name())) {
+				if !seen[line] {
+					seen[line] = true
+					p.URLs = append(p.URLs, line)
+				}
+			}
+		case strings.HasSuffix(e.Name(), ".ip"):
+			policy(strings.TrimSuffix(e.Name(), ".ip"))
+		case strings.HasSuffix(e.Name(), ".scope"):
+			policy(strings.TrimSuffix(e.Name(), ".scope"))
+		case strings.HasSuffix(e.Name(), ".workspace"):
+			policy(strings.TrimSuffix(e.Name(), ".workspace"))
+		case strings.HasSuffix(e.Name(), ".once"):
+			policy(strings.TrimSuffix(e.Name(), ".once"))
+		case strings.HasSuffix(e.Name(), ".limits"):
+			policy(strings.TrimSuffix(e.Name(), ".limits"))
+		}
+	}
+
+	now := time.Now()
+	out := make([]ContainerPolicy, 0, len(byName))
+	for name, p := range byName {
+		p.URLs = append(p.URLs, temporaryURLs(name, now)...)
+		applyScope(p)
+		applyLimits(p)
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Container < out[j].Container })
+	return out
+}
+
+// readSessionIPs returns the recorded address of every session, keyed by
+// container name.
+func readSessionIPs() map[string]string {
+	dir := sessionDir()
+	ips := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ips
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".ip") {
+			continue
+		}
+		lines := readLines(filepath.Join(dir, e.Name()))
+		if len(lines) > 0 {
+			ips[strings.TrimSuffix(e.Name(), ".ip")] = lines[0]
+		}
+	}
+	return ips
+}
+
+// readLines returns the trimmed, non-empty lines of a file.
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -11,7 +11,6 @@ package network
 import (
 	"os"
 	"path/filepath"
-	"sort"
 	"testing"
 
 	"github.com/cloud-exit/exitbox/internal/config"
@@ -32,28 +31,57 @@ func TestSessionURLLifecycle(t *testing.T) {
 		t.Fatalf("RegisterSessionURLs(b): %v", err)
 	}
 
-	// Collect all - should be deduplicated
-	all := collectAllSessionURLs()
-	sort.Strings(all)
-	if len(all) != 3 {
-		t.Errorf("expected 3 unique URLs, got %d: %v", len(all), all)
+	if err := RegisterSessionIP("container-a", "10.89.0.5"); err != nil {
+		t.Fatalf("RegisterSessionIP(a): %v", err)
+	}
+
+	// Collect all - one policy per container
+	all := collectSessions()
+	if len(all) != 2 {
+		t.Fatalf("expected 2 sessions, got %d: %v", len(all), all)
+	}
+	if all[0].Container != "container-a" || all[0].IP != "10.89.0.5" || len(all[0].URLs) != 2 {
+		t.Errorf("unexpected session a: %+v", all[0])
+	}
+	if all[1].Container != "container-b" || all[1].IP != "" || len(all[1].URLs) != 2 {
+		t.Errorf("unexpected session b: %+v", all[1])
 	}
 
 	// Remove container-a's session
-	sessionFile := filepath.Join(sessionDir(), "container-a.urls")
-	os.Remove(sessionFile)
+	os.Remove(filepath.Join(sessionDir(), "container-a.urls"))
+	os.Remove(filepath.Join(sessionDir(), "container-a.ip"))
 
-	// Remaining should only have bar.com and example.com
-	remaining := collectAllSessionURLs()
-	sort.Strings(remaining)
-	if len(remaining) != 2 {
-		t.Errorf("expected 2 remaining URLs, got %d: %v", len(remaining), remaining)
+	remaining := collectSessions()
+	if len(remaining) != 1 || remaining[0].Container != "container-b" {
+		t.Errorf("expected only container-b to remain, got %v", remaining)
 	}
 
 	// Clean all
 	os.RemoveAll(sessionDir())
-	empty := collectAllSessionURLs()
+	empty := collectSessions()
 	if len(empty) != 0 {
-		t.Errorf("expected 0 URLs after cleanup, got %d", len(empty))
+		t.Errorf("expected 0 sessions after cleanup, got %d", len(empty))
+	}
+}
+
+func TestRegisterSessionIPUnbindsStaleSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	origCache := config.Cache
+	config.Cache = tmpDir
+	defer func() { config.Cache = origCache }()
+
+	if err := RegisterSessionIP("old", "10.89.0.5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterSessionIP("new", "10.89.0.5"); err != nil {
+		t.Fatal(err)
+	}
+
+	ips := readSessionIPs()
+	if _, ok := ips["old"]; ok {
+		t.Error("stale session should lose the reused address")
+	}
+	if ips["new"] != "10.89.0.5" {
+		t.Errorf("new session ip = %q, want 10.89.0.5", ips["new"])
 	}
 }
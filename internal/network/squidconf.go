@@ -17,20 +17,63 @@
 package network
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"net"
+	"regexp"
 	"strings"
 
+	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/ui"
 )
 
-// GenerateSquidConfig generates the squid.conf content.
-func GenerateSquidConfig(subnet string, domains []string, extraURLs []string) string {
+// SquidOptions holds the optional parts of the Squid config.
+type SquidOptions struct {
+	// HTTPRules restrict methods and paths for their domains.
+	HTTPRules []config.HTTPRule
+	// TLSInspection enables ssl-bump for domains with HTTP rules. Without
+	// it, HTTPS to those domains is denied because the rules cannot be
+	// checked.
+	TLSInspection bool
+	// DNSResolver sends Squid's lookups to the resolver in its container.
+	DNSResolver bool
+	// Upstream forwards requests to a parent proxy instead of connecting
+	// directly. Its cache_peer line is included from a separate file.
+	Upstream *UpstreamPeer
+	// LimitPages uses ExitBox's own error pages for requests refused by an
+	// egress limit.
+	LimitPages bool
+}
+
+// GenerateSquidConfig generates the squid.conf content. domains form the
+// shared allowlist for every agent container; each policy's URLs are only
+// allowed for requests coming from that container's source address.
+// Containers whose policy excludes the global allowlist get their own URLs
+// and nothing else.
+func GenerateSquidConfig(subnet string, domains []string, policies []ContainerPolicy, opts SquidOptions) string {
 	var b strings.Builder
 
-	b.WriteString(`# Squid Configuration for Agentbox
-http_port 3128
-shutdown_lifetime 1 seconds
+	httpRules := buildHTTPRules(opts.HTTPRules)
+	bump := opts.TLSInspection && len(httpRules.domains) > 0
 
+	b.WriteString("# Squid Configuration for Agentbox\n")
+	if bump {
+		fmt.Fprintf(&b, "http_port 3128 ssl-bump tls-cert=%s/ca.pem tls-key=%s/ca.key generate-host-certificates=on dynamic_cert_mem_cache_size=4MB\n",
+			squidSSLDir, squidSSLDir)
+	} else {
+		b.WriteString("http_port 3128\n")
+	}
+	b.WriteString("shutdown_lifetime 1 seconds\n")
+	if opts.DNSResolver {
+		b.WriteString("dns_nameservers 127.0.0.1\n")
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, `# Structured access log (read by "exitbox network log")
+logformat exitbox %s
+access_log stdio:%s/%s exitbox
+umask 022
+`, accessLogFormat, squidLogDir, accessLogName)
+	b.WriteString(`
 # Access Control Lists
 acl SSL_ports port 443
 acl Safe_ports port 80		# http
@@ -54,7 +97,7 @@ acl localhost src 127.0.0.1/32
 
 # Only allow proxy clients from the internal agent network
 `)
-	fmt.Fprintf(&b, "acl agent_sources src %s\n\n# Allowlist\n", subnet)
+	fmt.Fprintf(&b, "acl agent_sources src %s\n\n# Allowlist\n", agentSources(subnet, policies))
 
 	seen := make(map[string]bool)
 	count := 0
@@ -73,35 +116,113 @@ acl localhost src 127.0.0.1/32
 		count++
 	}
 
-	// Extra URLs
-	for _, url := range extraURLs {
-		if url == "" {
+	if count == 0 {
+		ui.Warn("Allowlist is empty or invalid. Blocking all outbound destinations.")
+		b.WriteString("acl allowed_domains dstdomain .__agentbox_block_all__.invalid\n")
+	}
+
+	// Per-container allowlists. A container whose address is not known yet
+	// gets no extra domains rather than leaking them to the whole subnet.
+	// Containers excluded from the shared allowlist are decided before any
+	// shared rule applies.
+	var rules, exclusive []string
+	for _, p := range policies {
+		if p.IP == "" || net.ParseIP(p.IP) == nil {
 			continue
 		}
-		normalized, err := NormalizeAllowlistEntry(url)
-		if err != nil {
-			ui.Warnf("Skipping invalid --allow-urls entry: %s", url)
+		var entries []string
+		local := make(map[string]bool)
+		for _, url := range p.URLs {
+			if url == "" {
+				continue
+			}
+			normalized, err := NormalizeAllowlistEntry(url)
+			if err != nil {
+				ui.Warnf("Skipping invalid --allow-urls entry: %s", url)
+				continue
+			}
+			if (seen[normalized] && !p.ExcludeGlobal) || local[normalized] {
+				continue
+			}
+			local[normalized] = true
+			entries = append(entries, normalized)
+		}
+		if len(entries) == 0 && !p.ExcludeGlobal {
 			continue
 		}
-		if seen[normalized] {
+		name := containerACLName(p.Container)
+		fmt.Fprintf(&b, "\n# Session: %s\n", p.Container)
+		fmt.Fprintf(&b, "acl %s_src src %s\n", name, hostCIDR(p.IP))
+		for _, e := range entries {
+			fmt.Fprintf(&b, "acl %s_domains dstdomain %s\n", name, e)
+		}
+		if !p.ExcludeGlobal {
+			rules = append(rules, fmt.Sprintf("http_access allow %s_src %s_domains\n", name, name))
 			continue
 		}
-		seen[normalized] = true
-		fmt.Fprintf(&b, "acl allowed_domains dstdomain %s\n", normalized)
-		count++
+		if len(entries) > 0 {
+			exclusive = append(exclusive, fmt.Sprintf("http_access allow %s_src %s_domains\n", name, name))
+		}
+		exclusive = append(exclusive, fmt.Sprintf("http_access deny %s_src\n", name))
 	}
 
-	if count == 0 {
-		ui.Warn("Allowlist is empty or invalid. Blocking all outbound destinations.")
-		b.WriteString("acl allowed_domains dstdomain .__agentbox_block_all__.invalid\n")
+	limits := writeLimitACLs(&b, policies, opts.LimitPages)
+
+	if len(httpRules.domains) > 0 {
+		b.WriteString("\n# HTTP rules (method and path restrictions)\n")
+		for _, d := range httpRules.domains {
+			fmt.Fprintf(&b, "acl restricted_domains dstdomain %s\n", d)
+		}
+		fmt.Fprintf(&b, "acl dot_segments urlpath_regex %s\n", dotSegmentRegex)
+		b.WriteString(httpRules.acls)
+		if bump {
+			b.WriteString(`
+# TLS inspection: decrypt only connections to restricted domains
+sslcrtd_program /usr/lib/squid/security_file_certgen -s /var/lib/squid/ssl_db -M 4MB
+sslcrtd_children 5
+acl bump_step1 at_step SslBump1
+`)
+			for _, d := range httpRules.domains {
+				fmt.Fprintf(&b, "acl restricted_sni ssl::server_name %s\n", d)
+			}
+			b.WriteString(`ssl_bump peek bump_step1
+ssl_bump bump restricted_domains
+ssl_bump bump restricted_sni
+ssl_bump splice all
+`)
+		}
 	}
 
 	b.WriteString(`
 # Enforce Access Control
 # Only allow access from localhost and our network
 http_access allow localhost
-http_access allow agent_sources allowed_domains
-
+`)
+	for _, r := range limits {
+		b.WriteString(r)
+	}
+	for _, r := range exclusive {
+		b.WriteString(r)
+	}
+	if len(httpRules.domains) > 0 {
+		// Restricted domains are reachable only through their rules, even
+		// when a broader allowlist entry also covers them. Paths with ".."
+		// segments are refused first: the origin would resolve them
+		// to a path outside the prefix the rule matched.
+		b.WriteString("http_access deny restricted_domains dot_segments\n")
+		if bump {
+			b.WriteString("http_access allow agent_sources CONNECT restricted_domains\n")
+		}
+		for _, name := range httpRules.names {
+			fmt.Fprintf(&b, "http_access allow agent_sources %s\n", name)
+		}
+		b.WriteString("http_access deny restricted_domains\n")
+	}
+	b.WriteString("http_access allow agent_sources allowed_domains\n")
+	for _, r := range rules {
+		b.WriteString(r)
+	}
+	b.WriteString(`
 # Deny everything else
 http_access deny all
 
@@ -109,6 +230,156 @@ http_access deny all
 forwarded_for off
 via off
 `)
+	if opts.Upstream != nil {
+		writeUpstreamSection(&b, opts.Upstream)
+	}
+	writeDelayPools(&b, policies)
+
+	return b.String()
+}
+
+// agentSources returns the source addresses allowed to use the shared
+// allowlist: the whole subnet, unless a container excluded from it has no
+// known address yet. Then only the known addresses of other containers are
+// allowed, so the new container gets nothing until its own rules are in
+// place.
+func agentSources(subnet string, policies []ContainerPolicy) string {
+	pending := false
+	var known []string
+	for _, p := range policies {
+		switch {
+		case p.IP == "" || net.ParseIP(p.IP) == nil:
+			pending = pending || p.ExcludeGlobal
+		case !p.ExcludeGlobal:
+			known = append(known, hostCIDR(p.IP))
+		}
+	}
+	if !pending {
+		return subnet
+	}
+	if len(known) == 0 {
+		return "255.255.255.255/32"
+	}
+	return strings.Join(known, " ")
+}
+
+// squidSSLDir is where the Squid image keeps its copy of the CA.
+const squidSSLDir = "/etc/squid/ssl"
+
+// httpRuleSet is the Squid form of the HTTP rules.
+type httpRuleSet struct {
+	domains []string // normalized restricted domains
+	acls    string   // acl lines for every rule
+	names   []string // per-rule ACL combinations for http_access
+}
+
+// buildHTTPRules validates the HTTP rules and renders their ACLs. Invalid
+// rules are skipped with a warning. The domain of a rule with invalid
+// methods or paths stays restricted, so a typo blocks the domain instead of
+// opening it to anything a broader allowlist entry covers.
+func buildHTTPRules(rules []config.HTTPRule) httpRuleSet {
+	var set httpRuleSet
+	var acls strings.Builder
+	seen := make(map[string]bool)
+
+	for i, r := range rules {
+		domain, err := NormalizeAllowlistEntry(r.Domain)
+		if err != nil {
+			ui.Warnf("Skipping HTTP rule with invalid domain: %s", r.Domain)
+			continue
+		}
+		if !seen[domain] {
+			seen[domain] = true
+			set.domains = append(set.domains, domain)
+		}
+		methods, ok := ruleMethods(r.Methods)
+		if !ok {
+			ui.Warnf("Skipping HTTP rule for %s: invalid methods %v", r.Domain, r.Methods)
+			continue
+		}
+		paths, ok := rulePathRegexes(r.Paths)
+		if !ok {
+			ui.Warnf("Skipping HTTP rule for %s: invalid paths %v", r.Domain, r.Paths)
+			continue
+		}
+
+		name := fmt.Sprintf("http_rule_%d", i+1)
+		fmt.Fprintf(&acls, "acl %s_domain dstdomain %s\n", name, domain)
+		combo := name + "_domain"
+		if len(methods) > 0 {
+			fmt.Fprintf(&acls, "acl %s_methods method %s\n", name, strings.Join(methods, " "))
+			combo += " " + name + "_methods"
+		}
+		if len(paths) > 0 {
+			for _, p := range paths {
+				fmt.Fprintf(&acls, "acl %s_paths urlpath_regex %s\n", name, p)
+			}
+			combo += " " + name + "_paths"
+		}
+		set.names = append(set.names, combo)
+	}
+	set.acls = acls.String()
+	return set
+}
+
+// ruleMethods upper-cases and validates HTTP method names.
+func ruleMethods(in []string) ([]string, bool) {
+	var out []string
+	for _, m := range in {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m == "" {
+			return nil, false
+		}
+		for _, r := range m {
+			if r < 'A' || r > 'Z' {
+				return nil, false
+			}
+		}
+		out = append(out, m)
+	}
+	return out, true
+}
+
+// dotSegmentRegex matches URL paths with a ".." segment, plain or
+// percent-encoded.
+const dotSegmentRegex = `(^|/)(\.|%2[eE]){2}(/|$)`
 
+// rulePathRegexes turns path prefixes into anchored Squid urlpath_regex
+// patterns. "*" matches any run of characters.
+func rulePathRegexes(in []string) ([]string, bool) {
+	var out []string
+	for _, p := range in {
+		p = strings.TrimSpace(p)
+		if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, " \t\r\n\"'") {
+			return nil, false
+		}
+		out = append(out, "^"+strings.ReplaceAll(regexp.QuoteMeta(p), `\*`, ".*"))
+	}
+	return out, true
+}
+
+// containerACLName turns a container name into a Squid ACL name. Other
+// characters become underscores, so a short hash of the original name
+// keeps names such as "a-b" and "a_b" apart.
+func containerACLName(containerName string) string {
+	var b strings.Builder
+	b.WriteString("ctr_")
+	for _, r := range containerName {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	sum := sha256.Sum256([]byte(containerName))
+	fmt.Fprintf(&b, "_%x", sum[:4])
 	return b.String()
 }
+
+// hostCIDR returns a single-host CIDR for an IPv4 or IPv6 address.
+func hostCIDR(ip string) string {
+	if strings.Contains(ip, ":") {
+		return ip + "/128"
+	}
+	return ip + "/32"
+}
@@ -2,12 +2,15 @@ package network
 
 import (
 	"os"
+	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
 )
 
 func TestGenerateSquidConfig_BasicStructure(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil)
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil, SquidOptions{})
 
 	// Should contain core squid directives
 	required := []string{
@@ -29,7 +32,7 @@ func TestGenerateSquidConfig_BasicStructure(t *testing.T) {
 
 func TestGenerateSquidConfig_SubnetInACL(t *testing.T) {
 	subnet := "10.89.0.0/24"
-	conf := GenerateSquidConfig(subnet, []string{"example.com"}, nil)
+	conf := GenerateSquidConfig(subnet, []string{"example.com"}, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "acl agent_sources src "+subnet) {
 		t.Error("config should contain agent_sources ACL with subnet")
@@ -38,7 +41,7 @@ func TestGenerateSquidConfig_SubnetInACL(t *testing.T) {
 
 func TestGenerateSquidConfig_Domains(t *testing.T) {
 	domains := []string{"github.com", "npmjs.org"}
-	conf := GenerateSquidConfig("10.89.0.0/24", domains, nil)
+	conf := GenerateSquidConfig("10.89.0.0/24", domains, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "acl allowed_domains dstdomain .github.com") {
 		t.Error("config should contain .github.com domain ACL")
@@ -48,17 +51,118 @@ func TestGenerateSquidConfig_Domains(t *testing.T) {
 	}
 }
 
-func TestGenerateSquidConfig_ExtraURLs(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, []string{"extra.io"})
+func TestGenerateSquidConfig_ContainerPolicy(t *testing.T) {
+	a := containerACLName("exitbox-claude-a")
+	policies := []ContainerPolicy{
+		{Container: "exitbox-claude-a", IP: "10.89.0.5", URLs: []string{"extra.io"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
+
+	if strings.Contains(conf, "acl allowed_domains dstdomain .extra.io") {
+		t.Error("session URL must not be added to the shared allowlist")
+	}
+	if !strings.Contains(conf, "acl "+a+"_src src 10.89.0.5/32") {
+		t.Error("config should contain source ACL for the container")
+	}
+	if !strings.Contains(conf, "acl "+a+"_domains dstdomain .extra.io") {
+		t.Error("config should contain the container's domain ACL")
+	}
+	if !strings.Contains(conf, "http_access allow "+a+"_src "+a+"_domains") {
+		t.Error("config should allow the container's domains only from its address")
+	}
+}
+
+func TestGenerateSquidConfig_ContainerPoliciesIsolated(t *testing.T) {
+	a := containerACLName("exitbox-claude-a")
+	b := containerACLName("exitbox-codex-b")
+	policies := []ContainerPolicy{
+		{Container: "exitbox-claude-a", IP: "10.89.0.5", URLs: []string{"a.example.org"}},
+		{Container: "exitbox-codex-b", IP: "10.89.0.6", URLs: []string{"b.example.org"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
+
+	if !strings.Contains(conf, "acl "+a+"_domains dstdomain .a.example.org") {
+		t.Error("container a should have its own domain")
+	}
+	if strings.Contains(conf, "acl "+a+"_domains dstdomain .b.example.org") {
+		t.Error("container b's domain leaked into container a's ACL")
+	}
+	if strings.Contains(conf, "acl "+b+"_domains dstdomain .a.example.org") {
+		t.Error("container a's domain leaked into container b's ACL")
+	}
+}
+
+func TestGenerateSquidConfig_ContainerNamesDoNotCollide(t *testing.T) {
+	if containerACLName("exitbox-a-b") == containerACLName("exitbox-a_b") {
+		t.Fatal("container names differing only in punctuation share an ACL name")
+	}
+	policies := []ContainerPolicy{
+		{Container: "exitbox-a-b", IP: "10.89.0.5", URLs: []string{"a.example.org"}},
+		{Container: "exitbox-a_b", IP: "10.89.0.6", URLs: []string{"b.example.org"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
+
+	if strings.Contains(conf, "acl "+containerACLName("exitbox-a_b")+"_domains dstdomain .a.example.org") {
+		t.Error("container a-b's domain leaked into container a_b's ACL")
+	}
+	if strings.Contains(conf, "acl "+containerACLName("exitbox-a-b")+"_src src 10.89.0.6/32") {
+		t.Error("container a_b's address leaked into container a-b's ACL")
+	}
+}
+
+func TestGenerateSquidConfig_ContainerPolicyWithoutIP(t *testing.T) {
+	policies := []ContainerPolicy{
+		{Container: "exitbox-claude-a", URLs: []string{"extra.io"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
+
+	if strings.Contains(conf, ".extra.io") {
+		t.Error("session URLs must not be applied before the container address is known")
+	}
+}
+
+func TestGenerateSquidConfig_ExcludeGlobal(t *testing.T) {
+	a := containerACLName("exitbox-claude-a")
+	policies := []ContainerPolicy{
+		{Container: "exitbox-claude-a", IP: "10.89.0.5", URLs: []string{"example.com", "client.example"}, ExcludeGlobal: true},
+		{Container: "exitbox-codex-b", IP: "10.89.0.6"},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
+
+	if !strings.Contains(conf, "acl agent_sources src 10.89.0.0/24") {
+		t.Error("shared allowlist should still cover the subnet")
+	}
+	// The excluded container keeps domains that are also shared.
+	if !strings.Contains(conf, "acl "+a+"_domains dstdomain .example.com") {
+		t.Error("excluded container should get its own copy of shared domains")
+	}
+	allow := strings.Index(conf, "http_access allow "+a+"_src "+a+"_domains")
+	deny := strings.Index(conf, "http_access deny "+a+"_src")
+	shared := strings.Index(conf, "http_access allow agent_sources allowed_domains")
+	if allow < 0 || deny < 0 || !(allow < deny && deny < shared) {
+		t.Errorf("excluded container must be decided before the shared allowlist (allow=%d deny=%d shared=%d)", allow, deny, shared)
+	}
+}
+
+func TestGenerateSquidConfig_ExcludeGlobalPending(t *testing.T) {
+	policies := []ContainerPolicy{
+		{Container: "exitbox-claude-a", URLs: []string{"client.example"}, ExcludeGlobal: true},
+		{Container: "exitbox-codex-b", IP: "10.89.0.6"},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
+	if !strings.Contains(conf, "acl agent_sources src 10.89.0.6/32\n") {
+		t.Error("while an excluded container has no address, only known containers may use the shared allowlist")
+	}
 
-	if !strings.Contains(conf, "acl allowed_domains dstdomain .extra.io") {
-		t.Error("config should contain extra URL domain ACL")
+	conf = GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies[:1], SquidOptions{})
+	if !strings.Contains(conf, "acl agent_sources src 255.255.255.255/32\n") {
+		t.Error("with no known containers the shared allowlist should match nothing")
 	}
 }
 
 func TestGenerateSquidConfig_Deduplication(t *testing.T) {
 	domains := []string{"example.com", "example.com", "example.com"}
-	conf := GenerateSquidConfig("10.89.0.0/24", domains, nil)
+	conf := GenerateSquidConfig("10.89.0.0/24", domains, nil, SquidOptions{})
 
 	count := strings.Count(conf, ".example.com")
 	if count != 1 {
@@ -67,16 +171,19 @@ func TestGenerateSquidConfig_Deduplication(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_DeduplicationAcrossLists(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, []string{"example.com"})
+	policies := []ContainerPolicy{
+		{Container: "exitbox-claude-a", IP: "10.89.0.5", URLs: []string{"example.com"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
 
 	count := strings.Count(conf, ".example.com")
 	if count != 1 {
-		t.Errorf("expected 1 occurrence of .example.com across domains+extraURLs, got %d", count)
+		t.Errorf("expected 1 occurrence of .example.com across domains+session URLs, got %d", count)
 	}
 }
 
 func TestGenerateSquidConfig_EmptyAllowlist(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", nil, nil)
+	conf := GenerateSquidConfig("10.89.0.0/24", nil, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "__agentbox_block_all__") {
 		t.Error("empty allowlist should produce block-all entry")
@@ -84,17 +191,20 @@ func TestGenerateSquidConfig_EmptyAllowlist(t *testing.T) {
 }
 
 func TestGenerateSquidConfig_EmptyExtraURLsSkipped(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, []string{"", ""})
+	policies := []ContainerPolicy{
+		{Container: "exitbox-claude-a", IP: "10.89.0.5", URLs: []string{"", ""}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, policies, SquidOptions{})
 
 	// Should only have the one domain, empty strings skipped
-	count := strings.Count(conf, "acl allowed_domains dstdomain")
+	count := strings.Count(conf, "dstdomain")
 	if count != 1 {
 		t.Errorf("expected 1 domain ACL entry, got %d", count)
 	}
 }
 
 func TestGenerateSquidConfig_AllowAccess(t *testing.T) {
-	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil)
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil, SquidOptions{})
 
 	if !strings.Contains(conf, "http_access allow agent_sources allowed_domains") {
 		t.Error("config should allow agent_sources with allowed_domains")
@@ -104,6 +214,88 @@ func TestGenerateSquidConfig_AllowAccess(t *testing.T) {
 	}
 }
 
+func TestGenerateSquidConfig_HTTPRules(t *testing.T) {
+	rules := []config.HTTPRule{
+		{Domain: "api.github.com", Methods: []string{"get", "HEAD"}, Paths: []string{"/repos/our-org/*"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"github.com"}, nil, SquidOptions{HTTPRules: rules})
+
+	for _, want := range []string{
+		"acl restricted_domains dstdomain .api.github.com",
+		"acl http_rule_1_methods method GET HEAD",
+		"acl http_rule_1_paths urlpath_regex ^/repos/our-org/.*",
+		"http_access allow agent_sources http_rule_1_domain http_rule_1_methods http_rule_1_paths",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q", want)
+		}
+	}
+	// Rules must be checked before the broader github.com entry.
+	deny := strings.Index(conf, "http_access deny restricted_domains")
+	allow := strings.Index(conf, "http_access allow agent_sources allowed_domains")
+	if deny < 0 || deny > allow {
+		t.Error("restricted domains must be denied before the allowlist is applied")
+	}
+	// Without TLS inspection, HTTPS to restricted domains stays blocked.
+	if strings.Contains(conf, "ssl_bump") || strings.Contains(conf, "CONNECT restricted_domains") {
+		t.Error("ssl-bump must not be configured without TLS inspection")
+	}
+}
+
+func TestGenerateSquidConfig_HTTPRulesWithTLSInspection(t *testing.T) {
+	rules := []config.HTTPRule{{Domain: "api.github.com", Methods: []string{"GET"}}}
+	conf := GenerateSquidConfig("10.89.0.0/24", nil, nil, SquidOptions{HTTPRules: rules, TLSInspection: true})
+
+	for _, want := range []string{
+		"http_port 3128 ssl-bump tls-cert=/etc/squid/ssl/ca.pem",
+		"acl restricted_sni ssl::server_name .api.github.com",
+		"ssl_bump bump restricted_domains",
+		"ssl_bump splice all",
+		"http_access allow agent_sources CONNECT restricted_domains",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q", want)
+		}
+	}
+
+	// TLS inspection without rules intercepts nothing.
+	conf = GenerateSquidConfig("10.89.0.0/24", nil, nil, SquidOptions{TLSInspection: true})
+	if strings.Contains(conf, "ssl-bump") {
+		t.Error("ssl-bump should only be configured when there are HTTP rules")
+	}
+}
+
+func TestGenerateSquidConfig_InvalidHTTPRulesSkipped(t *testing.T) {
+	rules := []config.HTTPRule{
+		{Domain: "bad domain", Methods: []string{"GET"}},
+		{Domain: "a.example", Methods: []string{"GET;"}},
+		{Domain: "b.example", Paths: []string{"no-slash"}},
+		{Domain: "c.example", Paths: []string{"/a b"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil, SquidOptions{HTTPRules: rules})
+	if strings.Contains(conf, "http_rule_") {
+		t.Errorf("invalid rules should be skipped:\n%s", conf)
+	}
+	// Domains of broken rules stay blocked rather than falling back to
+	// the allowlist.
+	for _, d := range []string{".a.example", ".b.example", ".c.example"} {
+		if !strings.Contains(conf, "acl restricted_domains dstdomain "+d+"\n") {
+			t.Errorf("%s should remain restricted", d)
+		}
+	}
+}
+
+func TestGenerateSquidConfig_DNSResolver(t *testing.T) {
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil, SquidOptions{})
+	if strings.Contains(conf, "dns_nameservers") {
+		t.Error("dns_nameservers should only be set when the resolver is used")
+	}
+	conf = GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil, SquidOptions{DNSResolver: true})
+	if !strings.Contains(conf, "dns_nameservers 127.0.0.1\n") {
+		t.Error("config should send lookups to the local resolver")
+	}
+}
+
 func TestGetSquidDNSServers_Default(t *testing.T) {
 	os.Unsetenv("EXITBOX_SQUID_DNS")
 	servers := getSquidDNSServers()
@@ -149,3 +341,34 @@ func TestSessionURLFileRoundtrip(t *testing.T) {
 		t.Errorf("expected 1 session file, got %d", len(entries))
 	}
 }
+
+func TestGenerateSquidConfig_HTTPRulesDenyDotSegments(t *testing.T) {
+	rules := []config.HTTPRule{
+		{Domain: "api.github.com", Methods: []string{"GET"}, Paths: []string{"/repos/our-org/*"}},
+	}
+	conf := GenerateSquidConfig("10.89.0.0/24", nil, nil, SquidOptions{HTTPRules: rules})
+
+	if !strings.Contains(conf, "acl dot_segments urlpath_regex "+dotSegmentRegex) {
+		t.Error("config missing the dot-segment ACL")
+	}
+	deny := strings.Index(conf, "http_access deny restricted_domains dot_segments")
+	allow := strings.Index(conf, "http_access allow agent_sources http_rule_1_domain")
+	if deny < 0 || deny > allow {
+		t.Error("dot-segment paths must be denied before the rules allow anything")
+	}
+
+	re := regexp.MustCompile(dotSegmentRegex)
+	for path, want := range map[string]bool{
+		"/repos/our-org/../other/repo":       true,
+		"/repos/our-org/%2e%2e/other/repo":   true,
+		"/repos/our-org/%2E./other/repo":     true,
+		"/repos/our-org/..":                  true,
+		"/repos/our-org/repo":                false,
+		"/repos/our-org/repo..name/contents": false,
+		"/repos/our-org/.github/workflows":   false,
+	} {
+		if got := re.MatchString(path); got != want {
+			t.Errorf("dot segment match of %s = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+)
+
+// TLS inspection lets Squid enforce HTTP rules (config.HTTPRule) on HTTPS
+// traffic. It is only switched on when the allowlist has rules, and only
+// connections to domains with rules are decrypted; everything else is
+// spliced through untouched. Squid signs the intercepted connections with a
+// CA generated once per install, which is trusted only inside agent images.
+
+// squidCADir is where the host CA directory is mounted inside the Squid
+// container. The image copies it to a squid-owned location at start.
+const squidCADir = "/etc/exitbox-ca"
+
+// tlsLabel marks a Squid container that was started with the CA mounted.
+const tlsLabel = "exitbox.tls-inspection"
+
+// CACertFile returns the path of the TLS inspection CA certificate.
+func CACertFile() string {
+	return filepath.Join(config.TLSDir(), "ca.pem")
+}
+
+// caKeyFile returns the path of the TLS inspection CA private key.
+func caKeyFile() string {
+	return filepath.Join(config.TLSDir(), "ca.key")
+}
+
+// TLSInspectionEnabled reports whether the allowlist has HTTP rules, which
+// turns on TLS inspection for the domains they cover.
+func TLSInspectionEnabled() bool {
+	return len(config.LoadAllowlistOrDefault().HTTPRules) > 0
+}
+
+// InspectionCA returns the PEM certificate of the TLS inspection CA when
+// inspection is enabled, generating the CA on first use. It returns nil
+// when inspection is disabled.
+func InspectionCA() ([]byte, error) {
+	if !TLSInspectionEnabled() {
+		return nil, nil
+	}
+	if err := ensureCA(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(CACertFile())
+}
+
+// ensureCA generates the CA certificate and key unless both exist.
+func ensureCA() error {
+	_, certErr := os.Stat(CACertFile())
+	_, keyErr := os.Stat(caKeyFile())
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(config.TLSDir(), 0700); err != nil {
+		return err
+	}
+	certPEM, keyPEM, err := generateCA(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to generate TLS inspection CA: %w", err)
+	}
+	if err := os.WriteFile(caKeyFile(), keyPEM, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(CACertFile(), certPEM, 0644)
+}
+
+// generateCA creates a self-signed CA certificate and its private key.
+func generateCA(now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := "ExitBox TLS Inspection CA"
+	if host, err := os.Hostname(); err == nil && host != "" {
+		name += " (" + host + ")"
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"ExitBox"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// squidHasTLS reports whether the running Squid container was started with
+// the CA mounted, i.e. whether it can intercept TLS.
+func squidHasTLS(rt container.Runtime) bool {
+	return squidHasLabel(rt, tlsLabel)
+}
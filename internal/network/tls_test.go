@@ -0,0 +1,78 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestGenerateCA(t *testing.T) {
+	now := time.Now()
+	certPEM, keyPEM, err := generateCA(now)
+	if err != nil {
+		t.Fatalf("generateCA: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatal("certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	if !cert.IsCA || !cert.MaxPathLenZero {
+		t.Error("certificate should be a CA that cannot sign intermediates")
+	}
+	if cert.NotAfter.Before(now.AddDate(9, 0, 0)) {
+		t.Errorf("CA expires too soon: %v", cert.NotAfter)
+	}
+	if block, _ := pem.Decode(keyPEM); block == nil || block.Type != "EC PRIVATE KEY" {
+		t.Error("key is not an EC private key PEM")
+	}
+}
+
+func TestInspectionCA(t *testing.T) {
+	origHome, origData := config.Home, config.Data
+	config.Home, config.Data = t.TempDir(), t.TempDir()
+	defer func() { config.Home, config.Data = origHome, origData }()
+
+	// No rules: no CA.
+	if err := config.SaveAllowlist(&config.Allowlist{Custom: []string{"example.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ca, err := InspectionCA(); err != nil || ca != nil {
+		t.Fatalf("InspectionCA without rules = %v, %v", ca, err)
+	}
+
+	al := &config.Allowlist{HTTPRules: []config.HTTPRule{{Domain: "api.github.com", Methods: []string{"GET"}}}}
+	if err := config.SaveAllowlist(al); err != nil {
+		t.Fatal(err)
+	}
+	first, err := InspectionCA()
+	if err != nil || first == nil {
+		t.Fatalf("InspectionCA = %v, %v", first, err)
+	}
+	second, _ := InspectionCA()
+	if string(first) != string(second) {
+		t.Error("CA should be generated once and reused")
+	}
+	info, err := os.Stat(caKeyFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("CA key mode = %v, want 0600", info.Mode().Perm())
+	}
+}
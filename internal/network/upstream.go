@@ -0,0 +1,275 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/cloud-exit/exitbox/internal/vault"
+)
+
+// Behind a corporate proxy Squid cannot reach the internet itself, so it
+// forwards every request to the parent proxy (cache_peer + never_direct),
+// except bypass destinations it reaches directly. Credentials come from the
+// vault: they are unlocked once when the firewall starts and cached, bound
+// to the proxy URL, until the firewall stops. The cache_peer line carrying
+// them lives in a separate owner-only file that the image copies into
+// place for the squid user, so squid.conf stays free of secrets.
+
+// squidUpstreamMount is where the cache_peer file is mounted inside the
+// Squid container, and squidUpstreamConf where Squid includes it from.
+const (
+	squidUpstreamMount = "/etc/exitbox/upstream.conf"
+	squidUpstreamConf  = "/etc/squid/upstream.conf"
+)
+
+// upstreamLabel marks a Squid container that can include the cache_peer file.
+const upstreamLabel = "exitbox.upstream-proxy"
+
+// UpstreamPeer is the parent proxy Squid forwards requests to.
+type UpstreamPeer struct {
+	Host   string
+	Port   int
+	TLS    bool
+	Login  string // "user:password", empty without credentials
+	Bypass []string
+}
+
+// upstreamLoginFile returns the path of the cached upstream proxy login.
+func upstreamLoginFile() string {
+	return filepath.Join(config.Cache, "upstream-proxy.login")
+}
+
+// parseUpstreamURL returns the host, port and whether TLS is used for a
+// parent proxy URL.
+func parseUpstreamURL(raw string) (host string, port int, useTLS bool, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return "", 0, false, fmt.Errorf("invalid upstream proxy URL %q", raw)
+	}
+	switch u.Scheme {
+	case "http":
+		port = 80
+	case "https":
+		port, useTLS = 443, true
+	default:
+		return "", 0, false, fmt.Errorf("upstream proxy URL must start with http:// or https://: %s", raw)
+	}
+	if u.User != nil {
+		return "", 0, false, fmt.Errorf("upstream proxy URL must not contain credentials; store them in the vault")
+	}
+	if p := u.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil || port < 1 || port > 65535 {
+			return "", 0, false, fmt.Errorf("invalid port in upstream proxy URL %q", raw)
+		}
+	}
+	return u.Hostname(), port, useTLS, nil
+}
+
+// UnlockUpstreamProxy reads the upstream proxy credentials from the vault,
+// asking prompt for the vault password, and caches them for the proxy. It
+// does nothing without an upstream proxy or credentials, or when they are
+// already unlocked for the configured URL. prompt is given the proxy
+// settings to show, which are the approved ones (see config.PendingSettings).
+func UnlockUpstreamProxy(prompt func(workspace string, up config.UpstreamProxy) (string, error)) error {
+	cfg := config.LoadOrDefault()
+	up := cfg.Settings.UpstreamProxy
+	if up.URL == "" || up.Credentials == nil {
+		return nil
+	}
+	if _, _, _, err := parseUpstreamURL(up.URL); err != nil {
+		return err
+	}
+	if _, ok := cachedUpstreamLogin(up.URL); ok {
+		return nil
+	}
+
+	ws := up.Credentials.VaultWorkspace
+	if ws == "" {
+		ws = cfg.Settings.DefaultWorkspace
+	}
+	if ws == "" || !vault.IsInitialized(ws) {
+		return fmt.Errorf("upstream proxy credentials: no vault for workspace '%s'", ws)
+	}
+	password, err := prompt(ws, up)
+	if err != nil {
+		return err
+	}
+	store, err := vault.Open(ws, password)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	user, err := store.Get(up.Credentials.UsernameKey)
+	if err != nil {
+		return fmt.Errorf("upstream proxy username: %w", err)
+	}
+	pass, err := store.Get(up.Credentials.PasswordKey)
+	if err != nil {
+		return fmt.Errorf("upstream proxy password: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(upstreamLoginFile()), 0755); err != nil {
+		return err
+	}
+	content := up.URL + "\n" + user + ":" + pass + "\n"
+	return os.WriteFile(upstreamLoginFile(), []byte(content), 0600)
+}
+
+// cachedUpstreamLogin returns the unlocked login if it was unlocked for
+// proxyURL. Credentials are never sent to a proxy the user did not unlock
+// them for.
+func cachedUpstreamLogin(proxyURL string) (string, bool) {
+	data, err := os.ReadFile(upstreamLoginFile())
+	if err != nil {
+		return "", false
+	}
+	unlockedFor, login, ok := strings.Cut(strings.TrimRight(string(data), "\n"), "\n")
+	if !ok || unlockedFor != proxyURL {
+		return "", false
+	}
+	return login, true
+}
+
+// upstreamConfigFile returns the path of the rendered cache_peer file.
+func upstreamConfigFile() string {
+	return filepath.Join(config.Cache, "squid-upstream.conf")
+}
+
+// writeUpstreamConfig renders the cache_peer file, empty without a peer.
+func writeUpstreamConfig(peer *UpstreamPeer) error {
+	content := ""
+	if peer != nil {
+		content = UpstreamPeerLine(peer)
+	}
+	if err := os.MkdirAll(filepath.Dir(upstreamConfigFile()), 0755); err != nil {
+		return err
+	}
+	// Written in place: the file is bind-mounted, so it must keep its inode.
+	if err := os.WriteFile(upstreamConfigFile(), []byte(content), 0600); err != nil {
+		return err
+	}
+	return os.Chmod(upstreamConfigFile(), 0600)
+}
+
+// clearUpstreamLogin removes the cached upstream proxy login and the
+// cache_peer file carrying it.
+func clearUpstreamLogin() {
+	_ = os.Remove(upstreamLoginFile())
+	_ = os.Remove(upstreamConfigFile())
+}
+
+// upstreamPeer returns the configured parent proxy, or nil when Squid
+// connects directly. Configured credentials that have not been unlocked are
+// an error, since the proxy would reject every request.
+func upstreamPeer() (*UpstreamPeer, error) {
+	up := config.LoadOrDefault().Settings.UpstreamProxy
+	if up.URL == "" {
+		return nil, nil
+	}
+	host, port, useTLS, err := parseUpstreamURL(up.URL)
+	if err != nil {
+		return nil, err
+	}
+	peer := &UpstreamPeer{Host: host, Port: port, TLS: useTLS, Bypass: up.Bypass}
+	if up.Credentials != nil {
+		login, ok := cachedUpstreamLogin(up.URL)
+		if !ok {
+			return nil, fmt.Errorf("upstream proxy credentials are locked; start an agent interactively to unlock them")
+		}
+		peer.Login = login
+	}
+	return peer, nil
+}
+
+// squidLoginValue escapes a login for cache_peer login=. Squid decodes
+// %XX escapes, so everything but unreserved characters and the separating
+// colon is escaped.
+func squidLoginValue(login string) string {
+	user, pass, _ := strings.Cut(login, ":")
+	return escapeLoginPart(user) + ":" + escapeLoginPart(pass)
+}
+
+func escapeLoginPart(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// UpstreamPeerLine returns the cache_peer directive for the parent proxy.
+func UpstreamPeerLine(peer *UpstreamPeer) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cache_peer %s parent %d 0 no-query no-digest no-netdb-exchange default name=upstream",
+		peerHost(peer.Host), peer.Port)
+	if peer.TLS {
+		b.WriteString(" tls")
+	}
+	if peer.Login != "" {
+		fmt.Fprintf(&b, " login=%s", squidLoginValue(peer.Login))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// writeUpstreamSection renders the routing directives: everything goes to
+// the parent proxy, except bypass destinations.
+func writeUpstreamSection(b *strings.Builder, peer *UpstreamPeer) {
+	b.WriteString("\n# Upstream proxy\n")
+	fmt.Fprintf(b, "include %s\n", squidUpstreamConf)
+	seen := make(map[string]bool)
+	for _, entry := range peer.Bypass {
+		normalized, err := NormalizeAllowlistEntry(entry)
+		if err != nil {
+			ui.Warnf("Skipping invalid upstream proxy bypass entry: %s", entry)
+			continue
+		}
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		fmt.Fprintf(b, "acl upstream_bypass dstdomain %s\n", normalized)
+	}
+	if len(seen) > 0 {
+		b.WriteString("always_direct allow upstream_bypass\n")
+	}
+	b.WriteString("never_direct allow all\n")
+}
+
+// peerHost brackets IPv6 addresses for cache_peer.
+func peerHost(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "[" + host + "]"
+	}
+	return host
+}
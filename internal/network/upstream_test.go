@@ -0,0 +1,169 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package network
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/vault"
+)
+
+func TestParseUpstreamURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		host string
+		port int
+		tls  bool
+	}{
+		{"http://proxy.corp.example:8080", "proxy.corp.example", 8080, false},
+		{"http://proxy.corp.example", "proxy.corp.example", 80, false},
+		{"https://proxy.corp.example", "proxy.corp.example", 443, true},
+		{"http://[fd00::1]:3128", "fd00::1", 3128, false},
+	}
+	for _, tt := range tests {
+		host, port, useTLS, err := parseUpstreamURL(tt.url)
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if host != tt.host || port != tt.port || useTLS != tt.tls {
+			t.Errorf("%s = %s %d %v", tt.url, host, port, useTLS)
+		}
+	}
+
+	for _, bad := range []string{"", "proxy:8080", "socks5://proxy:1080", "http://user:pw@proxy:8080", "http://proxy:99999"} {
+		if _, _, _, err := parseUpstreamURL(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestGenerateSquidConfig_Upstream(t *testing.T) {
+	conf := GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil, SquidOptions{})
+	if strings.Contains(conf, "never_direct") {
+		t.Error("never_direct should only be set with an upstream proxy")
+	}
+
+	peer := &UpstreamPeer{
+		Host:   "proxy.corp.example",
+		Port:   8080,
+		Login:  "alice:s3cret",
+		Bypass: []string{"*.corp.example", "corp.example", "10.0.0.5", "bad host"},
+	}
+	conf = GenerateSquidConfig("10.89.0.0/24", []string{"example.com"}, nil, SquidOptions{Upstream: peer})
+	for _, want := range []string{
+		"include /etc/squid/upstream.conf\n",
+		"acl upstream_bypass dstdomain .corp.example\n",
+		"acl upstream_bypass dstdomain 10.0.0.5\n",
+		"always_direct allow upstream_bypass\n",
+		"never_direct allow all\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("config missing %q", want)
+		}
+	}
+	if strings.Count(conf, ".corp.example\n") != 1 {
+		t.Error("bypass entries should be deduplicated")
+	}
+	if strings.Contains(conf, "s3cret") {
+		t.Error("squid.conf must not contain the upstream credentials")
+	}
+}
+
+func TestUpstreamPeerLine(t *testing.T) {
+	line := UpstreamPeerLine(&UpstreamPeer{Host: "proxy.corp.example", Port: 8080})
+	want := "cache_peer proxy.corp.example parent 8080 0 no-query no-digest no-netdb-exchange default name=upstream\n"
+	if line != want {
+		t.Errorf("line = %q, want %q", line, want)
+	}
+
+	line = UpstreamPeerLine(&UpstreamPeer{Host: "fd00::1", Port: 443, TLS: true, Login: `corp\alice:p@ss word:%`})
+	if !strings.HasPrefix(line, "cache_peer [fd00::1] parent 443 ") {
+		t.Errorf("IPv6 host not bracketed: %q", line)
+	}
+	if !strings.Contains(line, " tls login=corp%5Calice:p%40ss%20word%3A%25\n") {
+		t.Errorf("login not escaped: %q", line)
+	}
+}
+
+func TestUnlockUpstreamProxy(t *testing.T) {
+	useTempConfig(t)
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
+	if err := vault.Init("work", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.QuickSet("work", "pw", "PROXY_USER", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.QuickSet("work", "pw", "PROXY_PASS", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Settings.DefaultWorkspace = "work"
+	cfg.Settings.UpstreamProxy = config.UpstreamProxy{
+		URL:         "http://proxy.corp.example:8080",
+		Credentials: &config.UpstreamProxyCredentials{UsernameKey: "PROXY_USER", PasswordKey: "PROXY_PASS"},
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := upstreamPeer(); err == nil {
+		t.Error("locked credentials should be an error")
+	}
+
+	prompts := 0
+	prompt := func(ws string, up config.UpstreamProxy) (string, error) {
+		prompts++
+		if ws != "work" || up.URL != "http://proxy.corp.example:8080" || up.Credentials.PasswordKey != "PROXY_PASS" {
+			return "", fmt.Errorf("unexpected prompt for %s: %+v", ws, up)
+		}
+		return "pw", nil
+	}
+	if err := UnlockUpstreamProxy(prompt); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnlockUpstreamProxy(prompt); err != nil {
+		t.Fatal(err)
+	}
+	if prompts != 1 {
+		t.Errorf("prompted %d times, want 1", prompts)
+	}
+
+	peer, err := upstreamPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.Login != "alice:s3cret" {
+		t.Errorf("login = %q", peer.Login)
+	}
+	if fi, err := os.Stat(upstreamLoginFile()); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("login file mode = %v, err = %v", fi.Mode().Perm(), err)
+	}
+
+	// Pointing the config at another proxy must not send it the login.
+	cfg.Settings.UpstreamProxy.URL = "http://attacker.example:8080"
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upstreamPeer(); err == nil {
+		t.Error("login unlocked for another proxy URL was reused")
+	}
+
+	clearUpstreamLogin()
+	if _, err := os.Stat(upstreamLoginFile()); !os.IsNotExist(err) {
+		t.Error("login file not removed")
+	}
+}
@@ -0,0 +1,412 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package overlay mounts a project copy-on-write for a session: the agent
+// sees the project at /workspace, but its writes land in an upper
+// directory in the ExitBox cache until they are applied to the project.
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/project"
+)
+
+// Session is a session's overlay over a project.
+type Session struct {
+	Name    string
+	Dir     string // holds the upper and work directories
+	Project string
+	Updated time.Time
+}
+
+// Change is a file the agent added, modified or deleted in the overlay.
+type Change struct {
+	Path string // relative to the project, with forward slashes
+	Kind string // "added", "modified" or "deleted"
+}
+
+// Root returns the directory holding a project's overlays.
+func Root(projectDir string) string {
+	return filepath.Join(config.Cache, "overlays", project.GenerateFolderName(projectDir))
+}
+
+// Upper is where the agent's writes land.
+func (s *Session) Upper() string { return filepath.Join(s.Dir, "upper") }
+
+// Work is the overlay's scratch directory.
+func (s *Session) Work() string { return filepath.Join(s.Dir, "work") }
+
+// Open returns the session's overlay, creating it when the session has
+// none yet.
+func Open(projectDir, name string) (*Session, error) {
+	slug := project.SessionSlug(name)
+	if slug == "" {
+		return nil, fmt.Errorf("session name %q cannot name an overlay", name)
+	}
+	s := &Session{Name: name, Dir: filepath.Join(Root(projectDir), slug), Project: projectDir}
+	for _, dir := range []string{s.Upper(), s.Work()} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, "session"), []byte(name+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns a project's overlays, most recently used first.
+func List(projectDir string) ([]*Session, error) {
+	entries, err := os.ReadDir(Root(projectDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []*Session
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		s := &Session{Name: e.Name(), Dir: filepath.Join(Root(projectDir), e.Name()), Project: projectDir}
+		if data, readErr := os.ReadFile(filepath.Join(s.Dir, "session")); readErr == nil {
+			s.Name = strings.TrimSpace(string(data))
+		}
+		if info, statErr := os.Stat(s.Upper()); statErr == nil {
+			s.Updated = info.ModTime()
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Updated.After(out[j].Updated) })
+	return out, nil
+}
+
+// Find returns the project's overlay for a session name.
+func Find(projectDir, name string) (*Session, error) {
+	sessions, err := List(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	slug := project.SessionSlug(name)
+	for _, s := range sessions {
+		if s.Name == name || filepath.Base(s.Dir) == slug {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no overlay for session '%s' in this project", name)
+}
+
+// MountArgs returns the container flags mounting the project at /workspace
+// through the overlay. Podman mounts it itself; docker mounts it as a
+// volume of the local driver.
+func (s *Session) MountArgs(runtime string) ([]string, error) {
+	for _, dir := range []string{s.Project, s.Dir} {
+		if strings.ContainsAny(dir, ",:\"") {
+			return nil, fmt.Errorf("overlay paths cannot contain ',', ':' or '\"': %s", dir)
+		}
+	}
+	if runtime == "podman" {
+		return []string{"-v", s.Project + ":/workspace:O,upperdir=" + s.Upper() + ",workdir=" + s.Work()}, nil
+	}
+	opts := "lowerdir=" + s.Project + ",upperdir=" + s.Upper() + ",workdir=" + s.Work()
+	return []string{"--mount", `type=volume,dst=/workspace,volume-driver=local,volume-opt=type=overlay,volume-opt=device=overlay,"volume-opt=o=` + opts + `"`}, nil
+}
+
+// whiteoutPrefix marks deletions in overlays that cannot create whiteout
+// devices, such as fuse-overlayfs.
+const whiteoutPrefix = ".wh."
+
+// opaqueMarker marks a directory that hides its lower counterpart.
+const opaqueMarker = ".wh..wh..opq"
+
+// Changes lists what the agent changed, sorted by path. Files copied up
+// without being changed are left out, as are changes already applied.
+func (s *Session) Changes() ([]Change, error) {
+	upper := s.Upper()
+	seen := make(map[string]bool)
+	opaque := make(map[string]bool)
+	var changes []Change
+	add := func(rel, kind string) {
+		if !seen[rel] {
+			seen[rel] = true
+			changes = append(changes, Change{Path: rel, Kind: kind})
+		}
+	}
+
+	err := filepath.WalkDir(upper, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == upper {
+			return nil
+		}
+		rel, err := filepath.Rel(upper, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := d.Name()
+		lower := filepath.Join(s.Project, filepath.FromSlash(rel))
+
+		switch {
+		case name == opaqueMarker:
+			return nil
+		case strings.HasPrefix(name, whiteoutPrefix):
+			deleted := filepath.Join(filepath.Dir(lower), strings.TrimPrefix(name, whiteoutPrefix))
+			if _, statErr := os.Lstat(deleted); statErr == nil {
+				add(filepath.ToSlash(filepath.Join(filepath.Dir(rel), strings.TrimPrefix(name, whiteoutPrefix))), "deleted")
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if isWhiteout(info) {
+			if _, statErr := os.Lstat(lower); statErr == nil {
+				add(rel, "deleted")
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// Below an opaque directory nothing of the lower one shows.
+			if isOpaque(p) || opaque[filepath.ToSlash(filepath.Dir(rel))] {
+				opaque[rel] = true
+				hiddenLower(lower, p, rel, add)
+			}
+			return nil
+		}
+
+		lowerInfo, statErr := os.Lstat(lower)
+		switch {
+		case statErr != nil:
+			add(rel, "added")
+		case !sameFile(p, info, lower, lowerInfo):
+			add(rel, "modified")
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// hiddenLower reports the lower entries under an opaque upper directory
+// as deleted, unless the upper directory has them too.
+func hiddenLower(lower, upper, rel string, add func(rel, kind string)) {
+	entries, err := os.ReadDir(lower)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if _, statErr := os.Lstat(filepath.Join(upper, e.Name())); statErr != nil {
+			add(rel+"/"+e.Name(), "deleted")
+		}
+	}
+}
+
+// sameFile reports whether an upper file has the lower one's content and
+// mode, as after a copy-up with no change.
+func sameFile(upper string, upperInfo fs.FileInfo, lower string, lowerInfo fs.FileInfo) bool {
+	if upperInfo.Mode() != lowerInfo.Mode() {
+		return false
+	}
+	if upperInfo.Mode()&fs.ModeSymlink != 0 {
+		a, errA := os.Readlink(upper)
+		b, errB := os.Readlink(lower)
+		return errA == nil && errB == nil && a == b
+	}
+	if !upperInfo.Mode().IsRegular() || upperInfo.Size() != lowerInfo.Size() {
+		return false
+	}
+	a, errA := os.ReadFile(upper)
+	b, errB := os.ReadFile(lower)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// Select returns the changes at or under the given paths, or all of them
+// when no paths are given.
+func Select(changes []Change, paths []string) []Change {
+	if len(paths) == 0 {
+		return changes
+	}
+	var out []Change
+	for _, c := range changes {
+		for _, p := range paths {
+			p = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(p)), "/")
+			if c.Path == p || strings.HasPrefix(c.Path, p+"/") || p == "." {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Apply writes changes to the project.
+func (s *Session) Apply(changes []Change) error {
+	for _, c := range changes {
+		if err := s.apply(c); err != nil {
+			return fmt.Errorf("%s: %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+func (s *Session) apply(c Change) error {
+	target := filepath.Join(s.Project, filepath.FromSlash(c.Path))
+	// The overlay is the agent's; never follow a symlink out of the
+	// project on its behalf.
+	if err := checkParents(s.Project, target); err != nil {
+		return err
+	}
+	if c.Kind == "deleted" {
+		return os.RemoveAll(target)
+	}
+
+	src := filepath.Join(s.Upper(), filepath.FromSlash(c.Path))
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file")
+	}
+	return copyFile(src, target, info.Mode().Perm())
+}
+
+// checkParents fails when a directory between root and target is a
+// symlink.
+func checkParents(root, target string) error {
+	rel, err := filepath.Rel(root, filepath.Dir(target))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("outside the project")
+	}
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", dir)
+		}
+	}
+	return nil
+}
+
+// copyFile replaces dst with a copy of src.
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".exitbox-apply-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(dst); err == nil && info.IsDir() {
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// Diff writes a unified diff of a change to w.
+func (s *Session) Diff(w io.Writer, c Change) error {
+	oldFile := filepath.Join(s.Project, filepath.FromSlash(c.Path))
+	newFile := filepath.Join(s.Upper(), filepath.FromSlash(c.Path))
+	switch c.Kind {
+	case "added":
+		oldFile = os.DevNull
+	case "deleted":
+		newFile = os.DevNull
+	}
+	if info, err := os.Lstat(newFile); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		link, _ := os.Readlink(newFile)
+		_, err := fmt.Fprintf(w, "%s: symlink to %s\n", c.Path, link)
+		return err
+	}
+	if info, err := os.Stat(oldFile); err == nil && info.IsDir() {
+		_, err := fmt.Fprintf(w, "%s: directory %s\n", c.Path, c.Kind)
+		return err
+	}
+
+	cmd := exec.Command("diff", "-u", "-L", "a/"+c.Path, "-L", "b/"+c.Path, oldFile, newFile)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// Exit status 1 means the files differ.
+		return nil
+	}
+	return err
+}
+
+// Discard removes the overlay and everything the agent wrote to it.
+func (s *Session) Discard() error {
+	return os.RemoveAll(s.Dir)
+}
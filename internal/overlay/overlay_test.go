@@ -0,0 +1,153 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newSession returns an overlay over a project, with the agent's writes
+// laid out as fuse-overlayfs leaves them in the upper directory.
+func newSession(t *testing.T) *Session {
+	t.Helper()
+	oldCache := config.Cache
+	config.Cache = t.TempDir()
+	t.Cleanup(func() { config.Cache = oldCache })
+
+	projectDir := t.TempDir()
+	writeFiles(t, projectDir, map[string]string{
+		"keep.txt":      "same\n",
+		"edit.txt":      "old\n",
+		"gone.txt":      "bye\n",
+		"dir/a.txt":     "a\n",
+		"dir/b.txt":     "b\n",
+		"redo/old.txt":  "old\n",
+		"redo/kept.txt": "kept\n",
+	})
+
+	s, err := Open(projectDir, "Task One")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, s.Upper(), map[string]string{
+		"keep.txt":              "same\n", // copied up, unchanged
+		"edit.txt":              "new\n",
+		"new/file.txt":          "hello\n",
+		".wh.gone.txt":          "",
+		"dir/.wh.b.txt":         "",
+		"redo/.wh..wh..opq":     "",
+		"redo/kept.txt":         "kept\n",
+		".wh.never-existed.txt": "",
+	})
+	return s
+}
+
+func TestChanges(t *testing.T) {
+	s := newSession(t)
+	got, err := s.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{"dir/b.txt", "deleted"},
+		{"edit.txt", "modified"},
+		{"gone.txt", "deleted"},
+		{"new/file.txt", "added"},
+		{"redo/old.txt", "deleted"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() = %v, want %v", got, want)
+	}
+
+	if found, err := Find(s.Project, "task-one"); err != nil || found.Name != "Task One" {
+		t.Errorf("Find() = %+v, %v", found, err)
+	}
+}
+
+func TestApplySelected(t *testing.T) {
+	s := newSession(t)
+	changes, err := s.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	picked := Select(changes, []string{"new", "gone.txt"})
+	if len(picked) != 2 {
+		t.Fatalf("Select() = %v", picked)
+	}
+	if err := s.Apply(picked); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(s.Project, "new", "file.txt")); err != nil || string(data) != "hello\n" {
+		t.Errorf("new/file.txt = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.Project, "gone.txt")); !os.IsNotExist(err) {
+		t.Errorf("gone.txt still exists: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(s.Project, "edit.txt")); string(data) != "old\n" {
+		t.Errorf("edit.txt was applied: %q", data)
+	}
+
+	rest, err := s.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 3 {
+		t.Errorf("after apply, Changes() = %v", rest)
+	}
+}
+
+func TestApplyRefusesSymlinkedParent(t *testing.T) {
+	s := newSession(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(s.Project, "link")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, s.Upper(), map[string]string{"link/evil.txt": "x\n"})
+	if err := s.Apply([]Change{{"link/evil.txt", "added"}}); err == nil {
+		t.Error("expected an error writing through a symlink")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("file was written outside the project")
+	}
+}
+
+func TestMountArgs(t *testing.T) {
+	s := &Session{Dir: "/cache/ov/s", Project: "/src/app"}
+	podman, err := s.MountArgs("podman")
+	if err != nil || podman[1] != "/src/app:/workspace:O,upperdir=/cache/ov/s/upper,workdir=/cache/ov/s/work" {
+		t.Errorf("podman = %v, %v", podman, err)
+	}
+	docker, err := s.MountArgs("docker")
+	if err != nil || !strings.Contains(docker[1], `"volume-opt=o=lowerdir=/src/app,upperdir=/cache/ov/s/upper,workdir=/cache/ov/s/work"`) {
+		t.Errorf("docker = %v, %v", docker, err)
+	}
+	s.Project = "/src/a,b"
+	if _, err := s.MountArgs("docker"); err == nil {
+		t.Error("expected an error for a path with a comma")
+	}
+}
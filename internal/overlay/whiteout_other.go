@@ -0,0 +1,35 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !linux
+
+package overlay
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// isWhiteout reports whether an upper entry is a kernel overlayfs
+// whiteout. Only Linux has them.
+func isWhiteout(fs.FileInfo) bool { return false }
+
+// isOpaque reports whether an upper directory hides the lower one.
+func isOpaque(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, opaqueMarker))
+	return err == nil
+}
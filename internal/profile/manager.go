@@ -127,8 +127,14 @@ func AddWorkspace(w config.Workspace, cfg *config.Config) error {
 	return config.SaveConfig(cfg)
 }
 
-// RemoveWorkspace removes a workspace from the global config.
+// RemoveWorkspace removes a workspace, and its allowlist, from the global
+// config.
 func RemoveWorkspace(name string, cfg *config.Config) error {
+	if w := findByName(cfg.Workspaces.Items, name); w != nil {
+		if err := config.SaveWorkspaceAllowlist(w.Name, nil); err != nil {
+			return err
+		}
+	}
 	cfg.Workspaces.Items = deleteByName(cfg.Workspaces.Items, name)
 	if strings.EqualFold(cfg.Workspaces.Active, name) {
 		cfg.Workspaces.Active = ""
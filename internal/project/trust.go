@@ -0,0 +1,113 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Anyone who can write to a repository can edit its .exitbox/config.yaml,
+// agents included, so everything in it but its masks is used only once
+// the user has approved it. The approval is kept outside the project,
+// where agents cannot write it.
+
+// TrustFile records the digest of a project's approved config.
+func TrustFile(projectDir string) string {
+	return filepath.Join(ParentDir(projectDir), "trusted-configs")
+}
+
+// legacyTrustFile is where TrustFile was kept when only mounts needed
+// approval.
+func legacyTrustFile(projectDir string) string {
+	return filepath.Join(ParentDir(projectDir), "trusted-mounts")
+}
+
+// migrateTrustFile moves an approval recorded under the legacy name.
+func migrateTrustFile(projectDir string) {
+	if _, err := os.Stat(TrustFile(projectDir)); !os.IsNotExist(err) {
+		return
+	}
+	_ = os.Rename(legacyTrustFile(projectDir), TrustFile(projectDir))
+}
+
+// NeedsTrust reports whether a project's config has anything to approve.
+// Masks only hide files and need no approval.
+func NeedsTrust(pc *config.ProjectConfig) bool {
+	rest := *pc
+	rest.Masks = nil
+	data, _ := yaml.Marshal(&rest)
+	return strings.TrimSpace(string(data)) != "{}"
+}
+
+// TrustDigest identifies a project's config as the user approved it. It
+// covers the paths the mount sources resolve to, since a symlink in the
+// project can be repointed without touching the config.
+func TrustDigest(projectDir string, pc *config.ProjectConfig) string {
+	h := sha256.New()
+	data, _ := yaml.Marshal(pc)
+	h.Write(data)
+	for _, m := range pc.Mounts {
+		source := m.Source
+		if strings.HasPrefix(source, "~/") {
+			source = filepath.Join(os.Getenv("HOME"), source[2:])
+		}
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(projectDir, source)
+		}
+		resolved, _ := filepath.EvalSymlinks(source)
+		fmt.Fprintf(h, "\n%s", resolved)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Trusted reports whether the user has approved a project's config as it
+// is now.
+func Trusted(projectDir string, pc *config.ProjectConfig) bool {
+	migrateTrustFile(projectDir)
+	stored, err := os.ReadFile(TrustFile(projectDir))
+	return err == nil && strings.TrimSpace(string(stored)) == TrustDigest(projectDir, pc)
+}
+
+// Trust records the user's approval of a project's config.
+func Trust(projectDir string, pc *config.ProjectConfig) error {
+	if err := os.MkdirAll(filepath.Dir(TrustFile(projectDir)), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(TrustFile(projectDir), []byte(TrustDigest(projectDir, pc)+"\n"), 0644)
+}
+
+// LoadConfig reads a project's config as far as it may be used: all of
+// it once approved, only its masks otherwise. trusted is false when
+// something was left out.
+func LoadConfig(projectDir string) (pc *config.ProjectConfig, trusted bool, err error) {
+	pc, err = config.LoadProjectConfig(projectDir)
+	if err != nil {
+		return nil, false, err
+	}
+	if !NeedsTrust(pc) || Trusted(projectDir, pc) {
+		return pc, true, nil
+	}
+	return &config.ProjectConfig{Masks: pc.Masks}, false, nil
+}
@@ -0,0 +1,117 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestTrusted(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	projectDir := t.TempDir()
+	pc := &config.ProjectConfig{Mounts: []config.Mount{{Source: "data"}}}
+	if Trusted(projectDir, pc) {
+		t.Fatal("config trusted before approval")
+	}
+	if err := Trust(projectDir, pc); err != nil {
+		t.Fatal(err)
+	}
+	if !Trusted(projectDir, pc) {
+		t.Error("approved config not trusted")
+	}
+
+	changes := map[string]func(*config.ProjectConfig){
+		"mounts":    func(c *config.ProjectConfig) { c.Mounts[0].Mode = "rw" },
+		"allowlist": func(c *config.ProjectConfig) { c.Allowlist = []string{"example.com"} },
+		"agent_args": func(c *config.ProjectConfig) {
+			c.AgentArgs = map[string][]string{"claude": {"--dangerously-skip-permissions"}}
+		},
+		"packages":    func(c *config.ProjectConfig) { c.Packages = []string{"curl"} },
+		"development": func(c *config.ProjectConfig) { c.Development = []string{"python"} },
+		"resources":   func(c *config.ProjectConfig) { c.Resources = &config.Resources{Memory: "64g"} },
+		"workspace":   func(c *config.ProjectConfig) { c.Workspace = "work" },
+		"agent":       func(c *config.ProjectConfig) { c.Agent = "codex" },
+	}
+	for field, change := range changes {
+		changed := &config.ProjectConfig{Mounts: []config.Mount{{Source: "data"}}}
+		change(changed)
+		if Trusted(projectDir, changed) {
+			t.Errorf("config with changed %s still trusted", field)
+		}
+	}
+}
+
+func TestTrustedMigratesLegacyFile(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	projectDir := t.TempDir()
+	pc := &config.ProjectConfig{Mounts: []config.Mount{{Source: "data"}}}
+	if err := Trust(projectDir, pc); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(TrustFile(projectDir), legacyTrustFile(projectDir)); err != nil {
+		t.Fatal(err)
+	}
+	if !Trusted(projectDir, pc) {
+		t.Error("config approved under the legacy name not trusted")
+	}
+	if _, err := os.Stat(legacyTrustFile(projectDir)); !os.IsNotExist(err) {
+		t.Error("legacy approval file not moved")
+	}
+}
+
+func TestNeedsTrust(t *testing.T) {
+	if NeedsTrust(&config.ProjectConfig{}) || NeedsTrust(&config.ProjectConfig{Masks: []string{"*.pem"}}) {
+		t.Error("an empty or masks-only config needs no approval")
+	}
+	if !NeedsTrust(&config.ProjectConfig{Packages: []string{"curl"}}) {
+		t.Error("packages need approval")
+	}
+}
+
+func TestLoadConfigKeepsMasksUntilTrusted(t *testing.T) {
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	projectDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(projectDir, ".exitbox"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := "packages: [curl]\nagent_args:\n  claude: [--verbose]\nmasks: [\"*.pem\"]\n"
+	if err := os.WriteFile(config.ProjectConfigFile(projectDir), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, trusted, err := LoadConfig(projectDir)
+	if err != nil || trusted {
+		t.Fatalf("LoadConfig = %v, %v", trusted, err)
+	}
+	if len(pc.Packages) != 0 || len(pc.AgentArgs) != 0 || !slices.Equal(pc.Masks, []string{"*.pem"}) {
+		t.Errorf("unapproved config = %+v", pc)
+	}
+
+	full, _ := config.LoadProjectConfig(projectDir)
+	if err := Trust(projectDir, full); err != nil {
+		t.Fatal(err)
+	}
+	if pc, trusted, _ := LoadConfig(projectDir); !trusted || len(pc.Packages) != 1 || len(pc.AgentArgs["claude"]) != 1 {
+		t.Errorf("approved config = %+v, %v", pc, trusted)
+	}
+}
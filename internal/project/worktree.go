@@ -0,0 +1,278 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Worktree is a private checkout of a project's git repository for one
+// session, mounted at /workspace instead of the project itself.
+//
+// It is a clone rather than a `git worktree`: a worktree's .git file
+// points into the project's repository, which the container cannot see.
+// The clone belongs to the agent, so its config and hooks are never used
+// on the host. Its work is brought back by fetching from it into the
+// project's repository, and everything else runs there.
+type Worktree struct {
+	Dir     string    `yaml:"-"`       // the clone
+	Repo    string    `yaml:"repo"`    // top level of the project's repository
+	Subdir  string    `yaml:"subdir"`  // project directory relative to Repo
+	Session string    `yaml:"session"` // session name
+	Branch  string    `yaml:"branch"`  // branch checked out in the clone
+	Base    string    `yaml:"base"`    // commit the session started from
+	Created time.Time `yaml:"created"`
+}
+
+// WorktreesDir returns the directory holding a project's session clones.
+func WorktreesDir(projectDir string) string {
+	return filepath.Join(ParentDir(projectDir), "worktrees")
+}
+
+// SessionSlug turns a session name into a directory or branch name.
+func SessionSlug(session string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(session) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	return strings.Trim(b.String(), "-.")
+}
+
+// metaFile holds a worktree's metadata next to the clone, out of the
+// agent's reach.
+func (w *Worktree) metaFile() string {
+	return w.Dir + ".yaml"
+}
+
+// Ref is the ref the session's work is fetched into in the project's
+// repository.
+func (w *Worktree) Ref() string {
+	return "refs/exitbox/" + filepath.Base(w.Dir)
+}
+
+// MountDir is the directory mounted at /workspace: the clone, or the
+// project's subdirectory within it.
+func (w *Worktree) MountDir() string {
+	return filepath.Join(w.Dir, w.Subdir)
+}
+
+// OpenWorktree returns the session's clone of the project, creating it
+// from the repository's HEAD when the session has none yet. created
+// reports whether it is new.
+func OpenWorktree(projectDir, session string) (w *Worktree, created bool, err error) {
+	slug := SessionSlug(session)
+	if slug == "" {
+		return nil, false, fmt.Errorf("session name %q cannot name a worktree", session)
+	}
+	dir := filepath.Join(WorktreesDir(projectDir), slug)
+
+	if data, readErr := os.ReadFile(dir + ".yaml"); readErr == nil {
+		w = &Worktree{}
+		if err := yaml.Unmarshal(data, w); err != nil {
+			return nil, false, fmt.Errorf("read worktree metadata: %w", err)
+		}
+		w.Dir = dir
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return w, false, nil
+		}
+	}
+
+	top, err := git(projectDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, false, fmt.Errorf("%s is not in a git repository", projectDir)
+	}
+	base, err := git(top, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, false, fmt.Errorf("the repository has no commits yet")
+	}
+	// rev-parse resolves symlinks, so compare against the resolved path.
+	resolved, err := filepath.EvalSymlinks(projectDir)
+	if err != nil {
+		return nil, false, err
+	}
+	subdir, err := filepath.Rel(top, resolved)
+	if err != nil || strings.HasPrefix(subdir, "..") {
+		return nil, false, fmt.Errorf("%s is outside its repository %s", projectDir, top)
+	}
+
+	w = &Worktree{
+		Dir:     dir,
+		Repo:    top,
+		Subdir:  subdir,
+		Session: session,
+		Branch:  "exitbox/" + slug,
+		Base:    base,
+		Created: time.Now(),
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, false, err
+	}
+	// No hardlinks: objects shared with the project would be writable
+	// from the container.
+	if _, err := git("", "clone", "--quiet", "--no-hardlinks", top, dir); err != nil {
+		return nil, false, err
+	}
+	if _, err := git(dir, "checkout", "--quiet", "-B", w.Branch, base); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, false, err
+	}
+	data, err := yaml.Marshal(w)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(w.metaFile(), data, 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, false, err
+	}
+	return w, true, nil
+}
+
+// Dirty reports whether the project's repository has uncommitted changes,
+// which a new clone does not include.
+func Dirty(projectDir string) bool {
+	out, err := git(projectDir, "status", "--porcelain")
+	return err == nil && out != ""
+}
+
+// Collect fetches the session's work into Ref in the project's
+// repository. Changes the agent left uncommitted are committed on top of
+// its last commit there, without running git inside the clone.
+func (w *Worktree) Collect() error {
+	if _, err := git(w.Repo, "fetch", "--quiet", "--no-tags", w.Dir, "+HEAD:"+w.Ref()); err != nil {
+		return err
+	}
+	head, err := git(w.Repo, "rev-parse", w.Ref())
+	if err != nil {
+		return err
+	}
+
+	// Stage the clone's files in a scratch index, using the project's
+	// configuration and the clone's .gitignore files.
+	index, err := os.CreateTemp("", "exitbox-index-*")
+	if err != nil {
+		return err
+	}
+	indexFile := index.Name()
+	_ = index.Close()
+	defer os.Remove(indexFile)
+	env := []string{"GIT_INDEX_FILE=" + indexFile}
+	if _, err := gitEnv(w.Repo, env, "read-tree", head); err != nil {
+		return err
+	}
+	if _, err := gitEnv(w.Repo, env, "-c", "core.fsmonitor=false", "--work-tree="+w.Dir, "add", "--all"); err != nil {
+		return err
+	}
+	tree, err := gitEnv(w.Repo, env, "write-tree")
+	if err != nil {
+		return err
+	}
+	headTree, err := git(w.Repo, "rev-parse", head+"^{tree}")
+	if err != nil {
+		return err
+	}
+	if tree == headTree {
+		return nil
+	}
+	commit, err := git(w.Repo, "commit-tree", tree, "-p", head, "-m", "Uncommitted changes from ExitBox session "+w.Session)
+	if err != nil {
+		return err
+	}
+	_, err = git(w.Repo, "update-ref", w.Ref(), commit)
+	return err
+}
+
+// Commits lists the session's commits, oldest first. Collect must run
+// first.
+func (w *Worktree) Commits() ([]string, error) {
+	out, err := git(w.Repo, "log", "--reverse", "--format=%h %s", w.Base+".."+w.Ref())
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// DiffStat summarizes the session's changes. Collect must run first.
+func (w *Worktree) DiffStat() (string, error) {
+	return git(w.Repo, "diff", "--stat", w.Base, w.Ref())
+}
+
+// Merge merges the session's work into the project's current branch.
+func (w *Worktree) Merge() error {
+	return gitInteractive(w.Repo, "merge", "--no-ff", "-m", "Merge ExitBox session "+w.Session, w.Ref())
+}
+
+// CherryPick applies the session's commits to the project's current
+// branch.
+func (w *Worktree) CherryPick() error {
+	return gitInteractive(w.Repo, "cherry-pick", w.Base+".."+w.Ref())
+}
+
+// Remove deletes the clone, its metadata and Ref.
+func (w *Worktree) Remove() error {
+	_, _ = git(w.Repo, "update-ref", "-d", w.Ref())
+	if err := os.RemoveAll(w.Dir); err != nil {
+		return err
+	}
+	if err := os.Remove(w.metaFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// git runs git in dir and returns its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	return gitEnv(dir, nil, args...)
+}
+
+func gitEnv(dir string, env []string, args ...string) (string, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitInteractive runs git in dir with its output on the terminal, for
+// commands whose conflicts the user resolves.
+func gitInteractive(dir string, args ...string) error {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("git %s failed", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package project
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME":     "Test",
+		"GIT_AUTHOR_EMAIL":    "test@example.com",
+		"GIT_COMMITTER_NAME":  "Test",
+		"GIT_COMMITTER_EMAIL": "test@example.com",
+		"GIT_CONFIG_GLOBAL":   "/dev/null",
+	} {
+		t.Setenv(k, v)
+	}
+	oldHome := config.Home
+	config.Home = t.TempDir()
+	t.Cleanup(func() { config.Home = oldHome })
+
+	repo := t.TempDir()
+	run := func(args ...string) {
+		if _, err := git(repo, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "--quiet", "-b", "main")
+	if err := os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run("add", "a.txt")
+	run("commit", "--quiet", "-m", "initial")
+	return repo
+}
+
+func TestSessionSlug(t *testing.T) {
+	tests := map[string]string{
+		"feature":       "feature",
+		"Fix Login Bug": "fix-login-bug",
+		"../escape":     "escape",
+		"a/b":           "a-b",
+		"..":            "",
+	}
+	for in, want := range tests {
+		if got := SessionSlug(in); got != want {
+			t.Errorf("SessionSlug(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWorktreeCollectAndMerge(t *testing.T) {
+	repo := gitRepo(t)
+
+	w, created, err := OpenWorktree(repo, "my session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || w.Branch != "exitbox/my-session" {
+		t.Fatalf("created=%v branch=%q", created, w.Branch)
+	}
+	if !strings.HasPrefix(w.Dir, WorktreesDir(repo)) {
+		t.Errorf("Dir = %q, want under %q", w.Dir, WorktreesDir(repo))
+	}
+
+	// A committed change and an uncommitted one.
+	if err := os.WriteFile(filepath.Join(w.Dir, "b.txt"), []byte("b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(w.Dir, "add", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(w.Dir, "commit", "--quiet", "-m", "add b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(w.Dir, "a.txt"), []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	again, created, err := OpenWorktree(repo, "my session")
+	if err != nil || created || again.Base != w.Base {
+		t.Fatalf("reopen: created=%v err=%v", created, err)
+	}
+
+	if err := w.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	commits, err := w.Commits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || !strings.HasSuffix(commits[0], "add b") {
+		t.Errorf("Commits() = %q", commits)
+	}
+	stat, err := w.DiffStat()
+	if err != nil || !strings.Contains(stat, "a.txt") || !strings.Contains(stat, "b.txt") {
+		t.Errorf("DiffStat() = %q, %v", stat, err)
+	}
+
+	if err := w.Merge(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(repo, "a.txt"))
+	if err != nil || string(data) != "changed\n" {
+		t.Errorf("a.txt after merge = %q, %v", data, err)
+	}
+
+	if err := w.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(w.Dir); !os.IsNotExist(err) {
+		t.Errorf("clone still exists: %v", err)
+	}
+	if _, err := git(repo, "rev-parse", "--verify", "--quiet", w.Ref()); err == nil {
+		t.Errorf("%s still exists", w.Ref())
+	}
+}
+
+func TestOpenWorktreeRequiresRepo(t *testing.T) {
+	gitRepo(t)
+	if _, _, err := OpenWorktree(t.TempDir(), "s"); err == nil {
+		t.Error("expected an error outside a git repository")
+	}
+}
@@ -0,0 +1,162 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"github.com/cloud-exit/exitbox/static"
+)
+
+// SeccompBlocked lists the notable syscalls the bundled seccomp profile
+// refuses on top of the runtime defaults.
+var SeccompBlocked = []string{"ptrace", "process_vm_readv/writev", "keyctl", "bpf", "perf_event_open", "userfaultfd", "io_uring"}
+
+// Protection is a container protection and whether agent runs get it.
+type Protection struct {
+	Name   string
+	Active bool
+	Detail string
+}
+
+// Protections describes what confines agent containers, hardened or not,
+// on a runtime with caps.
+func Protections(h config.Hardening, hardened bool, caps container.Capabilities) []Protection {
+	out := []Protection{
+		{Name: "Capabilities", Active: true, Detail: "all dropped"},
+		{Name: "No new privileges", Active: true, Detail: "setuid binaries cannot gain privileges"},
+	}
+
+	rootfs := Protection{Name: "Read-only rootfs", Detail: "hardened mode only"}
+	if hardened {
+		rootfs = Protection{Name: rootfs.Name, Active: true, Detail: "writable home volume, tmpfs /tmp, /var/tmp and /run"}
+	}
+	out = append(out, rootfs)
+
+	seccomp := Protection{Name: "Seccomp", Active: caps.Seccomp, Detail: "runtime default"}
+	switch {
+	case !caps.Seccomp:
+		seccomp.Detail = "not supported by the runtime"
+	case hardened && seccompMode(h) == "exitbox":
+		seccomp.Detail = "ExitBox profile (blocks " + strings.Join(SeccompBlocked, ", ") + ")"
+	case hardened && seccompMode(h) != "runtime":
+		seccomp.Detail = "profile " + h.Seccomp
+	}
+	out = append(out, seccomp)
+
+	apparmor := Protection{Name: "AppArmor", Active: caps.AppArmor, Detail: "runtime default profile"}
+	switch {
+	case !caps.AppArmor:
+		apparmor.Detail = "not available"
+	case hardened && h.AppArmor != "":
+		apparmor.Detail = "profile " + h.AppArmor
+	}
+	out = append(out, apparmor)
+
+	selinux := Protection{Name: "SELinux", Active: caps.SELinux, Detail: "runtime default label"}
+	switch {
+	case !caps.SELinux:
+		selinux.Detail = "not available"
+	case hardened && len(h.SELinux) > 0:
+		selinux.Detail = "label " + strings.Join(h.SELinux, ", ")
+	}
+	return append(out, selinux)
+}
+
+// seccompMode returns "exitbox", "runtime" or a profile path.
+func seccompMode(h config.Hardening) string {
+	if h.Seccomp == "" {
+		return "exitbox"
+	}
+	return h.Seccomp
+}
+
+// hardeningArgs returns the container flags for hardened mode. tmpSized is
+// set when the resource limits already mount /tmp.
+func hardeningArgs(h config.Hardening, caps container.Capabilities, tmpSized bool) ([]string, error) {
+	// The root filesystem is read-only. Home stays writable in a volume
+	// seeded from the image (agent binaries live there) and removed with
+	// the container.
+	args := []string{"--read-only", "-v", "/home/user"}
+	if !tmpSized {
+		args = append(args, "--tmpfs", "/tmp:rw,exec,nosuid,nodev,mode=1777")
+	}
+	args = append(args,
+		"--tmpfs", "/var/tmp:rw,nosuid,nodev,mode=1777",
+		"--tmpfs", "/run:rw,nosuid,nodev,mode=755",
+	)
+
+	if caps.Seccomp {
+		switch mode := seccompMode(h); mode {
+		case "runtime":
+		case "exitbox":
+			file, err := writeSeccompProfile()
+			if err != nil {
+				return nil, fmt.Errorf("seccomp profile: %w", err)
+			}
+			args = append(args, "--security-opt", "seccomp="+file)
+		default:
+			if _, err := os.Stat(mode); err != nil {
+				return nil, fmt.Errorf("seccomp profile: %w", err)
+			}
+			args = append(args, "--security-opt", "seccomp="+mode)
+		}
+	} else {
+		ui.Warn("Hardened mode: the runtime does not support seccomp; running without a profile.")
+	}
+
+	if h.AppArmor != "" {
+		if caps.AppArmor {
+			args = append(args, "--security-opt", "apparmor="+h.AppArmor)
+		} else {
+			ui.Warnf("Hardened mode: AppArmor is not available; ignoring profile '%s'.", h.AppArmor)
+		}
+	}
+	if len(h.SELinux) > 0 {
+		if caps.SELinux {
+			for _, l := range h.SELinux {
+				args = append(args, "--security-opt", "label="+l)
+			}
+		} else {
+			ui.Warn("Hardened mode: SELinux is not enabled; ignoring labels.")
+		}
+	}
+	return args, nil
+}
+
+// writeSeccompProfile writes the bundled seccomp profile to the cache,
+// where the runtime can read it, and returns its path.
+func writeSeccompProfile() (string, error) {
+	file := filepath.Join(config.Cache, "seccomp-exitbox.json")
+	if data, err := os.ReadFile(file); err == nil && bytes.Equal(data, static.SeccompProfile) {
+		return file, nil
+	}
+	if err := os.MkdirAll(config.Cache, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(file, static.SeccompProfile, 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
@@ -0,0 +1,109 @@
+package run
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/static"
+)
+
+func TestSeccompProfileBlocksDangerousSyscalls(t *testing.T) {
+	var profile struct {
+		DefaultAction string `json:"defaultAction"`
+		Syscalls      []struct {
+			Names  []string `json:"names"`
+			Action string   `json:"action"`
+		} `json:"syscalls"`
+	}
+	if err := json.Unmarshal(static.SeccompProfile, &profile); err != nil {
+		t.Fatalf("seccomp profile is not valid JSON: %v", err)
+	}
+	if profile.DefaultAction != "SCMP_ACT_ERRNO" {
+		t.Errorf("defaultAction = %s, want an allowlist profile", profile.DefaultAction)
+	}
+	allowed := map[string]bool{}
+	for _, s := range profile.Syscalls {
+		if s.Action == "SCMP_ACT_ALLOW" {
+			for _, n := range s.Names {
+				allowed[n] = true
+			}
+		}
+	}
+	for _, name := range []string{"ptrace", "process_vm_readv", "process_vm_writev", "keyctl", "add_key", "request_key", "bpf", "perf_event_open", "userfaultfd", "io_uring_setup", "unshare", "mount"} {
+		if allowed[name] {
+			t.Errorf("%s is allowed", name)
+		}
+	}
+	for _, name := range []string{"read", "execve", "clone", "futex", "socket", "epoll_pwait"} {
+		if !allowed[name] {
+			t.Errorf("%s is not allowed", name)
+		}
+	}
+}
+
+func TestHardeningArgs(t *testing.T) {
+	old := config.Cache
+	config.Cache = t.TempDir()
+	t.Cleanup(func() { config.Cache = old })
+
+	caps := container.Capabilities{Seccomp: true, SELinux: true}
+	h := config.Hardening{AppArmor: "exitbox-agent", SELinux: []string{"type:container_t"}}
+	args, err := hardeningArgs(h, caps, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(args, " ")
+	profile := filepath.Join(config.Cache, "seccomp-exitbox.json")
+	for _, want := range []string{"--read-only", "-v /home/user", "--tmpfs /tmp:", "--tmpfs /run:", "seccomp=" + profile, "label=type:container_t"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("args missing %q: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "apparmor=") {
+		t.Error("AppArmor profile applied without AppArmor support")
+	}
+	if _, err := os.Stat(profile); err != nil {
+		t.Errorf("seccomp profile not written: %v", err)
+	}
+
+	args, err = hardeningArgs(config.Hardening{Seccomp: "runtime"}, caps, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(args, "/tmp:rw,exec,nosuid,nodev,mode=1777") || strings.Contains(strings.Join(args, " "), "seccomp=") {
+		t.Errorf("args = %v", args)
+	}
+
+	if _, err := hardeningArgs(config.Hardening{Seccomp: "/nonexistent.json"}, caps, false); err == nil {
+		t.Error("expected an error for a missing seccomp profile")
+	}
+}
+
+func TestProtections(t *testing.T) {
+	caps := container.Capabilities{Seccomp: true, AppArmor: true}
+	byName := func(ps []Protection) map[string]Protection {
+		m := map[string]Protection{}
+		for _, p := range ps {
+			m[p.Name] = p
+		}
+		return m
+	}
+
+	off := byName(Protections(config.Hardening{AppArmor: "exitbox-agent"}, false, caps))
+	if off["Read-only rootfs"].Active || off["Seccomp"].Detail != "runtime default" || off["AppArmor"].Detail != "runtime default profile" {
+		t.Errorf("not hardened: %+v", off)
+	}
+	on := byName(Protections(config.Hardening{AppArmor: "exitbox-agent"}, true, caps))
+	if !on["Read-only rootfs"].Active || !strings.HasPrefix(on["Seccomp"].Detail, "ExitBox profile") || on["AppArmor"].Detail != "profile exitbox-agent" {
+		t.Errorf("hardened: %+v", on)
+	}
+	if on["SELinux"].Active {
+		t.Error("SELinux reported active without support")
+	}
+}
@@ -0,0 +1,169 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package run
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/container"
+	"github.com/cloud-exit/exitbox/internal/ipc"
+	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"github.com/cloud-exit/exitbox/internal/ui"
+)
+
+// Summary is the result of a headless run.
+type Summary struct {
+	Agent           string         `json:"agent"`
+	ExitCode        int            `json:"exit_code"`
+	Started         time.Time      `json:"started"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	ChangedFiles    []FileChange   `json:"changed_files"`
+	DeniedDomains   []string       `json:"denied_domains"`
+	Approvals       []ipc.Decision `json:"approvals"`
+	// Error is why the run failed, when it did.
+	Error string `json:"error,omitempty"`
+}
+
+// FileChange is a workspace file the agent added, modified or deleted.
+type FileChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+}
+
+// Headless runs an agent without a terminal and summarizes what it did.
+// opts.Headless is implied.
+func Headless(rt container.Runtime, opts Options) (Summary, error) {
+	opts.Headless = true
+	if opts.ContainerName == "" {
+		// Named up front to find its requests in the network log.
+		opts.ContainerName = project.ContainerName(opts.Agent, opts.ProjectDir)
+	}
+	if opts.Policy == nil {
+		opts.Policy = &ipc.Policy{}
+	}
+
+	before, err := snapshotDir(opts.ProjectDir)
+	if err != nil {
+		ui.Warnf("Failed to scan workspace; changed files will not be reported: %v", err)
+	}
+
+	started := time.Now()
+	exitCode, err := AgentContainer(rt, opts)
+	summary := Summary{
+		Agent:           opts.Agent,
+		ExitCode:        exitCode,
+		Started:         started,
+		DurationSeconds: time.Since(started).Round(time.Millisecond).Seconds(),
+		ChangedFiles:    []FileChange{},
+		DeniedDomains:   []string{},
+		Approvals:       opts.Policy.Decisions(),
+	}
+	if err != nil {
+		summary.Error = err.Error()
+		if summary.ExitCode == 0 {
+			summary.ExitCode = 1
+		}
+		return summary, err
+	}
+
+	if before != nil {
+		if after, scanErr := snapshotDir(opts.ProjectDir); scanErr == nil {
+			summary.ChangedFiles = diffSnapshots(before, after)
+		}
+	}
+	if !opts.NoFirewall {
+		denied, deniedErr := network.DeniedDomains(opts.ContainerName, started)
+		if deniedErr != nil {
+			ui.Warnf("Failed to read network log: %v", deniedErr)
+		}
+		for _, d := range denied {
+			summary.DeniedDomains = append(summary.DeniedDomains, d.Domain)
+		}
+	}
+	if summary.Approvals == nil {
+		summary.Approvals = []ipc.Decision{}
+	}
+	return summary, nil
+}
+
+// fileStamp is what snapshotDir remembers about a file.
+type fileStamp struct {
+	size    int64
+	modTime int64
+	mode    fs.FileMode
+}
+
+// snapshotDir records every file under dir except version control and
+// ExitBox's own state.
+func snapshotDir(dir string) (map[string]fileStamp, error) {
+	snap := make(map[string]fileStamp)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped rather than failing the scan.
+			if d != nil && d.IsDir() && p != dir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if p != dir && (d.Name() == ".git" || d.Name() == ".exitbox") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		snap[filepath.ToSlash(rel)] = fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano(), mode: info.Mode()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// diffSnapshots lists the files added, modified or deleted between two
+// snapshots, sorted by path.
+func diffSnapshots(before, after map[string]fileStamp) []FileChange {
+	changes := []FileChange{}
+	for p, a := range after {
+		b, ok := before[p]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: p, Change: "added"})
+		case a != b:
+			changes = append(changes, FileChange{Path: p, Change: "modified"})
+		}
+	}
+	for p := range before {
+		if _, ok := after[p]; !ok {
+			changes = append(changes, FileChange{Path: p, Change: "deleted"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
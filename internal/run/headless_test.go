@@ -0,0 +1,58 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("keep.txt", "same")
+	write("edit.go", "package a")
+	write("gone.md", "bye")
+	write(".git/HEAD", "ref: refs/heads/main")
+
+	before, err := snapshotDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("edit.go", "package a // edited")
+	write("src/new.go", "package src")
+	write(".git/HEAD", "ref: refs/heads/other")
+	write(".exitbox/session-action", "resume=true")
+	if err := os.Remove(filepath.Join(dir, "gone.md")); err != nil {
+		t.Fatal(err)
+	}
+	// Same size, later mtime.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "keep.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	after, err := snapshotDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FileChange{
+		{Path: "edit.go", Change: "modified"},
+		{Path: "gone.md", Change: "deleted"},
+		{Path: "keep.txt", Change: "modified"},
+		{Path: "src/new.go", Change: "added"},
+	}
+	if got := diffSnapshots(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSnapshots = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"golang.org/x/term"
+)
+
+// reviewDeniedDomains lists the domains Squid refused for a container since
+// the session started and lets the user add chosen ones to the global,
+// project or workspace allowlist.
+func reviewDeniedDomains(containerName, projectDir, workspace string, since time.Time) {
+	denied, err := network.DeniedDomains(containerName, since)
+	if err != nil {
+		ui.Warnf("Learning mode: failed to read network log: %v", err)
+		return
+	}
+	if len(denied) == 0 {
+		ui.Info("Learning mode: no requests were denied this session.")
+		return
+	}
+
+	fmt.Println()
+	ui.Cecho("Learning mode: domains denied this session", ui.Cyan)
+	fmt.Println()
+	for i, d := range denied {
+		fmt.Printf("  %2d. %-50s %d request(s)\n", i+1, d.Domain, d.Count)
+	}
+	fmt.Println()
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		ui.Info("Not a terminal; add domains with 'exitbox setup' or by editing allowlist.yaml.")
+		return
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print("Add to allowlist (e.g. 1,3-4 or 'all'; Enter to skip): ")
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	picks, err := parseSelection(line, len(denied))
+	if err != nil {
+		ui.Warnf("Learning mode: %v", err)
+		return
+	}
+	if len(picks) == 0 {
+		return
+	}
+
+	var domains []string
+	for _, i := range picks {
+		domains = append(domains, denied[i].Domain)
+	}
+
+	choices := "[g]lobal, [p]roject"
+	if workspace != "" {
+		choices += ", [w]orkspace " + workspace
+	}
+	fmt.Printf("Save to %s? [g]: ", choices)
+	answer, _ := reader.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "p", "project":
+		saveScopedDomains("project", domains, func(d string) error { return network.AddProjectDomain(projectDir, d) })
+		return
+	case "w", "workspace":
+		if workspace != "" {
+			saveScopedDomains("workspace "+workspace, domains, func(d string) error { return network.AddWorkspaceDomain(workspace, d) })
+			return
+		}
+	}
+
+	al := config.LoadAllowlistOrDefault()
+	added := addCustomDomains(al, domains)
+	if len(added) == 0 {
+		ui.Info("Selected domains are already in the allowlist.")
+		return
+	}
+	if err := config.SaveAllowlist(al); err != nil {
+		ui.Warnf("Failed to save allowlist: %v", err)
+		return
+	}
+	ui.Successf("Added to allowlist: %s", strings.Join(added, ", "))
+}
+
+// saveScopedDomains adds domains to a project or workspace allowlist.
+func saveScopedDomains(label string, domains []string, add func(string) error) {
+	var added []string
+	for _, d := range domains {
+		if err := add(d); err != nil {
+			ui.Warnf("Failed to add %s: %v", d, err)
+			continue
+		}
+		added = append(added, d)
+	}
+	if len(added) > 0 {
+		ui.Successf("Added to %s allowlist: %s", label, strings.Join(added, ", "))
+	}
+}
+
+// parseSelection parses a list like "1,3-4" or "all" into zero-based
+// indices in [0, n).
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(input, "all") {
+		out := make([]int, n)
+		for i := range out {
+			out[i] = i
+		}
+		return out, nil
+	}
+
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if idx := strings.Index(part, "-"); idx >= 0 {
+			lo, hi = strings.TrimSpace(part[:idx]), strings.TrimSpace(part[idx+1:])
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		for i := from; i <= to; i++ {
+			seen[i-1] = true
+		}
+	}
+
+	out := make([]int, 0, len(seen))
+	for i := range seen {
+		out = append(out, i)
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// addCustomDomains appends domains not already covered by the allowlist to
+// its custom list and returns the ones added.
+func addCustomDomains(al *config.Allowlist, domains []string) []string {
+	existing := make(map[string]bool)
+	for _, d := range al.AllDomains() {
+		if n, err := network.NormalizeAllowlistEntry(d); err == nil {
+			existing[n] = true
+		}
+	}
+
+	var added []string
+	for _, d := range domains {
+		n, err := network.NormalizeAllowlistEntry(d)
+		if err != nil {
+			ui.Warnf("Skipping invalid domain: %s", d)
+			continue
+		}
+		if existing[n] {
+			continue
+		}
+		existing[n] = true
+		entry := strings.TrimPrefix(n, ".")
+		al.Custom = append(al.Custom, entry)
+		added = append(added, entry)
+	}
+	return added
+}
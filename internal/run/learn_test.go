@@ -0,0 +1,56 @@
+package run
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{"", nil},
+		{"  \n", nil},
+		{"1", []int{0}},
+		{"3,1", []int{0, 2}},
+		{"1-3", []int{0, 1, 2}},
+		{"2, 1-2 ,", []int{0, 1}},
+		{"all", []int{0, 1, 2, 3}},
+		{"ALL\n", []int{0, 1, 2, 3}},
+	}
+	for _, tc := range tests {
+		got, err := parseSelection(tc.input, 4)
+		if err != nil {
+			t.Errorf("parseSelection(%q) error: %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestParseSelection_Invalid(t *testing.T) {
+	for _, input := range []string{"0", "5", "x", "3-1", "1-9"} {
+		if _, err := parseSelection(input, 4); err == nil {
+			t.Errorf("parseSelection(%q) should fail", input)
+		}
+	}
+}
+
+func TestAddCustomDomains(t *testing.T) {
+	al := &config.Allowlist{
+		Development: []string{"github.com"},
+		Custom:      []string{"example.com"},
+	}
+	added := addCustomDomains(al, []string{"github.com", "pypi.org", "PyPI.org", "bad domain", "example.com"})
+	if !reflect.DeepEqual(added, []string{"pypi.org"}) {
+		t.Errorf("added = %v, want [pypi.org]", added)
+	}
+	if !reflect.DeepEqual(al.Custom, []string{"example.com", "pypi.org"}) {
+		t.Errorf("Custom = %v", al.Custom)
+	}
+}
@@ -0,0 +1,358 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/project"
+	"github.com/cloud-exit/exitbox/internal/ui"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// hostPathRules are the host paths project mounts are checked against.
+type hostPathRules struct {
+	// denied may not be mounted, nor anything in them or above them.
+	denied []string
+	// enclosing may be mounted below, but not themselves or above.
+	enclosing []string
+	// sensitive need an explicit opt-in and are mounted read-only.
+	sensitive []string
+}
+
+// defaultHostPathRules returns the rules for the invoking user.
+func defaultHostPathRules() hostPathRules {
+	home, _ := os.UserHomeDir()
+	r := hostPathRules{
+		denied: []string{
+			"/etc", "/proc", "/sys", "/dev", "/boot", "/root", "/run", "/var/run",
+			"/var/lib/docker", "/var/lib/containers",
+			config.Home, config.Cache,
+		},
+		enclosing: []string{"/usr", "/var", "/opt", "/tmp"},
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		// Runtime sockets live here.
+		r.denied = append(r.denied, dir)
+	}
+	if home != "" {
+		r.denied = append(r.denied, filepath.Join(home, ".local", "share", "containers"))
+		r.enclosing = append(r.enclosing, home)
+		for _, p := range []string{
+			".ssh", ".gnupg", ".aws", ".azure", ".kube", ".docker", ".config/gcloud",
+			".netrc", ".git-credentials", ".npmrc", ".pypirc",
+		} {
+			r.sensitive = append(r.sensitive, filepath.Join(home, p))
+		}
+	}
+	return r
+}
+
+// within reports whether p is dir or inside it.
+func within(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+// check validates a mount source, which must be absolute with symlinks
+// resolved, and reports whether it must be read-only.
+func (r hostPathRules) check(src string, optIn bool) (readOnly bool, err error) {
+	for _, d := range r.denied {
+		if within(src, d) || within(d, src) {
+			return false, fmt.Errorf("%s would expose %s", src, d)
+		}
+	}
+	for _, d := range r.enclosing {
+		if within(d, src) {
+			return false, fmt.Errorf("%s is too broad; mount a directory inside it", src)
+		}
+	}
+	for _, d := range r.sensitive {
+		switch {
+		case within(src, d):
+			if !optIn {
+				return false, fmt.Errorf("%s is sensitive; set 'sensitive: true' to mount it read-only", src)
+			}
+			return true, nil
+		case within(d, src):
+			return false, fmt.Errorf("%s would expose %s", src, d)
+		}
+	}
+	return false, nil
+}
+
+// mountTarget resolves a mount's container path. It must be under
+// /workspace, /home/user or /mnt, clear of ExitBox's own mounts.
+func mountTarget(m config.Mount, src string) (string, error) {
+	target := m.Target
+	switch {
+	case target == "":
+		target = "/workspace/" + strings.TrimPrefix(filepath.ToSlash(src), "/")
+	case target == "~":
+		target = "/home/user"
+	case strings.HasPrefix(target, "~/"):
+		target = "/home/user/" + target[2:]
+	}
+	if !path.IsAbs(target) {
+		return "", fmt.Errorf("target %s must be an absolute path", target)
+	}
+	target = path.Clean(target)
+	allowed := false
+	for _, root := range []string{"/workspace/", "/home/user/", "/mnt/"} {
+		if strings.HasPrefix(target, root) {
+			allowed = true
+		}
+	}
+	for _, reserved := range []string{"/workspace/.exitbox", "/home/user/.exitbox-config"} {
+		if target == reserved || strings.HasPrefix(target, reserved+"/") {
+			allowed = false
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("target %s must be under /workspace, /home/user or /mnt", target)
+	}
+	return target, nil
+}
+
+// projectMountArgs returns the container flags for a project's mounts.
+// Sources that do not exist are skipped with a warning, like
+// --include-dir; mounts that break the rules are errors.
+func projectMountArgs(mounts []config.Mount, projectDir string, rules hostPathRules) ([]string, error) {
+	var args []string
+	for _, m := range mounts {
+		if m.Source == "" {
+			return nil, fmt.Errorf("mount without a source")
+		}
+		mode := m.Mode
+		if mode == "" {
+			mode = "ro"
+		}
+		if mode != "ro" && mode != "rw" {
+			return nil, fmt.Errorf("mount %s: mode must be ro or rw", m.Source)
+		}
+
+		src, err := filepath.EvalSymlinks(expandPath(m.Source, projectDir))
+		if err != nil {
+			ui.Warnf("Project mount not accessible: %s: %v", m.Source, err)
+			continue
+		}
+		readOnly, err := rules.check(src, m.Sensitive)
+		if err != nil {
+			return nil, fmt.Errorf("mount %s: %w", m.Source, err)
+		}
+		if readOnly && mode == "rw" {
+			ui.Warnf("Mounting %s read-only; sensitive paths cannot be mounted read-write.", m.Source)
+		}
+		if readOnly {
+			mode = "ro"
+		}
+		target, err := mountTarget(m, src)
+		if err != nil {
+			return nil, fmt.Errorf("mount %s: %w", m.Source, err)
+		}
+		args = append(args, "-v", src+":"+target+":"+mode)
+	}
+	return args, nil
+}
+
+// matchMask reports whether a slash-separated path relative to the
+// project matches a mask pattern. Patterns without a slash match a name
+// at any depth; others match from the project root, with "**" matching
+// any number of directories.
+func matchMask(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// maskArgs returns the container flags hiding the files and directories
+// under dir that match the patterns: files are covered with /dev/null,
+// directories with an empty read-only tmpfs.
+func maskArgs(dir string, patterns []string) ([]string, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(p, "**", "*"), ""); err != nil || p == "" {
+			return nil, fmt.Errorf("invalid mask pattern '%s'", p)
+		}
+		if strings.HasPrefix(p, "../") || strings.Contains(p, "/../") {
+			return nil, fmt.Errorf("mask pattern '%s' leaves the project", p)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	var args []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return nil
+		}
+		rel, relErr := filepath.Rel(dir, p)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() && d.Name() == ".git" {
+			return fs.SkipDir
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if strings.ContainsAny(rel, ":,") {
+			for _, pattern := range patterns {
+				if matchMask(pattern, rel) {
+					return fmt.Errorf("cannot mask %s: ':' and ',' are not allowed in mount paths", rel)
+				}
+			}
+		}
+		for _, pattern := range patterns {
+			if d.IsDir() {
+				if matchMask(pattern, rel) || (strings.HasSuffix(pattern, "/**") && matchMask(strings.TrimSuffix(pattern, "/**"), rel)) {
+					args = append(args, "--tmpfs", "/workspace/"+rel+":ro,mode=0555")
+					return fs.SkipDir
+				}
+			} else if matchMask(pattern, rel) {
+				args = append(args, "-v", "/dev/null:/workspace/"+rel+":ro")
+				return nil
+			}
+		}
+		return nil
+	})
+	return args, err
+}
+
+// projectAllowlist normalizes a project's extra domains.
+func projectAllowlist(pc *config.ProjectConfig) ([]string, error) {
+	var domains []string
+	for _, entry := range pc.Allowlist {
+		domain, err := network.NormalizeAllowlistEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("allowlist entry '%s': %w", entry, err)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// ReviewProjectConfig shows what a project's config sets and asks the
+// user to approve it when it is new or has changed. Anyone who can write
+// to the repository can edit its config, so it is never used unseen when
+// it comes from a fresh clone or an agent. Without a terminal nothing is
+// asked and the unapproved config is skipped at run time.
+func ReviewProjectConfig(projectDir string) error {
+	pc, err := config.LoadProjectConfig(projectDir)
+	if err != nil {
+		return err
+	}
+	if !project.NeedsTrust(pc) || project.Trusted(projectDir, pc) || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	if _, err := projectMountArgs(pc.Mounts, projectDir, defaultHostPathRules()); err != nil {
+		return fmt.Errorf("%s: %w", config.ProjectConfigFile(projectDir), err)
+	}
+	domains, err := projectAllowlist(pc)
+	if err != nil {
+		return fmt.Errorf("%s: %w", config.ProjectConfigFile(projectDir), err)
+	}
+
+	fmt.Println()
+	ui.Cecho("This project's .exitbox/config.yaml is new or has changed:", ui.Cyan)
+	fmt.Println()
+	if pc.Workspace != "" {
+		fmt.Printf("  %-20s %s\n", "Workspace:", pc.Workspace)
+	}
+	if pc.Agent != "" {
+		fmt.Printf("  %-20s %s\n", "Default agent:", pc.Agent)
+	}
+	agents := make([]string, 0, len(pc.AgentArgs))
+	for name := range pc.AgentArgs {
+		agents = append(agents, name)
+	}
+	sort.Strings(agents)
+	for _, name := range agents {
+		fmt.Printf("  %-20s %s\n", name+" arguments:", strings.Join(pc.AgentArgs[name], " "))
+	}
+	if len(pc.Development) > 0 {
+		fmt.Printf("  %-20s %s\n", "Development:", strings.Join(pc.Development, ", "))
+	}
+	if len(pc.Packages) > 0 {
+		fmt.Printf("  %-20s %s\n", "Packages:", strings.Join(pc.Packages, ", "))
+	}
+	if r := pc.Resources; r != nil {
+		data, _ := yaml.Marshal(r)
+		fmt.Printf("  %-20s %s\n", "Resources:", strings.Join(strings.Fields(string(data)), " "))
+	}
+	if len(pc.Mounts) > 0 {
+		fmt.Println("  Host mounts:")
+		for _, m := range pc.Mounts {
+			mode := m.Mode
+			if mode == "" || m.Sensitive {
+				mode = "ro"
+			}
+			target := m.Target
+			if target == "" {
+				target = "(default)"
+			}
+			fmt.Printf("    %-38s -> %s (%s)\n", m.Source, target, mode)
+		}
+	}
+	if len(domains) > 0 {
+		fmt.Println("  Allowed domains:")
+		for _, d := range domains {
+			fmt.Printf("    %s\n", d)
+		}
+	}
+	fmt.Println()
+	fmt.Print("Use this config for this project? [y/N]: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		ui.Info("Running without the project's config; only its masks apply.")
+		return nil
+	}
+	return project.Trust(projectDir, pc)
+}
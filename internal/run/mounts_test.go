@@ -0,0 +1,153 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func testRules(home string) hostPathRules {
+	return hostPathRules{
+		denied:    []string{"/etc", "/proc", filepath.Join(home, ".config", "exitbox")},
+		enclosing: []string{"/usr", home},
+		sensitive: []string{filepath.Join(home, ".ssh"), filepath.Join(home, ".aws")},
+	}
+}
+
+func TestHostPathRules(t *testing.T) {
+	home := "/home/dev"
+	rules := testRules(home)
+	tests := []struct {
+		src      string
+		optIn    bool
+		readOnly bool
+		wantErr  bool
+	}{
+		{src: "/home/dev/datasets"},
+		{src: "/usr/share/dict"},
+		{src: "/", wantErr: true},
+		{src: "/etc", wantErr: true},
+		{src: "/etc/ssl", wantErr: true},
+		{src: "/usr", wantErr: true},
+		{src: "/home", wantErr: true},
+		{src: "/home/dev", wantErr: true},
+		{src: "/home/dev/.config", wantErr: true},
+		{src: "/home/dev/.ssh", wantErr: true},
+		{src: "/home/dev/.ssh", optIn: true, readOnly: true},
+		{src: "/home/dev/.aws/config", optIn: true, readOnly: true},
+	}
+	for _, tc := range tests {
+		readOnly, err := rules.check(tc.src, tc.optIn)
+		if (err != nil) != tc.wantErr || readOnly != tc.readOnly {
+			t.Errorf("check(%s, %v) = %v, %v", tc.src, tc.optIn, readOnly, err)
+		}
+	}
+}
+
+func TestProjectMountArgs(t *testing.T) {
+	projectDir := t.TempDir()
+	data := filepath.Join(projectDir, "data")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = filepath.EvalSymlinks(data)
+	rules := testRules("/nonexistent-home")
+
+	args, err := projectMountArgs([]config.Mount{
+		{Source: "data"},
+		{Source: "data", Target: "~/data", Mode: "rw"},
+		{Source: "missing"},
+	}, projectDir, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-v", data + ":/workspace/" + strings.TrimPrefix(data, "/") + ":ro",
+		"-v", data + ":/home/user/data:rw",
+	}
+	if !slices.Equal(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	for _, m := range []config.Mount{
+		{Source: "data", Mode: "rwx"},
+		{Source: "data", Target: "/etc/passwd"},
+		{Source: "data", Target: "/workspace/.exitbox"},
+		{Source: "data", Target: "relative"},
+	} {
+		if _, err := projectMountArgs([]config.Mount{m}, projectDir, rules); err == nil {
+			t.Errorf("mount %+v: expected an error", m)
+		}
+	}
+}
+
+func TestMatchMask(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.pem", "key.pem", true},
+		{"*.pem", "certs/deep/key.pem", true},
+		{"*.pem", "key.pem.txt", false},
+		{"secrets/**", "secrets/a/b.txt", true},
+		{"secrets/**", "other/secrets/a", false},
+		{"**/id_rsa", "a/b/id_rsa", true},
+		{"**/id_rsa", "id_rsa", true},
+		{"config/*.key", "config/app.key", true},
+		{"config/*.key", "config/sub/app.key", false},
+	}
+	for _, tc := range tests {
+		if got := matchMask(tc.pattern, tc.rel); got != tc.want {
+			t.Errorf("matchMask(%q, %q) = %v, want %v", tc.pattern, tc.rel, got, tc.want)
+		}
+	}
+}
+
+func TestMaskArgs(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"secrets/a.txt", "secrets/b/c.txt", "certs/key.pem", "main.go", ".git/x.pem"} {
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args, err := maskArgs(dir, []string{"secrets/**", "*.pem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"-v", "/dev/null:/workspace/certs/key.pem:ro",
+		"--tmpfs", "/workspace/secrets:ro,mode=0555",
+	}
+	if !slices.Equal(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	if _, err := maskArgs(dir, []string{"../outside"}); err == nil {
+		t.Error("expected an error for a pattern leaving the project")
+	}
+	if _, err := maskArgs(dir, []string{"[bad"}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func TestProjectAllowlist(t *testing.T) {
+	got, err := projectAllowlist(&config.ProjectConfig{Allowlist: []string{"https://API.example.com/v1", "*.pkg.dev"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{".api.example.com", ".pkg.dev"}; !slices.Equal(got, want) {
+		t.Errorf("projectAllowlist = %v, want %v", got, want)
+	}
+	if _, err := projectAllowlist(&config.ProjectConfig{Allowlist: []string{"not a domain"}}); err == nil {
+		t.Error("expected an error for an invalid domain")
+	}
+}
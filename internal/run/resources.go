@@ -0,0 +1,112 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package run
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+// sizePattern matches the sizes the runtimes accept, e.g. "512m" or "8g".
+var sizePattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// resolveResources works out a container's resource limits. From lowest
+// to highest precedence: DefaultResources, memory and cpus in
+// default_flags, default_flags.resources, the workspace's resources, the
+// project's .exitbox/config.yaml, the --resources preset and
+// --memory/--cpus. A layer's preset applies before the limits the layer
+// sets itself.
+func resolveResources(df config.DefaultFlags, workspace, project *config.Resources, opts Options) (config.Resources, error) {
+	r := config.DefaultResources.Merge(config.Resources{Memory: df.Memory, CPUs: df.CPUs})
+
+	layers := []config.Resources{df.Resources}
+	if workspace != nil {
+		layers = append(layers, *workspace)
+	}
+	if project != nil {
+		layers = append(layers, *project)
+	}
+	layers = append(layers,
+		config.Resources{Preset: opts.Resources},
+		config.Resources{Memory: opts.Memory, CPUs: opts.CPUs},
+	)
+	for _, layer := range layers {
+		if layer.Preset != "" {
+			preset, ok := df.Preset(layer.Preset)
+			if !ok {
+				return r, fmt.Errorf("unknown resource preset '%s'", layer.Preset)
+			}
+			r = r.Merge(preset)
+		}
+		r = r.Merge(layer)
+	}
+	return r, validateResources(r)
+}
+
+// validateResources checks limits before they reach the runtime, whose
+// errors would not say which setting is wrong.
+func validateResources(r config.Resources) error {
+	for _, s := range []struct{ name, value string }{
+		{"memory", r.Memory},
+		{"tmp_size", r.TmpSize},
+		{"disk_quota", r.DiskQuota},
+	} {
+		if s.value != "" && !sizePattern.MatchString(s.value) {
+			return fmt.Errorf("%s: invalid size '%s' (e.g. 512m, 8g)", s.name, s.value)
+		}
+	}
+	if r.MemorySwap != "" && r.MemorySwap != "-1" && !sizePattern.MatchString(r.MemorySwap) {
+		return fmt.Errorf("memory_swap: invalid size '%s' (e.g. 8g, or -1 for unlimited)", r.MemorySwap)
+	}
+	if cpus, err := strconv.ParseFloat(r.CPUs, 64); r.CPUs != "" && (err != nil || cpus <= 0) {
+		return fmt.Errorf("cpus: invalid count '%s'", r.CPUs)
+	}
+	if r.PidsLimit < 0 || r.NoFile < 0 {
+		return fmt.Errorf("pids_limit and nofile must not be negative")
+	}
+	return nil
+}
+
+// resourceArgs returns the container run flags for r.
+func resourceArgs(r config.Resources) []string {
+	var args []string
+	if r.Memory != "" {
+		args = append(args, "--memory="+r.Memory)
+	}
+	if r.MemorySwap != "" {
+		args = append(args, "--memory-swap="+r.MemorySwap)
+	}
+	if r.CPUs != "" {
+		args = append(args, "--cpus="+r.CPUs)
+	}
+	if r.PidsLimit > 0 {
+		args = append(args, fmt.Sprintf("--pids-limit=%d", r.PidsLimit))
+	}
+	if r.NoFile > 0 {
+		args = append(args, fmt.Sprintf("--ulimit=nofile=%d:%d", r.NoFile, r.NoFile))
+	}
+	if r.TmpSize != "" {
+		args = append(args, "--tmpfs", "/tmp:rw,exec,nosuid,nodev,mode=1777,size="+r.TmpSize)
+	}
+	if r.DiskQuota != "" {
+		args = append(args, "--storage-opt", "size="+r.DiskQuota)
+	}
+	return args
+}
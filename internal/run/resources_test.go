@@ -0,0 +1,80 @@
+package run
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestResolveResources(t *testing.T) {
+	df := config.DefaultFlags{
+		Memory:    "6g",
+		Resources: config.Resources{NoFile: 2048},
+		Presets: map[string]config.Resources{
+			"ci": {CPUs: "1", TmpSize: "256m", DiskQuota: "20g"},
+		},
+	}
+
+	r, err := resolveResources(df, nil, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != (config.Resources{Memory: "6g", CPUs: "4", PidsLimit: 4096, NoFile: 2048}) {
+		t.Errorf("defaults = %+v", r)
+	}
+
+	// The workspace's preset applies before its own limits, and the
+	// command line overrides both.
+	ws := &config.Resources{Preset: "small", PidsLimit: 512}
+	r, err = resolveResources(df, ws, nil, Options{Memory: "3g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Memory != "3g" || r.CPUs != "2" || r.PidsLimit != 512 || r.NoFile != 4096 || r.TmpSize != "512m" {
+		t.Errorf("workspace preset = %+v", r)
+	}
+
+	r, err = resolveResources(df, ws, nil, Options{Resources: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.CPUs != "1" || r.DiskQuota != "20g" || r.PidsLimit != 512 {
+		t.Errorf("--resources ci = %+v", r)
+	}
+
+	// The project's limits override the workspace's.
+	r, err = resolveResources(df, ws, &config.Resources{Preset: "ci", PidsLimit: 256}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.CPUs != "1" || r.PidsLimit != 256 || r.TmpSize != "256m" {
+		t.Errorf("project resources = %+v", r)
+	}
+
+	if _, err := resolveResources(df, nil, nil, Options{Resources: "huge"}); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+	if _, err := resolveResources(df, &config.Resources{TmpSize: "lots"}, nil, Options{}); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+	if _, err := resolveResources(df, nil, nil, Options{CPUs: "0"}); err == nil {
+		t.Error("expected an error for zero CPUs")
+	}
+}
+
+func TestResourceArgs(t *testing.T) {
+	got := resourceArgs(config.Resources{
+		Memory: "4g", CPUs: "2", MemorySwap: "4g", PidsLimit: 1024, NoFile: 4096,
+		TmpSize: "512m", DiskQuota: "10g",
+	})
+	want := []string{
+		"--memory=4g", "--memory-swap=4g", "--cpus=2", "--pids-limit=1024",
+		"--ulimit=nofile=4096:4096",
+		"--tmpfs", "/tmp:rw,exec,nosuid,nodev,mode=1777,size=512m",
+		"--storage-opt", "size=10g",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resourceArgs =\n  %v\nwant\n  %v", got, want)
+	}
+}
@@ -21,14 +21,18 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cloud-exit/exitbox/internal/config"
 	"github.com/cloud-exit/exitbox/internal/container"
 	"github.com/cloud-exit/exitbox/internal/ipc"
 	"github.com/cloud-exit/exitbox/internal/network"
+	"github.com/cloud-exit/exitbox/internal/overlay"
 	"github.com/cloud-exit/exitbox/internal/profile"
 	"github.com/cloud-exit/exitbox/internal/project"
+	"github.com/cloud-exit/exitbox/internal/session"
 	"github.com/cloud-exit/exitbox/internal/ui"
 	"golang.org/x/term"
 )
@@ -54,6 +58,7 @@ type Options struct {
 	EnvVars           []string
 	IncludeDirs       []string
 	AllowURLs         []string
+	Learn             bool
 	Passthrough       []string
 	Verbose           bool
 	StatusBar         bool
@@ -61,27 +66,86 @@ type Options struct {
 	Ollama            bool
 	Memory            string
 	CPUs              string
+	Resources         string // resource preset from --resources
+	Hardened          bool   // hardened mode, also on with settings.hardening.enabled
 	Keybindings       string
+	// Detach starts the container in the background and waits for it to
+	// exit; `exitbox attach` connects to its tmux session. LogFile is
+	// where the detached exitbox process writes its output.
+	Detach  bool
+	LogFile string
+	// Headless runs the agent without a terminal or tmux. Approval
+	// requests are answered by Policy; without one they are denied.
+	Headless bool
+	Policy   *ipc.Policy
+	// ContainerName names the container; a new name is generated when
+	// empty.
+	ContainerName string
+	// WorkspaceDir is mounted at /workspace in place of ProjectDir, e.g.
+	// a --worktree clone. ProjectDir still identifies the project.
+	WorkspaceDir string
+	// Overlay mounts ProjectDir copy-on-write, keeping the agent's
+	// writes in the overlay until `exitbox changes` applies them.
+	Overlay *overlay.Session
+}
+
+// userArgs returns the flags selecting the user the agent runs as, so
+// that files it writes to bind mounts are owned by the host user uid:gid.
+func userArgs(caps container.Capabilities, uid, gid int) []string {
+	user := fmt.Sprintf("%d:%d", uid, gid)
+	switch {
+	case caps.Rootless && caps.UserNS:
+		// Rootless podman maps the host user to the same IDs inside.
+		return []string{"--userns=keep-id", "--user", user}
+	case caps.Rootless:
+		// Rootless docker and nerdctl map the host user to the
+		// container's root. Any other user is a subordinate ID that
+		// cannot write the workspace.
+		return []string{"--user", "0:0"}
+	default:
+		// Rootful runtimes share the host's IDs.
+		return []string{"--user", user}
+	}
 }
 
 // AgentContainer runs an agent container interactively.
 func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 	cmd := container.Cmd(rt)
 	imageName := project.ImageName(opts.Agent, opts.ProjectDir, opts.WorkspaceHash)
-	containerName := project.ContainerName(opts.Agent, opts.ProjectDir)
+	containerName := opts.ContainerName
+	if containerName == "" {
+		containerName = project.ContainerName(opts.Agent, opts.ProjectDir)
+	}
 
 	var args []string
 
-	// Interactive mode
-	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+	// Interactive mode. A detached container still gets a terminal for
+	// its tmux session; a headless one never gets one.
+	if opts.Detach {
+		args = append(args, "-dit")
+	} else if !opts.Headless && term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
 		args = append(args, "-it")
 	}
 	args = append(args, "--rm", "--name", containerName, "--init")
 
-	// Podman-specific
-	if cmd == "podman" {
-		args = append(args, "--userns=keep-id", "--security-opt=no-new-privileges")
+	// Run as a user that owns the bind-mounted files on the host.
+	caps := rt.Capabilities()
+	args = append(args, userArgs(caps, os.Getuid(), os.Getgid())...)
+
+	// The project's .exitbox/config.yaml. All of it but its masks is used
+	// only once the user has approved it.
+	projectCfg, trusted, err := project.LoadConfig(opts.ProjectDir)
+	if err != nil {
+		return 1, err
 	}
+	if !trusted {
+		ui.Warnf("Skipping the settings in %s: they have not been approved. Run 'exitbox run' in a terminal to review them.", config.ProjectConfigFile(opts.ProjectDir))
+	}
+	domains, err := projectAllowlist(projectCfg)
+	if err != nil {
+		return 1, fmt.Errorf("%s: %w", config.ProjectConfigFile(opts.ProjectDir), err)
+	}
+	opts.AllowURLs = append(opts.AllowURLs, domains...)
 
 	// Ollama mode: route traffic through the firewall to host Ollama.
 	if opts.Ollama {
@@ -95,8 +159,20 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		// all container ports directly (e.g. Codex OAuth on 1455).
 		args = append(args, "--network", "host")
 	} else {
+		if !caps.InternalNetworks {
+			return 1, fmt.Errorf("%s cannot create internal networks, so the firewall cannot be enforced; upgrade it or run with --no-firewall", cmd)
+		}
 		network.EnsureNetworks(rt)
 		args = append(args, "--network", network.InternalNetwork)
+		unlock := promptUpstreamPassword
+		if opts.Headless {
+			unlock = func(workspace string, _ config.UpstreamProxy) (string, error) {
+				return headlessVaultPassword(workspace)
+			}
+		}
+		if err := network.UnlockUpstreamProxy(unlock); err != nil {
+			return 1, fmt.Errorf("failed to unlock upstream proxy credentials: %w", err)
+		}
 		if err := network.StartSquidProxy(rt, containerName, opts.AllowURLs); err != nil {
 			return 1, fmt.Errorf("failed to start firewall (Squid proxy): %w", err)
 		}
@@ -104,6 +180,12 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		args = append(args, proxyArgs...)
 	}
 
+	// A headless run without a policy denies every approval request.
+	policy := opts.Policy
+	if policy == nil {
+		policy = &ipc.Policy{}
+	}
+
 	// IPC server for runtime domain allow requests.
 	var ipcServer *ipc.Server
 	if !opts.NoFirewall {
@@ -112,10 +194,7 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		if ipcErr != nil {
 			ui.Warnf("Failed to start IPC server: %v", ipcErr)
 		} else {
-			ipcServer.Handle("allow_domain", ipc.NewAllowDomainHandler(ipc.AllowDomainHandlerConfig{
-				Runtime:       rt,
-				ContainerName: containerName,
-			}))
+			ipcServer.LogRejections(config.IPCRejectLog(), containerName)
 			ipcServer.Start()
 			defer ipcServer.Stop()
 		}
@@ -123,32 +202,39 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 
 	// Ensure squid cleanup runs on ALL return paths (including early errors).
 	defer func() {
-		if len(opts.AllowURLs) > 0 {
-			network.RemoveSessionURLs(rt, containerName)
+		if !opts.NoFirewall {
+			network.RemoveSession(rt, containerName)
 		}
 		network.CleanupSquidIfUnused(rt)
 	}()
 
-	// Resource limits
-	memory := "8g"
-	if opts.Memory != "" {
-		memory = opts.Memory
-	}
-	cpus := "4"
-	if opts.CPUs != "" {
-		cpus = opts.CPUs
-	}
-	args = append(args, "--memory="+memory, "--cpus="+cpus)
-
 	// Mount workspace
 	mountMode := ""
 	if opts.ReadOnly {
 		mountMode = ":ro"
 	}
-	args = append(args, "-w", "/workspace", "-v", opts.ProjectDir+":/workspace"+mountMode)
-
-	// Non-root
-	args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	workspaceDir := opts.ProjectDir
+	if opts.WorkspaceDir != "" {
+		workspaceDir = opts.WorkspaceDir
+	}
+	if opts.Overlay != nil {
+		if !caps.Overlay {
+			return 1, fmt.Errorf("%s cannot mount an overlay workspace; use podman or a rootful docker", cmd)
+		}
+		mount, err := opts.Overlay.MountArgs(rt.Name())
+		if err != nil {
+			return 1, err
+		}
+		args = append(args, "-w", "/workspace")
+		args = append(args, mount...)
+	} else {
+		args = append(args, "-w", "/workspace", "-v", workspaceDir+":/workspace"+mountMode)
+	}
+	if workspaceDir != opts.ProjectDir || opts.Overlay != nil {
+		// Session actions and workspace switches are written to the
+		// project's .exitbox directory.
+		args = append(args, "-v", filepath.Join(opts.ProjectDir, ".exitbox")+":/workspace/.exitbox")
+	}
 
 	// Include dirs
 	for _, dir := range opts.IncludeDirs {
@@ -162,6 +248,17 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		args = append(args, "-v", dir+":/workspace/"+rel)
 	}
 
+	// Mounts from the project's .exitbox/config.yaml, once approved, and
+	// the config itself read-only so agents cannot edit the next run's.
+	mounts, err := projectMountArgs(projectCfg.Mounts, opts.ProjectDir, defaultHostPathRules())
+	if err != nil {
+		return 1, fmt.Errorf("%s: %w", config.ProjectConfigFile(opts.ProjectDir), err)
+	}
+	args = append(args, mounts...)
+	if _, statErr := os.Stat(config.ProjectConfigFile(opts.ProjectDir)); statErr == nil {
+		args = append(args, "-v", config.ProjectConfigFile(opts.ProjectDir)+":/workspace/.exitbox/config.yaml:ro")
+	}
+
 	// Workspace resolution and isolated config mounts.
 	cfg := config.LoadOrDefault()
 	activeWorkspace, err := profile.ResolveActiveWorkspace(cfg, opts.ProjectDir, opts.WorkspaceOverride)
@@ -193,6 +290,71 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		)
 	}
 
+	workspaceName := ""
+	if activeWorkspace != nil {
+		workspaceName = activeWorkspace.Workspace.Name
+	}
+
+	// Resource limits
+	var workspaceResources *config.Resources
+	if activeWorkspace != nil {
+		workspaceResources = activeWorkspace.Workspace.Resources
+	}
+	resources, err := resolveResources(cfg.Settings.DefaultFlags, workspaceResources, projectCfg.Resources, opts)
+	if err != nil {
+		return 1, fmt.Errorf("invalid resource limits: %w", err)
+	}
+	args = append(args, resourceArgs(resources)...)
+
+	// Approval rules decide IPC requests before anyone is prompted.
+	var rules *ipc.Rules
+	if ipcServer != nil {
+		files := []string{ipc.ProjectRulesFile(opts.ProjectDir)}
+		if workspaceName != "" {
+			files = append(files, ipc.WorkspaceRulesFile(workspaceName))
+		}
+		if rules, err = ipc.LoadRules(files...); err != nil {
+			return 1, fmt.Errorf("invalid approval rules: %w", err)
+		}
+		rules.Agent = opts.Agent
+		rules.Container = containerName
+		rules.Project = opts.ProjectDir
+		rules.Session = opts.SessionName
+		rules.Workspace = workspaceName
+		rules.Audit = true
+		if opts.Headless {
+			rules.Prompter = "policy"
+		}
+		ipcServer.AuditRejections(rules)
+	}
+
+	// Project and workspace allowlists and egress limits apply to this
+	// container, and runtime domain approvals can be persisted to them. The
+	// proxy is reloaded so they are in place before the container starts.
+	if !opts.NoFirewall {
+		if err := network.RegisterSessionLimits(containerName, workspaceName); err != nil {
+			return 1, fmt.Errorf("invalid egress limits: %w", err)
+		}
+		if err := network.RegisterSessionScope(containerName, opts.ProjectDir, workspaceName); err != nil {
+			ui.Warnf("Failed to register session scope: %v", err)
+		} else if err := network.ReloadSquid(rt); err != nil {
+			ui.Warnf("Failed to apply session scope: %v", err)
+		}
+		if ipcServer != nil {
+			allowCfg := ipc.AllowDomainHandlerConfig{
+				Runtime:       rt,
+				ContainerName: containerName,
+				ProjectDir:    opts.ProjectDir,
+				WorkspaceName: workspaceName,
+				Rules:         rules,
+			}
+			if opts.Headless {
+				allowCfg.PromptFunc = policy.AllowDomain
+			}
+			ipcServer.Handle("allow_domain", ipc.NewAllowDomainHandler(allowCfg))
+		}
+	}
+
 	// Register vault IPC handlers when vault is enabled for the workspace.
 	var vaultState *ipc.VaultState
 	if activeWorkspace != nil && activeWorkspace.Workspace.Vault.Enabled && ipcServer != nil {
@@ -201,9 +363,39 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 			Runtime:       rt,
 			ContainerName: containerName,
 			WorkspaceName: activeWorkspace.Workspace.Name,
+			Rules:         rules,
+		}
+		listCfg := vCfg
+		if opts.Headless {
+			vCfg.PromptApproveFunc = policy.ApproveVaultKey
+			listCfg.PromptApproveFunc = policy.ApproveVaultList
+			vCfg.PromptPasswordFunc = func() (string, error) {
+				return headlessVaultPassword(activeWorkspace.Workspace.Name)
+			}
+			listCfg.PromptPasswordFunc = vCfg.PromptPasswordFunc
 		}
 		ipcServer.Handle("vault_get", ipc.NewVaultGetHandler(vCfg, vaultState))
-		ipcServer.Handle("vault_list", ipc.NewVaultListHandler(vCfg, vaultState))
+		ipcServer.Handle("vault_list", ipc.NewVaultListHandler(listCfg, vaultState))
+	}
+
+	// Git pushes and pull requests run on the host with the user's
+	// credentials. An overlay's commits are not visible to the host.
+	if ipcServer != nil {
+		gitCfg := ipc.GitHandlerConfig{
+			Runtime:       rt,
+			ContainerName: containerName,
+			RepoDir:       workspaceDir,
+			Rules:         rules,
+		}
+		if opts.Overlay != nil {
+			gitCfg.Disabled = "git requests are not available in --overlay sessions; apply the changes with 'exitbox changes' first"
+		}
+		if opts.Headless {
+			// Only approval rules can allow git requests in a headless run.
+			gitCfg.PromptFunc = func(string, []string) (bool, error) { return false, nil }
+		}
+		ipcServer.Handle("git_push", ipc.NewGitPushHandler(gitCfg))
+		ipcServer.Handle("open_pr", ipc.NewOpenPRHandler(gitCfg))
 	}
 	defer func() {
 		if vaultState != nil {
@@ -216,7 +408,7 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		args = append(args, "-e", "EXITBOX_VAULT_ENABLED=true")
 
 		// Mask all .env* files (except sample/example files) by mounting /dev/null over them.
-		matches, _ := filepath.Glob(filepath.Join(opts.ProjectDir, ".env*"))
+		matches, _ := filepath.Glob(filepath.Join(workspaceDir, ".env*"))
 		for _, f := range matches {
 			info, statErr := os.Stat(f)
 			if statErr != nil || info.IsDir() {
@@ -226,7 +418,7 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 			if isEnvSampleFile(base) {
 				continue
 			}
-			rel, relErr := filepath.Rel(opts.ProjectDir, f)
+			rel, relErr := filepath.Rel(workspaceDir, f)
 			if relErr != nil {
 				continue
 			}
@@ -234,6 +426,21 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		}
 	}
 
+	// Masks from the project config, skipping files already masked above.
+	masks, err := maskArgs(workspaceDir, projectCfg.Masks)
+	if err != nil {
+		return 1, fmt.Errorf("%s: %w", config.ProjectConfigFile(opts.ProjectDir), err)
+	}
+	mounted := make(map[string]bool)
+	for _, a := range args {
+		mounted[a] = true
+	}
+	for i := 0; i+1 < len(masks); i += 2 {
+		if !mounted[masks[i+1]] {
+			args = append(args, masks[i], masks[i+1])
+		}
+	}
+
 	// Environment variables
 	projectName := filepath.Base(opts.ProjectDir)
 	projectKey := project.GenerateFolderName(opts.ProjectDir)
@@ -282,6 +489,13 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		"--security-opt=no-new-privileges:true",
 		"--cap-drop=ALL",
 	)
+	if opts.Hardened || cfg.Settings.Hardening.Enabled {
+		hardenArgs, hardenErr := hardeningArgs(cfg.Settings.Hardening, caps, resources.TmpSize != "")
+		if hardenErr != nil {
+			return 1, fmt.Errorf("hardened mode: %w", hardenErr)
+		}
+		args = append(args, hardenArgs...)
+	}
 
 	// IPC socket mount
 	if ipcServer != nil {
@@ -299,26 +513,95 @@ func AgentContainer(rt container.Runtime, opts Options) (int, error) {
 		ui.Debugf("Container run: %s run %s", cmd, strings.Join(args, " "))
 	}
 
-	// Run with inherited stdio
-	c := exec.Command(cmd, append([]string{"run"}, args...)...)
-	c.Stdin = os.Stdin
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
+	// Per-container firewall rules are keyed on the container's source
+	// address, which is only known once the container is running.
+	exited := make(chan struct{})
+	if !opts.NoFirewall {
+		go network.WatchSessionIP(rt, containerName, exited)
+		go network.WatchSessionQuota(rt, containerName, exited)
+	}
 
-	err = c.Run()
+	record := session.Running{
+		Container: containerName,
+		Agent:     opts.Agent,
+		Project:   opts.ProjectDir,
+		Workspace: workspaceName,
+		Session:   opts.SessionName,
+		Started:   time.Now(),
+		Detached:  opts.Detach,
+		Headless:  opts.Headless,
+		PID:       os.Getpid(),
+		Log:       opts.LogFile,
+	}
+	defer session.RemoveRunning(containerName)
 
-	exitCode := 0
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		} else {
-			exitCode = 1
+	started := time.Now()
+	var exitCode int
+	if opts.Detach {
+		exitCode, err = runDetached(cmd, args, record)
+	} else {
+		if recErr := session.RecordRunning(record); recErr != nil {
+			ui.Warnf("Failed to record running container: %v", recErr)
 		}
+		// Run with inherited stdio
+		c := exec.Command(cmd, append([]string{"run"}, args...)...)
+		c.Stdin = os.Stdin
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		if runErr := c.Run(); runErr != nil {
+			if exitErr, ok := runErr.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			} else {
+				exitCode = 1
+			}
+		}
+	}
+	close(exited)
+	if err != nil {
+		return exitCode, err
+	}
+
+	if err := rules.AuditError(); err != nil {
+		ui.Warnf("Some approval requests are missing from the audit log: %v", err)
+	}
+	if ipcServer != nil && ipcServer.Rejected() > 0 {
+		ui.Warnf("Refused %d IPC request(s) without the session token; something in the container tried to reach the host. See %s.", ipcServer.Rejected(), config.IPCRejectLog())
+	}
+
+	if opts.Learn && !opts.NoFirewall && !opts.Headless {
+		reviewDeniedDomains(containerName, opts.ProjectDir, workspaceName, started)
 	}
 
 	return exitCode, nil
 }
 
+// runDetached starts the container in the background, records it once it
+// runs and waits for it to exit, returning its exit code.
+func runDetached(cmd string, args []string, record session.Running) (int, error) {
+	out, err := exec.Command(cmd, append([]string{"run"}, args...)...).CombinedOutput()
+	if err != nil {
+		return 1, fmt.Errorf("failed to start container: %s", strings.TrimSpace(string(out)))
+	}
+	if err := session.RecordRunning(record); err != nil {
+		ui.Warnf("Failed to record running container: %v", err)
+	}
+
+	out, err = exec.Command(cmd, "wait", record.Container).Output()
+	if err != nil {
+		// Including when --rm removed a container that exited right
+		// away: its exit code is lost.
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return 1, fmt.Errorf("failed to wait for container %s: %w", record.Container, err)
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 1, fmt.Errorf("failed to read the exit code of container %s: %w", record.Container, err)
+	}
+	return code, nil
+}
+
 func expandPath(dir, projectDir string) string {
 	if strings.HasPrefix(dir, "~/") {
 		dir = filepath.Join(os.Getenv("HOME"), dir[2:])
@@ -388,3 +671,42 @@ func isReservedEnvVar(key string) bool {
 	}
 	return reserved[key]
 }
+
+// UnlockUpstreamProxy unlocks the upstream proxy credentials from the
+// terminal, ahead of a detached run that has none.
+func UnlockUpstreamProxy() error {
+	return network.UnlockUpstreamProxy(promptUpstreamPassword)
+}
+
+// vaultPasswordEnv holds the vault password for headless runs, which have
+// no terminal to ask for it on.
+const vaultPasswordEnv = "EXITBOX_VAULT_PASSWORD"
+
+// headlessVaultPassword returns the vault password from vaultPasswordEnv.
+func headlessVaultPassword(workspace string) (string, error) {
+	if pw := os.Getenv(vaultPasswordEnv); pw != "" {
+		return pw, nil
+	}
+	return "", fmt.Errorf("vault for workspace '%s' is locked; set %s to unlock it in a headless run", workspace, vaultPasswordEnv)
+}
+
+// promptUpstreamPassword asks on the terminal for the vault password that
+// unlocks the upstream proxy credentials, naming the proxy they are sent
+// to and the vault entries read.
+func promptUpstreamPassword(workspace string, up config.UpstreamProxy) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("vault for workspace '%s' is locked and no terminal is available to unlock it", workspace)
+	}
+	fmt.Fprintf(os.Stderr, "The upstream proxy %s logs in with %s and %s from the vault.\n",
+		up.URL, up.Credentials.UsernameKey, up.Credentials.PasswordKey)
+	if !strings.HasPrefix(strings.ToLower(up.URL), "https://") {
+		fmt.Fprintln(os.Stderr, "The proxy URL is not https://, so they are sent to it unencrypted.")
+	}
+	fmt.Fprintf(os.Stderr, "Vault password for workspace '%s' (upstream proxy): ", workspace)
+	pw, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(pw), nil
+}
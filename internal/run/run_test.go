@@ -3,8 +3,11 @@ package run
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
+
+	"github.com/cloud-exit/exitbox/internal/container"
 )
 
 func TestExpandPath_Absolute(t *testing.T) {
@@ -119,3 +122,21 @@ func TestIsReservedEnvVar_NotReserved(t *testing.T) {
 		}
 	}
 }
+
+func TestUserArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		caps container.Capabilities
+		want []string
+	}{
+		{"rootless podman", container.Capabilities{Rootless: true, UserNS: true}, []string{"--userns=keep-id", "--user", "1000:1000"}},
+		{"rootful podman", container.Capabilities{UserNS: true}, []string{"--user", "1000:1000"}},
+		{"rootless docker", container.Capabilities{Rootless: true}, []string{"--user", "0:0"}},
+		{"rootful docker", container.Capabilities{}, []string{"--user", "1000:1000"}},
+	}
+	for _, tt := range tests {
+		if got := userArgs(tt.caps, 1000, 1000); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: userArgs = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Running records an agent container while it runs, so it can be found
+// again by `exitbox ps` and `exitbox attach`.
+type Running struct {
+	Container string    `yaml:"container"`
+	Agent     string    `yaml:"agent"`
+	Project   string    `yaml:"project"`
+	Workspace string    `yaml:"workspace,omitempty"`
+	Session   string    `yaml:"session,omitempty"`
+	Started   time.Time `yaml:"started"`
+	Detached  bool      `yaml:"detached,omitempty"`
+	Headless  bool      `yaml:"headless,omitempty"` // no terminal or tmux to attach to
+	PID       int       `yaml:"pid"`                // the exitbox process serving the container
+	Log       string    `yaml:"log,omitempty"`      // output of a detached exitbox process
+}
+
+// TmuxSession returns the name of the tmux session the agent runs in.
+func (r Running) TmuxSession() string {
+	return "exitbox-" + r.Agent
+}
+
+// RunningDir returns the directory holding one record per running container.
+func RunningDir() string {
+	return filepath.Join(config.Cache, "containers")
+}
+
+// RecordRunning writes the record for a running container.
+func RecordRunning(r Running) error {
+	if err := os.MkdirAll(RunningDir(), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(RunningDir(), r.Container+".yaml"), data, 0644)
+}
+
+// RemoveRunning removes the record of a container that has exited.
+func RemoveRunning(container string) {
+	_ = os.Remove(filepath.Join(RunningDir(), container+".yaml"))
+}
+
+// ListRunning returns the recorded containers, oldest first. Records are
+// not checked against the runtime; callers drop those no longer running.
+func ListRunning() ([]Running, error) {
+	entries, err := os.ReadDir(RunningDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read running dir: %w", err)
+	}
+	var out []Running
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		data, readErr := os.ReadFile(filepath.Join(RunningDir(), e.Name()))
+		if readErr != nil {
+			continue
+		}
+		var r Running
+		if yaml.Unmarshal(data, &r) != nil || r.Container == "" {
+			continue
+		}
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out, nil
+}
+
+// FindRunning picks a container by session name, container name or a
+// unique container name prefix. An empty selector matches the only
+// running container.
+func FindRunning(list []Running, selector string) (Running, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		switch len(list) {
+		case 0:
+			return Running{}, fmt.Errorf("no agent containers are running")
+		case 1:
+			return list[0], nil
+		}
+		return Running{}, fmt.Errorf("%d agent containers are running; name a session or container", len(list))
+	}
+
+	var matches []Running
+	for _, r := range list {
+		if r.Session == selector || r.Container == selector {
+			return r, nil
+		}
+		if strings.HasPrefix(r.Container, selector) {
+			matches = append(matches, r)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Running{}, fmt.Errorf("no running session or container '%s'", selector)
+	case 1:
+		return matches[0], nil
+	}
+	return Running{}, fmt.Errorf("'%s' matches %d containers", selector, len(matches))
+}
@@ -0,0 +1,59 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestRunningRecords(t *testing.T) {
+	oldCache := config.Cache
+	config.Cache = t.TempDir()
+	t.Cleanup(func() { config.Cache = oldCache })
+
+	now := time.Now()
+	for _, r := range []Running{
+		{Container: "exitbox-codex-app_1234abcd-beef0002", Agent: "codex", Session: "review", Started: now},
+		{Container: "exitbox-claude-app_1234abcd-beef0001", Agent: "claude", Session: "feature-x", Started: now.Add(-time.Hour), Detached: true},
+	} {
+		if err := RecordRunning(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := ListRunning()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListRunning = %v, %v", list, err)
+	}
+	if list[0].Session != "feature-x" || !list[0].Detached || list[0].TmuxSession() != "exitbox-claude" {
+		t.Errorf("oldest record = %+v", list[0])
+	}
+
+	if r, err := FindRunning(list, "review"); err != nil || r.Agent != "codex" {
+		t.Errorf("by session = %+v, %v", r, err)
+	}
+	if r, err := FindRunning(list, "exitbox-claude"); err != nil || r.Session != "feature-x" {
+		t.Errorf("by prefix = %+v, %v", r, err)
+	}
+	if _, err := FindRunning(list, "exitbox-"); err == nil {
+		t.Error("ambiguous prefix accepted")
+	}
+	if _, err := FindRunning(list, ""); err == nil {
+		t.Error("empty selector should need a single container")
+	}
+
+	RemoveRunning("exitbox-codex-app_1234abcd-beef0002")
+	list, _ = ListRunning()
+	if r, err := FindRunning(list, ""); err != nil || r.Agent != "claude" {
+		t.Errorf("only container = %+v, %v", r, err)
+	}
+}
@@ -56,6 +56,33 @@ func categoriesToAllowlist(cats []domainCategory) *config.Allowlist {
 	return al
 }
 
+// workspaceAllowlistToCategories converts a workspace allowlist (which may
+// be nil) into the 5 editable categories.
+func workspaceAllowlistToCategories(w *config.WorkspaceAllowlist) []domainCategory {
+	if w == nil {
+		return allowlistToCategories(&config.Allowlist{})
+	}
+	return allowlistToCategories(w.Categories())
+}
+
+// categoriesToWorkspaceAllowlist converts editable categories back to a
+// workspace allowlist, or nil when it would have no effect.
+func categoriesToWorkspaceAllowlist(cats []domainCategory, replace bool) *config.WorkspaceAllowlist {
+	al := categoriesToAllowlist(cats)
+	w := &config.WorkspaceAllowlist{
+		Replace:        replace,
+		AIProviders:    al.AIProviders,
+		Development:    al.Development,
+		CloudServices:  al.CloudServices,
+		CommonServices: al.CommonServices,
+		Custom:         al.Custom,
+	}
+	if w.IsEmpty() {
+		return nil
+	}
+	return w
+}
+
 // countDomains returns the total number of domains across all categories.
 func countDomains(cats []domainCategory) int {
 	n := 0
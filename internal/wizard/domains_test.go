@@ -0,0 +1,69 @@
+// ExitBox - Multi-Agent Container Sandbox
+// Copyright (C) 2026 Cloud Exit B.V.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package wizard
+
+import (
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/cloud-exit/exitbox/internal/config"
+)
+
+func TestWorkspaceAllowlistCategoriesRoundtrip(t *testing.T) {
+	if w := categoriesToWorkspaceAllowlist(workspaceAllowlistToCategories(nil), false); w != nil {
+		t.Errorf("empty workspace allowlist should convert to nil, got %+v", w)
+	}
+
+	in := &config.WorkspaceAllowlist{Replace: true, Development: []string{"github.com"}, Custom: []string{"client-a.example"}}
+	out := categoriesToWorkspaceAllowlist(workspaceAllowlistToCategories(in), in.Replace)
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("roundtrip = %+v, want %+v", out, in)
+	}
+
+	// Replacing with no domains blocks everything, which is kept.
+	if w := categoriesToWorkspaceAllowlist(workspaceAllowlistToCategories(nil), true); w == nil || !w.Replace {
+		t.Errorf("replace-only workspace allowlist should be kept, got %+v", w)
+	}
+}
+
+func TestUpdateDomains_EditsWorkspaceAllowlist(t *testing.T) {
+	m := NewModel()
+	m.step = stepDomains
+	global := countDomains(m.domainCategories)
+
+	press := func(keys ...string) {
+		for _, k := range keys {
+			next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+			m = next.(Model)
+		}
+	}
+	press("w", "r", "a")
+	for _, r := range "client-a.example" {
+		press(string(r))
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(Model)
+
+	if countDomains(m.domainCategories) != global {
+		t.Error("editing the workspace allowlist must not change the global one")
+	}
+	w := categoriesToWorkspaceAllowlist(m.wsDomains, m.wsReplace)
+	if w == nil || !w.Replace || !reflect.DeepEqual(w.AIProviders, []string{"client-a.example"}) {
+		t.Errorf("workspace allowlist = %+v", w)
+	}
+}
@@ -66,6 +66,8 @@ type State struct {
 	ReadOnly            bool
 	OriginalDevelopment []string          // non-nil when editing an existing workspace
 	DomainCategories    []domainCategory  // editable allowlist categories
+	WorkspaceDomains    []domainCategory  // editable workspace allowlist categories
+	WorkspaceReplace    bool              // workspace allowlist replaces the global one
 	CopyFrom            string            // workspace to copy credentials from (empty = none)
 	VaultEnabled        bool              // enable encrypted vault for secrets
 	VaultPassword       string            // vault encryption password (set during wizard init)
@@ -109,6 +111,9 @@ type Model struct {
 	domainItemCursor int              // highlighted domain within category
 	domainInputMode  bool             // typing new domain
 	domainInput      string           // current input text
+	wsDomains        []domainCategory // workspace allowlist categories
+	wsReplace        bool             // workspace allowlist replaces the global one
+	domainWorkspace  bool             // editing the workspace allowlist
 
 	// Top menu step (re-run only)
 	topMenuCursor int // 0=workspace management, 1=general settings
@@ -211,6 +216,7 @@ func NewModel() Model {
 		visitedSteps:     make(map[Step]bool),
 		isFirstRun:       true,
 		domainCategories: allowlistToCategories(config.DefaultAllowlist()),
+		wsDomains:        workspaceAllowlistToCategories(nil),
 		topMenuChoice:    -1,
 		keybindings: map[string]string{
 			"workspace_menu": kb.WorkspaceMenu,
@@ -312,6 +318,10 @@ func NewModelFromConfig(cfg *config.Config) Model {
 	// Fall back to global Tools.User for configs that predate per-workspace packages.
 	pkgSelected := make(map[string]bool)
 	ws := findWorkspace(cfg.Workspaces.Items, activeWorkspaceNameOrDefault(activeWorkspaceName))
+	var wsAllowlist *config.WorkspaceAllowlist
+	if ws != nil {
+		wsAllowlist = ws.Allowlist
+	}
 	if ws != nil && len(ws.Packages) > 0 {
 		for _, p := range ws.Packages {
 			pkgSelected[p] = true
@@ -352,6 +362,8 @@ func NewModelFromConfig(cfg *config.Config) Model {
 		visitedSteps:     visited,
 		isFirstRun:       false,
 		domainCategories: allowlistToCategories(config.LoadAllowlistOrDefault()),
+		wsDomains:        workspaceAllowlistToCategories(wsAllowlist),
+		wsReplace:        wsAllowlist != nil && wsAllowlist.Replace,
 		topMenuChoice:    -1,
 		keybindings: map[string]string{
 			"workspace_menu": kb.WorkspaceMenu,
@@ -716,12 +728,17 @@ func (m Model) updateWorkspaceSelect(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// Only check "make default" if this workspace is already the default.
 				m.checked["setting:make_default"] = ws.Name == m.defaultWorkspace
+
+				m.wsDomains = workspaceAllowlistToCategories(ws.Allowlist)
+				m.wsReplace = ws.Allowlist != nil && ws.Allowlist.Replace
 			} else {
 				// "Create new workspace" — start with a clean slate
 				m.workspaceInput = ""
 				m.state.WorkspaceName = ""
 				m.editingExisting = false
 				m.state.OriginalDevelopment = nil
+				m.wsDomains = workspaceAllowlistToCategories(nil)
+				m.wsReplace = false
 
 				// Clear all selections
 				for _, role := range Roles {
@@ -2393,6 +2410,13 @@ func (m Model) viewReview() string {
 		}
 		b.WriteString(fmt.Sprintf("  Allowlist:  %s\n", selectedStyle.Render(domainStr)))
 	}
+	if ws := categoriesToWorkspaceAllowlist(m.state.WorkspaceDomains, m.state.WorkspaceReplace); ws != nil {
+		domainStr := fmt.Sprintf("%d workspace domains", len(ws.AllDomains()))
+		if ws.Replace {
+			domainStr += " (replaces global)"
+		}
+		b.WriteString(fmt.Sprintf("  Workspace:  %s\n", selectedStyle.Render(domainStr)))
+	}
 
 	// Keybindings
 	if len(m.state.Keybindings) > 0 {
@@ -2665,6 +2689,8 @@ func (m Model) collectAllStepState() Model {
 	m.state.ReadOnly = m.checked["setting:read_only"]
 	m.state.MakeDefault = m.checked["setting:make_default"]
 	m.state.DomainCategories = m.domainCategories
+	m.state.WorkspaceDomains = m.wsDomains
+	m.state.WorkspaceReplace = m.wsReplace
 	m.state.Keybindings = copyMap(m.keybindings)
 	return m
 }
@@ -2732,6 +2758,8 @@ func (m Model) collectCurrentStepState() Model {
 		m.state.Keybindings = copyMap(m.keybindings)
 	case stepDomains:
 		m.state.DomainCategories = m.domainCategories
+		m.state.WorkspaceDomains = m.wsDomains
+		m.state.WorkspaceReplace = m.wsReplace
 	}
 	return m
 }
@@ -2748,7 +2776,7 @@ func (m Model) updateDomains(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.updateDomainInput(key)
 	}
 
-	cats := m.domainCategories
+	cats := m.editedDomains()
 	catCount := len(cats)
 	if catCount == 0 {
 		return m, nil
@@ -2784,13 +2812,23 @@ func (m Model) updateDomains(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case "d":
 		if domCount > 0 && m.domainItemCursor < domCount {
 			doms := cats[m.domainCatCursor].Domains
-			m.domainCategories[m.domainCatCursor].Domains = append(doms[:m.domainItemCursor], doms[m.domainItemCursor+1:]...)
-			if m.domainItemCursor >= len(m.domainCategories[m.domainCatCursor].Domains) && m.domainItemCursor > 0 {
+			cats[m.domainCatCursor].Domains = append(doms[:m.domainItemCursor], doms[m.domainItemCursor+1:]...)
+			if m.domainItemCursor >= len(cats[m.domainCatCursor].Domains) && m.domainItemCursor > 0 {
 				m.domainItemCursor--
 			}
 		}
+	case "w":
+		// Switch between the global and the workspace allowlist.
+		m.domainWorkspace = !m.domainWorkspace
+		m.domainItemCursor = 0
+	case "r":
+		if m.domainWorkspace {
+			m.wsReplace = !m.wsReplace
+		}
 	case "enter":
 		m.state.DomainCategories = m.domainCategories
+		m.state.WorkspaceDomains = m.wsDomains
+		m.state.WorkspaceReplace = m.wsReplace
 		m.visitedSteps[stepDomains] = true
 		m.step = stepVault
 		m.cursor = 0
@@ -2807,22 +2845,32 @@ func (m Model) updateDomains(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// editedDomains returns the categories the domains step is editing: the
+// global allowlist or the selected workspace's own.
+func (m Model) editedDomains() []domainCategory {
+	if m.domainWorkspace {
+		return m.wsDomains
+	}
+	return m.domainCategories
+}
+
 func (m Model) updateDomainInput(key tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch key.String() {
 	case "enter":
 		domain := strings.TrimSpace(m.domainInput)
-		if domain != "" {
+		cats := m.editedDomains()
+		if domain != "" && m.domainCatCursor < len(cats) {
 			// Dedup check
 			dup := false
-			for _, d := range m.domainCategories[m.domainCatCursor].Domains {
+			for _, d := range cats[m.domainCatCursor].Domains {
 				if d == domain {
 					dup = true
 					break
 				}
 			}
 			if !dup {
-				m.domainCategories[m.domainCatCursor].Domains = append(
-					m.domainCategories[m.domainCatCursor].Domains, domain)
+				cats[m.domainCatCursor].Domains = append(
+					cats[m.domainCatCursor].Domains, domain)
 			}
 		}
 		m.domainInputMode = false
@@ -2852,24 +2900,37 @@ func (m Model) viewDomains() string {
 	b.WriteString(m.stepTitle(8, "Network allowlist"))
 	b.WriteString("\n\n")
 
+	// Which allowlist is being edited
+	wsName := activeWorkspaceNameOrDefault(m.workspaceInput)
+	if m.domainWorkspace {
+		mode := "extends the global allowlist"
+		if m.wsReplace {
+			mode = "replaces the global allowlist"
+		}
+		b.WriteString("  " + selectedStyle.Render("Workspace "+wsName) + dimStyle.Render(" ("+mode+")") + "\n\n")
+	} else {
+		b.WriteString("  " + selectedStyle.Render("Global") + dimStyle.Render(" (all workspaces)") + "\n\n")
+	}
+	cats := m.editedDomains()
+
 	// Category tabs
 	b.WriteString("  ")
-	for i, cat := range m.domainCategories {
+	for i, cat := range cats {
 		label := cat.Name
 		if i == m.domainCatCursor {
 			b.WriteString(selectedStyle.Render("[" + label + "]"))
 		} else {
 			b.WriteString(dimStyle.Render(" " + label + " "))
 		}
-		if i < len(m.domainCategories)-1 {
+		if i < len(cats)-1 {
 			b.WriteString("  ")
 		}
 	}
 	b.WriteString("\n\n")
 
 	// Domains in selected category
-	if m.domainCatCursor < len(m.domainCategories) {
-		domains := m.domainCategories[m.domainCatCursor].Domains
+	if m.domainCatCursor < len(cats) {
+		domains := cats[m.domainCatCursor].Domains
 		if len(domains) == 0 {
 			b.WriteString(dimStyle.Render("    (no domains)\n"))
 		}
@@ -2889,6 +2950,10 @@ func (m Model) viewDomains() string {
 		b.WriteString(dimStyle.Render("\n  Press 'a' to add a domain\n"))
 	}
 
-	b.WriteString(helpStyle.Render("\nLeft/Right: category, a: add, d: delete, Enter: confirm, Esc: back" + m.tabHint()))
+	help := "\nLeft/Right: category, a: add, d: delete, w: global/workspace"
+	if m.domainWorkspace {
+		help += ", r: extend/replace"
+	}
+	b.WriteString(helpStyle.Render(help + ", Enter: confirm, Esc: back" + m.tabHint()))
 	return b.String()
 }
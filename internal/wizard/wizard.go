@@ -114,6 +114,7 @@ func RunWorkspaceCreation(existingCfg *config.Config, workspaceName string) (*Wo
 			Development: ComputeProfiles(wm.Result().Roles, wm.Result().Languages),
 			Packages:    wm.Result().CustomPackages,
 			Vault:       config.VaultConfig{Enabled: wm.Result().VaultEnabled},
+			Allowlist:   categoriesToWorkspaceAllowlist(wm.Result().WorkspaceDomains, wm.Result().WorkspaceReplace),
 		},
 		MakeDefault:   wm.Result().MakeDefault,
 		CopyFrom:      wm.Result().CopyFrom,
@@ -143,12 +144,12 @@ func applyResult(state State, existingCfg *config.Config) error {
 	cfg.ToolCategories = state.ToolCategories
 	cfg.Settings.AutoUpdate = state.AutoUpdate
 	cfg.Settings.StatusBar = state.StatusBar
-	cfg.Settings.DefaultFlags = config.DefaultFlags{
-		NoFirewall: !state.EnableFirewall,
-		AutoResume: state.AutoResume,
-		NoEnv:      !state.PassEnv,
-		ReadOnly:   state.ReadOnly,
-	}
+	// The wizard edits these flags; limits and presets set in config.yaml
+	// are kept.
+	cfg.Settings.DefaultFlags.NoFirewall = !state.EnableFirewall
+	cfg.Settings.DefaultFlags.AutoResume = state.AutoResume
+	cfg.Settings.DefaultFlags.NoEnv = !state.PassEnv
+	cfg.Settings.DefaultFlags.ReadOnly = state.ReadOnly
 
 	// Persist keybindings — only store non-default values (omitempty in YAML).
 	if len(state.Keybindings) > 0 {
@@ -162,12 +163,20 @@ func applyResult(state State, existingCfg *config.Config) error {
 		}
 		cfg.Settings.Keybindings = kb
 	}
+	// The workspace allowlist is kept unless the domains step edited it.
+	var wsAllowlist *config.WorkspaceAllowlist
+	if state.WorkspaceDomains != nil {
+		wsAllowlist = categoriesToWorkspaceAllowlist(state.WorkspaceDomains, state.WorkspaceReplace)
+	} else if existing := findWorkspace(cfg.Workspaces.Items, workspaceName); existing != nil {
+		wsAllowlist = existing.Allowlist
+	}
 	cfg.Workspaces.Active = workspaceName
 	cfg.Workspaces.Items = upsertWorkspace(cfg.Workspaces.Items, config.Workspace{
 		Name:        workspaceName,
 		Development: development,
 		Packages:    state.CustomPackages,
 		Vault:       config.VaultConfig{Enabled: state.VaultEnabled},
+		Allowlist:   wsAllowlist,
 	})
 	cfg.Settings.DefaultWorkspace = state.DefaultWorkspace
 
@@ -192,6 +201,10 @@ func applyResult(state State, existingCfg *config.Config) error {
 	var al *config.Allowlist
 	if len(state.DomainCategories) > 0 {
 		al = categoriesToAllowlist(state.DomainCategories)
+		// HTTP rules are not edited by the wizard; keep the existing ones.
+		if existing, err := config.LoadAllowlist(); err == nil {
+			al.HTTPRules = existing.HTTPRules
+		}
 	} else {
 		al = config.LoadAllowlistOrDefault()
 	}
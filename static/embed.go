@@ -46,8 +46,23 @@ var ExitboxVaultAmd64 []byte
 //go:embed build/exitbox-vault-arm64
 var ExitboxVaultArm64 []byte
 
+//go:embed build/exitbox-git-amd64
+var ExitboxGitAmd64 []byte
+
+//go:embed build/exitbox-git-arm64
+var ExitboxGitArm64 []byte
+
+//go:embed build/exitbox-dns-amd64
+var ExitboxDNSAmd64 []byte
+
+//go:embed build/exitbox-dns-arm64
+var ExitboxDNSArm64 []byte
+
 //go:embed config/allowlist.txt
 var DefaultAllowlistTxt []byte
 
 //go:embed config/allowlist.yaml
 var DefaultAllowlistYAML []byte
+
+//go:embed config/seccomp.json
+var SeccompProfile []byte